@@ -0,0 +1,509 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+	commonfile "github.com/elastic/beats/v7/libbeat/common/file"
+)
+
+// Archive format constants
+const (
+	// ArchiveTar is the format of tar archives, optionally compressed with
+	// any of the supported compression formats.
+	ArchiveTar = "tar"
+	// ArchiveZip is the format of zip archives.
+	ArchiveZip = "zip"
+)
+
+const (
+	// tarBlockSize is the size of a tar header block.
+	tarBlockSize = 512
+	// tarMagicOffset is the offset of the magic in a ustar (POSIX and GNU)
+	// tar header.
+	tarMagicOffset = 257
+	tarMagic       = "ustar"
+
+	zipMagicHeader      = "PK\x03\x04" // local file header signature
+	zipEmptyMagicHeader = "PK\x05\x06" // end of central directory signature
+)
+
+// archiveConfig configures the harvesting of the files stored in archives.
+type archiveConfig struct {
+	// Enabled makes the files stored in tar and zip archives to be
+	// harvested instead of the archives themselves.
+	Enabled bool `config:"enabled"`
+	// Members is a list of glob patterns selecting the archive members to
+	// harvest. A pattern containing a slash is matched against the path of
+	// the member within the archive, the others against its base name.
+	// All members are harvested if it's empty.
+	Members []string `config:"members"`
+}
+
+func (c *archiveConfig) Validate() error {
+	for _, pattern := range c.Members {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid archive member pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// selects reports whether the archive member name is selected by the
+// member patterns.
+func (c *archiveConfig) selects(name string) bool {
+	if len(c.Members) == 0 {
+		return true
+	}
+
+	name = cleanMemberName(name)
+	for _, pattern := range c.Members {
+		target := path.Base(name)
+		if strings.Contains(pattern, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanMemberName returns the member name as a clean relative path, without
+// any leading slash or ".." element.
+func cleanMemberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// archiveMemberPath returns the virtual path of an archive member: the
+// path of the archive followed by the path of the member in the archive.
+func archiveMemberPath(archive, name string) string {
+	return filepath.Join(archive, filepath.FromSlash(cleanMemberName(name)))
+}
+
+// archiveMemberFingerprint returns the fingerprint of an archive member: the
+// fingerprint of the archive and the SHA-256 of the member name.
+func archiveMemberFingerprint(archiveKey, name string) loginp.FingerprintID {
+	sum := sha256.Sum256([]byte(name))
+	return loginp.FingerprintID{Sum: archiveKey + "-" + hex.EncodeToString(sum[:])}
+}
+
+// archiveEntry is a regular, non-empty file stored in an archive.
+type archiveEntry struct {
+	name string
+	size int64
+}
+
+// archiveListing is the result of inspecting a file for archive members,
+// cached by the scanner while the file doesn't change.
+type archiveListing struct {
+	size    int64
+	modTime time.Time
+	// format is the archive format, empty if the file isn't an archive.
+	format  string
+	entries []archiveEntry
+}
+
+// detectArchive returns the archive format of f, or an empty string if f
+// isn't a supported archive. Tar archives are detected on the data
+// decompressed with compression, zip archives are never compressed.
+func detectArchive(f *os.File, compression string) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	var r io.Reader = f
+	if compression != CompressionNone {
+		dec, err := newDecompressor(compression, f)
+		if err != nil {
+			return "", fmt.Errorf("could not create %s reader: %w", compression, err)
+		}
+		defer dec.Close()
+		r = dec
+	}
+
+	header := make([]byte, tarBlockSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("failed to read archive header: %w", err)
+	}
+	header = header[:n]
+
+	switch {
+	case compression == CompressionNone &&
+		(bytes.HasPrefix(header, []byte(zipMagicHeader)) || bytes.HasPrefix(header, []byte(zipEmptyMagicHeader))):
+		return ArchiveZip, nil
+	case n == tarBlockSize && string(header[tarMagicOffset:tarMagicOffset+len(tarMagic)]) == tarMagic:
+		return ArchiveTar, nil
+	default:
+		return "", nil
+	}
+}
+
+// listArchive returns the regular, non-empty files stored in the archive f.
+// The first of several members with the same name wins.
+func listArchive(f *os.File, format, compression string) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	seen := map[string]struct{}{}
+	add := func(name string, size int64) {
+		if size == 0 {
+			return
+		}
+		if _, ok := seen[cleanMemberName(name)]; ok {
+			return
+		}
+		seen[cleanMemberName(name)] = struct{}{}
+		entries = append(entries, archiveEntry{name: name, size: size})
+	}
+
+	switch format {
+	case ArchiveTar:
+		tr, closer, err := openTar(f, compression)
+		if err != nil {
+			return nil, err
+		}
+		defer closer()
+
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read tar header: %w", err)
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				add(hdr.Name, hdr.Size)
+			}
+		}
+
+	case ArchiveZip:
+		zr, err := openZip(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range zr.File {
+			if zf.Mode().IsRegular() {
+				//nolint:gosec // the size is bounded by the archive
+				add(zf.Name, int64(zf.UncompressedSize64))
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	return entries, nil
+}
+
+// openTar returns a tar reader reading f from its beginning, decompressing
+// it with compression, and the function releasing the decompressor.
+func openTar(f *os.File, compression string) (*tar.Reader, func(), error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	if compression == CompressionNone {
+		return tar.NewReader(f), func() {}, nil
+	}
+
+	dec, err := newDecompressor(compression, f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create %s reader: %w", compression, err)
+	}
+	return tar.NewReader(dec), func() { _ = dec.Close() }, nil
+}
+
+// openZip returns a zip reader for f.
+func openZip(f *os.File) (*zip.Reader, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	return zr, nil
+}
+
+// archiveMembers returns the descriptors of the selected members of the
+// archive described by fd, or nil if fd isn't an archive. The listings are
+// cached in st, so an archive is only read again when it changes.
+func (st *scanState) archiveMembers(it *ingestTarget, fd loginp.FileDescriptor) ([]loginp.FileDescriptor, error) {
+	s := st.s
+	listing, ok := s.archives[it.filename]
+	if !ok || listing.size != it.info.Size() || !listing.modTime.Equal(it.info.ModTime()) {
+		var err error
+		listing, err = s.inspectArchive(it, fd.Compression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	st.archives[it.filename] = listing
+
+	if listing.format == "" {
+		return nil, nil
+	}
+
+	// An archive is not expected to grow, the fingerprint of one smaller
+	// than the fingerprint length is final.
+	archiveKey := fd.Fingerprint.Key()
+	if archiveKey == "" {
+		return nil, fmt.Errorf("archive %q has no fingerprint", it.filename)
+	}
+
+	members := make([]loginp.FileDescriptor, 0, len(listing.entries))
+	for _, e := range listing.entries {
+		if !s.cfg.Archive.selects(e.name) {
+			continue
+		}
+		members = append(members, loginp.FileDescriptor{
+			Filename:    archiveMemberPath(it.filename, e.name),
+			Info:        archiveMemberInfo{ExtendedFileInfo: fd.Info, name: e.name, size: e.size},
+			Fingerprint: archiveMemberFingerprint(archiveKey, e.name),
+			Compression: fd.Compression,
+			Member: &loginp.ArchiveMember{
+				Path:   it.filename,
+				Format: listing.format,
+				Name:   e.name,
+			},
+		})
+	}
+	return members, nil
+}
+
+// inspectArchive detects whether the ingest target is an archive and lists
+// its members.
+func (s *fileScanner) inspectArchive(it *ingestTarget, compression string) (archiveListing, error) {
+	listing := archiveListing{
+		size:    it.info.Size(),
+		modTime: it.info.ModTime(),
+	}
+
+	f, err := os.Open(it.originalFilename)
+	if err != nil {
+		return listing, fmt.Errorf("failed to open %q to look for archive members: %w", it.originalFilename, err)
+	}
+	defer f.Close()
+
+	listing.format, err = detectArchive(f, compression)
+	if err != nil {
+		return listing, fmt.Errorf("failed to detect the archive format of %q: %w", it.originalFilename, err)
+	}
+	if listing.format == "" {
+		return listing, nil
+	}
+
+	listing.entries, err = listArchive(f, listing.format, compression)
+	if err != nil {
+		return listing, fmt.Errorf("failed to list the members of %s archive %q: %w",
+			listing.format, it.originalFilename, err)
+	}
+	s.log.Debugf("found %d members in %s archive %q", len(listing.entries), listing.format, it.filename)
+	return listing, nil
+}
+
+// archiveMemberInfo is the file info of an archive member. It's the file
+// info of the archive with the name and size of the member.
+type archiveMemberInfo struct {
+	commonfile.ExtendedFileInfo
+	name string
+	size int64
+}
+
+func (i archiveMemberInfo) Name() string {
+	return path.Base(i.name)
+}
+
+func (i archiveMemberInfo) Size() int64 {
+	return i.size
+}
+
+// archiveMemberReader reads the data of a file stored in an archive. Like
+// compressedSeekerReader it emulates seeks within the data of the member.
+type archiveMemberReader struct {
+	f      *os.File // underlying archive file
+	member loginp.ArchiveMember
+	// open opens the data of the member from its beginning.
+	open func() (io.Reader, error)
+	// close releases the resources of the last opened member data.
+	close func() error
+
+	r      io.Reader
+	offset int64
+}
+
+func newArchiveMemberReader(f *os.File, compression string, member loginp.ArchiveMember) (*archiveMemberReader, error) {
+	r := &archiveMemberReader{f: f, member: member, close: func() error { return nil }}
+
+	switch member.Format {
+	case ArchiveTar:
+		r.open = func() (io.Reader, error) {
+			if err := r.close(); err != nil {
+				return nil, err
+			}
+			tr, closer, err := openTar(f, compression)
+			if err != nil {
+				return nil, err
+			}
+			r.close = func() error { closer(); return nil }
+
+			for {
+				hdr, err := tr.Next()
+				if errors.Is(err, io.EOF) {
+					return nil, fmt.Errorf("member %q not found in tar archive", member.Name)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to read tar header: %w", err)
+				}
+				if hdr.Typeflag == tar.TypeReg && hdr.Name == member.Name {
+					return tr, nil
+				}
+			}
+		}
+
+	case ArchiveZip:
+		zr, err := openZip(f)
+		if err != nil {
+			return nil, err
+		}
+		var zf *zip.File
+		for _, candidate := range zr.File {
+			if candidate.Name == member.Name {
+				zf = candidate
+				break
+			}
+		}
+		if zf == nil {
+			return nil, fmt.Errorf("member %q not found in zip archive", member.Name)
+		}
+		r.open = func() (io.Reader, error) {
+			if err := r.close(); err != nil {
+				return nil, err
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			r.close = rc.Close
+			return rc, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", member.Format)
+	}
+
+	var err error
+	r.r, err = r.open()
+	if err != nil {
+		return nil, fmt.Errorf("could not open archive member %q: %w", member.Name, err)
+	}
+	return r, nil
+}
+
+// IsCompressed returns true: archive members are read as compressed files,
+// their offsets are tracked on the data of the member.
+func (r *archiveMemberReader) IsCompressed() bool {
+	return true
+}
+
+// Stat returns Stat() of the archive.
+func (r *archiveMemberReader) Stat() (fs.FileInfo, error) {
+	return r.f.Stat()
+}
+
+// Name returns Name() of the archive.
+func (r *archiveMemberReader) Name() string {
+	return r.f.Name()
+}
+
+// OSFile returns the archive *os.File.
+func (r *archiveMemberReader) OSFile() *os.File {
+	return r.f
+}
+
+// Read reads the data of the member.
+func (r *archiveMemberReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *archiveMemberReader) Close() error {
+	membererr := r.close()
+	if membererr != nil {
+		membererr = fmt.Errorf("could not close archive member: %w", membererr)
+	}
+
+	plainerr := r.f.Close()
+	if plainerr != nil {
+		plainerr = fmt.Errorf("could not close archive file: %w", plainerr)
+	}
+
+	return errors.Join(membererr, plainerr)
+}
+
+// Seek seeks to offset within the data of the member.
+func (r *archiveMemberReader) Seek(offset int64, whence int) (int64, error) {
+	if whence >= io.SeekEnd {
+		return 0, fmt.Errorf("archiveMemberReader: SeekEnd (2) is unsupported")
+	}
+
+	finalOffset := offset
+	if whence == io.SeekCurrent {
+		finalOffset += r.offset
+	}
+	if finalOffset < 0 {
+		return 0, fmt.Errorf(
+			"archiveMemberReader: final offset must be non-negative, got: %d",
+			finalOffset)
+	}
+
+	if finalOffset < r.offset {
+		mr, err := r.open()
+		if err != nil {
+			return 0, fmt.Errorf(
+				"archiveMemberReader: could not reopen archive member %q: %w", r.member.Name, err)
+		}
+		r.r = mr
+		r.offset = 0
+	}
+
+	if _, err := io.CopyN(io.Discard, r, finalOffset-r.offset); err != nil && !errors.Is(err, io.EOF) {
+		return r.offset, fmt.Errorf(
+			"archiveMemberReader: could not advance to offset %d: %w", finalOffset, err)
+	}
+
+	r.offset = finalOffset
+	return finalOffset, nil
+}
@@ -0,0 +1,383 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+)
+
+// testArchiveMembers are the members of the test archives, in order.
+var testArchiveMembers = []struct {
+	name    string
+	content string
+}{
+	{name: "logs/app.log", content: "app line 1\napp line 2\n"},
+	{name: "logs/", content: ""},
+	{name: "logs/empty.log", content: ""},
+	{name: "logs/db/db.log", content: "db line 1\ndb line 2\ndb line 3\n"},
+	{name: "README.txt", content: "not a log\n"},
+}
+
+// newTestArchive returns an archive of testArchiveMembers in the given
+// format, compressed with compression.
+func newTestArchive(t *testing.T, format, compression string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch format {
+	case ArchiveTar:
+		var w io.Writer = &buf
+		var gw *gzip.Writer
+		if compression == CompressionGZIP {
+			gw = gzip.NewWriter(&buf)
+			w = gw
+		}
+		tw := tar.NewWriter(w)
+		for _, m := range testArchiveMembers {
+			hdr := &tar.Header{
+				Name:     m.name,
+				Mode:     0o644,
+				Size:     int64(len(m.content)),
+				ModTime:  time.Unix(1700000000, 0),
+				Typeflag: tar.TypeReg,
+				Format:   tar.FormatPAX,
+			}
+			if m.name[len(m.name)-1] == '/' {
+				hdr.Typeflag = tar.TypeDir
+				hdr.Mode = 0o755
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			_, err := tw.Write([]byte(m.content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		if gw != nil {
+			require.NoError(t, gw.Close())
+		}
+
+	case ArchiveZip:
+		require.Equal(t, CompressionNone, compression, "zip archives can't be compressed")
+		zw := zip.NewWriter(&buf)
+		for _, m := range testArchiveMembers {
+			w, err := zw.Create(m.name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(m.content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+
+	default:
+		t.Fatalf("unsupported archive format %q", format)
+	}
+
+	return buf.Bytes()
+}
+
+func writeTestArchive(t *testing.T, dir, name, format, compression string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, newTestArchive(t, format, compression), 0o644))
+	return p
+}
+
+func TestArchiveConfig(t *testing.T) {
+	t.Run("invalid pattern", func(t *testing.T) {
+		c := archiveConfig{Members: []string{"["}}
+		require.ErrorContains(t, c.Validate(), `invalid archive member pattern "["`)
+	})
+
+	tcs := map[string]struct {
+		members []string
+		want    map[string]bool
+	}{
+		"no patterns selects all members": {
+			want: map[string]bool{"logs/app.log": true, "logs/db/db.log": true, "README.txt": true},
+		},
+		"patterns without slash match the base name": {
+			members: []string{"*.log"},
+			want:    map[string]bool{"logs/app.log": true, "logs/db/db.log": true, "README.txt": false},
+		},
+		"patterns with slash match the full path": {
+			members: []string{"logs/*.log"},
+			want:    map[string]bool{"logs/app.log": true, "logs/db/db.log": false, "README.txt": false},
+		},
+		"leading slash and dot elements are ignored": {
+			members: []string{"logs/db/*"},
+			want:    map[string]bool{"/logs/db/db.log": true, "./logs/db/db.log": true, "logs/app.log": false},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			c := archiveConfig{Enabled: true, Members: tc.members}
+			require.NoError(t, c.Validate())
+
+			for member, want := range tc.want {
+				assert.Equal(t, want, c.selects(member), "member %q", member)
+			}
+		})
+	}
+}
+
+func TestDetectAndListArchive(t *testing.T) {
+	dir := t.TempDir()
+	want := []archiveEntry{
+		{name: "logs/app.log", size: 22},
+		{name: "logs/db/db.log", size: 30},
+		{name: "README.txt", size: 10},
+	}
+
+	tcs := []struct {
+		name        string
+		format      string
+		compression string
+	}{
+		{name: "tar", format: ArchiveTar},
+		{name: "tar.gz", format: ArchiveTar, compression: CompressionGZIP},
+		{name: "zip", format: ArchiveZip},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			p := writeTestArchive(t, dir, "bundle."+tc.name, tc.format, tc.compression)
+			f, err := os.Open(p)
+			require.NoError(t, err)
+			defer f.Close()
+
+			format, err := detectArchive(f, tc.compression)
+			require.NoError(t, err)
+			require.Equal(t, tc.format, format)
+
+			entries, err := listArchive(f, format, tc.compression)
+			require.NoError(t, err)
+			assert.Equal(t, want, entries)
+		})
+	}
+
+	t.Run("not an archive", func(t *testing.T) {
+		f, err := os.Open(filepath.Join("testdata", "log.log"))
+		require.NoError(t, err)
+		defer f.Close()
+
+		format, err := detectArchive(f, CompressionNone)
+		require.NoError(t, err)
+		assert.Empty(t, format)
+	})
+
+	t.Run("compressed tar read without compression", func(t *testing.T) {
+		p := writeTestArchive(t, dir, "plain.tar.gz", ArchiveTar, CompressionGZIP)
+		f, err := os.Open(p)
+		require.NoError(t, err)
+		defer f.Close()
+
+		format, err := detectArchive(f, CompressionNone)
+		require.NoError(t, err)
+		assert.Empty(t, format)
+	})
+
+	t.Run("truncated tar", func(t *testing.T) {
+		data := newTestArchive(t, ArchiveTar, CompressionNone)
+		p := filepath.Join(dir, "truncated.tar")
+		require.NoError(t, os.WriteFile(p, data[:tarBlockSize+10], 0o644))
+		f, err := os.Open(p)
+		require.NoError(t, err)
+		defer f.Close()
+
+		_, err = listArchive(f, ArchiveTar, CompressionNone)
+		require.Error(t, err)
+	})
+}
+
+func TestArchiveMemberReader(t *testing.T) {
+	dir := t.TempDir()
+	content := "db line 1\ndb line 2\ndb line 3\n"
+
+	tcs := []struct {
+		name        string
+		format      string
+		compression string
+	}{
+		{name: "tar", format: ArchiveTar},
+		{name: "tar.gz", format: ArchiveTar, compression: CompressionGZIP},
+		{name: "zip", format: ArchiveZip},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			p := writeTestArchive(t, dir, "bundle."+tc.name, tc.format, tc.compression)
+			f, err := os.Open(p)
+			require.NoError(t, err)
+
+			r, err := newArchiveMemberReader(f, tc.compression, loginp.ArchiveMember{
+				Path: p, Format: tc.format, Name: "logs/db/db.log",
+			})
+			require.NoError(t, err)
+			defer r.Close()
+
+			assert.True(t, r.IsCompressed())
+			assert.Equal(t, p, r.Name())
+
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(data))
+
+			off, err := r.Seek(10, io.SeekStart)
+			require.NoError(t, err)
+			assert.EqualValues(t, 10, off)
+			buf := make([]byte, 9)
+			_, err = io.ReadFull(r, buf)
+			require.NoError(t, err)
+			assert.Equal(t, "db line 2", string(buf))
+
+			off, err = r.Seek(1, io.SeekCurrent)
+			require.NoError(t, err)
+			assert.EqualValues(t, 20, off)
+			data, err = io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "db line 3\n", string(data))
+
+			_, err = r.Seek(0, io.SeekEnd)
+			require.Error(t, err)
+		})
+
+		t.Run(tc.name+" missing member", func(t *testing.T) {
+			p := writeTestArchive(t, dir, "missing."+tc.name, tc.format, tc.compression)
+			f, err := os.Open(p)
+			require.NoError(t, err)
+			defer f.Close()
+
+			_, err = newArchiveMemberReader(f, tc.compression, loginp.ArchiveMember{
+				Path: p, Format: tc.format, Name: "nope.log",
+			})
+			require.ErrorContains(t, err, `member "nope.log" not found`)
+		})
+	}
+}
+
+func TestFileScannerArchives(t *testing.T) {
+	dir := t.TempDir()
+	tarPath := writeTestArchive(t, dir, "bundle.tar.gz", ArchiveTar, CompressionGZIP)
+	zipPath := writeTestArchive(t, dir, "bundle.zip", ArchiveZip, CompressionNone)
+	plainPath := filepath.Join(dir, "plain.log")
+	require.NoError(t, os.WriteFile(plainPath, []byte("plain line\n"), 0o644))
+
+	newScanner := func(t *testing.T, members ...string) *fileScanner {
+		cfg := fileScannerConfig{
+			Fingerprint: fingerprintConfig{
+				Enabled: true,
+				Offset:  0,
+				Length:  64,
+				Growing: true,
+			},
+			Archive: archiveConfig{Enabled: true, Members: members},
+		}
+		s, err := newFileScanner(logptest.NewTestingLogger(t, ""),
+			[]string{filepath.Join(dir, "*")}, cfg, CompressionAuto)
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("archive members replace the archives", func(t *testing.T) {
+		s := newScanner(t)
+		files := s.GetFiles(loginp.FileScanOptions{}).Files
+
+		want := []string{
+			plainPath,
+			filepath.Join(tarPath, "logs", "app.log"),
+			filepath.Join(tarPath, "logs", "db", "db.log"),
+			filepath.Join(tarPath, "README.txt"),
+			filepath.Join(zipPath, "logs", "app.log"),
+			filepath.Join(zipPath, "logs", "db", "db.log"),
+			filepath.Join(zipPath, "README.txt"),
+		}
+		require.Len(t, files, len(want))
+		for _, p := range want {
+			require.Contains(t, files, p)
+		}
+
+		plain := files[plainPath]
+		assert.False(t, plain.IsArchiveMember())
+		assert.False(t, plain.IsStatic())
+
+		member := files[filepath.Join(tarPath, "logs", "db", "db.log")]
+		require.True(t, member.IsArchiveMember())
+		assert.True(t, member.IsStatic())
+		assert.Equal(t, loginp.ArchiveMember{Path: tarPath, Format: ArchiveTar, Name: "logs/db/db.log"}, *member.Member)
+		assert.Equal(t, CompressionGZIP, member.Compression)
+		assert.EqualValues(t, 30, member.Info.Size())
+		assert.Equal(t, "db.log", member.Info.Name())
+		assert.True(t, member.Fingerprint.Complete())
+		assert.Empty(t, member.Fingerprint.Raw)
+
+		other := files[filepath.Join(tarPath, "logs", "app.log")]
+		assert.NotEqual(t, member.FileID(), other.FileID())
+		assert.NotContains(t, member.Fingerprint.Key(), identitySep)
+
+		zipMember := files[filepath.Join(zipPath, "logs", "db", "db.log")]
+		assert.Equal(t, CompressionNone, zipMember.Compression)
+		assert.NotEqual(t, member.FileID(), zipMember.FileID())
+	})
+
+	t.Run("member identity is stable across scans", func(t *testing.T) {
+		s := newScanner(t)
+		first := s.GetFiles(loginp.FileScanOptions{}).Files
+		second := s.GetFiles(loginp.FileScanOptions{}).Files
+		require.Equal(t, len(first), len(second))
+		for p, fd := range first {
+			assert.Equal(t, fd.FileID(), second[p].FileID(), "file %q", p)
+		}
+	})
+
+	t.Run("members are filtered by glob", func(t *testing.T) {
+		s := newScanner(t, "*.log")
+		files := s.GetFiles(loginp.FileScanOptions{}).Files
+		assert.Len(t, files, 5)
+		assert.NotContains(t, files, filepath.Join(tarPath, "README.txt"))
+		assert.NotContains(t, files, filepath.Join(zipPath, "README.txt"))
+	})
+
+	t.Run("unreadable archives are unobservable", func(t *testing.T) {
+		brokenDir := t.TempDir()
+		data := newTestArchive(t, ArchiveTar, CompressionNone)
+		broken := filepath.Join(brokenDir, "broken.tar")
+		require.NoError(t, os.WriteFile(broken, data[:tarBlockSize+10], 0o644))
+
+		cfg := fileScannerConfig{
+			Fingerprint: fingerprintConfig{Enabled: true, Length: 64},
+			Archive:     archiveConfig{Enabled: true},
+		}
+		s, err := newFileScanner(logptest.NewTestingLogger(t, ""),
+			[]string{filepath.Join(brokenDir, "*")}, cfg, CompressionNone)
+		require.NoError(t, err)
+
+		res := s.GetFiles(loginp.FileScanOptions{})
+		assert.Empty(t, res.Files)
+		assert.Equal(t, []string{broken}, res.Unobservable)
+	})
+}
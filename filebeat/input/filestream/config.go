@@ -41,7 +41,14 @@ const (
 	CompressionNone = ""
 	// CompressionGZIP treats all files as gzip compressed.
 	CompressionGZIP = "gzip"
-	// CompressionAuto auto-detects gzip files and decompresses them.
+	// CompressionZSTD treats all files as zstd compressed.
+	CompressionZSTD = "zstd"
+	// CompressionBZIP2 treats all files as bzip2 compressed.
+	CompressionBZIP2 = "bzip2"
+	// CompressionXZ treats all files as xz compressed.
+	CompressionXZ = "xz"
+	// CompressionAuto auto-detects gzip, zstd, bzip2 and xz files and
+	// decompresses them.
 	CompressionAuto = "auto"
 )
 
@@ -58,10 +65,14 @@ type config struct {
 	FileIdentity *conf.Namespace   `config:"file_identity"`
 
 	// Compression specifies how file compression is handled.
-	// Valid values: "" (none), "gzip" (all files are gzip), "auto"
-	// (auto-detect).
+	// Valid values: "" (none), "gzip", "zstd", "bzip2" or "xz" (all files
+	// use that format), "auto" (auto-detect).
 	Compression string `config:"compression"`
 
+	// Archive configures the harvesting of the files stored in tar and zip
+	// archives.
+	Archive archiveConfig `config:"archive"`
+
 	// GZIPExperimental is deprecated and is ignored. Use Compression instead.
 	// Deprecated.
 	GZIPExperimental *bool `config:"gzip_experimental"`
@@ -231,15 +242,27 @@ func (c *config) Validate() error {
 	switch c.Compression {
 	case CompressionNone:
 		// no validation needed
-	case CompressionGZIP, CompressionAuto:
+	case CompressionGZIP, CompressionZSTD, CompressionBZIP2, CompressionXZ, CompressionAuto:
 		if c.FileIdentity != nil && c.FileIdentity.Name() != fingerprintName {
 			return fmt.Errorf(
 				"compression='%s' requires 'file_identity' to be 'fingerprint'. Current file_identity is '%s'",
 				c.Compression, c.FileIdentity.Name())
 		}
 	default:
-		return fmt.Errorf("invalid compression value %q, must be one of: %q, %q, %q",
-			c.Compression, CompressionNone, CompressionGZIP, CompressionAuto)
+		return fmt.Errorf("invalid compression value %q, must be one of: %q, %q, %q, %q, %q, %q",
+			c.Compression, CompressionNone, CompressionGZIP, CompressionZSTD,
+			CompressionBZIP2, CompressionXZ, CompressionAuto)
+	}
+
+	if c.Archive.Enabled {
+		if c.FileIdentity != nil && c.FileIdentity.Name() != fingerprintName {
+			return fmt.Errorf(
+				"archive.enabled requires 'file_identity' to be 'fingerprint'. Current file_identity is '%s'",
+				c.FileIdentity.Name())
+		}
+		if c.Delete.Enabled {
+			return errors.New("archive.enabled and delete.enabled cannot be enabled at the same time")
+		}
 	}
 
 	if c.ID == "" && c.TakeOver.Enabled {
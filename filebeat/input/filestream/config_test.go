@@ -79,6 +79,9 @@ func TestConfigValidate(t *testing.T) {
 		}{
 			{name: "none is valid", compression: CompressionNone},
 			{name: "gzip is valid", compression: CompressionGZIP},
+			{name: "zstd is valid", compression: CompressionZSTD},
+			{name: "bzip2 is valid", compression: CompressionBZIP2},
+			{name: "xz is valid", compression: CompressionXZ},
 			{name: "auto is valid", compression: CompressionAuto},
 			{name: "invalid value returns error", compression: "invalid", wantErr: `invalid compression value "invalid"`},
 		}
@@ -187,6 +190,47 @@ func TestConfigValidate(t *testing.T) {
 		}
 	})
 
+	t.Run("archive validation", func(t *testing.T) {
+		tcs := []struct {
+			name    string
+			cfg     string
+			wantErr string
+		}{
+			{
+				name: "archive with default file_identity is valid",
+				cfg:  "archive.enabled: true\narchive.members: ['*.log', 'logs/*']",
+			},
+			{
+				name:    "archive with native errors",
+				cfg:     "archive.enabled: true\nfile_identity.native: ~",
+				wantErr: "archive.enabled requires 'file_identity' to be 'fingerprint'",
+			},
+			{
+				name:    "archive with delete errors",
+				cfg:     "archive.enabled: true\ndelete.enabled: true",
+				wantErr: "archive.enabled and delete.enabled cannot be enabled at the same time",
+			},
+			{
+				name:    "invalid member pattern errors",
+				cfg:     "archive.enabled: true\narchive.members: ['[']",
+				wantErr: `invalid archive member pattern "["`,
+			},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				c := conf.MustNewConfigFrom("paths: [/foo/bar]\n" + tc.cfg)
+				cfg := defaultConfig()
+				err := c.Unpack(&cfg)
+				if tc.wantErr == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorContains(t, err, tc.wantErr)
+				}
+			})
+		}
+	})
+
 	t.Run("read_until_eof", func(t *testing.T) {
 		t.Run("valid config", func(t *testing.T) {
 			c, err := conf.NewConfigFrom(`
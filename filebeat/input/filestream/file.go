@@ -19,6 +19,7 @@ package filestream
 
 import (
 	"bytes"
+	"compress/bzip2"
 	"errors"
 	"fmt"
 	"io"
@@ -26,10 +27,23 @@ import (
 	"os"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
 )
 
+// Magic bytes of the supported compression formats.
 const (
-	magicHeader = "\x1f\x8b" // RFC 1952 magic bytes
+	magicHeader     = "\x1f\x8b"         // RFC 1952 magic bytes
+	zstdMagicHeader = "\x28\xb5\x2f\xfd" // RFC 8878 magic number
+	xzMagicHeader   = "\xfd7zXZ\x00"     // XZ file format header magic bytes
+
+	// A bzip2 stream starts with "BZh", the block size from '1' to '9', and
+	// the magic of its first block, or the end of stream magic if it's empty.
+	bzip2MagicHeader = "BZh"
+	bzip2BlockMagic  = "\x31\x41\x59\x26\x53\x59"
+	bzip2EOSMagic    = "\x17\x72\x45\x38\x50\x90"
+
+	maxMagicLen = len(bzip2MagicHeader) + 1 + len(bzip2BlockMagic) // longest magic header
 )
 
 type File interface {
@@ -40,8 +54,8 @@ type File interface {
 	Name() string
 	// OSFile returns the underlying *os.File.
 	OSFile() *os.File
-	// IsGZIP returns true if the file is a GZIP file.
-	IsGZIP() bool
+	// IsCompressed returns true if the file is a compressed file.
+	IsCompressed() bool
 }
 
 // plainFile is a wrapper around an *os.File that implements the File interface.
@@ -50,7 +64,7 @@ type plainFile struct {
 	*os.File
 }
 
-func (pf *plainFile) IsGZIP() bool {
+func (pf *plainFile) IsCompressed() bool {
 	return false
 }
 
@@ -64,61 +78,143 @@ func (pf *plainFile) OSFile() *os.File {
 	return pf.File
 }
 
-type gzipSeekerReader struct {
-	f        *os.File     // underlying gzip-compressed file
-	gzr      *gzip.Reader // reader that yields uncompressed bytes
-	buffSize int64        // buffer size used when emulating seeks
+// decompressor yields the decompressed data of a compressed stream.
+type decompressor interface {
+	io.Reader
+	// Reset discards the state of the decompressor and restarts the
+	// decompression from r.
+	Reset(r io.Reader) error
+	Close() error
+}
+
+// newDecompressor returns a decompressor reading the data compressed with
+// compression from r.
+func newDecompressor(compression string, r io.Reader) (decompressor, error) {
+	switch compression {
+	case CompressionGZIP:
+		return gzip.NewReader(r)
+	case CompressionZSTD:
+		// A single goroutine is enough for the sequential reads of a
+		// harvester, and the decoder doesn't start background goroutines.
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zstdDecompressor{d}, nil
+	case CompressionBZIP2:
+		return newResettableDecompressor(r, func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		})
+	case CompressionXZ:
+		return newResettableDecompressor(r, func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		})
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// zstdDecompressor adapts *zstd.Decoder to the decompressor interface.
+type zstdDecompressor struct {
+	*zstd.Decoder
+}
+
+func (d zstdDecompressor) Close() error {
+	d.Decoder.Close()
+	return nil
+}
+
+// resettableDecompressor implements Reset for the decompressors that can't
+// be reset by creating a new decompressor.
+type resettableDecompressor struct {
+	io.Reader
+	newReader func(io.Reader) (io.Reader, error)
+}
+
+func newResettableDecompressor(r io.Reader, newReader func(io.Reader) (io.Reader, error)) (*resettableDecompressor, error) {
+	d := &resettableDecompressor{newReader: newReader}
+	if err := d.Reset(r); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *resettableDecompressor) Reset(r io.Reader) error {
+	dr, err := d.newReader(r)
+	if err != nil {
+		return err
+	}
+	d.Reader = dr
+	return nil
+}
+
+func (d *resettableDecompressor) Close() error {
+	return nil
+}
+
+// compressedSeekerReader reads the decompressed data of a compressed file,
+// and emulates seeks within the decompressed data.
+type compressedSeekerReader struct {
+	f           *os.File     // underlying compressed file
+	compression string       // compression format of f
+	dec         decompressor // reader that yields uncompressed bytes
+	buffSize    int64        // buffer size used when emulating seeks
 
 	// offset is the current offset in the *decompressed* stream. It's updated
 	// by read.
 	offset int64
 }
 
-func newGzipSeekerReader(f *os.File, buffSize int) (*gzipSeekerReader, error) {
-	gzr, err := gzip.NewReader(f)
+func newGzipSeekerReader(f *os.File, buffSize int) (*compressedSeekerReader, error) {
+	return newCompressedSeekerReader(f, CompressionGZIP, buffSize)
+}
+
+func newCompressedSeekerReader(f *os.File, compression string, buffSize int) (*compressedSeekerReader, error) {
+	dec, err := newDecompressor(compression, f)
 	if err != nil {
-		return nil, fmt.Errorf("could not create gzip reader: %w", err)
+		return nil, fmt.Errorf("could not create %s reader: %w", compression, err)
 	}
 
-	return &gzipSeekerReader{
-		f:        f,
-		gzr:      gzr,
-		buffSize: int64(buffSize),
-		offset:   0,
+	return &compressedSeekerReader{
+		f:           f,
+		compression: compression,
+		dec:         dec,
+		buffSize:    int64(buffSize),
+		offset:      0,
 	}, nil
 }
 
-func (r *gzipSeekerReader) IsGZIP() bool {
+func (r *compressedSeekerReader) IsCompressed() bool {
 	return true
 }
 
 // Stat returns Stat() of the underlying *os.File.
-func (r *gzipSeekerReader) Stat() (fs.FileInfo, error) {
+func (r *compressedSeekerReader) Stat() (fs.FileInfo, error) {
 	return r.f.Stat()
 }
 
 // Name returns Name() of the underlying *os.File.
-func (r *gzipSeekerReader) Name() string {
+func (r *compressedSeekerReader) Name() string {
 	return r.f.Name()
 }
 
 // OSFile returns the underlying *os.File.
-func (r *gzipSeekerReader) OSFile() *os.File {
+func (r *compressedSeekerReader) OSFile() *os.File {
 	return r.f
 }
 
 // Read reads plain data, decompressing it on the fly.
-func (r *gzipSeekerReader) Read(p []byte) (n int, err error) {
-	n, err = r.gzr.Read(p)
+func (r *compressedSeekerReader) Read(p []byte) (n int, err error) {
+	n, err = r.dec.Read(p)
 
 	r.offset += int64(n)
 	return n, err
 }
 
-func (r *gzipSeekerReader) Close() error {
-	gzerr := r.gzr.Close()
-	if gzerr != nil {
-		gzerr = fmt.Errorf("could not close gzip reader: %w", gzerr)
+func (r *compressedSeekerReader) Close() error {
+	decerr := r.dec.Close()
+	if decerr != nil {
+		decerr = fmt.Errorf("could not close %s reader: %w", r.compression, decerr)
 	}
 
 	plainerr := r.f.Close()
@@ -126,13 +222,13 @@ func (r *gzipSeekerReader) Close() error {
 		plainerr = fmt.Errorf("could not close plain file: %w", plainerr)
 	}
 
-	return errors.Join(gzerr, plainerr)
+	return errors.Join(decerr, plainerr)
 }
 
 // Seek seeks to offset within the *decompressed* data stream.
-func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
+func (r *compressedSeekerReader) Seek(offset int64, whence int) (int64, error) {
 	if whence >= io.SeekEnd {
-		return 0, fmt.Errorf("gzipSeekerReader: SeekEnd (2) is unsupported")
+		return 0, fmt.Errorf("compressedSeekerReader: SeekEnd (2) is unsupported")
 	}
 
 	finalOffset := offset
@@ -144,7 +240,7 @@ func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
 
 	if finalOffset < 0 {
 		return 0, fmt.Errorf(
-			"gzipSeekerReader: final offset must be non-negative, got: %d",
+			"compressedSeekerReader: final offset must be non-negative, got: %d",
 			finalOffset)
 	}
 
@@ -154,13 +250,13 @@ func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
 		n, err := r.f.Seek(0, 0)
 		if err != nil {
 			return n, fmt.Errorf(
-				"gzipSeekerReader: could not seek to 0: %w", err)
+				"compressedSeekerReader: could not seek to 0: %w", err)
 		}
 
-		err = r.gzr.Reset(r.f)
+		err = r.dec.Reset(r.f)
 		if err != nil {
 			return n, fmt.Errorf(
-				"gzipSeekerReader: could not reset gzip reader: %w", err)
+				"compressedSeekerReader: could not reset %s reader: %w", r.compression, err)
 		}
 		r.offset = 0
 
@@ -183,7 +279,7 @@ func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
 		_, err = r.Read(make([]byte, bytesToAdvance))
 		if err != nil && !errors.Is(err, io.EOF) {
 			return r.offset, fmt.Errorf(
-				"gzipSeekerReader: could read bytesToAdvance=%d: %w",
+				"compressedSeekerReader: could read bytesToAdvance=%d: %w",
 				bytesToAdvance, err)
 		}
 
@@ -195,10 +291,10 @@ func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
 	leftover := bytesToAdvance % r.buffSize
 	buff := make([]byte, r.buffSize)
 	for i := range chunks {
-		_, err = r.gzr.Read(buff)
+		_, err = r.dec.Read(buff)
 		if err != nil && !errors.Is(err, io.EOF) {
 			return r.offset, fmt.Errorf(
-				"gzipSeekerReader: could read chunk %d: %w", i, err)
+				"compressedSeekerReader: could read chunk %d: %w", i, err)
 		}
 	}
 
@@ -206,7 +302,7 @@ func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
 		_, err = r.Read(make([]byte, leftover))
 		if err != nil && !errors.Is(err, io.EOF) {
 			return r.offset, fmt.Errorf(
-				"gzipSeekerReader: could read leftover %d: %w", leftover, err)
+				"compressedSeekerReader: could read leftover %d: %w", leftover, err)
 		}
 	}
 
@@ -218,22 +314,57 @@ func (r *gzipSeekerReader) Seek(offset int64, whence int) (int64, error) {
 // defined by RFC 1952. The file offset is reset to the original position before
 // returning.
 func IsGZIP(f *os.File) (bool, error) {
+	compression, err := DetectCompression(f)
+	if err != nil {
+		return false, fmt.Errorf("GZIP: %w", err)
+	}
+	return compression == CompressionGZIP, nil
+}
+
+// DetectCompression returns the compression format of the file f detected
+// from its magic header bytes, or CompressionNone if f isn't compressed with
+// a supported format. The file offset is reset to the original position
+// before returning.
+func DetectCompression(f *os.File) (string, error) {
 	// Remember current offset so we can reset it afterward.
 	offset, err := f.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return false, err
+		return CompressionNone, err
 	}
 	// Ensure we always reset the offset.
 	defer func() { _, _ = f.Seek(offset, io.SeekStart) }()
 
-	// Read magic bytes, 2 bytes.
-	header := make([]byte, len(magicHeader))
-	if _, err := f.ReadAt(header, 0); err != nil {
-		if errors.Is(err, io.EOF) {
-			return false, nil // empty or too short file – definitely not gzip
-		}
-		return false, fmt.Errorf("GZIP: failed to read magic bytes: %w", err)
+	// Read the magic bytes. Files shorter than the longest magic header
+	// are still checked against the shorter ones.
+	header := make([]byte, maxMagicLen)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return CompressionNone, fmt.Errorf("failed to read magic bytes: %w", err)
 	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte(magicHeader)):
+		return CompressionGZIP, nil
+	case bytes.HasPrefix(header, []byte(zstdMagicHeader)):
+		return CompressionZSTD, nil
+	case bytes.HasPrefix(header, []byte(xzMagicHeader)):
+		return CompressionXZ, nil
+	case isBZIP2(header):
+		return CompressionBZIP2, nil
+	default:
+		return CompressionNone, nil
+	}
+}
 
-	return bytes.Equal(header, []byte(magicHeader)), nil
+// isBZIP2 reports whether header is the header of a bzip2 stream.
+func isBZIP2(header []byte) bool {
+	if len(header) < maxMagicLen || !bytes.HasPrefix(header, []byte(bzip2MagicHeader)) {
+		return false
+	}
+	if level := header[len(bzip2MagicHeader)]; level < '1' || level > '9' {
+		return false
+	}
+	block := string(header[len(bzip2MagicHeader)+1:])
+	return block == bzip2BlockMagic || block == bzip2EOSMagic
 }
@@ -27,8 +27,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
 
 	"github.com/elastic/beats/v7/filebeat/testing/gziptest"
 )
@@ -42,7 +44,7 @@ var (
 )
 
 var _ File = (*plainFile)(nil)
-var _ File = (*gzipSeekerReader)(nil)
+var _ File = (*compressedSeekerReader)(nil)
 
 func TestPlainFile(t *testing.T) {
 	testContent := []byte("hello world")
@@ -50,8 +52,8 @@ func TestPlainFile(t *testing.T) {
 
 	pf := newPlainFile(osFile)
 
-	t.Run("IsGZIP returns false", func(t *testing.T) {
-		assert.False(t, pf.IsGZIP())
+	t.Run("IsCompressed returns false", func(t *testing.T) {
+		assert.False(t, pf.IsCompressed())
 	})
 
 	t.Run("OSFile returns underlying os.File", func(t *testing.T) {
@@ -76,12 +78,12 @@ func TestGzipSeekerReader(t *testing.T) {
 		assert.Contains(t, err.Error(), "could not create gzip reader")
 		assert.Contains(t, err.Error(), gzip.ErrHeader.Error())
 	})
-	t.Run("IsGZIP returns true", func(t *testing.T) {
+	t.Run("IsCompressed returns true", func(t *testing.T) {
 		osFile := createAndOpenFile(t, newGzippedDataSource(t))
 		gsr, err := newGzipSeekerReader(osFile, 1024)
 		require.NoError(t, err)
 
-		assert.True(t, gsr.IsGZIP())
+		assert.True(t, gsr.IsCompressed())
 	})
 
 	t.Run("OSFile returns underlying os.File", func(t *testing.T) {
@@ -581,6 +583,80 @@ func TestIsGZIP(t *testing.T) {
 	})
 }
 
+func TestCompressedSeekerReader(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "log.log"))
+	require.NoError(t, err)
+
+	for _, compression := range []string{CompressionGZIP, CompressionZSTD, CompressionBZIP2, CompressionXZ} {
+		t.Run(compression, func(t *testing.T) {
+			// A small buffer size exercises the chunked reads when advancing.
+			osFile := createAndOpenFile(t, newCompressedDataSource(t, compression))
+			r, err := newCompressedSeekerReader(osFile, compression, 64)
+			require.NoError(t, err)
+			assert.True(t, r.IsCompressed())
+
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, content, got)
+
+			readAt := func(offset int64, whence int, want []byte) {
+				t.Helper()
+				_, err := r.Seek(offset, whence)
+				require.NoError(t, err)
+				got := make([]byte, len(want))
+				_, err = io.ReadFull(r, got)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+			readAt(0, io.SeekStart, content[:10])
+			readAt(500, io.SeekStart, content[500:520])
+			readAt(100, io.SeekCurrent, content[620:640])
+			readAt(5, io.SeekStart, content[5:15])
+
+			require.NoError(t, r.Close())
+		})
+	}
+
+	t.Run("error on data in another format", func(t *testing.T) {
+		osFile := createAndOpenFile(t, newCompressedDataSource(t, CompressionGZIP))
+		_, err := newCompressedSeekerReader(osFile, CompressionXZ, 1024)
+		assert.ErrorContains(t, err, "could not create xz reader")
+	})
+}
+
+func TestDetectCompression(t *testing.T) {
+	testCases := map[string]struct {
+		content []byte
+		want    string
+	}{
+		"gzip":                      {content: newCompressedDataSource(t, CompressionGZIP), want: CompressionGZIP},
+		"zstd":                      {content: newCompressedDataSource(t, CompressionZSTD), want: CompressionZSTD},
+		"bzip2":                     {content: newCompressedDataSource(t, CompressionBZIP2), want: CompressionBZIP2},
+		"xz":                        {content: newCompressedDataSource(t, CompressionXZ), want: CompressionXZ},
+		"plain":                     {content: plainContent, want: CompressionNone},
+		"plain starting like bzip2": {content: []byte("BZh9 is not a bzip2 stream"), want: CompressionNone},
+		"truncated zstd magic":      {content: []byte(zstdMagicHeader[:2]), want: CompressionNone},
+		"empty":                     {content: nil, want: CompressionNone},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f := createAndOpenFile(t, tc.content)
+			_, err := f.Seek(1, io.SeekStart)
+			require.NoError(t, err)
+
+			got, err := DetectCompression(f)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			// The offset is restored.
+			offset, err := f.Seek(0, io.SeekCurrent)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), offset)
+		})
+	}
+}
+
 func createAndOpenFile(t *testing.T, content []byte) *os.File {
 	t.Helper()
 
@@ -613,3 +689,36 @@ func newGzippedDataSource(t *testing.T) []byte {
 	require.NoError(t, err, "failed to close gzip writer")
 	return tempBuffer.Bytes()
 }
+
+// newCompressedDataSource returns the content of testdata/log.log compressed
+// with compression. There's no bzip2 compressor in the standard library, so
+// the bzip2 version is read from testdata/log.log.bz2.
+func newCompressedDataSource(t *testing.T, compression string) []byte {
+	t.Helper()
+	if compression == CompressionBZIP2 {
+		data, err := os.ReadFile(filepath.Join("testdata", "log.log.bz2"))
+		require.NoError(t, err)
+		return data
+	}
+
+	content, err := os.ReadFile(filepath.Join("testdata", "log.log"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch compression {
+	case CompressionGZIP:
+		w = gzip.NewWriter(&buf)
+	case CompressionZSTD:
+		w, err = zstd.NewWriter(&buf)
+	case CompressionXZ:
+		w, err = xz.NewWriter(&buf)
+	default:
+		t.Fatalf("unsupported compression %q", compression)
+	}
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
@@ -45,7 +45,7 @@ var (
 // The file handle is owned by the harvester session, so Close does NOT close it.
 // The close-on-state-change conditions (inactive/removed/renamed and
 // close-after-interval) are evaluated by the harvester runner's waker, so logFile
-// only reports end of data: io.EOF when close_on_eof (or a GZIP file) reaches the
+// only reports end of data: io.EOF when close_on_eof (or a compressed file) reaches the
 // end, ErrFileTruncate when the file shrank, or ErrWouldBlock when an active file
 // has nothing to read yet.
 type logFile struct {
@@ -82,7 +82,7 @@ func newFileReader(
 
 // Read reads from the file into buf without blocking. It returns:
 //   - the bytes read with a nil error when data was available;
-//   - io.EOF when close_on_eof (or a GZIP file) reaches the end;
+//   - io.EOF when close_on_eof (or a compressed file) reaches the end;
 //   - ErrFileTruncate when the file shrank;
 //   - ErrWouldBlock when an active file has no data right now;
 //   - ErrClosed when the reader's context was cancelled.
@@ -160,7 +160,7 @@ func (f *logFile) errorChecks(err error) error {
 }
 
 func (f *logFile) handleEOF() error {
-	if f.closeOnEOF || f.file.IsGZIP() {
+	if f.closeOnEOF || f.file.IsCompressed() {
 		return io.EOF
 	}
 
@@ -100,6 +100,10 @@ type fileScannerConfig struct {
 	Symlinks      bool              `config:"symlinks"`
 	RecursiveGlob bool              `config:"recursive_glob"`
 	Fingerprint   fingerprintConfig `config:"fingerprint"`
+
+	// Archive is not user-configurable here, the user-facing option is the
+	// archive input option. normalizeConfig in input.go propagates it here.
+	Archive archiveConfig `config:"-"`
 }
 
 func defaultFileScannerConfig() fileScannerConfig {
@@ -147,6 +151,10 @@ type fileScanner struct {
 
 	// lastCount is the number of unique files the previous scan produced.
 	lastCount int
+
+	// archives holds the archive listings of the previous scan, keyed by
+	// filename, so unchanged archives are not read again.
+	archives map[string]archiveListing
 }
 
 func newFileScanner(logger *logp.Logger, paths []string, config fileScannerConfig, compression string) (*fileScanner, error) {
@@ -267,6 +275,9 @@ func (s *fileScanner) GetFiles(opts loginp.FileScanOptions) loginp.ScanResults {
 	}
 
 	s.lastCount = len(st.fdByName)
+	if s.cfg.Archive.Enabled {
+		s.archives = st.archives
+	}
 	return loginp.ScanResults{
 		Files:        st.fdByName,
 		Metrics:      st.metrics,
@@ -294,6 +305,11 @@ type scanState struct {
 	// uses them to postpone delete detection so a transient failure does not wipe
 	// registry state and re-ingest files.
 	unobservable map[string]struct{}
+	// archives holds the archive listings of this scan, keyed by filename.
+	archives map[string]archiveListing
+	// memberNames maps an archive to the paths of its members recorded
+	// in fdByName.
+	memberNames map[string][]string
 
 	metrics loginp.FileScanMetrics
 }
@@ -308,6 +324,7 @@ func (s *fileScanner) newScanState(opts loginp.FileScanOptions) *scanState {
 		uniqueIDs:    make(map[string]matchedTarget, s.lastCount),
 		uniqueFiles:  make(map[string]struct{}, s.lastCount),
 		unobservable: map[string]struct{}{},
+		archives:     map[string]archiveListing{},
 	}
 }
 
@@ -328,7 +345,7 @@ func (st *scanState) recordUnobservable(path string) {
 // matched filename, used to resolve identity collisions deterministically.
 // Passed to walk as the process callback.
 func (st *scanState) process(filename string, orderIndex int) {
-	s, opts := st.s, st.opts
+	s := st.s
 	st.metrics.FilesMatched++
 
 	// in case multiple globs match on the same file we filter out duplicates
@@ -385,6 +402,19 @@ func (st *scanState) process(filename string, orderIndex int) {
 		return
 	}
 
+	var members []loginp.FileDescriptor
+	if s.cfg.Archive.Enabled {
+		members, err = st.archiveMembers(&it, fd)
+		if err != nil {
+			st.metrics.FilesNoIngestTarget++
+			// The members of an archive that can't be read this scan must
+			// not be considered removed.
+			st.recordUnobservable(filename)
+			s.log.Warnf("cannot read the members of archive %q: %s", filename, err)
+			return
+		}
+	}
+
 	fileID := fd.FileID()
 	if known, exists := st.uniqueIDs[fileID]; exists {
 		st.metrics.FilesNoIngestTarget++
@@ -398,22 +428,57 @@ func (st *scanState) process(filename string, orderIndex int) {
 			return
 		}
 		s.log.Debugf("%q supersedes already matched ingest target %q for the same file", filename, known.name)
-		// the superseded descriptor was already counted as ignored if it
-		// matched the ignore options; take that back so FilesIgnored counts
-		// only the descriptors actually returned
-		if oldFd, ok := st.fdByName[known.name]; ok && isFileIgnored(oldFd, opts) {
-			st.metrics.FilesIgnored--
-		}
-		delete(st.fdByName, known.name)
+		st.drop(known.name)
 	}
 	st.uniqueIDs[fileID] = matchedTarget{name: filename, order: orderIndex}
+
+	if members != nil {
+		names := make([]string, 0, len(members))
+		for _, member := range members {
+			if _, exists := st.fdByName[member.Filename]; exists {
+				continue
+			}
+			names = append(names, member.Filename)
+			st.add(member)
+		}
+		if st.memberNames == nil {
+			st.memberNames = map[string][]string{}
+		}
+		st.memberNames[filename] = names
+		return
+	}
+
 	s.attachBridgingRaw(&fd)
-	st.fdByName[filename] = fd
-	if isFileIgnored(fd, opts) {
+	st.add(fd)
+}
+
+// add records fd in the scan state.
+func (st *scanState) add(fd loginp.FileDescriptor) {
+	st.fdByName[fd.Filename] = fd
+	if isFileIgnored(fd, st.opts) {
 		st.metrics.FilesIgnored++
 	}
 }
 
+// drop removes the descriptor recorded for filename, or the descriptors of
+// its members if it's an archive.
+func (st *scanState) drop(filename string) {
+	names := []string{filename}
+	if members, ok := st.memberNames[filename]; ok {
+		names = members
+		delete(st.memberNames, filename)
+	}
+	for _, name := range names {
+		// the dropped descriptor was already counted as ignored if it
+		// matched the ignore options; take that back so FilesIgnored counts
+		// only the descriptors actually returned
+		if fd, ok := st.fdByName[name]; ok && isFileIgnored(fd, st.opts) {
+			st.metrics.FilesIgnored--
+		}
+		delete(st.fdByName, name)
+	}
+}
+
 // debugLogUnobservable logs a sample of the path prefixes a scan could not
 // observe (permissions or file-descriptor exhaustion). prefixes must be sorted.
 func (s *fileScanner) debugLogUnobservable(prefixes []string) {
@@ -854,7 +919,7 @@ func (s *fileScanner) getIngestTarget(filename string) (it ingestTarget, err err
 //   - dataSize in (offset, offset+length) under non-growing mode: return
 //     errFileTooSmall (today's static-fingerprint behaviour).
 //
-// GZIP is honoured: all reads are on the decompressed stream.
+// Compression is honoured: all reads are on the decompressed stream.
 func (s *fileScanner) toFileDescriptor(it *ingestTarget) (fd loginp.FileDescriptor, err error) {
 	fd.Filename = it.filename
 	fd.Info = it.info
@@ -892,27 +957,27 @@ func (s *fileScanner) toFileDescriptor(it *ingestTarget) (fd loginp.FileDescript
 
 	switch s.compression {
 	case CompressionNone:
-		// fd.GZIP stays false
-	case CompressionGZIP:
-		fd.GZIP = true
+		// fd.Compression stays empty
+	case CompressionGZIP, CompressionZSTD, CompressionBZIP2, CompressionXZ:
+		fd.Compression = s.compression
 	case CompressionAuto:
 		osFile, err := opener.Open()
 		if err != nil {
 			return fd, fmt.Errorf("fileScanner: failed to open %q to create FileDescriptor: %w", it.originalFilename, err)
 		}
 
-		fd.GZIP, err = IsGZIP(osFile)
+		fd.Compression, err = DetectCompression(osFile)
 		if err != nil {
-			return fd, fmt.Errorf("failed to check if %q is gzip: %w",
+			return fd, fmt.Errorf("failed to detect the compression of %q: %w",
 				it.originalFilename, err)
 		}
 	}
 
-	// Fast path for non-GZIP files we know the size from lstat and can
+	// Fast path for non-compressed files we know the size from lstat and can
 	// reject too-small files in static mode without opening the file. This
 	// preserves the no-open guarantee for static fingerprint on
 	// unreadable/permission-denied small files.
-	if !fd.GZIP {
+	if !fd.IsCompressed() {
 		// size <= offset we cannot read anything from the offset, regardless of mode.
 		if it.info.Size() <= offset {
 			return fd, fmt.Errorf(
@@ -926,19 +991,19 @@ func (s *fileScanner) toFileDescriptor(it *ingestTarget) (fd loginp.FileDescript
 		}
 	}
 
-	// Wrap the open file (plain or GZIP) so subsequent reads/seeks operate
+	// Wrap the open file (plain or compressed) so subsequent reads/seeks operate
 	// on the decompressed stream when applicable.
 	var file File
-	if fd.GZIP {
+	if fd.IsCompressed() {
 		osFile, err := opener.Open()
 		if err != nil {
 			return fd, fmt.Errorf("fileScanner: failed to open %q to create FileDescriptor: %w", it.originalFilename, err)
 		}
 
 		// Check if there is enough *decompressed* data for fingerprint
-		file, err = newGzipSeekerReader(osFile, int(threshold))
+		file, err = newCompressedSeekerReader(osFile, fd.Compression, int(threshold))
 		if err != nil {
-			return fd, fmt.Errorf("failed to create gzip seeker: %w", err)
+			return fd, fmt.Errorf("failed to create %s seeker: %w", fd.Compression, err)
 		}
 		defer file.Close()
 	} else {
@@ -210,7 +210,7 @@ func (w *fileWatcher) watch(
 		}
 
 		// srcID is the file identity (harvester ID/registry key), resolved lazily via ensureSrcID:
-		// an unchanged, untracked file (gzip, empty, ignore_older) never needs one, saving allocs.
+		// an unchanged, untracked file (compressed, empty, ignore_older) never needs one, saving allocs.
 		var srcID string
 		ensureSrcID := func() string {
 			if srcID == "" { // getFileIdentity never returns ""
@@ -501,7 +501,7 @@ func (w *fileWatcher) reconcileClosedHarvester(prevDesc *loginp.FileDescriptor,
 
 // tracksHarvesterProgress reports whether a file contributes to the harvester progress metrics.
 func tracksHarvesterProgress(fd *loginp.FileDescriptor, opts loginp.FileScanOptions) bool {
-	return !fd.GZIP && fd.Info.Size() > 0 && !isFileIgnored(*fd, opts)
+	return !fd.IsStatic() && fd.Info.Size() > 0 && !isFileIgnored(*fd, opts)
 }
 
 // isFileIgnored returns true when a file is ignored, no matter the reason.
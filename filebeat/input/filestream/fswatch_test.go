@@ -2232,29 +2232,29 @@ func TestFileWatcherHarvesterMetrics(t *testing.T) {
 
 	now := time.Now()
 	oldModTime := now.Add(-2 * time.Hour)
-	descriptor := func(name string, size int64, modTime time.Time, gzip bool) loginp.FileDescriptor {
+	descriptor := func(name string, size int64, modTime time.Time, compression string) loginp.FileDescriptor {
 		return loginp.FileDescriptor{
 			Filename:    name,
 			Fingerprint: loginp.FingerprintID{Sum: name},
-			GZIP:        gzip,
+			Compression: compression,
 			Info:        file.ExtendFileInfo(&testFileInfo{name: name, size: size, time: modTime}),
 		}
 	}
 	paths := map[string]loginp.FileDescriptor{
-		"complete":  descriptor("complete", 100, now, false),
-		"near":      descriptor("near", 100, now, false),
-		"lagging":   descriptor("lagging", 100, now, false),
-		"no-active": descriptor("no-active", 100, now, false),
-		"gzip":      descriptor("gzip", 100, now, true),
-		"ignored":   descriptor("ignored", 100, oldModTime, false),
+		"complete":  descriptor("complete", 100, now, CompressionNone),
+		"near":      descriptor("near", 100, now, CompressionNone),
+		"lagging":   descriptor("lagging", 100, now, CompressionNone),
+		"no-active": descriptor("no-active", 100, now, CompressionNone),
+		"gzip":      descriptor("gzip", 100, now, CompressionGZIP),
+		"ignored":   descriptor("ignored", 100, oldModTime, CompressionNone),
 	}
 	fw.prev = map[string]loginp.FileDescriptor{
-		"complete":  descriptor("complete", 100, now, false),
-		"near":      descriptor("near", 100, now, false),
-		"lagging":   descriptor("lagging", 100, now, false),
-		"no-active": descriptor("no-active", 100, now, false),
-		"gzip":      descriptor("gzip", 100, now, true),
-		"ignored":   descriptor("ignored", 100, oldModTime, false),
+		"complete":  descriptor("complete", 100, now, CompressionNone),
+		"near":      descriptor("near", 100, now, CompressionNone),
+		"lagging":   descriptor("lagging", 100, now, CompressionNone),
+		"no-active": descriptor("no-active", 100, now, CompressionNone),
+		"gzip":      descriptor("gzip", 100, now, CompressionGZIP),
+		"ignored":   descriptor("ignored", 100, oldModTime, CompressionNone),
 	}
 	fw.scanner = &testFileScanner{files: paths}
 
@@ -2283,7 +2283,7 @@ func TestFileWatcherHarvesterMetrics(t *testing.T) {
 	// Copy paths and 'truncate' one file
 	truncatedPaths := map[string]loginp.FileDescriptor{}
 	maps.Copy(truncatedPaths, paths)
-	truncatedPaths["complete"] = descriptor("complete", 50, now, false)
+	truncatedPaths["complete"] = descriptor("complete", 50, now, CompressionNone)
 	fw.scanner = &testFileScanner{files: truncatedPaths}
 	fw.watch(t.Context(), metrics, time.Hour, time.Time{})
 
@@ -2298,7 +2298,7 @@ func TestFileWatcherHarvesterMetrics(t *testing.T) {
 	// Copy truncatedPaths and make one file older
 	ignoredPaths := map[string]loginp.FileDescriptor{}
 	maps.Copy(ignoredPaths, truncatedPaths)
-	ignoredPaths["near"] = descriptor("near", 100, oldModTime, false)
+	ignoredPaths["near"] = descriptor("near", 100, oldModTime, CompressionNone)
 	fw.scanner = &testFileScanner{files: ignoredPaths}
 	fw.watch(t.Context(), metrics, time.Hour, time.Time{})
 
@@ -181,8 +181,11 @@ func configure(
 
 // normalizeConfig reconciles filestream defaults with file_identity semantics.
 // In 9.x, scanner fingerprinting defaults to enabled, but non-fingerprint
-// identities should turn it off unless the user explicitly sets it.
+// identities should turn it off unless the user explicitly sets it. It also
+// propagates the archive options to the scanner.
 func normalizeConfig(cfg *conf.C, c *config) error {
+	c.FileWatcher.Scanner.Archive = c.Archive
+
 	if c.FileIdentity == nil {
 		c.FileWatcher.Scanner.Fingerprint.Growing = defaultFingerprintIdentityConfig().Growing
 		return nil
@@ -221,7 +224,7 @@ func (inp *filestream) Test(src loginp.Source, ctx input.TestContext) error {
 
 	// Validate the source can be opened and the reader pipeline built. The file
 	// handle is owned here (not by a session), so it must be closed explicitly.
-	f, enc, _, err := inp.openFile(ctx.Logger, fs.newPath, 0)
+	f, enc, _, err := inp.openSource(ctx.Logger, fs, 0)
 	if err != nil {
 		return err
 	}
@@ -484,7 +487,7 @@ func (inp *filestream) buildPipeline(
 
 	r = readfile.NewLimitReader(r, inp.readerConfig.MaxBytes)
 
-	if f.IsGZIP() {
+	if f.IsCompressed() {
 		r = NewEOFLookaheadReader(r, io.EOF)
 	}
 
@@ -539,17 +542,17 @@ func (inp *filestream) openFile(
 	}
 
 	truncated := false
-	// GZIP files are considered static, they're not supposed to change or be
-	// truncated. Also:
+	// Compressed files are considered static, they're not supposed to change
+	// or be truncated. Also:
 	//  - as the offset is tracked on the decompressed data, it's
 	// expected to see offset > fi.Size()
-	//  - it should not start reading GZIP files from the beginning if it
-	//  already started ingesting the file.
-	// The only situation a GZIP file should change is if it's still been
+	//  - it should not start reading compressed files from the beginning if
+	//  it already started ingesting the file.
+	// The only situation a compressed file should change is if it's still been
 	// written to disk when filebeat picks it up. It should only grow, not
 	// shrink.
 	// Therefore, only check truncation for plain files.
-	if !f.IsGZIP() && fi.Size() < offset {
+	if !f.IsCompressed() && fi.Size() < offset {
 		// if the file was truncated we need to reset the offset and notify
 		// all callers so they can also reset their offsets
 		truncated = true
@@ -574,50 +577,100 @@ func (inp *filestream) openFile(
 	return f, enc, truncated, nil
 }
 
+// openSource opens the file of the source fs like openFile does. Archive
+// members are opened from their archive with openArchiveMember.
+func (inp *filestream) openSource(
+	log *logp.Logger,
+	fs fileSource,
+	offset int64,
+) (File, encoding.Encoding, bool, error) {
+	if fs.desc.IsArchiveMember() {
+		f, enc, err := inp.openArchiveMember(fs.desc, offset)
+		return f, enc, false, err
+	}
+	return inp.openFile(log, fs.newPath, offset)
+}
+
+// openArchiveMember opens the archive member described by desc and checks
+// for the encoding. Archive members are static, they're never truncated.
+func (inp *filestream) openArchiveMember(
+	desc loginp.FileDescriptor,
+	offset int64,
+) (File, encoding.Encoding, error) {
+	member := *desc.Member
+	rawFile, err := file.ReadOpen(member.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed opening %s: %w", member.Path, err)
+	}
+
+	f, err := newArchiveMemberReader(rawFile, desc.Compression, member)
+	if err != nil {
+		rawFile.Close()
+		return nil, nil, fmt.Errorf(
+			"failed to open member %q of %s archive %s: %w", member.Name, member.Format, member.Path, err)
+	}
+
+	ok := false
+	defer cleanup.IfNot(&ok, cleanup.IgnoreError(f.Close))
+
+	err = inp.initFileOffset(f, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc, err := inp.encodingFactory(f)
+	if err != nil {
+		if errors.Is(err, transform.ErrShortSrc) {
+			return nil, nil, fmt.Errorf("initialising encoding for '%v' failed due to file being too short", f)
+		}
+		return nil, nil, fmt.Errorf("initialising encoding for '%v' failed: %w", f, err)
+	}
+
+	ok = true // no need to close the file
+	return f, enc, nil
+}
+
 // newFile wraps the given os.File into an appropriate File interface implementation.
 //
 // The behavior depends on the compression setting:
 //   - "" (none): returns a plain file reader (plainFile)
-//   - "gzip": always creates a gzipSeekerReader (errors if file is not gzip)
-//   - "auto": auto-detects gzip files; returns gzipSeekerReader for gzip files,
-//     plainFile otherwise
+//   - "gzip", "zstd", "bzip2", "xz": always creates a compressedSeekerReader
+//     for that format (errors if the file is not in that format)
+//   - "auto": auto-detects compressed files; returns compressedSeekerReader
+//     for compressed files, plainFile otherwise
 //
 // It returns an error if any happens.
 func (inp *filestream) newFile(rawFile *os.File) (File, error) {
-	switch inp.compression {
+	compression := inp.compression
+	switch compression {
 	case CompressionNone:
 		return newPlainFile(rawFile), nil
 
-	case CompressionGZIP:
-		f, err := newGzipSeekerReader(rawFile, inp.readerConfig.BufferSize)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"failed to create gzip reader for %s: %w", rawFile.Name(), err)
-		}
-		return f, nil
+	case CompressionGZIP, CompressionZSTD, CompressionBZIP2, CompressionXZ:
 
 	case CompressionAuto:
-		isGZIP, err := IsGZIP(rawFile)
+		var err error
+		compression, err = DetectCompression(rawFile)
 		if err != nil {
 			return nil, fmt.Errorf(
-				"gzip detection error on %s: %w", rawFile.Name(), err)
+				"compression detection error on %s: %w", rawFile.Name(), err)
 		}
 
-		if !isGZIP {
+		if compression == CompressionNone {
 			return newPlainFile(rawFile), nil
 		}
 
-		f, err := newGzipSeekerReader(rawFile, inp.readerConfig.BufferSize)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"failed to create gzip reader for %s: %w", rawFile.Name(), err)
-		}
-		return f, nil
-
 	default:
 		// This should not happen as validation catches invalid values
 		return nil, fmt.Errorf("invalid compression mode: %q", inp.compression)
 	}
+
+	f, err := newCompressedSeekerReader(rawFile, compression, inp.readerConfig.BufferSize)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to create %s reader for %s: %w", compression, rawFile.Name(), err)
+	}
+	return f, nil
 }
 
 func checkFileBeforeOpening(fi os.FileInfo) error {
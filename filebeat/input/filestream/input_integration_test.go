@@ -1507,3 +1507,92 @@ func TestHarvesterGZIP(t *testing.T) {
 	cancelInput()
 	env.waitUntilInputStops()
 }
+
+// TestHarvesterCompressed verifies zstd, bzip2 and xz files are detected and
+// read once to EOF like gzip files.
+func TestHarvesterCompressed(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "log.log"))
+	require.NoError(t, err)
+	want := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	for _, compression := range []string{CompressionZSTD, CompressionBZIP2, CompressionXZ} {
+		t.Run(compression, func(t *testing.T) {
+			env := newInputTestingEnvironment(t)
+
+			logName := "data.log." + compression
+			id := "harvester-" + compression + "-" + uuid.Must(uuid.NewV4()).String()
+			cfg := map[string]any{
+				"id":                                id,
+				"paths":                             []string{env.abspath(logName) + "*"},
+				"prospector.scanner.check_interval": "10ms",
+				"prospector.scanner.fingerprint": map[string]any{
+					"enabled": true,
+					"offset":  0,
+					"length":  64,
+				},
+				"compression": "auto",
+			}
+			inp := env.mustCreateInput(cfg)
+			env.mustWriteToFile(logName, newCompressedDataSource(t, compression))
+
+			ctx, cancelInput := context.WithCancel(context.Background())
+			env.startInput(ctx, id, inp)
+
+			env.waitUntilEventCount(len(want))
+			env.requireEventsReceived(want)
+
+			cancelInput()
+			env.waitUntilInputStops()
+		})
+	}
+}
+
+func TestHarvesterArchiveMembers(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	id := "harvester-archive-" + uuid.Must(uuid.NewV4()).String()
+	cfg := map[string]any{
+		"id":                                id,
+		"paths":                             []string{env.abspath("bundle.*")},
+		"prospector.scanner.check_interval": "10ms",
+		"prospector.scanner.fingerprint": map[string]any{
+			"enabled": true,
+			"offset":  0,
+			"length":  64,
+		},
+		"compression":     "auto",
+		"archive.enabled": true,
+		"archive.members": []string{"*.log"},
+	}
+	inp := env.mustCreateInput(cfg)
+	env.mustWriteToFile("bundle.tar.gz", newTestArchive(t, ArchiveTar, CompressionGZIP))
+	env.mustWriteToFile("bundle.zip", newTestArchive(t, ArchiveZip, CompressionNone))
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, id, inp)
+
+	// 2 lines from app.log and 3 lines from db.log in each archive
+	env.waitUntilEventCount(10)
+
+	paths := map[string]int{}
+	for _, c := range env.pipeline.clients {
+		for _, evt := range c.GetEvents() {
+			p, err := evt.Fields.GetValue("log.file.path")
+			require.NoError(t, err)
+			//nolint:errcheck // It's a test, we can force the type cast
+			paths[p.(string)]++
+		}
+	}
+	require.Equal(t, map[string]int{
+		filepath.Join(env.abspath("bundle.tar.gz"), "logs", "app.log"):      2,
+		filepath.Join(env.abspath("bundle.tar.gz"), "logs", "db", "db.log"): 3,
+		filepath.Join(env.abspath("bundle.zip"), "logs", "app.log"):         2,
+		filepath.Join(env.abspath("bundle.zip"), "logs", "db", "db.log"):    3,
+	}, paths)
+
+	cancelInput()
+	env.waitUntilInputStops()
+
+	// one registry entry per harvested member
+	env.requireRegistryEntryCount(4)
+}
@@ -430,7 +430,7 @@ func TestNewFile(t *testing.T) {
 		"compression_gzip_with_gzip_file_returns_gzip_reader": {
 			compression:  CompressionGZIP,
 			filePath:     gzippedFilePath,
-			expectedType: &gzipSeekerReader{},
+			expectedType: &compressedSeekerReader{},
 		},
 		"compression_gzip_with_plain_file_returns_error": {
 			compression:   CompressionGZIP,
@@ -446,21 +446,21 @@ func TestNewFile(t *testing.T) {
 		"compression_auto_with_gzip_file_returns_gzip_reader": {
 			compression:  CompressionAuto,
 			filePath:     gzippedFilePath,
-			expectedType: &gzipSeekerReader{},
+			expectedType: &compressedSeekerReader{},
 		},
 		"compression_auto_with_unreadable_file_returns_error": {
 			compression: CompressionAuto,
 			filePath:    plainFilePath, // content doesn't matter
 			setup: func(t *testing.T, filePath string) *os.File {
 				// Return a file that is already closed to trigger a read error
-				// in IsGZIP
+				// in DetectCompression
 				f, err := os.Open(filePath)
 				require.NoError(t, err)
 				f.Close()
 				return f
 			},
 			expectError:   true,
-			errorContains: "gzip detection error",
+			errorContains: "compression detection error",
 		},
 	}
 
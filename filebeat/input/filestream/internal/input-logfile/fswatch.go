@@ -160,8 +160,13 @@ type FileDescriptor struct {
 	// Fingerprint is the file-identity material for the "fingerprint" identity.
 	// It is the zero value when fingerprinting is disabled or produced nothing.
 	Fingerprint FingerprintID
-	// GZIP indicates if the file is compressed with GZIP.
-	GZIP bool
+	// Compression is the compression format of the file, like "gzip", or
+	// empty if the file isn't compressed. For an archive member it's the
+	// compression format of the archive.
+	Compression string
+	// Member describes the archive the file is stored in. It's nil unless
+	// the file is an archive member.
+	Member *ArchiveMember
 
 	// bytesIngested is the number of bytes already ingested by the harvester for this file.
 	bytesIngested int64
@@ -170,6 +175,23 @@ type FileDescriptor struct {
 	bytesIngestedSet bool
 }
 
+// IsCompressed reports whether the file is compressed.
+func (fd *FileDescriptor) IsCompressed() bool {
+	return fd.Compression != ""
+}
+
+// IsArchiveMember reports whether the file is stored in an archive.
+func (fd *FileDescriptor) IsArchiveMember() bool {
+	return fd.Member != nil
+}
+
+// IsStatic reports whether the file is not expected to change once it's
+// written. Compressed files and archive members are static, their offsets
+// are tracked on the decompressed data and they're read once up to EOF.
+func (fd *FileDescriptor) IsStatic() bool {
+	return fd.IsCompressed() || fd.IsArchiveMember()
+}
+
 // SetBytesIngested allows for setting a size that is different than the one in Info
 func (fd *FileDescriptor) SetBytesIngested(s int64) {
 	fd.bytesIngested = s
@@ -224,6 +246,16 @@ func SameFile(prev, current *FileDescriptor) bool {
 	return prev.Fingerprint.Continues(current.Fingerprint)
 }
 
+// ArchiveMember identifies a file stored in an archive.
+type ArchiveMember struct {
+	// Path is the path of the archive.
+	Path string
+	// Format is the format of the archive, like "tar" or "zip".
+	Format string
+	// Name is the path of the file within the archive.
+	Name string
+}
+
 // FSEvent returns information about file system changes.
 type FSEvent struct {
 	// NewPath is the new path of the file.
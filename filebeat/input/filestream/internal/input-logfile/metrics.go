@@ -30,7 +30,7 @@ import (
 
 // Metrics defines a set of metrics for the filestream input.
 type Metrics struct {
-	// Total metrics: plain and GZIP files
+	// Total metrics: plain and compressed files
 	FilesOpened       *monitoring.Uint // Number of files that have been opened.
 	FilesClosed       *monitoring.Uint // Number of files closed.
 	FilesActive       *monitoring.Uint // Number of files currently open (gauge).
@@ -53,7 +53,7 @@ type Metrics struct {
 	ProcessingGZIPTime    metrics.Sample   // Histogram of the elapsed time for processing an event.
 
 	// Those metrics use the same registry/keys as the log input uses
-	// Total metrics: plain and GZIP files
+	// Total metrics: plain and compressed files
 	HarvesterStarted   *monitoring.Int
 	HarvesterClosed    *monitoring.Int
 	HarvesterRunning   *monitoring.Int
@@ -58,7 +58,7 @@ type harvestSession struct {
 	state      state
 	readOffset int64
 
-	done          bool      // terminal reached at open (e.g. GZIP already at EOF)
+	done          bool      // terminal reached at open (e.g. compressed file already at EOF)
 	closed        bool      // Close has been called
 	pendingDelete bool      // a worker must delete the file on the next slice
 	openedAt      time.Time // when the session was opened; for close.reader.after_interval
@@ -66,7 +66,7 @@ type harvestSession struct {
 
 	// metricsOffset, when non-nil, is the shared atomic the harvester ingestion
 	// progress metrics read from; updated as ReadSlice publishes messages.
-	// cleanupMetricsOffset removes it on Close. Both are nil for GZIP sources:
+	// cleanupMetricsOffset removes it on Close. Both are nil for compressed sources:
 	// their progress can't be represented by a plain offset/size comparison.
 	metricsOffset        *atomic.Int64
 	cleanupMetricsOffset func()
@@ -103,13 +103,13 @@ func (inp *filestream) OpenSession(
 	}
 
 	if st.EOF {
-		log.Debugf("GZIP file already read to EOF, not reading it again, file name '%s'",
+		log.Debugf("Compressed file already read to EOF, not reading it again, file name '%s'",
 			fs.newPath)
 		s.done = true
 		return s, nil
 	}
 
-	f, enc, truncated, err := inp.openFile(log, fs.newPath, st.Offset)
+	f, enc, truncated, err := inp.openSource(log, fs, st.Offset)
 	if err != nil {
 		log.Errorf("File could not be opened for reading: %v", err)
 		return nil, err
@@ -121,7 +121,7 @@ func (inp *filestream) OpenSession(
 	s.enc = enc
 	s.readOffset = s.state.Offset
 
-	if !fs.desc.GZIP {
+	if !fs.desc.IsStatic() {
 		s.metricsOffset, s.cleanupMetricsOffset = metrics.RegisterHarvesterOffset(id, s.state.Offset)
 	}
 
@@ -151,7 +151,7 @@ func (s *harvestSession) ReadSlice(
 		return loginp.SliceDone, nil
 	}
 
-	isGZIP := s.src.desc.GZIP
+	isGZIP := s.src.desc.Compression == CompressionGZIP
 
 	// Position the file at the last published offset (undoing any read-ahead
 	// from the previous slice) and build a fresh non-blocking pipeline for this
@@ -190,7 +190,7 @@ func (s *harvestSession) ReadSlice(
 				s.log.Debugf("End of file reached: %s; Backoff now.", s.src.newPath)
 				return loginp.SliceYield, nil
 			case errors.Is(err, io.EOF):
-				// EOF only reaches here for closeable files (close_eof, GZIP,
+				// EOF only reaches here for closeable files (close_eof, compressed,
 				// archived); tailing files yield via ErrWouldBlock instead.
 				s.log.Debugf("EOF has been reached. Closing. Path='%s'", s.src.newPath)
 				if s.inp.deleterConfig.Enabled {
@@ -258,7 +258,7 @@ func (s *harvestSession) ReadSlice(
 			_ = mapstr.AddTags(message.Fields, []string{"take_over"})
 		}
 
-		if isGZIP {
+		if s.src.desc.IsStatic() {
 			if perr, ok := (message.Private).(error); ok && errors.Is(perr, io.EOF) {
 				s.state.EOF = true
 			}
@@ -311,7 +311,12 @@ func (s *harvestSession) Poll() loginp.PollResult {
 		return loginp.PollPark
 	}
 
-	if closer.Renamed && !isSameFile(s.src.newPath, fi) {
+	// An archive member is renamed with its archive.
+	diskPath := s.src.newPath
+	if s.src.desc.IsArchiveMember() {
+		diskPath = s.src.desc.Member.Path
+	}
+	if closer.Renamed && !isSameFile(diskPath, fi) {
 		s.log.Debugf("close.on_state_change.renamed and file %s has been renamed", s.src.newPath)
 		return loginp.PollClose
 	}
@@ -324,9 +329,10 @@ func (s *harvestSession) Poll() loginp.PollResult {
 		return loginp.PollClose
 	}
 
-	// GZIP offsets are tracked on the decompressed stream, so a size comparison
-	// is invalid; resume until the session reads to EOF (SliceDone).
-	if s.src.desc.GZIP || fi.Size() != s.readOffset {
+	// Compressed file and archive member offsets are tracked on the
+	// decompressed stream, so a size comparison is invalid; resume until the
+	// session reads to EOF (SliceDone).
+	if s.src.desc.IsStatic() || fi.Size() != s.readOffset {
 		return loginp.PollResume
 	}
 
@@ -348,7 +354,7 @@ func (s *harvestSession) Poll() loginp.PollResult {
 func (s *harvestSession) Offset() int64 { return s.state.Offset }
 
 // IsGZIP reports whether the session reads a GZIP-compressed source.
-func (s *harvestSession) IsGZIP() bool { return s.src.desc.GZIP }
+func (s *harvestSession) IsGZIP() bool { return s.src.desc.Compression == CompressionGZIP }
 
 // Close releases the file handle held by the session.
 func (s *harvestSession) Close() error {
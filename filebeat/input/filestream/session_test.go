@@ -145,7 +145,7 @@ func TestHarvestSession_ReadSlice(t *testing.T) {
 		// Mark the source as GZIP. buildPipeline branches on the file's detected
 		// compression, not on this flag, so a plain-text body still reads while
 		// the GZIP metric counters are exercised.
-		s.src.desc.GZIP = true
+		s.src.desc.Compression = CompressionGZIP
 		pub := &countingPublisher{}
 
 		verdict, err := s.ReadSlice(backgroundCtx(), pub)
@@ -354,7 +354,7 @@ func TestFilestream_OpenSession_HarvesterOffsetMetric(t *testing.T) {
 		require.NoError(t, err)
 		inp := testFilestream(t, closerConfig{})
 		metrics := testMetrics(t)
-		src := fileSource{newPath: path, fileID: "id", desc: loginp.FileDescriptor{GZIP: true, Info: file.ExtendFileInfo(fi)}}
+		src := fileSource{newPath: path, fileID: "id", desc: loginp.FileDescriptor{Compression: CompressionGZIP, Info: file.ExtendFileInfo(fi)}}
 
 		sess, err := inp.OpenSession(backgroundCtx(), src, "gzip-id", loginp.NewCursorForTest("id", 0, 0), metrics)
 		require.NoError(t, err)
@@ -535,10 +535,10 @@ func (f *fakeFile) Seek(int64, int) (int64, error) {
 	}
 	return 0, nil
 }
-func (f *fakeFile) Close() error     { return nil }
-func (f *fakeFile) Name() string     { return "fake" }
-func (f *fakeFile) OSFile() *os.File { return nil }
-func (f *fakeFile) IsGZIP() bool     { return false }
+func (f *fakeFile) Close() error       { return nil }
+func (f *fakeFile) Name() string       { return "fake" }
+func (f *fakeFile) OSFile() *os.File   { return nil }
+func (f *fakeFile) IsCompressed() bool { return false }
 
 // fakeFile must satisfy the File interface.
 var _ File = (*fakeFile)(nil)
@@ -29,37 +29,40 @@ import (
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/monitoring"
 	"github.com/elastic/elastic-agent-libs/monitoring/adapter"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
 	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
 	"github.com/elastic/sarama"
 )
 
 type kafkaInputConfig struct {
 	// Kafka hosts with port, e.g. "localhost:9092"
-	Hosts                    []string          `config:"hosts" validate:"required"`
-	Topics                   []string          `config:"topics" validate:"required"`
-	GroupID                  string            `config:"group_id" validate:"required"`
-	GroupInstanceID          string            `config:"group_instance_id"`
-	ClientID                 string            `config:"client_id"`
-	Version                  kafka.Version     `config:"version"`
-	InitialOffset            initialOffset     `config:"initial_offset"`
-	ConnectBackoff           time.Duration     `config:"connect_backoff" validate:"min=0"`
-	ConsumeBackoff           time.Duration     `config:"consume_backoff" validate:"min=0"`
-	WaitClose                time.Duration     `config:"wait_close" validate:"min=0"`
-	MaxWaitTime              time.Duration     `config:"max_wait_time"`
-	IsolationLevel           isolationLevel    `config:"isolation_level"`
-	SessionTimeout           time.Duration     `config:"session_timeout" validate:"min=1"`
-	HeartbeatInterval        time.Duration     `config:"heartbeat_interval" validate:"min=1"`
-	Timeout                  time.Duration     `config:"timeout" validate:"min=1"`
-	KeepAlive                time.Duration     `config:"keep_alive" validate:"min=0"`
-	Fetch                    kafkaFetch        `config:"fetch"`
-	Rebalance                kafkaRebalance    `config:"rebalance"`
-	TLS                      *tlscommon.Config `config:"ssl"`
-	Kerberos                 *kerberos.Config  `config:"kerberos"`
-	Username                 string            `config:"username"`
-	Password                 string            `config:"password"`
-	Sasl                     kafka.SaslConfig  `config:"sasl"`
-	ExpandEventListFromField string            `config:"expand_event_list_from_field"`
-	Parsers                  parser.Config     `config:",inline"`
+	Hosts                    []string             `config:"hosts" validate:"required"`
+	Topics                   []string             `config:"topics" validate:"required"`
+	GroupID                  string               `config:"group_id" validate:"required"`
+	GroupInstanceID          string               `config:"group_instance_id"`
+	ClientID                 string               `config:"client_id"`
+	Version                  kafka.Version        `config:"version"`
+	InitialOffset            initialOffset        `config:"initial_offset"`
+	ResetOffset              resetOffsetConfig    `config:"reset_offset"`
+	ConnectBackoff           time.Duration        `config:"connect_backoff" validate:"min=0"`
+	ConsumeBackoff           time.Duration        `config:"consume_backoff" validate:"min=0"`
+	WaitClose                time.Duration        `config:"wait_close" validate:"min=0"`
+	MaxWaitTime              time.Duration        `config:"max_wait_time"`
+	IsolationLevel           isolationLevel       `config:"isolation_level"`
+	SessionTimeout           time.Duration        `config:"session_timeout" validate:"min=1"`
+	HeartbeatInterval        time.Duration        `config:"heartbeat_interval" validate:"min=1"`
+	Timeout                  time.Duration        `config:"timeout" validate:"min=1"`
+	KeepAlive                time.Duration        `config:"keep_alive" validate:"min=0"`
+	Fetch                    kafkaFetch           `config:"fetch"`
+	Rebalance                kafkaRebalance       `config:"rebalance"`
+	TLS                      *tlscommon.Config    `config:"ssl"`
+	Kerberos                 *kerberos.Config     `config:"kerberos"`
+	Username                 string               `config:"username"`
+	Password                 string               `config:"password"`
+	Sasl                     kafka.SaslConfig     `config:"sasl"`
+	ExpandEventListFromField string               `config:"expand_event_list_from_field"`
+	SchemaRegistry           schemaRegistryConfig `config:"schema_registry"`
+	Parsers                  parser.Config        `config:",inline"`
 }
 
 type kafkaFetch struct {
@@ -75,6 +78,15 @@ type kafkaRebalance struct {
 	RetryBackoff time.Duration     `config:"retry_backoff" validate:"min=0"`
 }
 
+// resetOffsetConfig configures the reset of the committed offsets of the
+// consumer group when the input starts.
+type resetOffsetConfig struct {
+	To resetOffset `config:"to"`
+	// Timestamp is the RFC 3339 timestamp the offsets are reset to when To
+	// is resetOffsetTimestamp.
+	Timestamp string `config:"timestamp"`
+}
+
 type initialOffset int
 
 const (
@@ -82,6 +94,15 @@ const (
 	initialOffsetNewest
 )
 
+type resetOffset int
+
+const (
+	resetOffsetNone resetOffset = iota
+	resetOffsetOldest
+	resetOffsetNewest
+	resetOffsetTimestamp
+)
+
 type rebalanceStrategy int
 
 const (
@@ -101,6 +122,12 @@ var (
 		"oldest": initialOffsetOldest,
 		"newest": initialOffsetNewest,
 	}
+	resetOffsets = map[string]resetOffset{
+		"none":      resetOffsetNone,
+		"oldest":    resetOffsetOldest,
+		"newest":    resetOffsetNewest,
+		"timestamp": resetOffsetTimestamp,
+	}
 	rebalanceStrategies = map[string]rebalanceStrategy{
 		"range":      rebalanceStrategyRange,
 		"roundrobin": rebalanceStrategyRoundRobin,
@@ -140,6 +167,9 @@ func defaultConfig() kafkaInputConfig {
 			MaxRetries:   4,
 			RetryBackoff: 2 * time.Second,
 		},
+		SchemaRegistry: schemaRegistryConfig{
+			Transport: httpcommon.DefaultHTTPTransportSettings(),
+		},
 	}
 }
 
@@ -156,9 +186,53 @@ func (c *kafkaInputConfig) Validate() error {
 	if c.Username != "" && c.Password == "" {
 		return fmt.Errorf("password must be set when username is configured")
 	}
+
+	if c.SchemaRegistry.URL != "" && c.ExpandEventListFromField != "" {
+		return errors.New("schema_registry.url and expand_event_list_from_field cannot be used together")
+	}
+
+	if c.ResetOffset.To == resetOffsetTimestamp && c.Version < kafka.Version("0.10.1") {
+		return errors.New("reset_offset.to 'timestamp' is not supported for kafka versions < 0.10.1")
+	}
+	return c.ResetOffset.Validate()
+}
+
+// Validate validates the reset_offset config.
+func (c *resetOffsetConfig) Validate() error {
+	if c.To != resetOffsetTimestamp {
+		if c.Timestamp != "" {
+			return errors.New("reset_offset.timestamp can only be set when reset_offset.to is 'timestamp'")
+		}
+		return nil
+	}
+	if c.Timestamp == "" {
+		return errors.New("reset_offset.timestamp is required when reset_offset.to is 'timestamp'")
+	}
+	if _, err := c.time(); err != nil {
+		return fmt.Errorf("invalid reset_offset.timestamp: %w", err)
+	}
 	return nil
 }
 
+func (c *resetOffsetConfig) time() (time.Time, error) {
+	return time.Parse(time.RFC3339, c.Timestamp)
+}
+
+// target returns the time argument of sarama.Client.GetOffset that resolves
+// the offsets the partitions are reset to.
+func (c *resetOffsetConfig) target() int64 {
+	switch c.To {
+	case resetOffsetOldest:
+		return sarama.OffsetOldest
+	case resetOffsetNewest:
+		return sarama.OffsetNewest
+	default:
+		// Validate ensures the timestamp is valid.
+		ts, _ := c.time()
+		return ts.UnixMilli()
+	}
+}
+
 func newSaramaConfig(config kafkaInputConfig, logger *logp.Logger) (*sarama.Config, error) {
 	k := sarama.NewConfig()
 
@@ -266,6 +340,16 @@ func (off *initialOffset) Unpack(value string) error {
 	return nil
 }
 
+// Unpack validates and unpack the "reset_offset.to" config option
+func (off *resetOffset) Unpack(value string) error {
+	resetOffset, ok := resetOffsets[value]
+	if !ok {
+		return fmt.Errorf("invalid reset offset '%s'", value)
+	}
+	*off = resetOffset
+	return nil
+}
+
 func (st rebalanceStrategy) asSaramaStrategy() sarama.BalanceStrategy {
 	return map[rebalanceStrategy]sarama.BalanceStrategy{
 		rebalanceStrategyRange:      sarama.NewBalanceStrategyRange(),
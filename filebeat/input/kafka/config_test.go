@@ -25,7 +25,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
 )
 
 // TestNewSaramaConfigDefaults verifies that the default input config maps the
@@ -119,3 +121,48 @@ func TestNewSaramaConfigGroupInstanceIDInvalid(t *testing.T) {
 		})
 	}
 }
+
+// TestSchemaRegistryConfig verifies the validation of the schema_registry
+// options.
+func TestSchemaRegistryConfig(t *testing.T) {
+	tests := map[string]struct {
+		config  mapstr.M
+		wantErr string
+	}{
+		"valid": {
+			config: mapstr.M{"schema_registry.url": "https://registry:8081"},
+		},
+		"invalid scheme": {
+			config:  mapstr.M{"schema_registry.url": "registry:8081"},
+			wantErr: "schema_registry.url must be an http or https URL",
+		},
+		"username without password": {
+			config:  mapstr.M{"schema_registry.url": "http://registry:8081", "schema_registry.username": "beats"},
+			wantErr: "schema_registry.password must be set",
+		},
+		"expand_event_list_from_field": {
+			config:  mapstr.M{"schema_registry.url": "http://registry:8081", "expand_event_list_from_field": "records"},
+			wantErr: "cannot be used together",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := conf.MustNewConfigFrom(mapstr.M{
+				"hosts":    "localhost:9092",
+				"topics":   "messages",
+				"group_id": "filebeat",
+			})
+			require.NoError(t, cfg.Merge(tc.config))
+
+			config := defaultConfig()
+			err := cfg.Unpack(&config)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 90*time.Second, config.SchemaRegistry.Transport.Timeout)
+		})
+	}
+}
@@ -78,7 +78,9 @@ func NewInput(config kafkaInputConfig, saramaConfig *sarama.Config) (*kafkaInput
 type kafkaInput struct {
 	config          kafkaInputConfig
 	saramaConfig    *sarama.Config
-	saramaWaitGroup sync.WaitGroup // indicates a sarama consumer group is active
+	schemaRegistry  *schemaRegistry // decodes the message values if schema_registry is configured
+	saramaWaitGroup sync.WaitGroup  // indicates a sarama consumer group is active
+	offsetsReset    atomic.Bool     // indicates the offsets configured by reset_offset were reset
 }
 
 func (input *kafkaInput) Name() string { return pluginName }
@@ -128,6 +130,15 @@ func (input *kafkaInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
 	log.Info("Starting Kafka input")
 	defer log.Info("Kafka input stopped")
 
+	metrics := newInputMetrics(ctx.MetricsRegistry)
+
+	if input.config.SchemaRegistry.URL != "" {
+		input.schemaRegistry, err = newSchemaRegistry(input.config.SchemaRegistry, log)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Sarama uses standard go contexts to control cancellation, so we need
 	// to wrap our input context channel in that interface.
 	goContext := doneChannelContext(ctx)
@@ -159,7 +170,7 @@ func (input *kafkaInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
 		// In an ideal run, this function never returns until shutdown; if it
 		// does, it means the errors have been logged and the consumer group
 		// has been closed, so we try creating a new one in the next iteration.
-		input.runConsumerGroup(log, client, goContext, consumerGroup)
+		input.runConsumerGroup(log, client, metrics, goContext, consumerGroup)
 	}
 
 	if errors.Is(ctx.Cancelation.Err(), context.Canceled) {
@@ -186,15 +197,23 @@ func (input *kafkaInput) Wait() {
 	input.saramaWaitGroup.Wait()
 }
 
-func (input *kafkaInput) runConsumerGroup(log *logp.Logger, client beat.Client, context context.Context, consumerGroup sarama.ConsumerGroup) {
+func (input *kafkaInput) runConsumerGroup(log *logp.Logger, client beat.Client, metrics *inputMetrics, context context.Context, consumerGroup sarama.ConsumerGroup) {
 	handler := &groupHandler{
 		version: input.config.Version,
 		client:  client,
 		parsers: input.config.Parsers,
 		// expandEventListFromField will be assigned the configuration option expand_event_list_from_field
 		expandEventListFromField: input.config.ExpandEventListFromField,
+		schemaRegistry:           input.schemaRegistry,
+		targetField:              input.config.SchemaRegistry.TargetField,
+		metrics:                  metrics,
 		log:                      log,
 	}
+	if input.config.ResetOffset.To != resetOffsetNone && !input.offsetsReset.Load() {
+		handler.resetOffsets = func(session sarama.ConsumerGroupSession) error {
+			return input.resetOffsets(log, session)
+		}
+	}
 
 	input.saramaWaitGroup.Add(1)
 	defer func() {
@@ -215,6 +234,27 @@ func (input *kafkaInput) runConsumerGroup(log *logp.Logger, client beat.Client,
 	}
 }
 
+// resetOffsets resets the offsets of the partitions claimed in the first
+// session of the consumer group, as configured by reset_offset. The offsets
+// aren't reset again when the consumer group reconnects or rebalances.
+func (input *kafkaInput) resetOffsets(log *logp.Logger, session sarama.ConsumerGroupSession) error {
+	if input.offsetsReset.Load() {
+		return nil
+	}
+
+	client, err := sarama.NewClient(input.config.Hosts, input.saramaConfig)
+	if err != nil {
+		return fmt.Errorf("could not connect to reset offsets: %w", err)
+	}
+	defer client.Close()
+
+	if err := resetClaimedOffsets(log, session, client, input.config.ResetOffset); err != nil {
+		return err
+	}
+	input.offsetsReset.Store(true)
+	return nil
+}
+
 // The metadata attached to incoming events, so they can be ACKed once they've
 // been successfully sent.
 type eventMeta struct {
@@ -289,10 +329,24 @@ type groupHandler struct {
 	// if the fileset using this input expects to receive multiple messages bundled under a specific field then this value is assigned
 	// ex. in this case are the azure fielsets where the events are found under the json object "records"
 	expandEventListFromField string // TODO
-	log                      *logp.Logger
+	// schemaRegistry, if set, decodes the message values, which are written
+	// to targetField.
+	schemaRegistry *schemaRegistry
+	targetField    string
+	metrics        *inputMetrics
+	// resetOffsets, if set, resets the offsets of the claimed partitions
+	// when the session starts.
+	resetOffsets func(sarama.ConsumerGroupSession) error
+	log          *logp.Logger
 }
 
 func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	if h.resetOffsets != nil {
+		if err := h.resetOffsets(session); err != nil {
+			return err
+		}
+	}
+
 	h.Lock()
 	h.session = session
 	h.Unlock()
@@ -317,7 +371,11 @@ func (h *groupHandler) ack(message *sarama.ConsumerMessage) {
 }
 
 func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	reader := h.createReader(claim)
+	metrics := h.metrics.claim(claim.Topic(), claim.Partition(), claim.InitialOffset())
+	defer h.metrics.release(claim.Topic(), claim.Partition())
+	metrics.fetched(claim.HighWaterMarkOffset())
+
+	reader := h.createReader(claim, metrics)
 	parser := h.parsers.Create(reader, h.log)
 	for h.session.Context().Err() == nil {
 		message, err := parser.Next()
@@ -337,11 +395,12 @@ func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim s
 	return nil
 }
 
-func (h *groupHandler) createReader(claim sarama.ConsumerGroupClaim) reader.Reader {
+func (h *groupHandler) createReader(claim sarama.ConsumerGroupClaim, metrics *partitionMetrics) reader.Reader {
 	if h.expandEventListFromField != "" {
 		return &listFromFieldReader{
 			claim:        claim,
 			groupHandler: h,
+			metrics:      metrics,
 			field:        h.expandEventListFromField,
 			log:          h.log,
 		}
@@ -349,6 +408,7 @@ func (h *groupHandler) createReader(claim sarama.ConsumerGroupClaim) reader.Read
 	return &recordReader{
 		claim:        claim,
 		groupHandler: h,
+		metrics:      metrics,
 		log:          h.log,
 	}
 }
@@ -389,6 +449,7 @@ type recordReader struct {
 
 	claim        sarama.ConsumerGroupClaim
 	groupHandler *groupHandler
+	metrics      *partitionMetrics
 	log          *logp.Logger
 }
 
@@ -405,11 +466,49 @@ func (m *recordReader) Next() (reader.Message, error) {
 		return reader.Message{}, io.EOF
 	}
 
+	m.metrics.fetched(m.claim.HighWaterMarkOffset())
+
 	timestamp, kafkaFields := composeEventMetadata(m.claim, m.groupHandler, msg)
 	ackHandler := func() {
 		m.groupHandler.ack(msg)
+		m.metrics.acked(msg.Offset)
+	}
+	message := composeMessage(timestamp, msg.Value, kafkaFields, ackHandler)
+	if m.groupHandler.schemaRegistry != nil {
+		m.groupHandler.decodeValue(m.claim.Topic(), &message)
+	}
+	return message, nil
+}
+
+// decodeValue replaces the raw value of message with the value decoded with
+// its schema from the schema registry. The content of the message becomes the
+// JSON encoding of the decoded value, so it can still be handled by parsers.
+// If the value can't be decoded, the raw value is kept and the error is added
+// to the event.
+func (h *groupHandler) decodeValue(topic string, message *reader.Message) {
+	value, err := h.schemaRegistry.decode(message.Content)
+	if err != nil {
+		h.metrics.decodeFailed(topic)
+		h.log.Errorw("Failed to decode Kafka message", "topic", topic, "error", err)
+		_, _ = message.Fields.Put("error.message", err.Error())
+		return
+	}
+	h.metrics.decoded(topic)
+
+	if content, err := json.Marshal(value); err == nil {
+		message.Content = content
+	}
+	delete(message.Fields, "message")
+
+	record, isRecord := value.(mapstr.M)
+	switch {
+	case h.targetField != "":
+		_, _ = message.Fields.Put(h.targetField, value)
+	case isRecord:
+		message.Fields.DeepUpdate(record)
+	default:
+		message.Fields["message"] = value
 	}
-	return composeMessage(timestamp, msg.Value, kafkaFields, ackHandler), nil
 }
 
 type listFromFieldReader struct {
@@ -417,6 +516,7 @@ type listFromFieldReader struct {
 
 	claim        sarama.ConsumerGroupClaim
 	groupHandler *groupHandler
+	metrics      *partitionMetrics
 	buffer       []reader.Message
 	field        string
 	log          *logp.Logger
@@ -439,6 +539,8 @@ func (l *listFromFieldReader) Next() (reader.Message, error) {
 		return reader.Message{}, io.EOF
 	}
 
+	l.metrics.fetched(l.claim.HighWaterMarkOffset())
+
 	timestamp, kafkaFields := composeEventMetadata(l.claim, l.groupHandler, msg)
 	messages := l.parseMultipleMessages(msg.Value)
 
@@ -447,6 +549,7 @@ func (l *listFromFieldReader) Next() (reader.Message, error) {
 	ackHandler := func() {
 		if neededAcks.Add(-1) == 0 {
 			l.groupHandler.ack(msg)
+			l.metrics.acked(msg.Offset)
 		}
 	}
 	for _, message := range messages {
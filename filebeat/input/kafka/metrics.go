@@ -0,0 +1,176 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// inputMetrics reports the progress of the consumer group on the partitions
+// claimed by the input, and the decoding of the messages of each topic.
+type inputMetrics struct {
+	mu         sync.Mutex
+	partitions *monitoring.Registry     // registry of the claimed partitions, keyed by topic and partition
+	topics     *monitoring.Registry     // registry of the topics with decoded messages
+	decoding   map[string]*topicMetrics // metrics of the topics, keyed by registry name
+}
+
+// topicMetrics reports the decoding of the messages of a topic.
+type topicMetrics struct {
+	decodedMessages *monitoring.Uint // number of messages decoded with their schema
+	decodeErrors    *monitoring.Uint // number of messages that couldn't be decoded
+}
+
+// partitionMetrics reports the progress of the consumer group on a partition.
+type partitionMetrics struct {
+	mu        sync.Mutex
+	committed int64 // offset of the next message to consume after the acknowledged ones
+	highWater int64 // offset of the next message produced to the partition
+
+	committedOffset *monitoring.Int // offset of the next message to consume after the acknowledged ones
+	highWatermark   *monitoring.Int // offset of the next message produced to the partition
+	lag             *monitoring.Int // number of messages not acknowledged yet
+}
+
+// newInputMetrics returns the metrics of an input reported in reg. If reg is
+// nil a nil inputMetrics is returned, which doesn't report any metric.
+func newInputMetrics(reg *monitoring.Registry) *inputMetrics {
+	if reg == nil {
+		return nil
+	}
+	return &inputMetrics{
+		partitions: reg.GetOrCreateRegistry("partitions"),
+		topics:     reg.GetOrCreateRegistry("topics"),
+		decoding:   map[string]*topicMetrics{},
+	}
+}
+
+// partitionKey returns the name of the registry of a partition.
+func partitionKey(topic string, partition int32) string {
+	return topicKey(topic) + "-" + strconv.Itoa(int(partition))
+}
+
+// topicKey returns the name of the registry of a topic. Registry names are
+// split on dots, which are valid in topic names.
+func topicKey(topic string) string {
+	return strings.ReplaceAll(topic, ".", "_")
+}
+
+// claim starts reporting the metrics of a partition claimed by the input,
+// whose consumption starts at offset.
+func (m *inputMetrics) claim(topic string, partition int32, offset int64) *partitionMetrics {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := partitionKey(topic, partition)
+	m.partitions.Remove(key)
+	reg := m.partitions.NewRegistry(key)
+	monitoring.NewString(reg, "topic").Set(topic)
+	monitoring.NewInt(reg, "partition").Set(int64(partition))
+
+	pm := &partitionMetrics{
+		committed:       offset,
+		committedOffset: monitoring.NewInt(reg, "committed_offset"),
+		highWatermark:   monitoring.NewInt(reg, "high_watermark"),
+		lag:             monitoring.NewInt(reg, "lag"),
+	}
+	pm.committedOffset.Set(offset)
+	return pm
+}
+
+// release stops reporting the metrics of a partition that isn't claimed by
+// the input anymore.
+func (m *inputMetrics) release(topic string, partition int32) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.partitions.Remove(partitionKey(topic, partition))
+}
+
+// fetched updates the high watermark of the partition.
+func (m *partitionMetrics) fetched(highWater int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.highWater = highWater
+	m.highWatermark.Set(highWater)
+	m.updateLag()
+}
+
+// acked updates the committed offset once the message at offset is
+// acknowledged.
+func (m *partitionMetrics) acked(offset int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committed = offset + 1
+	m.committedOffset.Set(m.committed)
+	m.updateLag()
+}
+
+func (m *partitionMetrics) updateLag() {
+	m.lag.Set(max(m.highWater-m.committed, 0))
+}
+
+// decoded counts a message of topic decoded with its schema.
+func (m *inputMetrics) decoded(topic string) {
+	if m == nil {
+		return
+	}
+	m.topic(topic).decodedMessages.Inc()
+}
+
+// decodeFailed counts a message of topic that couldn't be decoded.
+func (m *inputMetrics) decodeFailed(topic string) {
+	if m == nil {
+		return
+	}
+	m.topic(topic).decodeErrors.Inc()
+}
+
+func (m *inputMetrics) topic(topic string) *topicMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The topics whose names only differ by dots and underscores share their
+	// metrics.
+	key := topicKey(topic)
+	tm, ok := m.decoding[key]
+	if !ok {
+		reg := m.topics.NewRegistry(key)
+		tm = &topicMetrics{
+			decodedMessages: monitoring.NewUint(reg, "decoded_messages_total"),
+			decodeErrors:    monitoring.NewUint(reg, "decode_errors_total"),
+		}
+		m.decoding[key] = tm
+	}
+	return tm
+}
@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func TestPartitionMetrics(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	metrics := newInputMetrics(reg)
+
+	snapshot := func() map[string]any {
+		return monitoring.CollectStructSnapshot(reg, monitoring.Full, false)
+	}
+
+	pm := metrics.claim("logs.app", 3, 10)
+	pm.fetched(15)
+	assert.Equal(t, map[string]any{
+		"partitions": map[string]any{
+			"logs_app-3": map[string]any{
+				"topic":            "logs.app",
+				"partition":        int64(3),
+				"committed_offset": int64(10),
+				"high_watermark":   int64(15),
+				"lag":              int64(5),
+			},
+		},
+	}, snapshot())
+
+	pm.acked(10)
+	pm.acked(11)
+	pm.fetched(20)
+	partition := snapshot()["partitions"].(map[string]any)["logs_app-3"].(map[string]any)
+	assert.Equal(t, int64(12), partition["committed_offset"])
+	assert.Equal(t, int64(20), partition["high_watermark"])
+	assert.Equal(t, int64(8), partition["lag"])
+
+	// A partition claimed again starts from its new initial offset.
+	pm = metrics.claim("logs.app", 3, 18)
+	partition = snapshot()["partitions"].(map[string]any)["logs_app-3"].(map[string]any)
+	assert.Equal(t, int64(18), partition["committed_offset"])
+
+	metrics.release("logs.app", 3)
+	assert.Empty(t, snapshot()["partitions"])
+
+	// Metrics of released partitions and nil metrics are ignored.
+	pm.acked(19)
+	var nilMetrics *inputMetrics
+	nilMetrics.claim("logs", 0, 0).fetched(1)
+	nilMetrics.release("logs", 0)
+}
@@ -0,0 +1,63 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/sarama"
+)
+
+// offsetGetter resolves the offsets of partitions. It's implemented by
+// sarama.Client.
+type offsetGetter interface {
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+}
+
+// resetClaimedOffsets resets the offsets of the partitions claimed in session to the
+// offsets configured by cfg, resolved with offsets. The consumption of the
+// claims starts at the reset offsets, which are committed like the offsets of
+// acknowledged messages.
+//
+// The offset manager of sarama only moves an offset forward on MarkOffset and
+// backward on ResetOffset, so both are called to set the offset whether it's
+// after or before the committed one.
+func resetClaimedOffsets(log *logp.Logger, session sarama.ConsumerGroupSession, offsets offsetGetter, cfg resetOffsetConfig) error {
+	target := cfg.target()
+	for topic, partitions := range session.Claims() {
+		for _, partition := range partitions {
+			offset, err := offsets.GetOffset(topic, partition, target)
+			if err != nil {
+				return fmt.Errorf("could not get the reset offset of partition %d of topic %s: %w", partition, topic, err)
+			}
+			// There are no messages after the timestamp, the consumption
+			// starts with the next produced message.
+			if offset < 0 {
+				offset, err = offsets.GetOffset(topic, partition, sarama.OffsetNewest)
+				if err != nil {
+					return fmt.Errorf("could not get the newest offset of partition %d of topic %s: %w", partition, topic, err)
+				}
+			}
+			session.MarkOffset(topic, partition, offset, "")
+			session.ResetOffset(topic, partition, offset, "")
+			log.Infow("Reset consumer group offset", "topic", topic, "partition", partition, "offset", offset)
+		}
+	}
+	return nil
+}
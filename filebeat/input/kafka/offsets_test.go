@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/sarama"
+)
+
+func TestResetOffsetConfig(t *testing.T) {
+	tests := map[string]struct {
+		config  mapstr.M
+		version string
+		wantErr string
+		want    int64
+	}{
+		"oldest": {
+			config: mapstr.M{"to": "oldest"},
+			want:   sarama.OffsetOldest,
+		},
+		"newest": {
+			config: mapstr.M{"to": "newest"},
+			want:   sarama.OffsetNewest,
+		},
+		"timestamp": {
+			config: mapstr.M{"to": "timestamp", "timestamp": "2026-10-01T10:00:00Z"},
+			want:   time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC).UnixMilli(),
+		},
+		"invalid to": {
+			config:  mapstr.M{"to": "earliest"},
+			wantErr: "invalid reset offset 'earliest'",
+		},
+		"missing timestamp": {
+			config:  mapstr.M{"to": "timestamp"},
+			wantErr: "reset_offset.timestamp is required",
+		},
+		"invalid timestamp": {
+			config:  mapstr.M{"to": "timestamp", "timestamp": "yesterday"},
+			wantErr: "invalid reset_offset.timestamp",
+		},
+		"timestamp without to": {
+			config:  mapstr.M{"to": "oldest", "timestamp": "2026-10-01T10:00:00Z"},
+			wantErr: "reset_offset.timestamp can only be set",
+		},
+		"timestamp with old version": {
+			config:  mapstr.M{"to": "timestamp", "timestamp": "2026-10-01T10:00:00Z"},
+			version: "0.10.0",
+			wantErr: "not supported for kafka versions < 0.10.1",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := conf.MustNewConfigFrom(mapstr.M{
+				"hosts":        "localhost:9092",
+				"topics":       "messages",
+				"group_id":     "filebeat",
+				"reset_offset": tc.config,
+			})
+			if tc.version != "" {
+				require.NoError(t, cfg.SetString("version", -1, tc.version))
+			}
+
+			config := defaultConfig()
+			err := cfg.Unpack(&config)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, config.ResetOffset.target())
+		})
+	}
+}
+
+func TestResetClaimedOffsets(t *testing.T) {
+	ts := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC).UnixMilli()
+	offsets := offsetGetterMock{
+		{"logs", 0, ts}:                  42,
+		{"logs", 1, ts}:                  -1, // no message after the timestamp
+		{"logs", 1, sarama.OffsetNewest}: 7,
+	}
+	// The committed offset of partition 0 is after the reset offset and the
+	// one of partition 1 before it.
+	session := &sessionMock{
+		claims:  map[string][]int32{"logs": {0, 1}},
+		offsets: map[string]int64{"logs-0": 100, "logs-1": 3},
+	}
+	cfg := resetOffsetConfig{To: resetOffsetTimestamp, Timestamp: "2026-10-01T10:00:00Z"}
+
+	err := resetClaimedOffsets(logp.NewNopLogger(), session, offsets, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"logs-0": 42, "logs-1": 7}, session.offsets)
+
+	// Without committed offsets.
+	session = &sessionMock{claims: map[string][]int32{"logs": {0, 1}}}
+	err = resetClaimedOffsets(logp.NewNopLogger(), session, offsets, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"logs-0": 42, "logs-1": 7}, session.offsets)
+
+	session = &sessionMock{claims: map[string][]int32{"logs": {2}}}
+	err = resetClaimedOffsets(logp.NewNopLogger(), session, offsets, cfg)
+	require.ErrorContains(t, err, "could not get the reset offset of partition 2 of topic logs")
+	assert.Empty(t, session.offsets)
+}
+
+type offsetRequest struct {
+	topic     string
+	partition int32
+	time      int64
+}
+
+type offsetGetterMock map[offsetRequest]int64
+
+func (m offsetGetterMock) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	offset, ok := m[offsetRequest{topic, partition, time}]
+	if !ok {
+		return 0, errors.New("unknown partition")
+	}
+	return offset, nil
+}
+
+// sessionMock is a consumer group session that tracks the offsets of the
+// partitions like the offset manager of sarama: MarkOffset only moves an
+// offset forward and ResetOffset only moves it backward. Partitions without
+// committed offset start at -1.
+type sessionMock struct {
+	sarama.ConsumerGroupSession
+	claims  map[string][]int32
+	offsets map[string]int64
+}
+
+func (s *sessionMock) Claims() map[string][]int32 {
+	return s.claims
+}
+
+func (s *sessionMock) offset(topic string, partition int32) int64 {
+	if offset, ok := s.offsets[partitionKey(topic, partition)]; ok {
+		return offset
+	}
+	return -1
+}
+
+func (s *sessionMock) setOffset(topic string, partition int32, offset int64) {
+	if s.offsets == nil {
+		s.offsets = map[string]int64{}
+	}
+	s.offsets[partitionKey(topic, partition)] = offset
+}
+
+func (s *sessionMock) MarkOffset(topic string, partition int32, offset int64, _ string) {
+	if offset > s.offset(topic, partition) {
+		s.setOffset(topic, partition, offset)
+	}
+}
+
+func (s *sessionMock) ResetOffset(topic string, partition int32, offset int64, _ string) {
+	if offset <= s.offset(topic, partition) {
+		s.setOffset(topic, partition, offset)
+	}
+}
@@ -0,0 +1,459 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// The well known types are imported by the protobuf schemas without
+	// being registered as references.
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/avro"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+const (
+	// magicByte starts every message in the schema registry wire format,
+	// followed by the schema ID as a 4 bytes big endian integer.
+	magicByte        = 0
+	wireHeaderLength = 5
+
+	schemaTypeAvro     = "AVRO"
+	schemaTypeProtobuf = "PROTOBUF"
+
+	// schemaRetryInterval is the time a failure to fetch a schema is cached
+	// for, so messages written with an unavailable schema don't send a
+	// request each to the registry.
+	schemaRetryInterval = 10 * time.Second
+)
+
+// schemaRegistryConfig configures the decoding of the messages framed with
+// the Confluent schema registry wire format.
+type schemaRegistryConfig struct {
+	URL      string `config:"url"`
+	Username string `config:"username"`
+	Password string `config:"password"`
+	// TargetField is the field the decoded value is written to. Decoded
+	// records are written to the root of the event if it's empty.
+	TargetField string                           `config:"target_field"`
+	Transport   httpcommon.HTTPTransportSettings `config:",inline"`
+}
+
+// Validate validates the schema_registry config.
+func (c *schemaRegistryConfig) Validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid schema_registry.url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("schema_registry.url must be an http or https URL, got '%s'", c.URL)
+	}
+	if c.Username != "" && c.Password == "" {
+		return errors.New("schema_registry.password must be set when schema_registry.username is configured")
+	}
+	return nil
+}
+
+// valueDecoder decodes the message values written with a schema.
+type valueDecoder interface {
+	decode(data []byte) (any, error)
+}
+
+// schemaRegistry decodes the message values framed with the schema registry
+// wire format, fetching their schemas from the registry. The schemas are
+// cached by ID, since a schema ID always refers to the same schema, and the
+// failures to fetch them are cached for schemaRetryInterval.
+type schemaRegistry struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+
+	// fetches ensures a single request fetches a schema at a time, the
+	// other decoders of messages with the same schema wait for its result.
+	fetches singleflight.Group
+
+	mu      sync.Mutex
+	schemas map[int]cachedSchema
+}
+
+// cachedSchema is the decoder of a fetched schema, or the failure to fetch
+// the schema, which is cached until retry.
+type cachedSchema struct {
+	decoder valueDecoder
+	err     error
+	retry   time.Time
+}
+
+func newSchemaRegistry(config schemaRegistryConfig, log *logp.Logger) (*schemaRegistry, error) {
+	client, err := config.Transport.Client(httpcommon.WithLogger(log))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema registry client: %w", err)
+	}
+	return &schemaRegistry{
+		url:      strings.TrimSuffix(config.URL, "/"),
+		username: config.Username,
+		password: config.Password,
+		client:   client,
+		schemas:  map[int]cachedSchema{},
+	}, nil
+}
+
+// decode decodes a message value framed with the schema registry wire format.
+func (r *schemaRegistry) decode(value []byte) (any, error) {
+	if len(value) < wireHeaderLength || value[0] != magicByte {
+		return nil, errors.New("message is not framed with the schema registry wire format")
+	}
+	id := int(binary.BigEndian.Uint32(value[1:wireHeaderLength]))
+
+	decoder, err := r.decoder(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decoder.decode(value[wireHeaderLength:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message with schema %d: %w", id, err)
+	}
+	return v, nil
+}
+
+// decoder returns the decoder of the schema with the given ID, fetching the
+// schema if it isn't cached yet. The schema is fetched without holding the
+// lock, so the messages with cached schemas are decoded in the meantime.
+func (r *schemaRegistry) decoder(id int) (valueDecoder, error) {
+	if cached, ok := r.cached(id); ok {
+		return cached.decoder, cached.err
+	}
+
+	v, err, _ := r.fetches.Do(strconv.Itoa(id), func() (any, error) {
+		// The schema may have been fetched since the cache was checked.
+		if cached, ok := r.cached(id); ok {
+			return cached.decoder, cached.err
+		}
+
+		var cached cachedSchema
+		cached.decoder, cached.err = r.fetchDecoder(id)
+		if cached.err != nil {
+			cached.err = fmt.Errorf("failed to fetch schema %d: %w", id, cached.err)
+			cached.retry = time.Now().Add(schemaRetryInterval)
+		}
+
+		r.mu.Lock()
+		r.schemas[id] = cached
+		r.mu.Unlock()
+		return cached.decoder, cached.err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(valueDecoder), nil
+}
+
+// cached returns the cached schema with the given ID. Failures are only
+// returned until they can be retried.
+func (r *schemaRegistry) cached(id int) (cachedSchema, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached, ok := r.schemas[id]
+	if ok && cached.err != nil && !time.Now().Before(cached.retry) {
+		return cachedSchema{}, false
+	}
+	return cached, ok
+}
+
+// registrySchema is a schema returned by the schema registry API.
+type registrySchema struct {
+	Schema     string            `json:"schema"`
+	SchemaType string            `json:"schemaType"`
+	References []schemaReference `json:"references"`
+}
+
+type schemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+func (r *schemaRegistry) fetchDecoder(id int) (valueDecoder, error) {
+	path := "/schemas/ids/" + strconv.Itoa(id)
+	var s registrySchema
+	if err := r.get(path, &s); err != nil {
+		return nil, err
+	}
+
+	switch s.SchemaType {
+	case "", schemaTypeAvro:
+		if len(s.References) != 0 {
+			return nil, errors.New("avro schema references are not supported")
+		}
+		schema, err := avro.Parse([]byte(s.Schema))
+		if err != nil {
+			return nil, err
+		}
+		return avroDecoder{schema: schema}, nil
+
+	case schemaTypeProtobuf:
+		// The serialized format returns the schema as an encoded file
+		// descriptor, which doesn't need to be parsed from its source.
+		if err := r.get(path+"?format=serialized", &s); err != nil {
+			return nil, err
+		}
+		file, err := r.protoFile("", s, new(protoregistry.Files))
+		if err != nil {
+			return nil, err
+		}
+		return protoDecoder{file: file}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported schema type '%s'", s.SchemaType)
+	}
+}
+
+// protoFile builds the file descriptor of the serialized protobuf schema s,
+// fetching the schemas it references and registering them in files. If name
+// isn't empty it's used as the path of the file, which is how the file is
+// imported by the schemas referencing it.
+func (r *schemaRegistry) protoFile(name string, s registrySchema, files *protoregistry.Files) (protoreflect.FileDescriptor, error) {
+	for _, ref := range s.References {
+		if _, err := files.FindFileByPath(ref.Name); err == nil {
+			continue
+		}
+		var dep registrySchema
+		path := "/subjects/" + url.PathEscape(ref.Subject) + "/versions/" + strconv.Itoa(ref.Version) + "?format=serialized"
+		if err := r.get(path, &dep); err != nil {
+			return nil, fmt.Errorf("failed to fetch reference '%s': %w", ref.Name, err)
+		}
+		file, err := r.protoFile(ref.Name, dep, files)
+		if err != nil {
+			return nil, fmt.Errorf("reference '%s': %w", ref.Name, err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := base64.StdEncoding.DecodeString(s.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serialized protobuf schema: %w", err)
+	}
+	var fdp descriptorpb.FileDescriptorProto
+	if err := proto.Unmarshal(data, &fdp); err != nil {
+		return nil, fmt.Errorf("invalid serialized protobuf schema: %w", err)
+	}
+	if name != "" {
+		fdp.Name = proto.String(name)
+	}
+	for _, dep := range fdp.GetDependency() {
+		if _, err := files.FindFileByPath(dep); err == nil {
+			continue
+		}
+		if file, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+			if err := files.RegisterFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return protodesc.NewFile(&fdp, files)
+}
+
+// get fetches path from the schema registry and decodes the JSON response
+// into v.
+func (r *schemaRegistry) get(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, r.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("schema registry returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// avroDecoder decodes the values written with an Avro schema.
+type avroDecoder struct {
+	schema *avro.Schema
+}
+
+func (d avroDecoder) decode(data []byte) (any, error) {
+	return d.schema.Decode(data)
+}
+
+// protoDecoder decodes the values written with a message type of a protobuf
+// schema.
+type protoDecoder struct {
+	file protoreflect.FileDescriptor
+}
+
+func (d protoDecoder) decode(data []byte) (any, error) {
+	indexes, data, err := readMessageIndexes(data)
+	if err != nil {
+		return nil, err
+	}
+	md, err := d.message(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("invalid protobuf message %s: %w", md.FullName(), err)
+	}
+	return protoMessageValue(msg), nil
+}
+
+// message returns the message type identified by indexes, the path of the
+// message type in the nested message types of the file.
+func (d protoDecoder) message(indexes []int64) (protoreflect.MessageDescriptor, error) {
+	var md protoreflect.MessageDescriptor
+	messages := d.file.Messages()
+	for _, i := range indexes {
+		if i < 0 || i >= int64(messages.Len()) {
+			return nil, fmt.Errorf("invalid message indexes %v of protobuf schema %s", indexes, d.file.Path())
+		}
+		md = messages.Get(int(i))
+		messages = md.Messages()
+	}
+	return md, nil
+}
+
+// readMessageIndexes reads the message indexes that follow the schema ID of
+// protobuf messages. They're encoded as an array of zig-zag varints, and the
+// common case of the first message type as a single 0.
+func readMessageIndexes(data []byte) ([]int64, []byte, error) {
+	n, k := binary.Varint(data)
+	if k <= 0 || n < 0 {
+		return nil, nil, errors.New("invalid protobuf message indexes")
+	}
+	data = data[k:]
+	if n == 0 {
+		return []int64{0}, data, nil
+	}
+	// Every index takes at least one byte, this bounds the allocation of
+	// the indexes of malformed messages.
+	if n > int64(len(data)) {
+		return nil, nil, errors.New("invalid protobuf message indexes")
+	}
+
+	indexes := make([]int64, 0, n)
+	for range n {
+		i, k := binary.Varint(data)
+		if k <= 0 {
+			return nil, nil, errors.New("invalid protobuf message indexes")
+		}
+		indexes = append(indexes, i)
+		data = data[k:]
+	}
+	return indexes, data, nil
+}
+
+// protoMessageValue converts a protobuf message to a mapstr.M keyed by the
+// field names. Only the populated fields are included.
+func protoMessageValue(m protoreflect.Message) any {
+	md := m.Descriptor()
+	if md.FullName() == "google.protobuf.Timestamp" {
+		seconds := m.Get(md.Fields().ByName("seconds")).Int()
+		nanos := m.Get(md.Fields().ByName("nanos")).Int()
+		return time.Unix(seconds, nanos).UTC()
+	}
+
+	out := mapstr.M{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		out[string(fd.Name())] = protoFieldValue(fd, v)
+		return true
+	})
+	return out
+}
+
+func protoFieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		items := make([]any, list.Len())
+		for i := range items {
+			items[i] = protoSingularValue(fd, list.Get(i))
+		}
+		return items
+	case fd.IsMap():
+		m := mapstr.M{}
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			m[k.String()] = protoSingularValue(fd.MapValue(), v)
+			return true
+		})
+		return m
+	default:
+		return protoSingularValue(fd, v)
+	}
+}
+
+func protoSingularValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoMessageValue(v.Message())
+	default:
+		return v.Interface()
+	}
+}
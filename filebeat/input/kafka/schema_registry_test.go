@@ -0,0 +1,364 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/avro"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+const testAvroSchema = `{
+  "type": "record",
+  "name": "Event",
+  "fields": [
+    {"name": "name", "type": "string"},
+    {"name": "count", "type": "long"}
+  ]
+}`
+
+// testProtoFile returns a protobuf schema with an Event message type, the
+// second message type of the file.
+func testProtoFile(t *testing.T) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("events.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Level"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("INFO"), Number: proto.Int32(0)},
+				{Name: proto.String("WARN"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Other")},
+			{
+				Name: proto.String("Event"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", optional),
+					field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", optional),
+					field("level", 3, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.Level", optional),
+					field("tags", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+					field("ts", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp", optional),
+				},
+			},
+		},
+	}
+}
+
+// newTestRegistryServer serves the schemas of the tests like a schema
+// registry, and counts the requests for each path.
+func newTestRegistryServer(t *testing.T, fdp *descriptorpb.FileDescriptorProto) (*httptest.Server, map[string]int) {
+	t.Helper()
+	serialized, err := proto.Marshal(fdp)
+	require.NoError(t, err)
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.RequestURI()]++
+		var s registrySchema
+		switch r.URL.RequestURI() {
+		case "/schemas/ids/1":
+			s = registrySchema{Schema: testAvroSchema}
+		case "/schemas/ids/2":
+			s = registrySchema{Schema: "syntax = \"proto3\"; ...", SchemaType: schemaTypeProtobuf}
+		case "/schemas/ids/2?format=serialized":
+			s = registrySchema{Schema: base64.StdEncoding.EncodeToString(serialized), SchemaType: schemaTypeProtobuf}
+		case "/schemas/ids/3":
+			s = registrySchema{Schema: `{"type": "object"}`, SchemaType: "JSON"}
+		default:
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(s))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func newTestSchemaRegistry(t *testing.T, url string) *schemaRegistry {
+	t.Helper()
+	registry, err := newSchemaRegistry(schemaRegistryConfig{
+		URL:       url,
+		Transport: httpcommon.DefaultHTTPTransportSettings(),
+	}, logp.NewNopLogger())
+	require.NoError(t, err)
+	return registry
+}
+
+// frame frames data with the schema registry wire format.
+func frame(id byte, data ...[]byte) []byte {
+	out := []byte{magicByte, 0, 0, 0, id}
+	for _, d := range data {
+		out = append(out, d...)
+	}
+	return out
+}
+
+func TestSchemaRegistryDecode(t *testing.T) {
+	fdp := testProtoFile(t)
+	server, requests := newTestRegistryServer(t, fdp)
+	registry := newTestSchemaRegistry(t, server.URL)
+
+	t.Run("avro", func(t *testing.T) {
+		schema, err := avro.Parse([]byte(testAvroSchema))
+		require.NoError(t, err)
+		data, err := schema.Append(nil, mapstr.M{"name": "login", "count": 3})
+		require.NoError(t, err)
+
+		for range 2 {
+			v, err := registry.decode(frame(1, data))
+			require.NoError(t, err)
+			assert.Equal(t, mapstr.M{"name": "login", "count": int64(3)}, v)
+		}
+		assert.Equal(t, 1, requests["/schemas/ids/1"], "schemas must be cached")
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		file, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+		require.NoError(t, err)
+		md := file.Messages().ByName("Event")
+		msg := dynamicpb.NewMessage(md)
+		msg.Set(md.Fields().ByName("name"), protoreflect.ValueOfString("login"))
+		msg.Set(md.Fields().ByName("count"), protoreflect.ValueOfInt64(3))
+		msg.Set(md.Fields().ByName("level"), protoreflect.ValueOfEnum(1))
+		tags := msg.Mutable(md.Fields().ByName("tags")).List()
+		tags.Append(protoreflect.ValueOfString("a"))
+		tags.Append(protoreflect.ValueOfString("b"))
+		ts := timestamppb.New(time.Unix(1700000000, 5).UTC())
+		msg.Set(md.Fields().ByName("ts"), protoreflect.ValueOfMessage(ts.ProtoReflect()))
+		data, err := proto.Marshal(msg)
+		require.NoError(t, err)
+
+		// The message indexes [1] select the second message type.
+		v, err := registry.decode(frame(2, []byte{0x02, 0x02}, data))
+		require.NoError(t, err)
+		assert.Equal(t, mapstr.M{
+			"name":  "login",
+			"count": int64(3),
+			"level": "WARN",
+			"tags":  []any{"a", "b"},
+			"ts":    time.Unix(1700000000, 5).UTC(),
+		}, v)
+
+		// A single 0 selects the first message type.
+		v, err = registry.decode(frame(2, []byte{0x00}))
+		require.NoError(t, err)
+		assert.Equal(t, mapstr.M{}, v)
+
+		_, err = registry.decode(frame(2, []byte{0x02, 0x04}))
+		assert.ErrorContains(t, err, "invalid message indexes [2]")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := registry.decode([]byte("plain text"))
+		assert.ErrorContains(t, err, "not framed with the schema registry wire format")
+
+		_, err = registry.decode(frame(3))
+		assert.ErrorContains(t, err, "unsupported schema type 'JSON'")
+
+		_, err = registry.decode(frame(4))
+		assert.ErrorContains(t, err, "failed to fetch schema 4: schema registry returned 404 Not Found")
+
+		_, err = registry.decode(frame(1, []byte{0x02}))
+		assert.ErrorContains(t, err, "failed to decode message with schema 1")
+	})
+}
+
+func TestSchemaRegistryFetch(t *testing.T) {
+	schema, err := avro.Parse([]byte(testAvroSchema))
+	require.NoError(t, err)
+	data, err := schema.Append(nil, mapstr.M{"name": "login", "count": 3})
+	require.NoError(t, err)
+
+	t.Run("concurrent decodes fetch the schema once", func(t *testing.T) {
+		server, requests := newTestRegistryServer(t, testProtoFile(t))
+		registry := newTestSchemaRegistry(t, server.URL)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := registry.decode(frame(1, data))
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, requests["/schemas/ids/1"])
+	})
+
+	t.Run("failures are cached until retry", func(t *testing.T) {
+		server, requests := newTestRegistryServer(t, testProtoFile(t))
+		registry := newTestSchemaRegistry(t, server.URL)
+
+		for range 2 {
+			_, err := registry.decode(frame(4))
+			assert.ErrorContains(t, err, "failed to fetch schema 4: schema registry returned 404 Not Found")
+		}
+		assert.Equal(t, 1, requests["/schemas/ids/4"], "failures must be cached")
+
+		registry.mu.Lock()
+		failure := registry.schemas[4]
+		failure.retry = time.Now()
+		registry.schemas[4] = failure
+		registry.mu.Unlock()
+		for range 2 {
+			_, err := registry.decode(frame(4))
+			assert.ErrorContains(t, err, "failed to fetch schema 4")
+		}
+		assert.Equal(t, 2, requests["/schemas/ids/4"], "failures must be retried once")
+	})
+}
+
+func TestReadMessageIndexes(t *testing.T) {
+	tests := map[string]struct {
+		data    []byte
+		want    []int64
+		wantErr bool
+	}{
+		"first message type": {
+			data: []byte{0x00, 0x10},
+			want: []int64{0},
+		},
+		"nested message type": {
+			data: []byte{0x04, 0x02, 0x06, 0x10},
+			want: []int64{1, 3},
+		},
+		"empty": {
+			data:    nil,
+			wantErr: true,
+		},
+		"negative count": {
+			data:    []byte{0x01},
+			wantErr: true,
+		},
+		"truncated indexes": {
+			data:    []byte{0x06, 0x02},
+			wantErr: true,
+		},
+		"count larger than message": {
+			data:    binary.AppendVarint(nil, 1<<40),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			indexes, rest, err := readMessageIndexes(tc.data)
+			if tc.wantErr {
+				assert.ErrorContains(t, err, "invalid protobuf message indexes")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, indexes)
+			assert.Equal(t, []byte{0x10}, rest)
+		})
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	server, _ := newTestRegistryServer(t, testProtoFile(t))
+	schema, err := avro.Parse([]byte(testAvroSchema))
+	require.NoError(t, err)
+	data, err := schema.Append(nil, mapstr.M{"name": "login", "count": 3})
+	require.NoError(t, err)
+
+	reg := monitoring.NewRegistry()
+	handler := &groupHandler{
+		schemaRegistry: newTestSchemaRegistry(t, server.URL),
+		metrics:        newInputMetrics(reg),
+		log:            logp.NewNopLogger(),
+	}
+	newMessage := func(value []byte) reader.Message {
+		return composeMessage(time.Now(), value, mapstr.M{"topic": "logs.app"}, func() {})
+	}
+
+	message := newMessage(frame(1, data))
+	handler.decodeValue("logs.app", &message)
+	assert.Equal(t, mapstr.M{
+		"kafka": mapstr.M{"topic": "logs.app"},
+		"name":  "login",
+		"count": int64(3),
+	}, message.Fields)
+	assert.JSONEq(t, `{"name": "login", "count": 3}`, string(message.Content))
+
+	handler.targetField = "event.original"
+	message = newMessage(frame(1, data))
+	handler.decodeValue("logs.app", &message)
+	assert.Equal(t, mapstr.M{
+		"kafka": mapstr.M{"topic": "logs.app"},
+		"event": mapstr.M{"original": mapstr.M{"name": "login", "count": int64(3)}},
+	}, message.Fields)
+
+	message = newMessage([]byte("plain text"))
+	handler.decodeValue("logs.app", &message)
+	assert.Equal(t, "plain text", message.Fields["message"])
+	assert.Equal(t, []byte("plain text"), message.Content)
+	errMessage, _ := message.Fields.GetValue("error.message")
+	assert.Contains(t, errMessage, "not framed with the schema registry wire format")
+
+	assert.Equal(t, map[string]any{
+		"topics": map[string]any{
+			"logs_app": map[string]any{
+				"decoded_messages_total": int64(2),
+				"decode_errors_total":    int64(1),
+			},
+		},
+	}, monitoring.CollectStructSnapshot(reg, monitoring.Full, false))
+}
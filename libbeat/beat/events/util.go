@@ -42,6 +42,10 @@ const (
 	// Bulk API encoding of the event. The key's value can be an empty string, `create`, `index`, or `delete`.
 	// If empty, `create` will be used if FieldMetaID is set; otherwise `index` will be used.
 	FieldMetaOpType = "op_type"
+
+	// FieldMetaQueuePriority defines the queue priority class of the event: `high`, `normal` or `low`.
+	// It is only honored by queues with priority lanes enabled; events without it are queued as `normal`.
+	FieldMetaQueuePriority = "queue_priority"
 )
 
 // GetMetaStringValue returns the value of the given event metadata string field
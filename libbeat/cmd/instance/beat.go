@@ -64,6 +64,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/publisher/pipeline"
 	"github.com/elastic/beats/v7/libbeat/publisher/processing"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
 	"github.com/elastic/beats/v7/libbeat/version"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/file"
@@ -379,7 +380,7 @@ func (b *Beat) createBeater(bt beat.Creator) (beat.Beater, error) {
 
 	log.Debug("Initializing output plugins")
 	outputEnabled := b.Config.Output.IsSet() && b.Config.Output.Config().Enabled()
-	if !outputEnabled {
+	if !outputEnabled && len(b.Config.Pipeline.Outputs) == 0 {
 		if b.Manager.Enabled() {
 			b.Info.Logger.Info("Output is configured through Central Management")
 		} else {
@@ -404,6 +405,7 @@ func (b *Beat) createBeater(bt beat.Creator) (beat.Beater, error) {
 		WaitClose:      time.Second,
 		Processors:     b.processors,
 		InputQueueSize: b.InputQueueSize,
+		OutputFactory:  b.createOutput,
 	}
 	publisher, err = pipeline.LoadWithSettings(b.Info, monitors, b.Config.Pipeline, outputFactory, settings)
 	if err != nil {
@@ -1567,19 +1569,33 @@ func (bc *beatConfig) Validate() error {
 			return fmt.Errorf("top level queue and output level queue settings defined, only one is allowed")
 		}
 		// elastic-agent doesn't support disk queue yet
-		if bc.Management.Enabled() && outputPC.Queue.Config().Enabled() && outputPC.Queue.Name() == diskqueue.QueueType {
-			return fmt.Errorf("disk queue is not supported when management is enabled")
+		if bc.Management.Enabled() && outputPC.Queue.Config().Enabled() && isDiskBackedQueue(outputPC.Queue.Name()) {
+			return fmt.Errorf("%s queue is not supported when management is enabled", outputPC.Queue.Name())
+		}
+	}
+
+	if len(bc.Pipeline.Outputs) > 0 {
+		if bc.Output.IsSet() && bc.Output.Config().Enabled() {
+			return errors.New("output and outputs can't be used together, configure either a single output or a list of named outputs")
+		}
+		if bc.Management.Enabled() {
+			return errors.New("outputs is not supported when management is enabled")
 		}
 	}
 
 	// elastic-agent doesn't support disk queue yet
-	if bc.Management.Enabled() && bc.Pipeline.Queue.Config().Enabled() && bc.Pipeline.Queue.Name() == diskqueue.QueueType {
-		return fmt.Errorf("disk queue is not supported when management is enabled")
+	if bc.Management.Enabled() && bc.Pipeline.Queue.Config().Enabled() && isDiskBackedQueue(bc.Pipeline.Queue.Name()) {
+		return fmt.Errorf("%s queue is not supported when management is enabled", bc.Pipeline.Queue.Name())
 	}
 
 	return nil
 }
 
+// isDiskBackedQueue reports whether the queue type stores events on disk.
+func isDiskBackedQueue(queueType string) bool {
+	return queueType == diskqueue.QueueType || queueType == spillqueue.QueueType
+}
+
 // runShutdownWatchdog releases the publisher pipeline if a Beater's Run does not
 // return within grace after it was told to stop, as a backstop against a hung
 // beater (for example one blocked in a guaranteed Publish). It returns
@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package queue
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+)
+
+// GenDumpCmd writes the events in the disk queue to stdout as NDJSON.
+func GenDumpCmd(settings instance.Settings) *cobra.Command {
+	var r segmentRange
+	command := &cobra.Command{
+		Use:   "dump",
+		Short: "Dump the events in the disk queue to stdout as NDJSON",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			b, inspector, err := newInspector(settings)
+			if err != nil {
+				return err
+			}
+			return dumpEvents(os.Stdout, inspector, r, b.Info.Beat, b.Info.Version)
+		}),
+	}
+	r.addFlags(command)
+	return command
+}
+
+func dumpEvents(out io.Writer, inspector *diskqueue.Inspector, r segmentRange, beatName, version string) error {
+	w := bufio.NewWriter(out)
+	encoder := json.New(version, json.Config{})
+	err := readEvents(inspector, r, func(segmentID, frameIndex uint64, event publisher.Event) error {
+		line, err := encoder.Encode(beatName, &event.Content)
+		if err != nil {
+			return fmt.Errorf("error encoding event %d of segment %d: %w", frameIndex, segmentID, err)
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package queue
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+)
+
+// GenListCmd lists the segment files of the disk queue and the read
+// position from its state file.
+func GenListCmd(settings instance.Settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the disk queue segments and the read position",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			_, inspector, err := newInspector(settings)
+			if err != nil {
+				return err
+			}
+			return listSegments(os.Stdout, inspector)
+		}),
+	}
+}
+
+// GenCountCmd prints the number of events that are still pending in the
+// disk queue.
+func GenCountCmd(settings instance.Settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "count",
+		Short: "Count the events pending in the disk queue",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			_, inspector, err := newInspector(settings)
+			if err != nil {
+				return err
+			}
+			pending, err := inspector.PendingEvents()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, pending)
+			return nil
+		}),
+	}
+}
+
+func listSegments(out io.Writer, inspector *diskqueue.Inspector) error {
+	position, err := inspector.Position()
+	if err != nil {
+		return err
+	}
+	segments, err := inspector.Segments()
+	if err != nil {
+		return err
+	}
+	pending, err := inspector.PendingEvents()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Queue directory: %s\n", inspector.Directory())
+	fmt.Fprintf(out, "State file:      %s\n", inspector.StateFile())
+	fmt.Fprintf(out, "Read position:   segment %d, frame %d, byte %d\n",
+		position.SegmentID, position.FrameIndex, position.ByteIndex)
+	fmt.Fprintf(out, "Pending events:  %d\n\n", pending)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEGMENT\tVERSION\tFRAMES\tSIZE\tCOMPRESSED\tENCRYPTED\tSTATUS")
+	for _, segment := range segments {
+		status := "pending"
+		switch {
+		case segment.ID < position.SegmentID:
+			status = "acknowledged"
+		case segment.ID == position.SegmentID && position.FrameIndex > 0:
+			status = "reading"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%t\t%t\t%s\n",
+			segment.ID, segment.SchemaVersion, segment.FrameCount,
+			segment.SizeOnDisk, segment.Compressed, segment.Encrypted, status)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package queue implements the subcommands used to inspect and recover the
+// contents of a disk queue without running the Beat.
+package queue
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
+)
+
+// segmentRange selects the events read by the dump and replay commands.
+type segmentRange struct {
+	from, to uint64
+	all      bool
+}
+
+func (r *segmentRange) addFlags(cmd *cobra.Command) {
+	cmd.Flags().Uint64Var(&r.from, "from-segment", 0, "First segment ID to read")
+	cmd.Flags().Uint64Var(&r.to, "to-segment", ^uint64(0), "Last segment ID to read")
+	cmd.Flags().BoolVar(&r.all, "all", false, "Include events that were already acknowledged but not deleted yet")
+}
+
+// newInspector loads the Beat configuration and returns an inspector for
+// its disk queue, or for the disk part of its spill queue.
+func newInspector(settings instance.Settings) (*instance.Beat, *diskqueue.Inspector, error) {
+	b, err := instance.NewInitializedBeat(settings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing beat: %w", err)
+	}
+	if err := instance.PromoteOutputQueueSettings(b); err != nil {
+		return nil, nil, err
+	}
+	var queueSettings diskqueue.Settings
+	switch b.Config.Pipeline.Queue.Name() {
+	case diskqueue.QueueType:
+		queueSettings, err = diskqueue.SettingsForUserConfig(b.Config.Pipeline.Queue.Config())
+	case spillqueue.QueueType:
+		var spillSettings spillqueue.Settings
+		spillSettings, err = spillqueue.SettingsForUserConfig(b.Config.Pipeline.Queue.Config())
+		queueSettings = spillSettings.Disk
+	default:
+		return nil, nil, fmt.Errorf("%s is not configured to use the disk queue", settings.Name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, diskqueue.NewInspector(b.Info.Logger, queueSettings, b.Info.Paths), nil
+}
+
+// readEvents calls fn for each event in the selected range, in queue order.
+func readEvents(
+	inspector *diskqueue.Inspector,
+	r segmentRange,
+	fn func(segmentID, frameIndex uint64, event publisher.Event) error,
+) error {
+	position, err := inspector.Position()
+	if err != nil {
+		return err
+	}
+	segments, err := inspector.Segments()
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.ID < r.from || segment.ID > r.to {
+			continue
+		}
+		var firstFrame uint64
+		if !r.all {
+			if segment.ID < position.SegmentID {
+				continue
+			}
+			if segment.ID == position.SegmentID {
+				firstFrame = position.FrameIndex
+			}
+		}
+		err := inspector.ReadEvents(segment.ID, firstFrame, func(frameIndex uint64, event publisher.Event) error {
+			return fn(segment.ID, frameIndex, event)
+		})
+		if err != nil {
+			return fmt.Errorf("error reading segment %d: %w", segment.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/idxmgmt"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// GenReplayCmd publishes the events in the disk queue through the output
+// configured for the Beat.
+func GenReplayCmd(settings instance.Settings) *cobra.Command {
+	var r segmentRange
+	var batchSize, maxRetries int
+	var backoff time.Duration
+	command := &cobra.Command{
+		Use:   "replay",
+		Short: "Publish the events in the disk queue through the configured output",
+		Long: "Publish the events in the disk queue through the configured output.\n\n" +
+			"The queue itself is not modified: the replayed events stay pending and are sent again\n" +
+			"the next time " + settings.Name + " starts, unless the queue directory is removed.\n" +
+			"Don't run this command while " + settings.Name + " is running.",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			b, inspector, err := newInspector(settings)
+			if err != nil {
+				return err
+			}
+
+			im, _ := idxmgmt.DefaultSupport(b.Info, nil)
+			group, err := outputs.Load(im, b.Info, nil, b.Config.Output.Name(), b.Config.Output.Config())
+			if err != nil {
+				return fmt.Errorf("error initializing output: %w", err)
+			}
+			defer group.CloseShared()
+			if len(group.Clients) == 0 {
+				return fmt.Errorf("%s output has no clients", b.Config.Output.Name())
+			}
+			if batchSize <= 0 {
+				batchSize = group.BatchSize
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			rp := newReplayer(group.Clients[0], batchSize, maxRetries, backoff)
+			if group.EncoderFactory != nil {
+				rp.encoder = group.EncoderFactory()
+			}
+			err = rp.run(ctx, func(add func(publisher.Event) error) error {
+				return readEvents(inspector, r, func(_, _ uint64, event publisher.Event) error {
+					return add(event)
+				})
+			})
+			fmt.Fprintf(os.Stdout, "Published %d events, dropped %d events\n", rp.published, rp.dropped)
+			return err
+		}),
+	}
+	r.addFlags(command)
+	command.Flags().IntVar(&batchSize, "batch-size", 0, "Number of events per batch, defaults to the output's bulk_max_size")
+	command.Flags().IntVar(&maxRetries, "max-retries", 3, "Number of consecutive failed attempts before giving up")
+	command.Flags().DurationVar(&backoff, "backoff", time.Second, "Time to wait before retrying a failed batch")
+	return command
+}
+
+// replayer publishes events through an output client one batch at a
+// time, waiting for each batch to be acknowledged before sending the
+// next one so events are replayed in queue order.
+type replayer struct {
+	client     outputs.Client
+	encoder    queue.Encoder[publisher.Event]
+	batchSize  int
+	maxRetries int
+	backoff    time.Duration
+
+	published int
+	dropped   int
+}
+
+func newReplayer(client outputs.Client, batchSize, maxRetries int, backoff time.Duration) *replayer {
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	return &replayer{
+		client:     client,
+		batchSize:  batchSize,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+	}
+}
+
+// run connects the client, then publishes every event passed to add by
+// the read function, and closes the client.
+func (r *replayer) run(ctx context.Context, read func(add func(publisher.Event) error) error) error {
+	if c, ok := r.client.(outputs.Connectable); ok {
+		if err := c.Connect(ctx); err != nil {
+			return fmt.Errorf("error connecting to %s: %w", r.client, err)
+		}
+	}
+	defer r.client.Close()
+
+	events := make([]publisher.Event, 0, r.batchSize)
+	err := read(func(event publisher.Event) error {
+		if r.encoder != nil {
+			event, _ = r.encoder.EncodeEntry(event)
+		}
+		events = append(events, event)
+		if len(events) < r.batchSize {
+			return nil
+		}
+		err := r.publish(ctx, events)
+		events = make([]publisher.Event, 0, r.batchSize)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		return r.publish(ctx, events)
+	}
+	return nil
+}
+
+func (r *replayer) publish(ctx context.Context, events []publisher.Event) error {
+	acked, dropped, err := outputs.PublishSync(ctx, r.client, events,
+		func(failures int, result outputs.BatchResult, err error) error {
+			if failures > r.maxRetries {
+				if err == nil {
+					err = errors.New("output requested a retry")
+				}
+				return fmt.Errorf("giving up after %d attempts with %d events left: %w",
+					failures, len(result.Retry), err)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(r.backoff):
+				return nil
+			}
+		})
+	r.published += acked
+	r.dropped += dropped
+	return err
+}
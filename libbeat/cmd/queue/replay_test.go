@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type scriptedClient struct {
+	// calls is invoked for each Publish, in order, and signals the batch.
+	calls    []func(batch publisher.Batch) error
+	received [][]publisher.Event
+}
+
+func (c *scriptedClient) Publish(_ context.Context, batch publisher.Batch) error {
+	c.received = append(c.received, batch.Events())
+	call := c.calls[0]
+	if len(c.calls) > 1 {
+		c.calls = c.calls[1:]
+	}
+	return call(batch)
+}
+
+func (c *scriptedClient) Close() error   { return nil }
+func (c *scriptedClient) String() string { return "scripted" }
+
+func ack(batch publisher.Batch) error { batch.ACK(); return nil }
+
+func replayTestEvents(n int) []publisher.Event {
+	events := make([]publisher.Event, n)
+	for i := range events {
+		events[i] = publisher.Event{Content: beat.Event{Fields: mapstr.M{"n": i}}}
+	}
+	return events
+}
+
+func replayAll(rp *replayer, events []publisher.Event) error {
+	return rp.run(context.Background(), func(add func(publisher.Event) error) error {
+		for _, event := range events {
+			if err := add(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestReplayerBatches(t *testing.T) {
+	client := &scriptedClient{calls: []func(publisher.Batch) error{ack}}
+	rp := newReplayer(client, 2, 0, 0)
+
+	require.NoError(t, replayAll(rp, replayTestEvents(5)))
+	assert.Equal(t, 5, rp.published)
+	assert.Len(t, client.received, 3)
+}
+
+func TestReplayerSplitAndRetry(t *testing.T) {
+	events := replayTestEvents(4)
+	client := &scriptedClient{calls: []func(publisher.Batch) error{
+		func(batch publisher.Batch) error {
+			batch.SplitRetry()
+			return nil
+		},
+		func(batch publisher.Batch) error {
+			batch.RetryEvents(batch.Events()[1:])
+			return errors.New("partial failure")
+		},
+		func(batch publisher.Batch) error {
+			batch.Drop()
+			return nil
+		},
+		ack,
+	}}
+	rp := newReplayer(client, 10, 1, 0)
+
+	require.NoError(t, replayAll(rp, events))
+	assert.Equal(t, [][]publisher.Event{events, events[:2], events[1:2], events[2:]}, client.received)
+	assert.Equal(t, 3, rp.published)
+	assert.Equal(t, 1, rp.dropped)
+}
+
+func TestReplayerGivesUp(t *testing.T) {
+	client := &scriptedClient{calls: []func(publisher.Batch) error{
+		func(batch publisher.Batch) error {
+			batch.Retry()
+			return errors.New("unavailable")
+		},
+	}}
+	rp := newReplayer(client, 10, 2, 0)
+
+	err := replayAll(rp, replayTestEvents(3))
+	assert.ErrorContains(t, err, "giving up after 3 attempts with 3 events left: unavailable")
+	assert.Len(t, client.received, 3)
+	assert.Zero(t, rp.published)
+}
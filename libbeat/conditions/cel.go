@@ -0,0 +1,265 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/ext"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// CELEventVariable is the name of the variable the event is bound to in CEL
+// expressions.
+const CELEventVariable = "event"
+
+// ErrCELNoSuchKey is the error of CEL expressions accessing a field missing
+// from the event.
+var ErrCELNoSuchKey = errors.New("no such key")
+
+// CEL is a Condition that evaluates a CEL expression returning a bool.
+type CEL struct {
+	expr    string
+	program cel.Program
+}
+
+// NewCELCondition compiles the expression. The expression must return a
+// bool.
+func NewCELCondition(expr string) (*CEL, error) {
+	program, err := CompileCEL(expr, cel.BoolType)
+	if err != nil {
+		return nil, err
+	}
+	return &CEL{expr: expr, program: program}, nil
+}
+
+// Check determines whether the given event matches this condition. Errors,
+// like accessing a missing field, do not match.
+func (c *CEL) Check(event ValuesMap) bool {
+	val, err := EvalCEL(c.program, event)
+	if err != nil {
+		return false
+	}
+	b, ok := val.(types.Bool)
+	return ok && bool(b)
+}
+
+func (c *CEL) String() string {
+	return fmt.Sprintf("cel: %v", c.expr)
+}
+
+// CompileCEL compiles a CEL expression with the event available as the
+// `event` variable. If outputType is not nil, the expression must return a
+// value of that type. The returned program is safe for concurrent use.
+func CompileCEL(expr string, outputType *cel.Type) (cel.Program, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(CELEventVariable, cel.MapType(cel.StringType, cel.DynType)),
+		cel.CustomTypeAdapter(celTypeAdapter),
+		ext.Strings(),
+		ext.Math(),
+		ext.Encoders(),
+		ext.Sets(),
+		ext.Lists(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("failed to compile CEL expression: %w", iss.Err())
+	}
+	if outputType != nil && !outputType.IsAssignableType(ast.OutputType()) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("CEL expression must return %v, not %v", outputType, ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// EvalCEL evaluates a program created by CompileCEL. Fields are looked up in
+// the event when the expression accesses them, `event["a.b"]` and
+// `event.a.b` are equivalent. Accessing a missing field fails with an error
+// wrapping ErrCELNoSuchKey.
+func EvalCEL(program cel.Program, event ValuesMap) (ref.Val, error) {
+	val, _, err := program.Eval(map[string]any{
+		CELEventVariable: celEvent{event: event},
+	})
+	return val, err
+}
+
+// celAdapter converts the types found in events that CEL doesn't know.
+type celAdapter struct {
+	types.Adapter
+}
+
+var celTypeAdapter = celAdapter{types.DefaultTypeAdapter}
+
+func (a celAdapter) NativeToValue(value any) ref.Val {
+	switch v := value.(type) {
+	case mapstr.M:
+		return newCELMap(a, v)
+	case map[string]any:
+		return newCELMap(a, v)
+	case []any:
+		return types.NewDynamicList(a, v)
+	case []mapstr.M:
+		return types.NewDynamicList(a, v)
+	case common.Time:
+		return types.Timestamp{Time: time.Time(v)}
+	}
+	return a.Adapter.NativeToValue(value)
+}
+
+// celEvent exposes a ValuesMap as a CEL map, without copying the event.
+type celEvent struct {
+	event ValuesMap
+}
+
+var errCELEventConversion = errors.New("the event can not be converted")
+
+func (e celEvent) ConvertToNative(reflect.Type) (any, error) {
+	return nil, errCELEventConversion
+}
+
+func (e celEvent) ConvertToType(typeVal ref.Type) ref.Val {
+	if typeVal == types.TypeType {
+		return types.MapType
+	}
+	return types.NewErrFromString(errCELEventConversion.Error())
+}
+
+// Equal never matches, the event can not be compared to other values.
+func (e celEvent) Equal(ref.Val) ref.Val {
+	return types.False
+}
+
+func (e celEvent) Type() ref.Type {
+	return types.MapType
+}
+
+func (e celEvent) Value() any {
+	return e.event
+}
+
+// Get implements traits.Indexer.
+func (e celEvent) Get(index ref.Val) ref.Val {
+	key, ok := index.(types.String)
+	if !ok {
+		return types.ValOrErr(index, "no such overload")
+	}
+	v, err := e.event.GetValue(string(key))
+	if err != nil {
+		return noSuchKey(key)
+	}
+	return celTypeAdapter.NativeToValue(v)
+}
+
+// IsSet implements traits.FieldTester, used by the has() macro.
+func (e celEvent) IsSet(field ref.Val) ref.Val {
+	return e.Contains(field)
+}
+
+// Contains implements traits.Container, used by the in operator.
+func (e celEvent) Contains(index ref.Val) ref.Val {
+	key, ok := index.(types.String)
+	if !ok {
+		return types.ValOrErr(index, "no such overload")
+	}
+	_, err := e.event.GetValue(string(key))
+	return types.Bool(err == nil)
+}
+
+// celMap exposes an object of the event as a CEL map. Unlike the maps of CEL
+// it isn't a traits.Mapper, so CEL looks up its keys with Get, which reports
+// missing keys with ErrCELNoSuchKey like the event.
+type celMap struct {
+	fields mapstr.M
+	m      traits.Mapper
+}
+
+func newCELMap(a types.Adapter, fields map[string]any) celMap {
+	return celMap{fields: fields, m: types.NewStringInterfaceMap(a, fields).(traits.Mapper)} //nolint:errcheck // CEL maps are Mappers.
+}
+
+func (m celMap) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return m.m.ConvertToNative(typeDesc)
+}
+
+func (m celMap) ConvertToType(typeVal ref.Type) ref.Val {
+	return m.m.ConvertToType(typeVal)
+}
+
+func (m celMap) Equal(other ref.Val) ref.Val {
+	if o, ok := other.(celMap); ok {
+		other = o.m
+	}
+	return m.m.Equal(other)
+}
+
+func (m celMap) Type() ref.Type {
+	return types.MapType
+}
+
+// Value returns the object as a mapstr.M.
+func (m celMap) Value() any {
+	return m.fields
+}
+
+// Get implements traits.Indexer.
+func (m celMap) Get(index ref.Val) ref.Val {
+	v, found := m.m.Find(index)
+	if found {
+		return v
+	}
+	if types.IsUnknownOrError(v) {
+		return v
+	}
+	return noSuchKey(index)
+}
+
+// IsSet implements traits.FieldTester, used by the has() macro.
+func (m celMap) IsSet(field ref.Val) ref.Val {
+	return m.m.Contains(field)
+}
+
+// Contains implements traits.Container, used by the in operator.
+func (m celMap) Contains(index ref.Val) ref.Val {
+	return m.m.Contains(index)
+}
+
+// Iterator implements traits.Iterable.
+func (m celMap) Iterator() traits.Iterator {
+	return m.m.Iterator()
+}
+
+// Size implements traits.Sizer.
+func (m celMap) Size() ref.Val {
+	return m.m.Size()
+}
+
+func noSuchKey(key ref.Val) ref.Val {
+	return types.WrapErr(fmt.Errorf("%w: %v", ErrCELNoSuchKey, key))
+}
@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestCELCondition(t *testing.T) {
+	testConfig(t, true, httpResponseTestEvent, &Config{
+		CEL: `event.http.code == 200 && event.type == "http"`,
+	})
+	testConfig(t, true, secdTestEvent, &Config{
+		CEL: `event.proc.cpu.total > 6000 && "prod" in event.tags`,
+	})
+	testConfig(t, true, secdTestEvent, &Config{
+		CEL: `event["proc.name"] == "secd" && event.proc.keywords.exists(k, k == "bar")`,
+	})
+	testConfig(t, true, secdTestEvent, &Config{
+		CEL: `event["@timestamp"] > timestamp("2000-01-01T00:00:00Z")`,
+	})
+	testConfig(t, true, secdTestEvent, &Config{
+		CEL: `has(event.proc.ppid) && !has(event.proc.missing) && !("missing" in event)`,
+	})
+	testConfig(t, false, secdTestEvent, &Config{
+		CEL: `event.missing == 1`,
+	})
+	testConfig(t, false, secdTestEvent, &Config{
+		CEL: `event.final`,
+	})
+}
+
+func TestCELConditionValuesMap(t *testing.T) {
+	cond, err := NewCELCondition(`event.kubernetes.labels.app.startsWith("nginx")`)
+	assert.NoError(t, err)
+	assert.True(t, cond.Check(mapstr.M{
+		"kubernetes": mapstr.M{"labels": map[string]any{"app": "nginx-ingress"}},
+	}))
+	assert.False(t, cond.Check(mapstr.M{}))
+	assert.Equal(t, `cel: event.kubernetes.labels.app.startsWith("nginx")`, cond.String())
+}
+
+func TestCELConditionInvalid(t *testing.T) {
+	_, err := NewCELCondition(`event.`)
+	assert.ErrorContains(t, err, "failed to compile CEL expression")
+
+	_, err = NewCELCondition(`1 + 1`)
+	assert.ErrorContains(t, err, "must return bool")
+}
+
+func TestEvalCELMissingKey(t *testing.T) {
+	event := mapstr.M{"a": mapstr.M{"b": 1}}
+	for _, expr := range []string{`event.missing`, `event["a.c"]`, `event.a.c`, `event.a["c"]`, `event.a.c.d`} {
+		program, err := CompileCEL(expr, nil)
+		assert.NoError(t, err)
+		_, err = EvalCEL(program, event)
+		assert.True(t, errors.Is(err, ErrCELNoSuchKey), "%s: %v", expr, err)
+	}
+}
@@ -37,6 +37,7 @@ type Config struct {
 	OR        []Config       `config:"or"`
 	AND       []Config       `config:"and"`
 	NOT       *Config        `config:"not"`
+	CEL       string         `config:"cel"`
 }
 
 // Condition is the interface for all defined conditions
@@ -81,6 +82,8 @@ func NewCondition(config *Config, logger *logp.Logger) (Condition, error) {
 		var conditionsList []Condition
 		conditionsList, err = NewConditionList(config.AND, logger)
 		condition = NewAndCondition(conditionsList)
+	case config.CEL != "":
+		condition, err = NewCELCondition(config.CEL)
 	case config.NOT != nil:
 		var inner Condition
 		inner, err = NewCondition(config.NOT, logger)
@@ -27,6 +27,23 @@ import (
 	"github.com/elastic/elastic-agent-libs/testing"
 )
 
+// retryAfterError carries the delay an endpoint asked to wait before the
+// next attempt.
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+// RetryAfter annotates a publish error with the delay the endpoint asked to
+// wait before publishing again. Clients wrapped with WithBackoff wait for
+// this delay instead of their own backoff.
+func RetryAfter(err error, delay time.Duration) error {
+	return &retryAfterError{err: err, delay: delay}
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
 type backoffClient struct {
 	client         NetworkClient
 	connectBackoff backoff.Backoff
@@ -57,10 +74,24 @@ func (b *backoffClient) Publish(ctx context.Context, batch publisher.Batch) erro
 	if err != nil {
 		b.client.Close()
 	}
+	var retryAfter *retryAfterError
+	if errors.As(err, &retryAfter) {
+		waitFor(ctx, retryAfter.delay)
+		return err
+	}
 	backoff.WaitOnError(ctx, b.publishBackoff, err)
 	return err
 }
 
+func waitFor(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
 func (b *backoffClient) Client() NetworkClient {
 	return b.client
 }
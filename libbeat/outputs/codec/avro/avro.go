@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	wireFormatRaw       = "raw"
+	wireFormatConfluent = "confluent"
+
+	// magicByte starts every message in the Confluent wire format.
+	magicByte = 0
+)
+
+type Config struct {
+	Schema     string         `config:"schema"`
+	SchemaID   int            `config:"schema_id"`
+	Subject    string         `config:"subject"`
+	Registry   RegistryConfig `config:"registry"`
+	WireFormat string         `config:"wire_format"`
+}
+
+type RegistryConfig struct {
+	Path string `config:"path"`
+}
+
+func (c *Config) Validate() error {
+	switch {
+	case c.Schema == "" && c.Subject == "":
+		return errors.New("avro codec requires either schema or subject")
+	case c.Schema != "" && c.Subject != "":
+		return errors.New("avro codec schema and subject can not be used together")
+	case c.Subject != "" && c.Registry.Path == "":
+		return errors.New("avro codec subject requires registry.path")
+	}
+
+	switch c.WireFormat {
+	case "", wireFormatRaw, wireFormatConfluent:
+	default:
+		return fmt.Errorf("unknown avro wire_format '%v'", c.WireFormat)
+	}
+	if c.Schema != "" && c.WireFormat == wireFormatConfluent && c.SchemaID <= 0 {
+		return errors.New("avro codec confluent wire_format with an inline schema requires schema_id")
+	}
+	return nil
+}
+
+// Encoder encodes events as Avro records.
+type Encoder struct {
+	schema   *Schema
+	schemaID int
+	header   bool
+}
+
+func init() {
+	codec.RegisterType("avro", func(info beat.Info, cfg *config.C) (codec.Codec, error) {
+		config := Config{}
+		if cfg == nil {
+			return nil, errors.New("empty avro codec configuration")
+		}
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		if config.Schema != "" {
+			schema, err := Parse([]byte(config.Schema))
+			if err != nil {
+				return nil, err
+			}
+			return New(schema, config.SchemaID, config.WireFormat == wireFormatConfluent), nil
+		}
+
+		beatPaths := info.Paths
+		if beatPaths == nil {
+			beatPaths = paths.Paths
+		}
+		registry, err := NewLocalRegistry(beatPaths.Resolve(paths.Config, config.Registry.Path))
+		if err != nil {
+			return nil, err
+		}
+		id, schema, err := registry.Latest(config.Subject)
+		if err != nil {
+			return nil, err
+		}
+		return New(schema, id, config.WireFormat != wireFormatRaw), nil
+	})
+}
+
+// New creates an Avro encoder for schema. If header is set, every message is
+// prefixed with the Confluent wire format header carrying schemaID.
+func New(schema *Schema, schemaID int, header bool) *Encoder {
+	return &Encoder{schema: schema, schemaID: schemaID, header: header}
+}
+
+// Encode serializes the event. Record fields are read from the event fields,
+// `@timestamp` and `@metadata` can be referenced by a field's `source`.
+func (e *Encoder) Encode(_ string, event *beat.Event) ([]byte, error) {
+	doc := make(mapstr.M, len(event.Fields)+2)
+	for k, v := range event.Fields {
+		doc[k] = v
+	}
+	doc["@timestamp"] = event.Timestamp
+	if event.Meta != nil {
+		doc["@metadata"] = event.Meta
+	}
+
+	var buf []byte
+	if e.header {
+		buf = make([]byte, 5, 256)
+		buf[0] = magicByte
+		binary.BigEndian.PutUint32(buf[1:], uint32(e.schemaID))
+	}
+	return e.schema.Append(buf, doc)
+}
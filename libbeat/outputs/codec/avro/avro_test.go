@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const testSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "co.elastic.beats",
+  "fields": [
+    {"name": "message", "type": "string"},
+    {"name": "count", "type": ["null", "long"]},
+    {"name": "ts", "source": "@timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}, "default": []},
+    {"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["info", "warn"]}}
+  ]
+}`
+
+func TestEncode(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	event := &beat.Event{
+		Timestamp: time.UnixMilli(1000),
+		Fields: mapstr.M{
+			"message": "hi",
+			"tags":    []string{"a"},
+			"level":   "warn",
+		},
+	}
+
+	out, err := New(schema, 0, false).Encode("test", event)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x04, 'h', 'i', // message
+		0x00,       // count: null branch
+		0xd0, 0x0f, // ts: 1000
+		0x02, 0x02, 'a', 0x00, // tags
+		0x02, // level: warn
+	}, out)
+
+	event.Fields["count"] = 3
+	delete(event.Fields, "tags")
+	out, err = New(schema, 7, true).Encode("test", event)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x00, 0x00, 0x00, 0x07, // confluent header
+		0x04, 'h', 'i',
+		0x02, 0x06, // count: long branch, 3
+		0xd0, 0x0f,
+		0x00, // tags: default
+		0x02,
+	}, out)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+	enc := New(schema, 0, false)
+
+	_, err = enc.Encode("test", &beat.Event{Fields: mapstr.M{"level": "info"}})
+	assert.ErrorContains(t, err, "field 'message': missing value")
+
+	_, err = enc.Encode("test", &beat.Event{Fields: mapstr.M{"message": "x", "level": "debug"}})
+	assert.ErrorContains(t, err, "'debug' is not a symbol of enum 'co.elastic.beats.Level'")
+
+	_, err = enc.Encode("test", &beat.Event{Fields: mapstr.M{"message": "x", "count": "many", "level": "info"}})
+	assert.ErrorContains(t, err, "field 'count'")
+}
+
+func TestDecode(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	event := &beat.Event{
+		Timestamp: time.UnixMilli(1000),
+		Fields: mapstr.M{
+			"message": "hi",
+			"count":   3,
+			"tags":    []string{"a", "b"},
+			"level":   "warn",
+		},
+	}
+	out, err := New(schema, 0, false).Encode("test", event)
+	require.NoError(t, err)
+
+	v, err := schema.Decode(out)
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{
+		"message": "hi",
+		"count":   int64(3),
+		"ts":      time.UnixMilli(1000).UTC(),
+		"tags":    []any{"a", "b"},
+		"level":   "warn",
+	}, v)
+
+	// Blocks with a negative count carry their size in bytes.
+	schema, err = Parse([]byte(`{"type": "map", "values": "int"}`))
+	require.NoError(t, err)
+	v, err = schema.Decode([]byte{0x01, 0x06, 0x02, 'a', 0x02, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"a": int32(1)}, v)
+
+	_, err = schema.Decode([]byte{0x02, 0x02, 'a'})
+	assert.ErrorContains(t, err, "map key 'a': unexpected end of data")
+
+	_, err = schema.Decode([]byte{0x00, 0x00})
+	assert.ErrorContains(t, err, "1 trailing bytes after avro value")
+}
+
+func TestLocalRegistry(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema := func(name, schema string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(schema), 0o600))
+	}
+	writeSchema("events-value.3.avsc", `"string"`)
+	writeSchema("events-value.12.avsc", testSchema)
+	writeSchema("other.5.avsc", `"long"`)
+
+	r, err := NewLocalRegistry(dir)
+	require.NoError(t, err)
+
+	id, schema, err := r.Latest("events-value")
+	require.NoError(t, err)
+	assert.Equal(t, 12, id)
+	assert.Equal(t, Record, schema.Kind)
+
+	schema, err = r.ByID(3)
+	require.NoError(t, err)
+	assert.Equal(t, String, schema.Kind)
+
+	_, _, err = r.Latest("missing")
+	assert.Error(t, err)
+
+	enc, err := codec.CreateEncoder(beat.Info{}, codec.Config{
+		Namespace: mustNamespace(t, map[string]any{
+			"avro.subject":       "events-value",
+			"avro.registry.path": dir,
+		}),
+	})
+	require.NoError(t, err)
+	out, err := enc.Encode("test", &beat.Event{Fields: mapstr.M{"message": "", "level": "info"}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 12}, out[:5])
+
+	writeSchema("duplicate.12.avsc", `"string"`)
+	_, err = NewLocalRegistry(dir)
+	assert.ErrorContains(t, err, "schema ID 12 is used by subjects")
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]Config{
+		"no schema":          {},
+		"schema and subject": {Schema: `"string"`, Subject: "s", Registry: RegistryConfig{Path: "x"}},
+		"no registry":        {Subject: "s"},
+		"unknown wire":       {Schema: `"string"`, WireFormat: "json"},
+		"confluent inline":   {Schema: `"string"`, WireFormat: wireFormatConfluent},
+	}
+	for name, c := range tests {
+		assert.Error(t, c.Validate(), name)
+	}
+
+	valid := Config{Schema: `"string"`, WireFormat: wireFormatConfluent, SchemaID: 1}
+	assert.NoError(t, valid.Validate())
+}
+
+func mustNamespace(t *testing.T, settings map[string]any) config.Namespace {
+	t.Helper()
+	var ns config.Namespace
+	require.NoError(t, config.MustNewConfigFrom(settings).Unpack(&ns))
+	return ns
+}
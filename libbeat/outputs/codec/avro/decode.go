@@ -0,0 +1,233 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var errShortBuffer = errors.New("unexpected end of data")
+
+// Decode decodes data encoded with the Avro binary encoding of the schema.
+// Records and maps are decoded as mapstr.M, arrays as []any, enums as their
+// symbol and longs with a timestamp or date logical type as time.Time.
+func (s *Schema) Decode(data []byte) (any, error) {
+	v, rest, err := s.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%v trailing bytes after avro value", len(rest))
+	}
+	return v, nil
+}
+
+func (s *Schema) decode(data []byte) (any, []byte, error) {
+	switch s.Kind {
+	case Null:
+		return nil, data, nil
+
+	case Boolean:
+		if len(data) < 1 {
+			return nil, nil, errShortBuffer
+		}
+		return data[0] != 0, data[1:], nil
+
+	case Int, Long:
+		n, rest, err := readLong(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ts, ok := s.toTime(n); ok {
+			return ts, rest, nil
+		}
+		if s.Kind == Int {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, nil, fmt.Errorf("value %v overflows avro int", n)
+			}
+			return int32(n), rest, nil
+		}
+		return n, rest, nil
+
+	case Float:
+		if len(data) < 4 {
+			return nil, nil, errShortBuffer
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), data[4:], nil
+
+	case Double:
+		if len(data) < 8 {
+			return nil, nil, errShortBuffer
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+
+	case Bytes, String:
+		b, rest, err := readBytes(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s.Kind == String {
+			return string(b), rest, nil
+		}
+		return append([]byte(nil), b...), rest, nil
+
+	case Fixed:
+		if len(data) < s.Size {
+			return nil, nil, errShortBuffer
+		}
+		return append([]byte(nil), data[:s.Size]...), data[s.Size:], nil
+
+	case Enum:
+		i, rest, err := readLong(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i < 0 || i >= int64(len(s.Symbols)) {
+			return nil, nil, fmt.Errorf("invalid index %v of enum '%v'", i, s.Name)
+		}
+		return s.Symbols[i], rest, nil
+
+	case Array:
+		var items []any
+		rest, err := readBlocks(data, func(data []byte) ([]byte, error) {
+			item, rest, err := s.Items.decode(data)
+			if err != nil {
+				return nil, fmt.Errorf("array item %v: %w", len(items), err)
+			}
+			items = append(items, item)
+			return rest, nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if items == nil {
+			items = []any{}
+		}
+		return items, rest, nil
+
+	case Map:
+		m := mapstr.M{}
+		rest, err := readBlocks(data, func(data []byte) ([]byte, error) {
+			k, rest, err := readBytes(data)
+			if err != nil {
+				return nil, err
+			}
+			value, rest, err := s.Values.decode(rest)
+			if err != nil {
+				return nil, fmt.Errorf("map key '%s': %w", k, err)
+			}
+			m[string(k)] = value
+			return rest, nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return m, rest, nil
+
+	case Record:
+		m := make(mapstr.M, len(s.Fields))
+		for _, f := range s.Fields {
+			value, rest, err := f.Type.decode(data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("field '%v': %w", f.Name, err)
+			}
+			m[f.Name] = value
+			data = rest
+		}
+		return m, data, nil
+
+	case Union:
+		i, rest, err := readLong(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i < 0 || i >= int64(len(s.Branches)) {
+			return nil, nil, fmt.Errorf("invalid union branch %v", i)
+		}
+		return s.Branches[i].decode(rest)
+	}
+	return nil, nil, fmt.Errorf("unsupported avro kind %v", s.Kind)
+}
+
+// toTime converts n to a time if the schema has a timestamp or date logical
+// type.
+func (s *Schema) toTime(n int64) (time.Time, bool) {
+	switch s.LogicalType {
+	case "timestamp-millis", "local-timestamp-millis":
+		return time.UnixMilli(n).UTC(), true
+	case "timestamp-micros", "local-timestamp-micros":
+		return time.UnixMicro(n).UTC(), true
+	case "date":
+		return time.Unix(n*86400, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// readBlocks reads the blocks of an array or map, calling readItem for each
+// item.
+func readBlocks(data []byte, readItem func([]byte) ([]byte, error)) ([]byte, error) {
+	for {
+		n, rest, err := readLong(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+		if n == 0 {
+			return data, nil
+		}
+		if n < 0 {
+			// A negative count is followed by the size of the block in bytes.
+			n = -n
+			if _, data, err = readLong(data); err != nil {
+				return nil, err
+			}
+		}
+		for ; n > 0; n-- {
+			if data, err = readItem(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readLong reads a zig-zag encoded variable length integer.
+func readLong(data []byte) (int64, []byte, error) {
+	u, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errShortBuffer
+	}
+	return int64(u>>1) ^ -int64(u&1), data[n:], nil
+}
+
+// readBytes reads length prefixed bytes.
+func readBytes(data []byte) ([]byte, []byte, error) {
+	n, rest, err := readLong(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < 0 || n > int64(len(rest)) {
+		return nil, nil, errShortBuffer
+	}
+	return rest[:n], rest[n:], nil
+}
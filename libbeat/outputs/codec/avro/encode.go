@@ -0,0 +1,317 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var errMissing = errors.New("missing value")
+
+// Append encodes v using the Avro binary encoding of the schema and appends
+// the result to buf.
+func (s *Schema) Append(buf []byte, v any) ([]byte, error) {
+	switch s.Kind {
+	case Null:
+		if v != nil {
+			return nil, fmt.Errorf("expected null, got %T", v)
+		}
+		return buf, nil
+
+	case Boolean:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, typeError("boolean", v)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+
+	case Int, Long:
+		n, err := s.toInteger(v)
+		if err != nil {
+			return nil, err
+		}
+		if s.Kind == Int && (n < math.MinInt32 || n > math.MaxInt32) {
+			return nil, fmt.Errorf("value %v overflows avro int", n)
+		}
+		return appendLong(buf, n), nil
+
+	case Float:
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, typeError("float", v)
+		}
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+
+	case Double:
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, typeError("double", v)
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+
+	case Bytes, String:
+		var data []byte
+		switch b := v.(type) {
+		case string:
+			data = []byte(b)
+		case []byte:
+			data = b
+		case time.Time:
+			data = []byte(b.UTC().Format(time.RFC3339Nano))
+		case common.Time:
+			data = []byte(time.Time(b).UTC().Format(time.RFC3339Nano))
+		default:
+			return nil, typeError("string", v)
+		}
+		buf = appendLong(buf, int64(len(data)))
+		return append(buf, data...), nil
+
+	case Fixed:
+		b, ok := v.([]byte)
+		if !ok || len(b) != s.Size {
+			return nil, fmt.Errorf("expected %v bytes for fixed '%v'", s.Size, s.Name)
+		}
+		return append(buf, b...), nil
+
+	case Enum:
+		sym, ok := v.(string)
+		if !ok {
+			return nil, typeError("enum symbol", v)
+		}
+		for i, known := range s.Symbols {
+			if known == sym {
+				return appendLong(buf, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("'%v' is not a symbol of enum '%v'", sym, s.Name)
+
+	case Array:
+		return s.appendArray(buf, v)
+
+	case Map:
+		return s.appendMap(buf, v)
+
+	case Record:
+		return s.appendRecord(buf, v)
+
+	case Union:
+		return s.appendUnion(buf, v)
+	}
+	return nil, fmt.Errorf("unsupported avro kind %v", s.Kind)
+}
+
+func (s *Schema) appendUnion(buf []byte, v any) ([]byte, error) {
+	// The first branch that accepts the value wins. Failed attempts only
+	// write past len(buf), so buf itself is never modified.
+	var lastErr error
+	for i, branch := range s.Branches {
+		if (v == nil) != (branch.Kind == Null) {
+			continue
+		}
+		out, err := branch.Append(appendLong(buf, int64(i)), v)
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = typeError("union branch", v)
+	}
+	return nil, lastErr
+}
+
+func (s *Schema) appendRecord(buf []byte, v any) ([]byte, error) {
+	m, ok := toMap(v)
+	if !ok {
+		return nil, typeError("record", v)
+	}
+
+	var err error
+	for _, f := range s.Fields {
+		value, getErr := m.GetValue(f.Source)
+		if getErr != nil {
+			value = nil
+			if f.HasDefault {
+				value = f.Default
+			}
+		}
+		buf, err = f.Type.Append(buf, value)
+		if err != nil {
+			if getErr != nil && !f.HasDefault {
+				err = errMissing
+			}
+			return nil, fmt.Errorf("field '%v': %w", f.Name, err)
+		}
+	}
+	return buf, nil
+}
+
+func (s *Schema) appendArray(buf []byte, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, typeError("array", v)
+	}
+
+	n := rv.Len()
+	if n > 0 {
+		buf = appendLong(buf, int64(n))
+		var err error
+		for i := 0; i < n; i++ {
+			buf, err = s.Items.Append(buf, rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("array item %v: %w", i, err)
+			}
+		}
+	}
+	return appendLong(buf, 0), nil
+}
+
+func (s *Schema) appendMap(buf []byte, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, typeError("map", v)
+	}
+
+	keys := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	if len(keys) > 0 {
+		buf = appendLong(buf, int64(len(keys)))
+		var err error
+		for _, k := range keys {
+			buf = appendLong(buf, int64(len(k)))
+			buf = append(buf, k...)
+			value := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface()
+			buf, err = s.Values.Append(buf, value)
+			if err != nil {
+				return nil, fmt.Errorf("map key '%v': %w", k, err)
+			}
+		}
+	}
+	return appendLong(buf, 0), nil
+}
+
+func (s *Schema) toInteger(v any) (int64, error) {
+	var ts time.Time
+	switch t := v.(type) {
+	case time.Time:
+		ts = t
+	case common.Time:
+		ts = time.Time(t)
+	default:
+		n, ok := toInt64(v)
+		if !ok {
+			return 0, typeError("integer", v)
+		}
+		return n, nil
+	}
+
+	switch s.LogicalType {
+	case "timestamp-millis", "local-timestamp-millis":
+		return ts.UnixMilli(), nil
+	case "timestamp-micros", "local-timestamp-micros":
+		return ts.UnixMicro(), nil
+	case "date":
+		return ts.Unix() / 86400, nil
+	}
+	return 0, fmt.Errorf("timestamp requires a timestamp or date logical type")
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), n <= math.MaxInt64
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case float32:
+		return int64(n), float32(int64(n)) == n
+	case float64:
+		return int64(n), float64(int64(n)) == n
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func toMap(v any) (mapstr.M, bool) {
+	switch m := v.(type) {
+	case mapstr.M:
+		return m, true
+	case map[string]any:
+		return mapstr.M(m), true
+	}
+	return nil, false
+}
+
+func typeError(expected string, v any) error {
+	return fmt.Errorf("expected %v, got %T", expected, v)
+}
+
+// appendLong appends n as a zig-zag encoded variable length integer.
+func appendLong(buf []byte, n int64) []byte {
+	return binary.AppendUvarint(buf, uint64((n<<1)^(n>>63)))
+}
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Registry resolves schemas like a Confluent compatible schema registry:
+// producers look up the latest schema of a subject, consumers look up
+// schemas by the ID found in the message.
+type Registry interface {
+	Latest(subject string) (int, *Schema, error)
+	ByID(id int) (*Schema, error)
+}
+
+// LocalRegistry is a schema registry stand-in backed by a directory of
+// `<subject>.<id>.avsc` files. The schema with the highest ID is the latest
+// version of a subject.
+type LocalRegistry struct {
+	byID    map[int]*Schema
+	latest  map[string]int
+	subject map[int]string
+}
+
+// NewLocalRegistry loads all schema files from dir.
+func NewLocalRegistry(dir string) (*LocalRegistry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.avsc"))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &LocalRegistry{
+		byID:    map[int]*Schema{},
+		latest:  map[string]int{},
+		subject: map[int]string{},
+	}
+	for _, path := range paths {
+		base := strings.TrimSuffix(filepath.Base(path), ".avsc")
+		i := strings.LastIndexByte(base, '.')
+		if i <= 0 {
+			return nil, fmt.Errorf("schema file %v is not named <subject>.<id>.avsc", path)
+		}
+		subject := base[:i]
+		id, err := strconv.Atoi(base[i+1:])
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("schema file %v is not named <subject>.<id>.avsc", path)
+		}
+		if other, exists := r.subject[id]; exists {
+			return nil, fmt.Errorf("schema ID %v is used by subjects '%v' and '%v'", id, other, subject)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schema file %v: %w", path, err)
+		}
+
+		r.byID[id] = schema
+		r.subject[id] = subject
+		if latest, ok := r.latest[subject]; !ok || id > latest {
+			r.latest[subject] = id
+		}
+	}
+	return r, nil
+}
+
+// Latest returns the ID and schema of the latest version of subject.
+func (r *LocalRegistry) Latest(subject string) (int, *Schema, error) {
+	id, ok := r.latest[subject]
+	if !ok {
+		return 0, nil, fmt.Errorf("no schema registered for subject '%v'", subject)
+	}
+	return id, r.byID[id], nil
+}
+
+// ByID returns the schema registered with the given ID.
+func (r *LocalRegistry) ByID(id int) (*Schema, error) {
+	schema, ok := r.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("no schema registered with ID %v", id)
+	}
+	return schema, nil
+}
@@ -0,0 +1,264 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind identifies the type of an Avro schema node.
+type Kind int
+
+const (
+	Null Kind = iota
+	Boolean
+	Int
+	Long
+	Float
+	Double
+	Bytes
+	String
+	Record
+	Enum
+	Array
+	Map
+	Union
+	Fixed
+)
+
+var primitiveKinds = map[string]Kind{
+	"null":    Null,
+	"boolean": Boolean,
+	"int":     Int,
+	"long":    Long,
+	"float":   Float,
+	"double":  Double,
+	"bytes":   Bytes,
+	"string":  String,
+}
+
+// Schema is a parsed Avro schema.
+type Schema struct {
+	Kind        Kind
+	Name        string // full name of records, enums and fixed types
+	LogicalType string
+
+	Fields   []*Field  // Record
+	Symbols  []string  // Enum
+	Items    *Schema   // Array
+	Values   *Schema   // Map
+	Branches []*Schema // Union
+	Size     int       // Fixed
+}
+
+// Field is a single field of a record schema.
+type Field struct {
+	Name string
+
+	// Source is the dotted path of the event field the value is read from.
+	// It is set from the non-standard `source` attribute and defaults to the
+	// field name, so fields like `@timestamp` that are not valid Avro names
+	// can still be mapped.
+	Source string
+
+	Type       *Schema
+	Default    any
+	HasDefault bool
+}
+
+// Parse parses an Avro schema in its JSON representation.
+func Parse(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	return parse(raw, "", map[string]*Schema{})
+}
+
+func parse(raw any, namespace string, names map[string]*Schema) (*Schema, error) {
+	switch v := raw.(type) {
+	case string:
+		if kind, ok := primitiveKinds[v]; ok {
+			return &Schema{Kind: kind}, nil
+		}
+		if s, ok := names[fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := names[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown avro type '%v'", v)
+
+	case []any:
+		s := &Schema{Kind: Union}
+		for _, b := range v {
+			branch, err := parse(b, namespace, names)
+			if err != nil {
+				return nil, err
+			}
+			if branch.Kind == Union {
+				return nil, errors.New("avro unions must not contain unions")
+			}
+			s.Branches = append(s.Branches, branch)
+		}
+		if len(s.Branches) == 0 {
+			return nil, errors.New("avro union must have at least one branch")
+		}
+		return s, nil
+
+	case map[string]any:
+		return parseComplex(v, namespace, names)
+	}
+	return nil, fmt.Errorf("invalid avro schema node of type %T", raw)
+}
+
+func parseComplex(v map[string]any, namespace string, names map[string]*Schema) (*Schema, error) {
+	logicalType, _ := v["logicalType"].(string)
+
+	typ, ok := v["type"].(string)
+	if !ok {
+		// {"type": {...}} or {"type": [...]}
+		return parse(v["type"], namespace, names)
+	}
+
+	if kind, ok := primitiveKinds[typ]; ok {
+		return &Schema{Kind: kind, LogicalType: logicalType}, nil
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		return parseNamed(typ, v, namespace, names)
+
+	case "array":
+		items, err := parse(v["items"], namespace, names)
+		if err != nil {
+			return nil, fmt.Errorf("array items: %w", err)
+		}
+		return &Schema{Kind: Array, Items: items}, nil
+
+	case "map":
+		values, err := parse(v["values"], namespace, names)
+		if err != nil {
+			return nil, fmt.Errorf("map values: %w", err)
+		}
+		return &Schema{Kind: Map, Values: values}, nil
+	}
+
+	return parse(typ, namespace, names)
+}
+
+func parseNamed(typ string, v map[string]any, namespace string, names map[string]*Schema) (*Schema, error) {
+	name, _ := v["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("avro %v schema requires a name", typ)
+	}
+	if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	full := fullName(name, namespace)
+	if _, exists := names[full]; exists {
+		return nil, fmt.Errorf("avro type '%v' is defined twice", full)
+	}
+	if i := strings.LastIndexByte(full, '.'); i >= 0 {
+		namespace = full[:i]
+	}
+
+	s := &Schema{Name: full}
+	names[full] = s
+
+	switch typ {
+	case "enum":
+		s.Kind = Enum
+		symbols, _ := v["symbols"].([]any)
+		for _, sym := range symbols {
+			str, ok := sym.(string)
+			if !ok {
+				return nil, fmt.Errorf("avro enum '%v' has a non-string symbol", full)
+			}
+			s.Symbols = append(s.Symbols, str)
+		}
+		if len(s.Symbols) == 0 {
+			return nil, fmt.Errorf("avro enum '%v' requires symbols", full)
+		}
+
+	case "fixed":
+		s.Kind = Fixed
+		size, ok := v["size"].(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("avro fixed '%v' requires a size", full)
+		}
+		n, err := size.Int64()
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("avro fixed '%v' has an invalid size", full)
+		}
+		s.Size = int(n)
+		s.LogicalType, _ = v["logicalType"].(string)
+
+	default:
+		s.Kind = Record
+		fields, ok := v["fields"].([]any)
+		if !ok {
+			return nil, fmt.Errorf("avro record '%v' requires fields", full)
+		}
+		for _, rawField := range fields {
+			f, err := parseField(rawField, namespace, names)
+			if err != nil {
+				return nil, fmt.Errorf("record '%v': %w", full, err)
+			}
+			s.Fields = append(s.Fields, f)
+		}
+	}
+	return s, nil
+}
+
+func parseField(raw any, namespace string, names map[string]*Schema) (*Field, error) {
+	v, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.New("field definition must be an object")
+	}
+	name, _ := v["name"].(string)
+	if name == "" {
+		return nil, errors.New("field requires a name")
+	}
+
+	typ, err := parse(v["type"], namespace, names)
+	if err != nil {
+		return nil, fmt.Errorf("field '%v': %w", name, err)
+	}
+
+	f := &Field{Name: name, Source: name, Type: typ}
+	if src, ok := v["source"].(string); ok && src != "" {
+		f.Source = src
+	}
+	f.Default, f.HasDefault = v["default"]
+	return f, nil
+}
+
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
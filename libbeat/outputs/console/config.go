@@ -28,8 +28,9 @@ type Config struct {
 	// old pretty settings to use if no codec is configured
 	Pretty bool `config:"pretty"`
 
-	BatchSize int
-	Queue     config.Namespace `config:"queue"`
+	BatchSize  int
+	Queue      config.Namespace `config:"queue"`
+	DeadLetter config.Namespace `config:"dead_letter"`
 }
 
 var defaultConfig = Config{}
@@ -40,6 +40,7 @@ type console struct {
 	writer   *bufio.Writer
 	codec    codec.Codec
 	index    string
+	dl       *outputs.DeadLetter
 }
 
 func init() {
@@ -47,7 +48,7 @@ func init() {
 }
 
 func makeConsole(
-	_ outputs.IndexManager,
+	im outputs.IndexManager,
 	beat beat.Info,
 	observer outputs.Observer,
 	cfg *config.C,
@@ -86,7 +87,12 @@ func makeConsole(
 		}
 	}
 
-	return outputs.Success(config.Queue, config.BatchSize, 0, nil, beat.Logger, beat.Paths, c)
+	c.dl, err = outputs.NewDeadLetter(im, beat, observer, "console", config.DeadLetter)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	return c.dl.Attach(outputs.Success(config.Queue, config.BatchSize, 0, nil, beat.Logger, beat.Paths, c))
 }
 
 func newConsole(index string, observer outputs.Observer, codec codec.Codec, logger *logp.Logger) (*console, error) {
@@ -123,6 +129,7 @@ var nl = []byte("\n")
 func (c *console) publishEvent(event *publisher.Event) bool {
 	serializedEvent, err := c.codec.Encode(c.index, &event.Content)
 	if err != nil {
+		c.dl.Send(*event, err)
 		if !event.Guaranteed() {
 			return false
 		}
@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	deadLetterFile   = "file"
+	deadLetterOutput = "output"
+)
+
+var errDeadLetterClosed = errors.New("dead-letter sink is closed")
+
+// DeadLetter routes the events an output permanently failed to send to a
+// dead-letter sink, instead of dropping them. The sink is either a local
+// file with rotation or a second output, configured in the output's
+// dead_letter section.
+//
+// Each event is wrapped in a new event holding the original event's JSON
+// encoding in `message`, the reason in `error.message` and the name of the
+// output that rejected it in `dead_letter.output`.
+//
+// The clients of an output share its sink. They may be closed and
+// reconnected many times, so they don't close it: the sink belongs to the
+// output's Group and is closed when the group is torn down, see Attach.
+//
+// A nil *DeadLetter is valid and drops every event.
+type DeadLetter struct {
+	output   string
+	sink     deadLetterSink
+	observer Observer
+	logger   *logp.Logger
+	index    string
+	encoder  *json.Encoder
+
+	mu        sync.Mutex // protects encoder
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+// deadLetterSink writes dead-letter events.
+type deadLetterSink interface {
+	// write stores the event, or returns an error if it was dropped.
+	write(event beat.Event) error
+	close() error
+}
+
+// NewDeadLetter returns the dead-letter sink configured in cfg for the
+// given output, or nil if cfg isn't set. The caller owns the result until
+// it hands it over to the output's group with Attach.
+func NewDeadLetter(
+	im IndexManager,
+	beatInfo beat.Info,
+	observer Observer,
+	output string,
+	cfg config.Namespace,
+) (*DeadLetter, error) {
+	if !cfg.IsSet() {
+		return nil, nil
+	}
+	if observer == nil {
+		observer = NewNilObserver()
+	}
+	logger := beatInfo.Logger.Named("dead_letter")
+
+	var sink deadLetterSink
+	var err error
+	switch cfg.Name() {
+	case deadLetterFile:
+		sink, err = newDeadLetterFile(beatInfo, logger, cfg.Config())
+	case deadLetterOutput:
+		sink, err = newDeadLetterOutput(im, beatInfo, logger, cfg.Config())
+	default:
+		err = fmt.Errorf("unknown dead_letter type %q, must be %q or %q",
+			cfg.Name(), deadLetterFile, deadLetterOutput)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error initializing dead_letter for the %s output: %w", output, err)
+	}
+
+	d := &DeadLetter{
+		output:   output,
+		sink:     sink,
+		observer: observer,
+		logger:   logger,
+		index:    beatInfo.Beat,
+		encoder:  json.New(beatInfo.Version, json.Config{}),
+	}
+	return d, nil
+}
+
+// Attach hands the sink over to the group returned by an output factory,
+// so it is closed with the group after its clients. The sink is closed
+// right away if the factory failed.
+func (d *DeadLetter) Attach(group Group, err error) (Group, error) {
+	if err != nil {
+		_ = d.Close()
+		return group, err
+	}
+	if d != nil {
+		group.Shared = append(group.Shared, d)
+		group.DeadLetter = d
+	}
+	return group, nil
+}
+
+// Close closes the sink. Events sent afterwards are dropped.
+func (d *DeadLetter) Close() error {
+	if d == nil {
+		return nil
+	}
+	var err error
+	d.closeOnce.Do(func() {
+		if dropped := d.dropped.Load(); dropped > 0 {
+			d.logger.Warnf("%d events couldn't be written to the dead-letter sink", dropped)
+		}
+		err = d.sink.close()
+	})
+	return err
+}
+
+// Send hands an event the output gave up on to the sink, with the reason.
+// It returns true if the sink accepted the event, and false if it was
+// dropped, in which case the caller should handle it as it would without
+// a dead-letter sink.
+func (d *DeadLetter) Send(event publisher.Event, reason error) bool {
+	if d == nil {
+		return false
+	}
+	if err := d.sink.write(d.wrap(event, reason)); err != nil {
+		if d.dropped.Add(1) == 1 {
+			d.logger.Errorf("Dropping event that couldn't be written to the dead-letter sink: %v", err)
+		}
+		return false
+	}
+	d.observer.DeadLetterEvents(1)
+	return true
+}
+
+// wrap returns the dead-letter event for an event and the reason it was
+// rejected.
+func (d *DeadLetter) wrap(event publisher.Event, reason error) beat.Event {
+	var message string
+	d.mu.Lock()
+	encoded, err := d.encoder.Encode(d.index, &event.Content)
+	if err == nil {
+		message = string(encoded)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		// The event may have been rejected because it can't be encoded.
+		message = event.Content.Fields.String()
+	}
+
+	reasonMessage := "unknown error"
+	if reason != nil {
+		reasonMessage = reason.Error()
+	}
+	return beat.Event{
+		Timestamp: event.Content.Timestamp,
+		Fields: mapstr.M{
+			"message": message,
+			"error": mapstr.M{
+				"message": reasonMessage,
+			},
+			"dead_letter": mapstr.M{
+				"output": d.output,
+			},
+		},
+	}
+}
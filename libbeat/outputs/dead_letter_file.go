@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/file"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+type deadLetterFileConfig struct {
+	Path          string `config:"path"`
+	Filename      string `config:"filename"`
+	RotateEveryKb uint   `config:"rotate_every_kb" validate:"min=1"`
+	NumberOfFiles uint   `config:"number_of_files"`
+	Permissions   uint32 `config:"permissions"`
+}
+
+func (c *deadLetterFileConfig) Validate() error {
+	if c.NumberOfFiles < 2 || c.NumberOfFiles > file.MaxBackupsLimit {
+		return fmt.Errorf("the number_of_files to keep should be between 2 and %v",
+			file.MaxBackupsLimit)
+	}
+	return nil
+}
+
+// deadLetterFileSink writes dead-letter events as NDJSON to a rotated file.
+type deadLetterFileSink struct {
+	mu      sync.Mutex
+	closed  bool
+	rotator *file.Rotator
+	encoder *json.Encoder
+	index   string
+}
+
+func newDeadLetterFile(beatInfo beat.Info, logger *logp.Logger, cfg *config.C) (*deadLetterFileSink, error) {
+	c := deadLetterFileConfig{
+		RotateEveryKb: 10 * 1024,
+		NumberOfFiles: 7,
+		Permissions:   0600,
+	}
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, err
+	}
+	if c.Path == "" {
+		beatPaths := beatInfo.Paths
+		if beatPaths == nil {
+			beatPaths = paths.Paths
+		}
+		c.Path = beatPaths.Resolve(paths.Data, "dead_letter")
+	}
+	if c.Filename == "" {
+		c.Filename = beatInfo.Beat
+	}
+
+	path := filepath.Join(c.Path, c.Filename)
+	rotator, err := file.NewFileRotator(
+		path,
+		file.MaxSizeBytes(c.RotateEveryKb*1024),
+		file.MaxBackups(c.NumberOfFiles),
+		file.Permissions(os.FileMode(c.Permissions)),
+		file.WithLogger(logger.Named("rotator").With(logp.Namespace("rotator"))),
+	)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Writing dead-letter events to %v", path)
+	return &deadLetterFileSink{
+		rotator: rotator,
+		encoder: json.New(beatInfo.Version, json.Config{}),
+		index:   beatInfo.Beat,
+	}, nil
+}
+
+func (s *deadLetterFileSink) write(event beat.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errDeadLetterClosed
+	}
+	encoded, err := s.encoder.Encode(s.index, &event)
+	if err != nil {
+		return err
+	}
+	_, err = s.rotator.Write(append(encoded, '\n'))
+	return err
+}
+
+func (s *deadLetterFileSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.rotator.Close()
+}
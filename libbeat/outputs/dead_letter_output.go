@@ -0,0 +1,218 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	// deadLetterQueueSize is the number of dead-letter events buffered for
+	// the second output. Events beyond it are dropped.
+	deadLetterQueueSize = 4096
+
+	deadLetterMaxBatchSize = 512
+	deadLetterBackoffInit  = time.Second
+	deadLetterBackoffMax   = time.Minute
+)
+
+var errDeadLetterFull = errors.New("dead-letter output queue is full")
+
+// deadLetterOutputSink sends dead-letter events to a second output. Events
+// are buffered in memory and published by a background goroutine, which
+// retries failed batches until the sink is closed.
+type deadLetterOutputSink struct {
+	logger    *logp.Logger
+	group     Group
+	client    Client
+	encoder   queue.Encoder[publisher.Event]
+	batchSize int
+
+	events chan beat.Event
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newDeadLetterOutput(im IndexManager, beatInfo beat.Info, logger *logp.Logger, cfg *config.C) (*deadLetterOutputSink, error) {
+	var outCfg config.Namespace
+	if err := cfg.Unpack(&outCfg); err != nil {
+		return nil, err
+	}
+	if !outCfg.IsSet() {
+		return nil, errors.New("dead_letter.output needs an output configuration")
+	}
+	if outCfg.Config().HasField("dead_letter") {
+		return nil, errors.New("the dead_letter output can't have a dead_letter section")
+	}
+
+	group, err := Load(im, beatInfo, nil, outCfg.Name(), outCfg.Config())
+	if err != nil {
+		return nil, fmt.Errorf("error initializing dead_letter %s output: %w", outCfg.Name(), err)
+	}
+	if len(group.Clients) == 0 {
+		_ = group.CloseShared()
+		return nil, fmt.Errorf("dead_letter %s output has no clients", outCfg.Name())
+	}
+	// The extra clients of load balanced outputs are not needed.
+	for _, c := range group.Clients[1:] {
+		_ = c.Close()
+	}
+
+	batchSize := group.BatchSize
+	if batchSize <= 0 || batchSize > deadLetterMaxBatchSize {
+		batchSize = deadLetterMaxBatchSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &deadLetterOutputSink{
+		logger:    logger,
+		group:     group,
+		client:    group.Clients[0],
+		batchSize: batchSize,
+		events:    make(chan beat.Event, deadLetterQueueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	if group.EncoderFactory != nil {
+		s.encoder = group.EncoderFactory()
+	}
+	logger.Infof("Sending dead-letter events to the %v output", outCfg.Name())
+	go s.run()
+	return s, nil
+}
+
+func (s *deadLetterOutputSink) write(event beat.Event) error {
+	select {
+	case <-s.ctx.Done():
+		return errDeadLetterClosed
+	default:
+	}
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return errDeadLetterFull
+	}
+}
+
+// close stops the sink. Events that haven't been published yet are lost.
+func (s *deadLetterOutputSink) close() error {
+	s.cancel()
+	<-s.done
+	if n := len(s.events); n > 0 {
+		s.logger.Warnf("%d dead-letter events weren't published before shutdown", n)
+	}
+	err := s.client.Close()
+	return errors.Join(err, s.group.CloseShared())
+}
+
+func (s *deadLetterOutputSink) run() {
+	defer close(s.done)
+
+	b := backoff.NewEqualJitterBackoff(deadLetterBackoffInit, deadLetterBackoffMax)
+	if !s.connect(b) {
+		return
+	}
+
+	for {
+		var events []publisher.Event
+		select {
+		case <-s.ctx.Done():
+			return
+		case event := <-s.events:
+			events = append(events, s.encode(event))
+		}
+	collect:
+		for len(events) < s.batchSize {
+			select {
+			case event := <-s.events:
+				events = append(events, s.encode(event))
+			default:
+				break collect
+			}
+		}
+		if !s.publish(events, b) {
+			return
+		}
+	}
+}
+
+// connect connects the client, retrying with backoff until it succeeds.
+// It returns false if the sink was closed first.
+func (s *deadLetterOutputSink) connect(b backoff.Backoff) bool {
+	c, ok := s.client.(Connectable)
+	if !ok {
+		return true
+	}
+	for {
+		err := c.Connect(s.ctx)
+		if err == nil {
+			return true
+		}
+		s.logger.Errorf("Failed to connect to the dead-letter output: %v", err)
+		if !b.Wait(s.ctx) {
+			return false
+		}
+	}
+}
+
+func (s *deadLetterOutputSink) encode(event beat.Event) publisher.Event {
+	e := publisher.Event{Content: event}
+	if s.encoder != nil {
+		e, _ = s.encoder.EncodeEntry(e)
+	}
+	return e
+}
+
+// publish sends the events, retrying until they are acknowledged or
+// dropped by the output. A failed publish is retried on a new connection,
+// as the pipeline's output workers do. It returns false if the sink was
+// closed first.
+func (s *deadLetterOutputSink) publish(events []publisher.Event, b backoff.Backoff) bool {
+	_, dropped, err := PublishSync(s.ctx, s.client, events, func(_ int, _ BatchResult, err error) error {
+		if err != nil {
+			s.logger.Errorf("Failed to publish to the dead-letter output: %v", err)
+		}
+		if !b.Wait(s.ctx) {
+			return s.ctx.Err()
+		}
+		if err != nil && !s.connect(b) {
+			return s.ctx.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+	if dropped > 0 {
+		s.logger.Errorf("The dead-letter output dropped %d events", dropped)
+	}
+	b.Reset()
+	return true
+}
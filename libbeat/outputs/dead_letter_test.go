@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func deadLetterNamespace(t *testing.T, cfg map[string]any) config.Namespace {
+	t.Helper()
+	var ns config.Namespace
+	require.NoError(t, config.MustNewConfigFrom(cfg).Unpack(&ns))
+	return ns
+}
+
+func deadLetterTestEvent(msg string) publisher.Event {
+	return publisher.Event{Content: beat.Event{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:    mapstr.M{"message": msg},
+	}}
+}
+
+func TestDeadLetterDisabled(t *testing.T) {
+	beatInfo := beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}
+	dl, err := NewDeadLetter(nil, beatInfo, nil, "test", config.Namespace{})
+	require.NoError(t, err)
+	assert.Nil(t, dl)
+
+	// A nil DeadLetter drops everything.
+	assert.False(t, dl.Send(deadLetterTestEvent("hello"), errors.New("boom")))
+	group, err := dl.Attach(Group{}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, group.Shared)
+	assert.NoError(t, dl.Close())
+}
+
+func TestDeadLetterUnknownType(t *testing.T) {
+	beatInfo := beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}
+	_, err := NewDeadLetter(nil, beatInfo, nil, "test", deadLetterNamespace(t, map[string]any{
+		"unknown": map[string]any{},
+	}))
+	assert.ErrorContains(t, err, `unknown dead_letter type "unknown"`)
+}
+
+func TestDeadLetterFile(t *testing.T) {
+	dir := t.TempDir()
+	beatInfo := beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}
+	dl, err := NewDeadLetter(nil, beatInfo, nil, "kafka", deadLetterNamespace(t, map[string]any{
+		"file.path": dir,
+	}))
+	require.NoError(t, err)
+
+	assert.True(t, dl.Send(deadLetterTestEvent("first"), errors.New("message too large")))
+	assert.True(t, dl.Send(deadLetterTestEvent("second"), errors.New("not authorized")))
+
+	// The sink belongs to the output group and stays open until the group
+	// is torn down.
+	group, err := dl.Attach(Group{}, nil)
+	require.NoError(t, err)
+	assert.True(t, dl.Send(deadLetterTestEvent("third"), nil))
+	require.NoError(t, group.CloseShared())
+	assert.False(t, dl.Send(deadLetterTestEvent("fourth"), nil))
+
+	files, err := filepath.Glob(filepath.Join(dir, "testbeat*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	var docs []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+		docs = append(docs, doc)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, docs, 3)
+
+	assert.Equal(t, "2024-01-02T03:04:05.000Z", docs[0]["@timestamp"])
+	assert.Equal(t, map[string]any{"message": "message too large"}, docs[0]["error"])
+	assert.Equal(t, map[string]any{"output": "kafka"}, docs[0]["dead_letter"])
+
+	var original map[string]any
+	require.NoError(t, json.Unmarshal([]byte(docs[0]["message"].(string)), &original))
+	assert.Equal(t, "first", original["message"])
+
+	assert.Equal(t, map[string]any{"message": "not authorized"}, docs[1]["error"])
+	assert.Equal(t, map[string]any{"message": "unknown error"}, docs[2]["error"])
+}
+
+type deadLetterTestClient struct {
+	events   chan beat.Event
+	fail     int
+	connects atomic.Int32
+}
+
+func (c *deadLetterTestClient) Connect(context.Context) error {
+	c.connects.Add(1)
+	return nil
+}
+
+func (c *deadLetterTestClient) Close() error   { return nil }
+func (c *deadLetterTestClient) String() string { return "dead_letter_test" }
+
+func (c *deadLetterTestClient) Publish(_ context.Context, batch publisher.Batch) error {
+	if c.fail > 0 {
+		c.fail--
+		batch.Retry()
+		return errors.New("unavailable")
+	}
+	for _, e := range batch.Events() {
+		c.events <- e.Content
+	}
+	batch.ACK()
+	return nil
+}
+
+func TestDeadLetterOutput(t *testing.T) {
+	client := &deadLetterTestClient{events: make(chan beat.Event, 10), fail: 1}
+	RegisterType("dead_letter_test", func(IndexManager, beat.Info, Observer, *config.C) (Group, error) {
+		return Group{Clients: []Client{client}}, nil
+	})
+	t.Cleanup(func() { delete(outputReg, "dead_letter_test") })
+
+	beatInfo := beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}
+	dl, err := NewDeadLetter(nil, beatInfo, nil, "redis", deadLetterNamespace(t, map[string]any{
+		"output.dead_letter_test": map[string]any{},
+	}))
+	require.NoError(t, err)
+	defer dl.Close()
+
+	require.True(t, dl.Send(deadLetterTestEvent("hello"), errors.New("boom")))
+
+	select {
+	case event := <-client.events:
+		assert.Equal(t, "boom", event.Fields["error"].(mapstr.M)["message"])
+		assert.Equal(t, "redis", event.Fields["dead_letter"].(mapstr.M)["output"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead-letter event wasn't published")
+	}
+	// The failed publish was retried on a new connection.
+	assert.Equal(t, int32(2), client.connects.Load())
+}
+
+func TestDeadLetterOutputNested(t *testing.T) {
+	RegisterType("dead_letter_test", func(IndexManager, beat.Info, Observer, *config.C) (Group, error) {
+		return Group{Clients: []Client{&deadLetterTestClient{}}}, nil
+	})
+	t.Cleanup(func() { delete(outputReg, "dead_letter_test") })
+
+	beatInfo := beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}
+	_, err := NewDeadLetter(nil, beatInfo, nil, "redis", deadLetterNamespace(t, map[string]any{
+		"output.dead_letter_test.dead_letter.file.path": t.TempDir(),
+	}))
+	assert.ErrorContains(t, err, "can't have a dead_letter section")
+}
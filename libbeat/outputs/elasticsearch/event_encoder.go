@@ -93,7 +93,8 @@ func newEventEncoder(escapeHTML bool,
 func (pe *eventEncoder) EncodeEntry(e publisher.Event) (publisher.Event, int) {
 	encodedEvent := pe.encodeRawEvent(&e.Content)
 	e.EncodedEvent = encodedEvent
-	e.Content = beat.Event{}
+	// Keep the timestamp, the pipeline's max_event_age policy needs it.
+	e.Content = beat.Event{Timestamp: e.Content.Timestamp}
 	return e, len(encodedEvent.encoding)
 }
 
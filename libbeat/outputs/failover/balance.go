@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// balancedClient publishes through all the clients of an output that has
+// several, e.g. one configured with several hosts and loadbalance: true or
+// several workers. Batches are sent to the connected clients in round-robin
+// order. Clients that fail are connected again the next time the output is
+// connected.
+type balancedClient struct {
+	clients []outputs.Client
+
+	mu        sync.Mutex
+	connected []bool
+	next      int
+}
+
+func newBalancedClient(clients []outputs.Client) *balancedClient {
+	return &balancedClient{
+		clients:   clients,
+		connected: make([]bool, len(clients)),
+	}
+}
+
+// Connect connects the clients that aren't connected. It only fails if no
+// client is connected.
+func (b *balancedClient) Connect(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for i, c := range b.clients {
+		if b.connected[i] {
+			continue
+		}
+		if conn, ok := c.(outputs.Connectable); ok {
+			if err := conn.Connect(ctx); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		b.connected[i] = true
+	}
+	for _, connected := range b.connected {
+		if connected {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *balancedClient) Publish(ctx context.Context, batch publisher.Batch) error {
+	b.mu.Lock()
+	i, ok := b.pickLocked()
+	b.mu.Unlock()
+	if !ok {
+		batch.Retry()
+		return errors.New("no connected client")
+	}
+
+	if err := b.clients[i].Publish(ctx, batch); err != nil {
+		b.mu.Lock()
+		b.connected[i] = false
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// pickLocked returns the next connected client.
+func (b *balancedClient) pickLocked() (int, bool) {
+	for range b.clients {
+		i := b.next
+		b.next = (b.next + 1) % len(b.clients)
+		if b.connected[i] {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (b *balancedClient) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for i, c := range b.clients {
+		b.connected[i] = false
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *balancedClient) String() string {
+	names := make([]string, len(b.clients))
+	for i, c := range b.clients {
+		names[i] = c.String()
+	}
+	return "loadbalance(" + strings.Join(names, ",") + ")"
+}
@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// indexKey is the event cache key holding the position of an encoded event
+// in its batch.
+const indexKey = "failover.index"
+
+// encodedBatch hands an output encoded copies of the events of a batch, so
+// the original events stay intact if the batch is retried on another output.
+type encodedBatch struct {
+	publisher.Batch
+	events    []publisher.Event
+	originals []publisher.Event
+}
+
+func newEncodedBatch(batch publisher.Batch, encoder queue.Encoder[publisher.Event]) *encodedBatch {
+	events := batch.Events()
+	b := &encodedBatch{
+		Batch:     batch,
+		events:    make([]publisher.Event, len(events)),
+		originals: events,
+	}
+	for i, event := range events {
+		encoded, _ := encoder.EncodeEntry(event)
+		// The encoded event shares the cache of the original, give it its
+		// own so the index doesn't leak into the original event.
+		encoded.Cache = publisher.EventCache{}
+		_, _ = encoded.Cache.Put(indexKey, i)
+		b.events[i] = encoded
+	}
+	return b
+}
+
+func (b *encodedBatch) Events() []publisher.Event {
+	return b.events
+}
+
+func (b *encodedBatch) RetryEvents(events []publisher.Event) {
+	originals := make([]publisher.Event, 0, len(events))
+	for _, event := range events {
+		value, err := event.Cache.GetValue(indexKey)
+		if err != nil {
+			continue
+		}
+		if i, ok := value.(int); ok && i >= 0 && i < len(b.originals) {
+			originals = append(originals, b.originals[i])
+		}
+	}
+	b.Batch.RetryEvents(originals)
+}
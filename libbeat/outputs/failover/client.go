@@ -0,0 +1,309 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// stats are the failover metrics, reported in the output registry.
+type stats struct {
+	active    *monitoring.String // name of the output events are sent to
+	switches  *monitoring.Uint   // switches away from an unhealthy output
+	failbacks *monitoring.Uint   // switches back to the primary output
+}
+
+func newStats(reg *monitoring.Registry) *stats {
+	return &stats{
+		active:    monitoring.NewString(reg, "active"),
+		switches:  monitoring.NewUint(reg, "switches"),
+		failbacks: monitoring.NewUint(reg, "failbacks"),
+	}
+}
+
+// client sends events to the first of its outputs, the primary, until it
+// has been failing for unhealthyAfter. It then switches to the next output.
+// While a standby output is active, the primary is probed every
+// probeInterval by connecting to it, and events are sent to it again as
+// soon as a probe succeeds.
+type client struct {
+	log            *logp.Logger
+	members        []*member
+	unhealthyAfter time.Duration
+	probeInterval  time.Duration
+	stats          *stats
+
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu     sync.Mutex
+	active int
+	// unhealthySince is when the active output started failing, or zero
+	// while it works.
+	unhealthySince time.Time
+	// primaryReady is set by the probe once the primary is connected again.
+	primaryReady bool
+	// cancelProbe stops the running probe, if any.
+	cancelProbe context.CancelFunc
+	probeDone   chan struct{}
+}
+
+var errNoClients = errors.New("failover output has no outputs")
+
+func newClient(log *logp.Logger, members []*member, cfg failoverConfig, stats *stats) *client {
+	c := &client{
+		log:            log,
+		members:        members,
+		unhealthyAfter: cfg.UnhealthyAfter,
+		probeInterval:  cfg.ProbeInterval,
+		stats:          stats,
+		now:            time.Now,
+	}
+	stats.active.Set(members[0].name)
+	return c
+}
+
+func (c *client) Connect(ctx context.Context) error {
+	if len(c.members) == 0 {
+		return errNoClients
+	}
+	if c.failback() {
+		return nil
+	}
+
+	c.mu.Lock()
+	m := c.members[c.active]
+	c.mu.Unlock()
+
+	err := connect(ctx, m)
+	if err == nil {
+		c.healthy()
+		c.ensureProbe(ctx)
+		return nil
+	}
+	if !c.unhealthy(ctx, err) {
+		return err
+	}
+
+	// Try the output we switched to right away.
+	c.mu.Lock()
+	m = c.members[c.active]
+	c.mu.Unlock()
+	if err := connect(ctx, m); err != nil {
+		return err
+	}
+	c.healthy()
+	return nil
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	c.failback()
+
+	c.mu.Lock()
+	m := c.members[c.active]
+	c.mu.Unlock()
+
+	err := publish(ctx, m, batch)
+	if err != nil {
+		c.unhealthy(ctx, err)
+		return err
+	}
+	c.healthy()
+	return nil
+}
+
+// Close closes the active output and stops probing the primary.
+func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopProbeLocked()
+	return c.members[c.active].client.Close()
+}
+
+func (c *client) String() string {
+	names := make([]string, len(c.members))
+	for i, m := range c.members {
+		names[i] = m.client.String()
+	}
+	return "failover(" + strings.Join(names, ",") + ")"
+}
+
+// healthy records that the active output works.
+func (c *client) healthy() {
+	c.mu.Lock()
+	c.unhealthySince = time.Time{}
+	c.mu.Unlock()
+}
+
+// unhealthy records a failure of the active output, and switches to the next
+// one if the active output has been failing for long enough. It returns true
+// if it switched.
+func (c *client) unhealthy(ctx context.Context, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.unhealthySince.IsZero() {
+		c.unhealthySince = now
+	}
+	if len(c.members) < 2 || now.Sub(c.unhealthySince) < c.unhealthyAfter {
+		return false
+	}
+	if (c.active+1)%len(c.members) == 0 {
+		c.stopProbeLocked()
+	}
+
+	from := c.members[c.active]
+	_ = from.client.Close()
+	c.active = (c.active + 1) % len(c.members)
+	c.unhealthySince = time.Time{}
+	to := c.members[c.active]
+
+	c.log.Warnf("The %s output has been failing for more than %v, switching to the %s output: %v",
+		from.name, c.unhealthyAfter, to.name, err)
+	c.stats.switches.Inc()
+	c.stats.active.Set(to.name)
+
+	if c.active != 0 && c.cancelProbe == nil {
+		c.startProbeLocked(ctx)
+	}
+	return true
+}
+
+// failback switches back to the primary output once the probe has connected
+// to it. It returns true if it switched.
+func (c *client) failback() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.primaryReady {
+		return false
+	}
+	c.primaryReady = false
+	c.cancelProbe = nil
+
+	if c.active != 0 {
+		from := c.members[c.active]
+		_ = from.client.Close()
+		c.log.Infof("The %s output is healthy again, switching back to it from the %s output",
+			c.members[0].name, from.name)
+		c.active = 0
+		c.unhealthySince = time.Time{}
+		c.stats.failbacks.Inc()
+		c.stats.active.Set(c.members[0].name)
+	}
+	return true
+}
+
+func (c *client) startProbeLocked(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	c.cancelProbe = cancel
+	c.probeDone = done
+	go func() {
+		defer close(done)
+		c.probe(ctx)
+	}()
+}
+
+// ensureProbe starts probing the primary if a standby output is active and
+// the probe was stopped by Close.
+func (c *client) ensureProbe(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != 0 && c.cancelProbe == nil && !c.primaryReady {
+		c.startProbeLocked(ctx)
+	}
+}
+
+// stopProbeLocked stops the probe and waits for it to return, so nothing
+// connects to the primary concurrently afterwards. A primary the probe left
+// connected is closed. c.mu is released while waiting.
+func (c *client) stopProbeLocked() {
+	cancel, done := c.cancelProbe, c.probeDone
+	c.cancelProbe = nil
+	if cancel != nil {
+		cancel()
+		c.mu.Unlock()
+		<-done
+		c.mu.Lock()
+	}
+	if c.primaryReady {
+		c.primaryReady = false
+		_ = c.members[0].client.Close()
+	}
+}
+
+// probe connects to the primary output every probeInterval until it
+// succeeds. Outputs that can't connect are assumed to be healthy after the
+// first interval.
+func (c *client) probe(ctx context.Context) {
+	primary := c.members[0]
+	ticker := time.NewTicker(c.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := connect(ctx, primary); err != nil {
+			c.log.Debugf("Probe of the %s output failed: %v", primary.name, err)
+			_ = primary.client.Close()
+			continue
+		}
+
+		c.mu.Lock()
+		if ctx.Err() == nil {
+			c.primaryReady = true
+		} else {
+			_ = primary.client.Close()
+		}
+		c.mu.Unlock()
+		return
+	}
+}
+
+func connect(ctx context.Context, m *member) error {
+	if conn, ok := m.client.(outputs.Connectable); ok {
+		if err := conn.Connect(ctx); err != nil {
+			return fmt.Errorf("%s output: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+func publish(ctx context.Context, m *member, batch publisher.Batch) error {
+	if m.encoder != nil {
+		batch = newEncodedBatch(batch, m.encoder)
+	}
+	if err := m.client.Publish(ctx, batch); err != nil {
+		return fmt.Errorf("%s output: %w", m.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/discard"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/kafka"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/logstash"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/sarama"
+)
+
+var errDown = errors.New("down")
+
+// testClient is a network client that fails while down is set, and whose
+// Connect blocks until cancelled while hang is set.
+type testClient struct {
+	name       string
+	down       atomic.Bool
+	hang       atomic.Bool
+	connects   atomic.Int32
+	connecting atomic.Int32
+	mu         sync.Mutex
+	published  []publisher.Event
+}
+
+func (c *testClient) Connect(ctx context.Context) error {
+	c.connects.Add(1)
+	c.connecting.Add(1)
+	defer c.connecting.Add(-1)
+	if c.hang.Load() {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if c.down.Load() {
+		return errDown
+	}
+	return nil
+}
+
+func (c *testClient) Publish(_ context.Context, batch publisher.Batch) error {
+	if c.down.Load() {
+		batch.Retry()
+		return errDown
+	}
+	c.mu.Lock()
+	c.published = append(c.published, batch.Events()...)
+	c.mu.Unlock()
+	batch.ACK()
+	return nil
+}
+
+func (c *testClient) Close() error   { return nil }
+func (c *testClient) String() string { return c.name }
+
+func (c *testClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.published)
+}
+
+func newTestClient(t *testing.T, cfg failoverConfig, clients ...*testClient) (*client, *monitoring.Registry) {
+	t.Helper()
+	members := make([]*member, len(clients))
+	for i, c := range clients {
+		members[i] = &member{name: c.name, client: c}
+	}
+	reg := monitoring.NewRegistry()
+	c := newClient(logptest.NewTestingLogger(t, ""), members, cfg, newStats(reg))
+	t.Cleanup(func() { _ = c.Close() })
+	return c, reg
+}
+
+func testBatch() *outest.Batch {
+	return outest.NewBatch(beat.Event{Fields: mapstr.M{"message": "hello"}})
+}
+
+func TestFailoverSwitchesAfterUnhealthyPeriod(t *testing.T) {
+	primary := &testClient{name: "primary"}
+	standby := &testClient{name: "standby"}
+	c, reg := newTestClient(t, failoverConfig{UnhealthyAfter: time.Minute, ProbeInterval: time.Hour}, primary, standby)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	ctx := t.Context()
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Publish(ctx, testBatch()))
+	assert.Equal(t, 1, primary.count())
+
+	// Failures within unhealthy_after don't switch.
+	primary.down.Store(true)
+	assert.Error(t, c.Publish(ctx, testBatch()))
+	now = now.Add(30 * time.Second)
+	assert.Error(t, c.Connect(ctx))
+	assert.Equal(t, "primary", monitoring.CollectFlatSnapshot(reg, monitoring.Full, false).Strings["active"])
+
+	now = now.Add(31 * time.Second)
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Publish(ctx, testBatch()))
+	assert.Equal(t, 1, standby.count())
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, "standby", snapshot.Strings["active"])
+	assert.Equal(t, int64(1), snapshot.Ints["switches"])
+	assert.Equal(t, int64(0), snapshot.Ints["failbacks"])
+}
+
+func TestFailoverFailsBackWhenProbeSucceeds(t *testing.T) {
+	primary := &testClient{name: "primary"}
+	standby := &testClient{name: "standby"}
+	c, reg := newTestClient(t, failoverConfig{UnhealthyAfter: 0, ProbeInterval: 10 * time.Millisecond}, primary, standby)
+
+	ctx := t.Context()
+	primary.down.Store(true)
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Publish(ctx, testBatch()))
+	assert.Equal(t, 1, standby.count())
+
+	// The probe keeps failing while the primary is down.
+	require.Eventually(t, func() bool { return primary.connects.Load() >= 3 }, 5*time.Second, time.Millisecond)
+	require.NoError(t, c.Publish(ctx, testBatch()))
+	assert.Equal(t, 2, standby.count())
+
+	primary.down.Store(false)
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.primaryReady
+	}, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, c.Publish(ctx, testBatch()))
+	assert.Equal(t, 1, primary.count())
+	assert.Equal(t, 2, standby.count())
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, "primary", snapshot.Strings["active"])
+	assert.Equal(t, int64(1), snapshot.Ints["switches"])
+	assert.Equal(t, int64(1), snapshot.Ints["failbacks"])
+}
+
+func TestFailoverCyclesThroughStandbys(t *testing.T) {
+	primary := &testClient{name: "primary"}
+	first := &testClient{name: "first"}
+	second := &testClient{name: "second"}
+	c, _ := newTestClient(t, failoverConfig{UnhealthyAfter: 0, ProbeInterval: time.Hour}, primary, first, second)
+
+	ctx := t.Context()
+	primary.down.Store(true)
+	first.down.Store(true)
+	assert.Error(t, c.Connect(ctx))
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Publish(ctx, testBatch()))
+	assert.Equal(t, 1, second.count())
+}
+
+func TestFailoverStopsProbeBeforeWrappingToPrimary(t *testing.T) {
+	primary := &testClient{name: "primary"}
+	standby := &testClient{name: "standby"}
+	c, _ := newTestClient(t, failoverConfig{UnhealthyAfter: 0, ProbeInterval: time.Millisecond}, primary, standby)
+
+	ctx := t.Context()
+	primary.down.Store(true)
+	require.NoError(t, c.Connect(ctx))
+
+	// Wait for the probe to be connecting to the primary.
+	primary.hang.Store(true)
+	require.Eventually(t, func() bool { return primary.connecting.Load() == 1 }, 5*time.Second, time.Millisecond)
+
+	standby.down.Store(true)
+	assert.Error(t, c.Publish(ctx, testBatch()))
+
+	c.mu.Lock()
+	assert.Equal(t, 0, c.active)
+	assert.Nil(t, c.cancelProbe)
+	c.mu.Unlock()
+	assert.Equal(t, int32(0), primary.connecting.Load())
+}
+
+// testEncoder encodes events by moving their message to EncodedEvent.
+type testEncoder struct{}
+
+type testEncoded struct{ message any }
+
+func (testEncoder) EncodeEntry(e publisher.Event) (publisher.Event, int) {
+	e.EncodedEvent = &testEncoded{message: e.Content.Fields["message"]}
+	e.Content = beat.Event{}
+	return e, 0
+}
+
+func TestEncodedBatchKeepsOriginalEvents(t *testing.T) {
+	batch := outest.NewBatch(
+		beat.Event{Fields: mapstr.M{"message": "a"}},
+		beat.Event{Fields: mapstr.M{"message": "b"}},
+	)
+	encoded := newEncodedBatch(batch, testEncoder{})
+	events := encoded.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "b", events[1].EncodedEvent.(*testEncoded).message)
+
+	encoded.RetryEvents(events[1:])
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	require.Len(t, batch.Signals[0].Events, 1)
+	assert.Equal(t, "b", batch.Signals[0].Events[0].Content.Fields["message"])
+}
+
+// mapEncoder encodes events to a map, which can't be compared.
+type mapEncoder struct{}
+
+func (mapEncoder) EncodeEntry(e publisher.Event) (publisher.Event, int) {
+	e.EncodedEvent = mapstr.M{"message": e.Content.Fields["message"]}
+	e.Content = beat.Event{}
+	return e, 0
+}
+
+func TestEncodedBatchRetriesUncomparableEvents(t *testing.T) {
+	batch := outest.NewBatch(
+		beat.Event{Fields: mapstr.M{"message": "a"}},
+		beat.Event{Fields: mapstr.M{"message": "b"}},
+	)
+	encoded := newEncodedBatch(batch, mapEncoder{})
+
+	encoded.RetryEvents(encoded.Events())
+	require.Len(t, batch.Signals, 1)
+	require.Len(t, batch.Signals[0].Events, 2)
+	assert.Equal(t, "a", batch.Signals[0].Events[0].Content.Fields["message"])
+	assert.Equal(t, "b", batch.Signals[0].Events[1].Content.Fields["message"])
+	_, err := batch.Signals[0].Events[0].Cache.GetValue(indexKey)
+	assert.Error(t, err, "the index must not leak into the original event")
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		cfg map[string]any
+		err string
+	}{
+		"valid": {
+			cfg: map[string]any{
+				"primary.discard": map[string]any{},
+				"standby":         []map[string]any{{"discard": map[string]any{}}},
+			},
+		},
+		"no primary": {
+			cfg: map[string]any{
+				"standby": []map[string]any{{"discard": map[string]any{}}},
+			},
+			err: "needs a primary output",
+		},
+		"no standby": {
+			cfg: map[string]any{
+				"primary.discard": map[string]any{},
+			},
+			err: "needs at least one standby output",
+		},
+		"nested": {
+			cfg: map[string]any{
+				"primary.discard": map[string]any{},
+				"standby":         []map[string]any{{"failover": map[string]any{}}},
+			},
+			err: "can't be nested",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			err := config.MustNewConfigFrom(tc.cfg).Unpack(&c)
+			if tc.err == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "discard", c.Primary.Name())
+				assert.Len(t, c.Standby, 1)
+				return
+			}
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestMakeFailoverReportsMetrics(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	reg := monitoring.NewRegistry()
+	cfg := config.MustNewConfigFrom(map[string]any{
+		"primary.discard": map[string]any{},
+		"standby":         []map[string]any{{"discard": map[string]any{}}},
+	})
+	group, err := outputs.Load(nil, beat.Info{Beat: "testbeat", Logger: logger}, outputs.NewStats(reg, logger), outputType, cfg)
+	require.NoError(t, err)
+	require.Len(t, group.Clients, 1)
+	defer group.Clients[0].Close()
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, "discard", snapshot.Strings["failover.active"])
+	assert.Equal(t, int64(0), snapshot.Ints["failover.switches"])
+}
+
+// TestFailoverReconnectsKafkaStandby switches back and forth between a
+// primary and a Kafka standby, which is closed on every switch and connected
+// again.
+func TestFailoverReconnectsKafkaStandby(t *testing.T) {
+	const topic = "logs"
+	// The broker is started once the output is loaded, since loading it
+	// sets the logger of sarama.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	logger := logptest.NewTestingLogger(t, "")
+	var ns config.Namespace
+	require.NoError(t, config.MustNewConfigFrom(map[string]any{
+		"kafka": map[string]any{
+			"hosts":   []string{listener.Addr().String()},
+			"topic":   topic,
+			"version": "1.0.0",
+		},
+	}).Unpack(&ns))
+	// Sarama keeps logging after the output is closed, so the Kafka output
+	// doesn't log to the test.
+	standby, group, err := loadMember(nil, beat.Info{Beat: "testbeat", Logger: logp.NewNopLogger()}, outputs.NewNilObserver(), ns)
+	require.NoError(t, err)
+	defer group.CloseShared() //nolint:errcheck // nothing to check in tests
+
+	broker := sarama.NewMockBrokerListener(t, 1, listener)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(topic, 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	primary := &testClient{name: "primary"}
+	reg := monitoring.NewRegistry()
+	c := newClient(logger, []*member{{name: primary.name, client: primary}, standby},
+		failoverConfig{UnhealthyAfter: 0, ProbeInterval: 10 * time.Millisecond}, newStats(reg))
+	defer c.Close()
+
+	ctx := t.Context()
+	// publishToStandby publishes a batch to Kafka, and waits for it to be
+	// acknowledged.
+	publishToStandby := func() {
+		t.Helper()
+		signals := make(chan outest.BatchSignal, 1)
+		batch := testBatch()
+		batch.OnSignal = func(sig outest.BatchSignal) { signals <- sig }
+		require.NoError(t, c.Publish(ctx, batch))
+		select {
+		case sig := <-signals:
+			require.Equal(t, outest.BatchACK, sig.Tag)
+		case <-time.After(10 * time.Second):
+			t.Fatal("batch wasn't acknowledged by Kafka")
+		}
+	}
+
+	for i := range 2 {
+		// Fail over to Kafka.
+		primary.down.Store(true)
+		assert.Error(t, c.Publish(ctx, testBatch()))
+		require.NoError(t, c.Connect(ctx))
+		publishToStandby()
+
+		// Fail back to the primary, which closes the Kafka client.
+		primary.down.Store(false)
+		require.Eventually(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.primaryReady
+		}, 5*time.Second, time.Millisecond)
+		require.NoError(t, c.Publish(ctx, testBatch()))
+		assert.Equal(t, i+1, primary.count())
+	}
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(2), snapshot.Ints["switches"])
+	assert.Equal(t, int64(2), snapshot.Ints["failbacks"])
+}
+
+func TestBalancedClientPublishesToAllClients(t *testing.T) {
+	first := &testClient{name: "first"}
+	second := &testClient{name: "second"}
+	third := &testClient{name: "third"}
+	b := newBalancedClient([]outputs.Client{first, second, third})
+	assert.Equal(t, "loadbalance(first,second,third)", b.String())
+
+	ctx := t.Context()
+	third.down.Store(true)
+	require.NoError(t, b.Connect(ctx))
+	for range 4 {
+		require.NoError(t, b.Publish(ctx, testBatch()))
+	}
+	assert.Equal(t, 2, first.count())
+	assert.Equal(t, 2, second.count())
+
+	// A failed client is skipped until it's connected again.
+	third.down.Store(false)
+	second.down.Store(true)
+	for range 3 {
+		_ = b.Publish(ctx, testBatch())
+	}
+	assert.Equal(t, 4, first.count())
+	require.NoError(t, b.Connect(ctx))
+	require.NoError(t, b.Publish(ctx, testBatch()))
+	assert.Equal(t, 1, third.count())
+
+	first.down.Store(true)
+	third.down.Store(true)
+	require.NoError(t, b.Close())
+	assert.ErrorIs(t, b.Connect(ctx), errDown)
+}
+
+func TestLoadMemberKeepsAllClients(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	var ns config.Namespace
+	require.NoError(t, config.MustNewConfigFrom(map[string]any{
+		"logstash": map[string]any{
+			"hosts":       []string{"localhost:5044", "localhost:5045"},
+			"loadbalance": true,
+		},
+	}).Unpack(&ns))
+	m, group, err := loadMember(nil, beat.Info{Beat: "testbeat", Logger: logger}, outputs.NewNilObserver(), ns)
+	require.NoError(t, err)
+	defer group.CloseShared() //nolint:errcheck // nothing to check in tests
+
+	b, ok := m.client.(*balancedClient)
+	require.True(t, ok, "member client must publish through all the clients")
+	assert.Len(t, b.clients, 2)
+}
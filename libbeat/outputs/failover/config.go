@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+type failoverConfig struct {
+	Primary        config.Namespace   `config:"primary"`
+	Standby        []config.Namespace `config:"standby"`
+	UnhealthyAfter time.Duration      `config:"unhealthy_after" validate:"min=0"`
+	ProbeInterval  time.Duration      `config:"probe_interval" validate:"positive,nonzero"`
+	Queue          config.Namespace   `config:"queue"`
+}
+
+func defaultConfig() failoverConfig {
+	return failoverConfig{
+		UnhealthyAfter: 30 * time.Second,
+		ProbeInterval:  10 * time.Second,
+	}
+}
+
+func (c *failoverConfig) Validate() error {
+	if !c.Primary.IsSet() {
+		return errors.New("the failover output needs a primary output")
+	}
+	if len(c.Standby) == 0 {
+		return errors.New("the failover output needs at least one standby output")
+	}
+	for _, ns := range c.members() {
+		if ns.Name() == outputType {
+			return fmt.Errorf("the %s output can't be nested", outputType)
+		}
+	}
+	return nil
+}
+
+// members returns the outputs in order of preference.
+func (c *failoverConfig) members() []config.Namespace {
+	return append([]config.Namespace{c.Primary}, c.Standby...)
+}
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package failover provides an output that sends events to a primary output,
+// and switches to standby outputs of other types while the primary is
+// unhealthy.
+package failover
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+const outputType = "failover"
+
+func init() {
+	outputs.RegisterType(outputType, makeFailover)
+}
+
+// member is one of the outputs of the failover output.
+type member struct {
+	name   string
+	client outputs.Client
+	// encoder is set for outputs that encode events early. The failover
+	// output can't let the queue encode events, since the output that
+	// publishes them isn't known until then.
+	encoder queue.Encoder[publisher.Event]
+}
+
+func makeFailover(
+	im outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return outputs.Fail(err)
+	}
+
+	var (
+		members    []*member
+		shared     outputs.Group
+		deadLetter *outputs.DeadLetter
+		batchSize  int
+		retry      int
+	)
+	for i, ns := range c.members() {
+		m, group, err := loadMember(im, beat, observer, ns)
+		if err != nil {
+			for _, m := range members {
+				_ = m.client.Close()
+			}
+			_ = shared.CloseShared()
+			return outputs.Fail(err)
+		}
+		shared.Shared = append(shared.Shared, group.Shared...)
+		if i == 0 {
+			retry = group.Retry
+			deadLetter = group.DeadLetter
+		}
+		// Use the smallest batch size, so that batches fit every output.
+		if group.BatchSize > 0 && (batchSize <= 0 || group.BatchSize < batchSize) {
+			batchSize = group.BatchSize
+		}
+		members = append(members, m)
+	}
+
+	var reg *monitoring.Registry
+	if s, ok := observer.(interface{ Registry() *monitoring.Registry }); ok && s.Registry() != nil {
+		reg = s.Registry().GetOrCreateRegistry(outputType)
+	} else {
+		reg = monitoring.NewRegistry()
+	}
+
+	client := newClient(beat.Logger.Named(outputType), members, c, newStats(reg))
+	group, err := outputs.Success(c.Queue, batchSize, retry, nil, beat.Logger, beat.Paths, client)
+	if err != nil {
+		_ = shared.CloseShared()
+		return group, err
+	}
+	// The failover output switches between its members by closing and
+	// reconnecting them, what they share lives as long as the failover
+	// output.
+	group.Shared = shared.Shared
+	// Events too old to send are dead-lettered on behalf of the primary.
+	group.DeadLetter = deadLetter
+	return group, nil
+}
+
+func loadMember(
+	im outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	ns config.Namespace,
+) (*member, outputs.Group, error) {
+	group, err := outputs.Load(im, beat, observer, ns.Name(), ns.Config())
+	if err != nil {
+		return nil, group, fmt.Errorf("error initializing the %s output: %w", ns.Name(), err)
+	}
+	if len(group.Clients) == 0 {
+		_ = group.CloseShared()
+		return nil, group, fmt.Errorf("the %s output has no clients", ns.Name())
+	}
+	m := &member{name: ns.Name(), client: group.Clients[0]}
+	if len(group.Clients) > 1 {
+		m.client = newBalancedClient(group.Clients)
+	}
+	if group.EncoderFactory != nil {
+		m.encoder = group.EncoderFactory()
+	}
+	return m, group, nil
+}
@@ -18,7 +18,9 @@
 package fileout
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 	"github.com/elastic/elastic-agent-libs/config"
@@ -34,6 +36,15 @@ type fileOutConfig struct {
 	Permissions     uint32            `config:"permissions"`
 	RotateOnStartup bool              `config:"rotate_on_startup"`
 	Queue           config.Namespace  `config:"queue"`
+	DeadLetter      config.Namespace  `config:"dead_letter"`
+	Parquet         parquetConfig     `config:"parquet"`
+}
+
+type parquetConfig struct {
+	Enabled       bool          `config:"enabled"`
+	RowGroupSize  int           `config:"row_group_size" validate:"min=1"`
+	FlushInterval time.Duration `config:"flush_interval" validate:"positive,nonzero"`
+	Fields        []string      `config:"fields"`
 }
 
 func defaultConfig() fileOutConfig {
@@ -43,6 +54,10 @@ func defaultConfig() fileOutConfig {
 		RotateEveryKb:   10 * 1024,
 		Permissions:     0600,
 		RotateOnStartup: true,
+		Parquet: parquetConfig{
+			RowGroupSize:  10000,
+			FlushInterval: time.Second,
+		},
 	}
 }
 
@@ -63,6 +78,9 @@ func (c *fileOutConfig) Validate() error {
 		return fmt.Errorf("the number_of_files to keep should be between 2 and %v",
 			file.MaxBackupsLimit)
 	}
+	if c.Parquet.Enabled && c.Codec.Namespace.IsSet() {
+		return errors.New("the codec setting can not be used with parquet")
+	}
 
 	return nil
 }
@@ -42,6 +42,10 @@ func TestConfig(t *testing.T) {
 					RotateEveryKb:   10 * 1024,
 					Permissions:     0600,
 					RotateOnStartup: true,
+					Parquet: parquetConfig{
+						RowGroupSize:  10000,
+						FlushInterval: time.Second,
+					},
 				}
 
 				assert.Equal(t, expectedConfig, actual)
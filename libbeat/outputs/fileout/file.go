@@ -20,13 +20,18 @@ package fileout
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/elastic/beats/v7/libbeat/asset"
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/mapping"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/fileout/parquet"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	c "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/file"
@@ -44,11 +49,27 @@ type fileOutput struct {
 	observer outputs.Observer
 	rotator  *file.Rotator
 	codec    codec.Codec
+	dl       *outputs.DeadLetter
+
+	// parquetMu guards parquet and pending, the row group is also written
+	// by the flush timer.
+	parquetMu sync.Mutex
+	parquet   *parquet.Writer
+	// pending are the batches with events in the current row group, they
+	// are acknowledged once the row group is written.
+	pending   []pendingBatch
+	stopFlush chan struct{}
+	flushDone chan struct{}
+}
+
+type pendingBatch struct {
+	batch  publisher.Batch
+	events []publisher.Event
 }
 
 // makeFileout instantiates a new file output instance.
 func makeFileout(
-	_ outputs.IndexManager,
+	im outputs.IndexManager,
 	beat beat.Info,
 	observer outputs.Observer,
 	cfg *c.C,
@@ -66,8 +87,13 @@ func makeFileout(
 	if err = fo.init(beat, *foConfig); err != nil {
 		return outputs.Fail(err)
 	}
+	fo.dl, err = outputs.NewDeadLetter(im, beat, observer, "file", foConfig.DeadLetter)
+	if err != nil {
+		_ = fo.rotator.Close()
+		return outputs.Fail(err)
+	}
 
-	return outputs.Success(foConfig.Queue, -1, 0, nil, beat.Logger, beat.Paths, fo)
+	return fo.dl.Attach(outputs.Success(foConfig.Queue, -1, 0, nil, beat.Logger, beat.Paths, fo))
 }
 
 func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
@@ -84,24 +110,51 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 
 	out.filePath = path
 
-	var err error
-	out.rotator, err = file.NewFileRotator(
-		path,
-		file.MaxSizeBytes(c.RotateEveryKb*1024),
+	options := []file.RotatorOption{
+		file.MaxSizeBytes(c.RotateEveryKb * 1024),
 		file.MaxBackups(c.NumberOfFiles),
 		file.Permissions(os.FileMode(c.Permissions)),
 		file.RotateOnStartup(c.RotateOnStartup),
 		file.WithLogger(beat.Logger.Named("rotator").With(logp.Namespace("rotator"))),
-	)
-	if err != nil {
-		return err
+	}
+	if c.Parquet.Enabled {
+		// Parquet files are only complete once their footer is written, the
+		// parquet writer rotates them itself. Never append to an existing
+		// file.
+		options = append(options,
+			file.MaxSizeBytes(math.MaxUint),
+			file.RotateOnStartup(true),
+			file.Extension("parquet"),
+		)
 	}
 
-	out.codec, err = codec.CreateEncoder(beat, c.Codec)
+	var err error
+	out.rotator, err = file.NewFileRotator(path, options...)
 	if err != nil {
 		return err
 	}
 
+	if c.Parquet.Enabled {
+		schema, err := parquetSchema(beat, c.Parquet)
+		if err != nil {
+			_ = out.rotator.Close()
+			return err
+		}
+		out.parquet = parquet.NewWriter(out.rotator, out.rotator.Rotate, schema, parquet.WriterSettings{
+			RowGroupSize: c.Parquet.RowGroupSize,
+			MaxFileSize:  int64(c.RotateEveryKb) * 1024,
+			CreatedBy:    beat.Beat + " version " + beat.Version,
+		})
+		out.stopFlush = make(chan struct{})
+		out.flushDone = make(chan struct{})
+		go out.runFlushTimer(c.Parquet.FlushInterval)
+	} else {
+		out.codec, err = codec.CreateEncoder(beat, c.Codec)
+		if err != nil {
+			return err
+		}
+	}
+
 	out.log.Infof("Initialized file output. "+
 		"path=%v max_size_bytes=%v max_backups=%v permissions=%v",
 		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions))
@@ -109,12 +162,39 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 	return nil
 }
 
+// parquetSchema derives the parquet schema from the fields.yml mapping of the
+// beat.
+func parquetSchema(beat beat.Info, c parquetConfig) (*parquet.Schema, error) {
+	data, err := asset.GetFields(beat.Beat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fields of %v: %w", beat.Beat, err)
+	}
+	fields, err := mapping.LoadFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fields of %v: %w", beat.Beat, err)
+	}
+	return parquet.NewSchema(fields, c.Fields)
+}
+
 // Implement Outputer
 func (out *fileOutput) Close() error {
+	if out.parquet != nil {
+		close(out.stopFlush)
+		<-out.flushDone
+
+		out.parquetMu.Lock()
+		out.writeParquet(out.parquet.Close)
+		out.parquetMu.Unlock()
+	}
 	return out.rotator.Close()
 }
 
 func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
+	if out.parquet != nil {
+		out.publishParquet(batch)
+		return nil
+	}
+
 	defer batch.ACK()
 
 	st := out.observer
@@ -136,6 +216,7 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 			out.log.Debug("Failed event logged to event log file")
 			out.log.Debugw(fmt.Sprintf("Failed event: %v", event), logp.TypeKey, logp.EventType)
 
+			out.dl.Send(*event, err)
 			dropped++
 			continue
 		}
@@ -150,6 +231,7 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 				out.log.Warnf("Writing event to file failed with: %+v", err)
 			}
 
+			out.dl.Send(*event, err)
 			dropped++
 			continue
 		}
@@ -166,6 +248,96 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 	return nil
 }
 
+// publishParquet buffers the events in the current row group. The batch is
+// acknowledged once the row group is written, which happens as soon as it is
+// full after a batch, and at least every flush interval.
+func (out *fileOutput) publishParquet(batch publisher.Batch) {
+	out.parquetMu.Lock()
+	defer out.parquetMu.Unlock()
+
+	st := out.observer
+	events := batch.Events()
+	st.NewBatch(len(events))
+
+	buffered := make([]publisher.Event, 0, len(events))
+	for i := range events {
+		event := &events[i]
+
+		if err := out.parquet.Add(&event.Content); err != nil {
+			if event.Guaranteed() {
+				out.log.Errorf("Failed to convert the event: %+v", err)
+			} else {
+				out.log.Warnf("Failed to convert the event: %+v", err)
+			}
+			out.log.Debugw(fmt.Sprintf("Failed event: %v", event), logp.TypeKey, logp.EventType)
+
+			out.dl.Send(*event, err)
+			continue
+		}
+		buffered = append(buffered, *event)
+	}
+	st.PermanentErrors(len(events) - len(buffered))
+
+	if len(buffered) == 0 {
+		batch.ACK()
+		return
+	}
+	out.pending = append(out.pending, pendingBatch{batch: batch, events: buffered})
+	if out.parquet.Full() {
+		out.writeParquet(out.parquet.Flush)
+	}
+}
+
+// runFlushTimer writes the current row group every flush interval, so
+// events are acknowledged even if the row group doesn't fill up.
+func (out *fileOutput) runFlushTimer(interval time.Duration) {
+	defer close(out.flushDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-out.stopFlush:
+			return
+		case <-ticker.C:
+		}
+
+		out.parquetMu.Lock()
+		if out.parquet.Rows() > 0 {
+			out.writeParquet(out.parquet.Flush)
+		}
+		out.parquetMu.Unlock()
+	}
+}
+
+// writeParquet writes the current row group using write, and acknowledges
+// the pending batches. If the write fails, their events are retried.
+// parquetMu must be held.
+func (out *fileOutput) writeParquet(write func() (int, error)) {
+	st := out.observer
+	pending := out.pending
+	out.pending = nil
+
+	begin := time.Now()
+	n, err := write()
+	st.WriteBytes(n)
+	if err != nil {
+		st.WriteError(err)
+		out.log.Errorf("Writing parquet row group failed with: %+v", err)
+		for _, p := range pending {
+			st.RetryableErrors(len(p.events))
+			p.batch.RetryEvents(p.events)
+		}
+		return
+	}
+	st.ReportLatency(time.Since(begin))
+
+	for _, p := range pending {
+		st.AckedEvents(len(p.events))
+		p.batch.ACK()
+	}
+}
+
 func (out *fileOutput) String() string {
 	return "file(" + out.filePath + ")"
 }
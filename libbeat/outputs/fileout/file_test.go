@@ -18,3 +18,141 @@
 //go:build !integration
 
 package fileout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/asset"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/fileout/parquet"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const parquetTestFields = `
+- key: test
+  title: Test
+  fields:
+    - name: message
+      type: keyword
+    - name: count
+      type: long
+`
+
+func newParquetOutput(t *testing.T, settings mapstr.M) (*fileOutput, string) {
+	t.Helper()
+	require.NoError(t, asset.SetFields("fileout-parquet-test", "fields.yml", asset.BeatFieldsPri, func() string {
+		data, err := asset.EncodeData(parquetTestFields)
+		require.NoError(t, err)
+		return data
+	}))
+
+	dir := t.TempDir()
+	cfg := config.MustNewConfigFrom(mapstr.M{
+		"path":            dir,
+		"filename":        "events",
+		"parquet.enabled": true,
+	})
+	require.NoError(t, cfg.Merge(settings))
+	info := beat.Info{Beat: "fileout-parquet-test", Logger: logp.NewNopLogger()}
+	group, err := makeFileout(nil, info, outputs.NewNilObserver(), cfg)
+	require.NoError(t, err)
+	return group.Clients[0].(*fileOutput), dir
+}
+
+func readParquetRows(t *testing.T, dir string) int64 {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "events-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	r, err := file.NewParquetReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer r.Close()
+	return r.NumRows()
+}
+
+func TestParquetMode(t *testing.T) {
+	client, dir := newParquetOutput(t, mapstr.M{"parquet.row_group_size": 2})
+
+	batch := outest.NewBatch(
+		beat.Event{Fields: mapstr.M{"message": "a", "count": 1}},
+		beat.Event{Fields: mapstr.M{"message": "b"}},
+		beat.Event{Fields: mapstr.M{"count": "not a number"}},
+		beat.Event{Fields: mapstr.M{"message": "c", "count": 3}},
+	)
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	require.NoError(t, client.Close())
+
+	assert.EqualValues(t, 3, readParquetRows(t, dir))
+}
+
+func TestParquetAcksAfterFlushInterval(t *testing.T) {
+	client, dir := newParquetOutput(t, mapstr.M{"parquet.flush_interval": "10ms"})
+
+	signals := make(chan outest.BatchSignal, 1)
+	batch := outest.NewBatch(beat.Event{Fields: mapstr.M{"message": "a"}})
+	batch.OnSignal = func(sig outest.BatchSignal) { signals <- sig }
+	require.NoError(t, client.Publish(context.Background(), batch))
+
+	select {
+	case sig := <-signals:
+		assert.Equal(t, outest.BatchACK, sig.Tag)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the row group was not written after the flush interval")
+	}
+	require.NoError(t, client.Close())
+
+	assert.EqualValues(t, 1, readParquetRows(t, dir))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestParquetRetriesOnWriteError(t *testing.T) {
+	client, _ := newParquetOutput(t, mapstr.M{"parquet.row_group_size": 2})
+	schema, err := parquetSchema(client.beat, parquetConfig{})
+	require.NoError(t, err)
+	client.parquet = parquet.NewWriter(failingWriter{}, func() error { return nil }, schema, parquet.WriterSettings{RowGroupSize: 2})
+
+	batch := outest.NewBatch(
+		beat.Event{Fields: mapstr.M{"message": "a"}},
+		beat.Event{Fields: mapstr.M{"count": "not a number"}},
+		beat.Event{Fields: mapstr.M{"message": "b"}},
+	)
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	require.Len(t, batch.Signals[0].Events, 2)
+	assert.Equal(t, "a", batch.Signals[0].Events[0].Content.Fields["message"])
+	assert.Equal(t, "b", batch.Signals[0].Events[1].Content.Fields["message"])
+	require.NoError(t, client.Close())
+}
+
+func TestParquetConfigRejectsCodec(t *testing.T) {
+	_, err := readConfig(config.MustNewConfigFrom(mapstr.M{
+		"parquet.enabled": true,
+		"codec.format":    mapstr.M{"string": "%{[message]}"},
+	}))
+	assert.ErrorContains(t, err, "codec setting can not be used with parquet")
+}
@@ -0,0 +1,427 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package parquet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/mapping"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const timestampKey = "@timestamp"
+
+// Schema is the Parquet schema derived from a fields.yml mapping. Every field
+// becomes an optional column, nested inside optional groups following the
+// dotted field names.
+type Schema struct {
+	root    node
+	columns []*column
+	group   *schema.GroupNode
+}
+
+type node struct {
+	name     string
+	children []*node
+	column   *column
+}
+
+type column struct {
+	key      string
+	path     []string
+	physical parquet.Type
+	logical  schema.LogicalType
+	convert  func(any) (any, error)
+
+	// buffers of the current row group
+	defs     []int16
+	bools    []bool
+	int32s   []int32
+	int64s   []int64
+	float32s []float32
+	float64s []float64
+	bytes    []parquet.ByteArray
+}
+
+// NewSchema creates the schema for all fields of the mapping. If include is
+// not empty, only fields equal to or nested below one of its entries are
+// part of the schema. An `@timestamp` column is always present.
+func NewSchema(fields mapping.Fields, include []string) (*Schema, error) {
+	s := &Schema{}
+	s.add(newColumn(timestampKey, "date"))
+
+	var walk func(fields mapping.Fields, prefix string)
+	walk = func(fields mapping.Fields, prefix string) {
+		for i := range fields {
+			f := &fields[i]
+			key := f.Name
+			if prefix != "" {
+				key = prefix + "." + f.Name
+			}
+			if len(f.Fields) > 0 {
+				walk(f.Fields, key)
+				continue
+			}
+			if f.Type == "alias" || strings.Contains(key, "*") || !included(key, include) {
+				continue
+			}
+			s.add(newColumn(key, f.Type))
+		}
+	}
+	walk(fields, "")
+
+	collectColumns(&s.root, &s.columns)
+	if len(s.columns) == 1 && len(include) > 0 {
+		return nil, errors.New("none of the included fields is part of the mapping")
+	}
+
+	columns, err := s.root.fields()
+	if err != nil {
+		return nil, err
+	}
+	s.group, err = schema.NewGroupNode("schema", parquet.Repetitions.Required, columns, -1)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func included(key string, include []string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	for _, prefix := range include {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// add inserts the column into the schema tree. Columns colliding with an
+// existing column or group are ignored.
+func (s *Schema) add(c *column) {
+	n := &s.root
+	for i, name := range c.path {
+		last := i == len(c.path)-1
+
+		var child *node
+		for _, existing := range n.children {
+			if existing.name == name {
+				child = existing
+				break
+			}
+		}
+		if child == nil {
+			child = &node{name: name}
+			if last {
+				child.column = c
+			}
+			n.children = append(n.children, child)
+		} else if last || child.column != nil {
+			return
+		}
+		n = child
+	}
+}
+
+// collectColumns lists the columns in schema order, the order their chunks
+// are written in every row group.
+func collectColumns(n *node, columns *[]*column) {
+	if n.column != nil {
+		*columns = append(*columns, n.column)
+		return
+	}
+	for _, child := range n.children {
+		collectColumns(child, columns)
+	}
+}
+
+// fields converts the children of the node to Parquet schema nodes.
+func (n *node) fields() (schema.FieldList, error) {
+	fields := make(schema.FieldList, 0, len(n.children))
+	for _, child := range n.children {
+		var field schema.Node
+		var err error
+		if c := child.column; c != nil {
+			field, err = schema.NewPrimitiveNodeLogical(child.name, parquet.Repetitions.Optional, c.logical, c.physical, 0, -1)
+		} else {
+			var children schema.FieldList
+			if children, err = child.fields(); err == nil {
+				field, err = schema.NewGroupNode(child.name, parquet.Repetitions.Optional, children, -1)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column '%v': %w", child.name, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Columns returns the dotted names of all columns.
+func (s *Schema) Columns() []string {
+	keys := make([]string, len(s.columns))
+	for i, c := range s.columns {
+		keys[i] = c.key
+	}
+	return keys
+}
+
+func newColumn(key, fieldType string) *column {
+	c := &column{
+		key:     key,
+		path:    strings.Split(key, "."),
+		logical: schema.NoLogicalType{},
+	}
+
+	switch fieldType {
+	case "boolean":
+		c.physical, c.convert = parquet.Types.Boolean, toBool
+	case "byte", "short", "integer":
+		c.physical, c.convert = parquet.Types.Int32, toInt32
+	case "long":
+		c.physical, c.convert = parquet.Types.Int64, toInt64
+	case "unsigned_long":
+		c.physical, c.logical, c.convert = parquet.Types.Int64, schema.NewIntLogicalType(64, false), toUint64
+	case "float", "half_float":
+		c.physical, c.convert = parquet.Types.Float, toFloat32
+	case "double", "scaled_float":
+		c.physical, c.convert = parquet.Types.Double, toFloat64
+	case "date", "date_nanos":
+		c.physical, c.logical, c.convert = parquet.Types.Int64, schema.NewTimestampLogicalType(true, schema.TimeUnitMillis), toTimestamp
+	default:
+		// keyword, text, ip, version and all object like types. Values
+		// that are not strings are stored as JSON.
+		c.physical, c.logical, c.convert = parquet.Types.ByteArray, schema.StringLogicalType{}, toBytes
+	}
+	return c
+}
+
+// lookup returns the definition level and the raw value of the column in
+// the event.
+func (c *column) lookup(ts time.Time, fields mapstr.M) (int, any) {
+	if c.key == timestampKey {
+		return 1, ts
+	}
+
+	m := fields
+	for i, name := range c.path {
+		v, ok := m[name]
+		if !ok || v == nil {
+			return i, nil
+		}
+		if i == len(c.path)-1 {
+			return i + 1, v
+		}
+		switch sub := v.(type) {
+		case mapstr.M:
+			m = sub
+		case map[string]any:
+			m = sub
+		default:
+			return i, nil
+		}
+	}
+	return 0, nil
+}
+
+func (c *column) append(def int, v any) {
+	c.defs = append(c.defs, int16(def))
+	if v == nil {
+		return
+	}
+
+	switch x := v.(type) {
+	case bool:
+		c.bools = append(c.bools, x)
+	case int32:
+		c.int32s = append(c.int32s, x)
+	case int64:
+		c.int64s = append(c.int64s, x)
+	case uint64:
+		c.int64s = append(c.int64s, int64(x))
+	case float32:
+		c.float32s = append(c.float32s, x)
+	case float64:
+		c.float64s = append(c.float64s, x)
+	case []byte:
+		c.bytes = append(c.bytes, x)
+	}
+}
+
+func (c *column) reset() {
+	c.defs = c.defs[:0]
+	c.bools = c.bools[:0]
+	c.int32s = c.int32s[:0]
+	c.int64s = c.int64s[:0]
+	c.float32s = c.float32s[:0]
+	c.float64s = c.float64s[:0]
+	clear(c.bytes)
+	c.bytes = c.bytes[:0]
+}
+
+func toBool(v any) (any, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		return strconv.ParseBool(b)
+	}
+	return nil, typeError("boolean", v)
+}
+
+func toInt32(v any) (any, error) {
+	n, err := toInt64(v)
+	if err != nil {
+		return nil, err
+	}
+	i := n.(int64)
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return nil, fmt.Errorf("value %v overflows integer", i)
+	}
+	return int32(i), nil
+}
+
+func toInt64(v any) (any, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint:
+		if uint64(n) <= math.MaxInt64 {
+			return int64(n), nil
+		}
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+	case float32:
+		if float32(int64(n)) == n {
+			return int64(n), nil
+		}
+	case float64:
+		if float64(int64(n)) == n {
+			return int64(n), nil
+		}
+	case json.Number:
+		return n.Int64()
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	}
+	return nil, typeError("integer", v)
+}
+
+func toUint64(v any) (any, error) {
+	switch n := v.(type) {
+	case uint:
+		return uint64(n), nil
+	case uint64:
+		return n, nil
+	case string:
+		return strconv.ParseUint(n, 10, 64)
+	}
+	i, err := toInt64(v)
+	if err != nil || i.(int64) < 0 {
+		return nil, typeError("unsigned integer", v)
+	}
+	return uint64(i.(int64)), nil
+}
+
+func toFloat64(v any) (any, error) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return nil, typeError("number", v)
+	}
+	return float64(i.(int64)), nil
+}
+
+func toFloat32(v any) (any, error) {
+	f, err := toFloat64(v)
+	if err != nil {
+		return nil, err
+	}
+	return float32(f.(float64)), nil
+}
+
+func toTimestamp(v any) (any, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UnixMilli(), nil
+	case common.Time:
+		return time.Time(t).UnixMilli(), nil
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return nil, err
+		}
+		return ts.UnixMilli(), nil
+	}
+	return toInt64(v)
+}
+
+func toBytes(v any) (any, error) {
+	switch s := v.(type) {
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	case time.Time:
+		return []byte(s.UTC().Format(time.RFC3339Nano)), nil
+	case common.Time:
+		return []byte(time.Time(s).UTC().Format(time.RFC3339Nano)), nil
+	}
+	return json.Marshal(v)
+}
+
+func typeError(expected string, v any) error {
+	return fmt.Errorf("expected %v, got %T", expected, v)
+}
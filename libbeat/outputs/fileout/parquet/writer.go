@@ -0,0 +1,239 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+)
+
+// WriterSettings configures a Writer.
+type WriterSettings struct {
+	// RowGroupSize is the number of events buffered per row group.
+	RowGroupSize int
+
+	// MaxFileSize is the size in bytes after which the current file is
+	// finished and rotated. The check happens after each row group.
+	MaxFileSize int64
+
+	// CreatedBy is stored in the file metadata.
+	CreatedBy string
+}
+
+// Writer buffers events into row groups and writes them as Parquet files to
+// out. A file is only readable once its footer has been written, which
+// happens when the file is rotated or the writer is closed.
+type Writer struct {
+	out      io.Writer
+	rotate   func() error
+	schema   *Schema
+	settings WriterSettings
+	props    *parquet.WriterProperties
+
+	rows    int
+	scratch []rowValue
+
+	// file encodes the current file into buf, which is copied to out after
+	// every row group. It is nil until the first row group of a file is
+	// written.
+	file *file.Writer
+	buf  bytes.Buffer
+	size int64
+}
+
+type rowValue struct {
+	def   int
+	value any
+}
+
+// NewWriter creates a writer. rotate is called after a file has been
+// finished, so the next write starts a new file.
+func NewWriter(out io.Writer, rotate func() error, schema *Schema, settings WriterSettings) *Writer {
+	if settings.RowGroupSize <= 0 {
+		settings.RowGroupSize = 1
+	}
+	var props []parquet.WriterProperty
+	if settings.CreatedBy != "" {
+		props = append(props, parquet.WithCreatedBy(settings.CreatedBy))
+	}
+	return &Writer{
+		out:      out,
+		rotate:   rotate,
+		schema:   schema,
+		settings: settings,
+		props:    parquet.NewWriterProperties(props...),
+	}
+}
+
+// Add buffers the event in the current row group. An error is returned if a
+// field can not be converted to the type of its column, in which case the
+// event is not added.
+func (w *Writer) Add(event *beat.Event) error {
+	row := w.scratch[:0]
+	for _, c := range w.schema.columns {
+		def, v := c.lookup(event.Timestamp, event.Fields)
+		if v != nil {
+			var err error
+			if v, err = c.convert(v); err != nil {
+				return fmt.Errorf("field '%v': %w", c.key, err)
+			}
+		}
+		row = append(row, rowValue{def: def, value: v})
+	}
+	w.scratch = row
+
+	for i, c := range w.schema.columns {
+		c.append(row[i].def, row[i].value)
+	}
+	w.rows++
+	return nil
+}
+
+// Rows returns the number of events buffered in the current row group.
+func (w *Writer) Rows() int {
+	return w.rows
+}
+
+// Full reports whether the current row group reached the configured size.
+func (w *Writer) Full() bool {
+	return w.rows >= w.settings.RowGroupSize
+}
+
+// Flush writes the buffered events as a row group, and finishes the file if
+// it grew past the maximum file size. It returns the number of bytes
+// written. If a write fails the buffered events and the unfinished file are
+// dropped.
+func (w *Writer) Flush() (int, error) {
+	n, err := w.writeRowGroup()
+	if err != nil || w.size < w.settings.MaxFileSize {
+		return n, err
+	}
+
+	m, err := w.finish()
+	if err != nil {
+		return n + m, err
+	}
+	return n + m, w.rotate()
+}
+
+// Close writes the buffered events and the file footer.
+func (w *Writer) Close() (int, error) {
+	n, err := w.writeRowGroup()
+	if err != nil {
+		return n, err
+	}
+	m, err := w.finish()
+	return n + m, err
+}
+
+func (w *Writer) writeRowGroup() (int, error) {
+	if w.rows == 0 {
+		return 0, nil
+	}
+	defer func() {
+		for _, c := range w.schema.columns {
+			c.reset()
+		}
+		w.rows = 0
+	}()
+
+	if w.file == nil {
+		w.file = file.NewParquetWriter(&w.buf, w.schema.group, file.WithWriterProps(w.props))
+	}
+	rg := w.file.AppendRowGroup()
+	for _, c := range w.schema.columns {
+		cw, err := rg.NextColumn()
+		if err == nil {
+			err = c.write(cw)
+		}
+		if err != nil {
+			return 0, w.abandon(fmt.Errorf("column '%v': %w", c.key, err))
+		}
+	}
+	if err := rg.Close(); err != nil {
+		return 0, w.abandon(err)
+	}
+	return w.copyOut()
+}
+
+func (w *Writer) finish() (int, error) {
+	if w.file == nil {
+		return 0, nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return 0, w.abandon(err)
+	}
+	n, err := w.copyOut()
+	w.size = 0
+	return n, err
+}
+
+// copyOut writes the encoded part of the current file to out.
+func (w *Writer) copyOut() (int, error) {
+	n, err := w.out.Write(w.buf.Bytes())
+	w.buf.Reset()
+	if err != nil {
+		return n, w.abandon(err)
+	}
+	w.size += int64(n)
+	return n, nil
+}
+
+// abandon drops the unfinished file after a failed write, a file without
+// footer can not be read anyway.
+func (w *Writer) abandon(err error) error {
+	w.file = nil
+	w.buf.Reset()
+	w.size = 0
+	if rotateErr := w.rotate(); rotateErr != nil {
+		return errors.Join(err, rotateErr)
+	}
+	return err
+}
+
+// write writes the buffered values of the column as a column chunk.
+func (c *column) write(cw file.ColumnChunkWriter) error {
+	var err error
+	switch cw := cw.(type) {
+	case *file.BooleanColumnChunkWriter:
+		_, err = cw.WriteBatch(c.bools, c.defs, nil)
+	case *file.Int32ColumnChunkWriter:
+		_, err = cw.WriteBatch(c.int32s, c.defs, nil)
+	case *file.Int64ColumnChunkWriter:
+		_, err = cw.WriteBatch(c.int64s, c.defs, nil)
+	case *file.Float32ColumnChunkWriter:
+		_, err = cw.WriteBatch(c.float32s, c.defs, nil)
+	case *file.Float64ColumnChunkWriter:
+		_, err = cw.WriteBatch(c.float64s, c.defs, nil)
+	case *file.ByteArrayColumnChunkWriter:
+		_, err = cw.WriteBatch(c.bytes, c.defs, nil)
+	default:
+		err = fmt.Errorf("unexpected column writer %T", cw)
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/mapping"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const testFields = `
+- key: test
+  title: Test
+  fields:
+    - name: message
+      type: text
+    - name: event
+      type: group
+      fields:
+        - name: duration
+          type: long
+        - name: dataset
+          type: keyword
+    - name: ok
+      type: boolean
+    - name: labels.*
+      type: object
+    - name: old
+      type: alias
+      path: message
+`
+
+func testSchema(t *testing.T, include ...string) *Schema {
+	t.Helper()
+	fields, err := mapping.LoadFields([]byte(testFields))
+	require.NoError(t, err)
+	schema, err := NewSchema(fields, include)
+	require.NoError(t, err)
+	return schema
+}
+
+func TestNewSchema(t *testing.T) {
+	assert.Equal(t,
+		[]string{"@timestamp", "message", "event.duration", "event.dataset", "ok"},
+		testSchema(t).Columns())
+	assert.Equal(t,
+		[]string{"@timestamp", "event.duration", "event.dataset"},
+		testSchema(t, "event").Columns())
+
+	fields, err := mapping.LoadFields([]byte(testFields))
+	require.NoError(t, err)
+	_, err = NewSchema(fields, []string{"missing"})
+	assert.Error(t, err)
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	rotations := 0
+	w := NewWriter(&out, func() error { rotations++; return nil }, testSchema(t), WriterSettings{
+		RowGroupSize: 2,
+		MaxFileSize:  1 << 20,
+		CreatedBy:    "test",
+	})
+
+	ts := time.UnixMilli(1700000000000)
+	events := []mapstr.M{
+		{"message": "a", "event": mapstr.M{"duration": 10}, "ok": true},
+		{"message": "b", "ok": false},
+		{"event": mapstr.M{"duration": int64(30), "dataset": "x"}},
+	}
+	for _, fields := range events {
+		require.NoError(t, w.Add(&beat.Event{Timestamp: ts, Fields: fields}))
+		if w.Full() {
+			_, err := w.Flush()
+			require.NoError(t, err)
+		}
+	}
+
+	err := w.Add(&beat.Event{Timestamp: ts, Fields: mapstr.M{"event": mapstr.M{"duration": "soon"}}})
+	assert.ErrorContains(t, err, "field 'event.duration'")
+
+	n, err := w.Close()
+	require.NoError(t, err)
+	assert.Positive(t, n)
+	assert.Zero(t, rotations)
+
+	r, err := file.NewParquetReader(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	defer r.Close()
+
+	meta := r.MetaData()
+	assert.EqualValues(t, 3, meta.NumRows)
+	assert.Equal(t, "test", meta.GetCreatedBy())
+
+	var paths []string
+	for _, c := range meta.Schema.Columns() {
+		paths = append(paths, c.Path())
+	}
+	assert.Equal(t, []string{"@timestamp", "message", "event.duration", "event.dataset", "ok"}, paths)
+	assert.True(t, meta.Schema.Column(0).LogicalType().Equals(schema.NewTimestampLogicalType(true, schema.TimeUnitMillis)))
+	assert.True(t, meta.Schema.Column(1).LogicalType().Equals(schema.StringLogicalType{}))
+
+	require.Equal(t, 2, r.NumRowGroups())
+	assert.EqualValues(t, 2, r.RowGroup(0).NumRows())
+	assert.EqualValues(t, 1, r.RowGroup(1).NumRows())
+
+	// event.duration is the third column
+	readDurations := func(rg int) ([]int16, []int64) {
+		col, err := r.RowGroup(rg).Column(2)
+		require.NoError(t, err)
+		defs := make([]int16, 3)
+		values := make([]int64, 3)
+		total, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(3, values, defs, nil)
+		require.NoError(t, err)
+		return defs[:total], values[:n]
+	}
+
+	defs, values := readDurations(0)
+	assert.Equal(t, []int16{2, 0}, defs)
+	assert.Equal(t, []int64{10}, values)
+
+	defs, values = readDurations(1)
+	assert.Equal(t, []int16{2}, defs)
+	assert.Equal(t, []int64{30}, values)
+
+	col, err := r.RowGroup(0).Column(1)
+	require.NoError(t, err)
+	messages := make([]parquet.ByteArray, 2)
+	_, n, err = col.(*file.ByteArrayColumnChunkReader).ReadBatch(2, messages, make([]int16, 2), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []parquet.ByteArray{parquet.ByteArray("a"), parquet.ByteArray("b")}, messages[:n])
+}
+
+func TestWriterRotate(t *testing.T) {
+	var out bytes.Buffer
+	rotations := 0
+	w := NewWriter(&out, func() error { rotations++; return nil }, testSchema(t), WriterSettings{
+		RowGroupSize: 1,
+		MaxFileSize:  1,
+	})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, w.Add(&beat.Event{Fields: mapstr.M{"message": "m"}}))
+		_, err := w.Flush()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, rotations)
+	assert.Equal(t, 3, bytes.Count(out.Bytes(), []byte("PAR1"))/2)
+
+	// nothing buffered, closing does not write an empty file
+	n, err := w.Close()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -48,6 +48,7 @@ type client struct {
 	key      *fmtstr.EventFormatString
 	index    string
 	codec    codec.Codec
+	dl       *outputs.DeadLetter
 	config   sarama.Config
 	mux      sync.Mutex
 	done     chan struct{}
@@ -55,6 +56,10 @@ type client struct {
 	producerMux sync.RWMutex
 	producer    sarama.AsyncProducer
 
+	// txnMux serializes the transactions of the transactional producer,
+	// which can only have one open transaction at a time.
+	txnMux sync.Mutex
+
 	recordHeaders []sarama.RecordHeader
 
 	wg sync.WaitGroup
@@ -67,6 +72,12 @@ type msgRef struct {
 	failed []publisher.Event
 	batch  publisher.Batch
 
+	// delivered and settled are only set for the batches published in a
+	// transaction, see publishTxn. settled is closed once all the events of
+	// the batch were either delivered or failed.
+	delivered []publisher.Event
+	settled   chan struct{}
+
 	err error
 }
 
@@ -96,6 +107,7 @@ func newKafkaClient(
 	topic outil.Selector,
 	headers []header,
 	writer codec.Codec,
+	dl *outputs.DeadLetter,
 	cfg *sarama.Config,
 	logger *logp.Logger,
 ) (*client, error) {
@@ -107,6 +119,7 @@ func newKafkaClient(
 		key:      key,
 		index:    strings.ToLower(index),
 		codec:    writer,
+		dl:       dl,
 		config:   *cfg,
 		done:     make(chan struct{}),
 	}
@@ -136,6 +149,25 @@ func (c *client) Connect(_ context.Context) error {
 
 	c.log.Debugf("connect: %v", c.hosts)
 
+	// A producer is left behind when reconnecting after a failed Publish.
+	if c.producer != nil {
+		c.producerMux.Lock()
+		c.producer.AsyncClose()
+		c.producerMux.Unlock()
+		c.wg.Wait()
+		c.producer = nil
+	}
+
+	// The client is connected again after Close, e.g. by the failover
+	// output, so sends need a done channel that isn't closed yet.
+	c.producerMux.Lock()
+	select {
+	case <-c.done:
+		c.done = make(chan struct{})
+	default:
+	}
+	c.producerMux.Unlock()
+
 	// try to connect
 	producer, err := sarama.NewAsyncProducer(c.hosts, &c.config)
 	if err != nil {
@@ -163,7 +195,7 @@ func (c *client) Close() error {
 	}
 
 	// Releases any Publish goroutine blocked on a channel send.
-	close(c.done)
+	c.closeDone()
 
 	// Take the write lock so AsyncClose, which closes the input channel, waits
 	// for in-flight sends to finish instead of racing with them; see send.
@@ -176,6 +208,16 @@ func (c *client) Close() error {
 	return nil
 }
 
+// closeDone closes the done channel unless it's closed already. It doesn't
+// take producerMux, which is held by the sends closeDone releases.
+func (c *client) closeDone() {
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
 func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
 	events := batch.Events()
 	c.observer.NewBatch(len(events))
@@ -188,12 +230,24 @@ func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
 		batch:  batch,
 	}
 
+	if c.config.Producer.Transaction.ID != "" {
+		return c.publishTxn(ref)
+	}
+
+	c.sendEvents(ref)
+	return nil
+}
+
+// sendEvents sends the events of the batch of ref to the producer.
+func (c *client) sendEvents(ref *msgRef) {
+	events := ref.batch.Events()
 	ch := c.producer.Input()
 	for i := range events {
 		d := &events[i]
 		msg, err := c.getEventMessage(d)
 		if err != nil {
 			c.log.Errorf("Dropping event: %+v", err)
+			c.dl.Send(*d, err)
 			ref.done()
 			c.observer.PermanentErrors(1)
 			continue
@@ -207,7 +261,72 @@ func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
 			c.observer.PermanentErrors(1)
 		}
 	}
+}
+
+// publishTxn publishes the batch of ref in a Kafka transaction, which is
+// committed once all the events of the batch were delivered. The transaction
+// is aborted and the batch retried if any event fails or the commit fails.
+// An error is returned if the producer is in a fatal state, so it's replaced
+// on reconnect.
+func (c *client) publishTxn(ref *msgRef) error {
+	if ref.total == 0 {
+		ref.finish()
+		return nil
+	}
+
+	c.txnMux.Lock()
+	defer c.txnMux.Unlock()
+
+	producer := c.producer
+	if err := producer.BeginTxn(); err != nil {
+		ref.retry(ref.batch.Events(), fmt.Errorf("could not begin transaction: %w", err))
+		return c.txnError(producer)
+	}
+
+	ref.settled = make(chan struct{})
+	c.sendEvents(ref)
+	<-ref.settled
+
+	err := ref.err
+	if err == nil && len(ref.failed) > 0 {
+		err = errors.New("events failed in transaction")
+	}
+	if err == nil {
+		err = c.endTxn(producer.CommitTxn)
+		if err == nil {
+			ref.finish()
+			return nil
+		}
+		err = fmt.Errorf("could not commit transaction: %w", err)
+	}
 
+	if abortErr := c.endTxn(producer.AbortTxn); abortErr != nil {
+		c.log.Errorf("Kafka could not abort transaction: %v", abortErr)
+	}
+	ref.retry(append(ref.failed, ref.delivered...), err)
+	return c.txnError(producer)
+}
+
+// endTxn commits or aborts the current transaction with end, unless the
+// client is closing.
+func (c *client) endTxn(end func() error) error {
+	c.producerMux.RLock()
+	defer c.producerMux.RUnlock()
+
+	select {
+	case <-c.done:
+		return errors.New("output closing")
+	default:
+	}
+	return end()
+}
+
+// txnError returns an error if the transactional producer is in a fatal
+// state and can't be used anymore.
+func (c *client) txnError(producer sarama.AsyncProducer) error {
+	if producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0 {
+		return errors.New("kafka transactional producer is in a fatal state")
+	}
 	return nil
 }
 
@@ -311,7 +430,7 @@ func (c *client) successWorker(ch <-chan *sarama.ProducerMessage) {
 			c.log.Debug("Failed to assert libMsg.Metadata to *message")
 			return
 		}
-		msg.ref.done()
+		msg.ref.succeed(msg)
 	}
 }
 
@@ -413,26 +532,38 @@ func (r *msgRef) done() {
 	r.dec()
 }
 
+// succeed marks msg as delivered.
+func (r *msgRef) succeed(msg *message) {
+	if r.settled != nil {
+		r.delivered = append(r.delivered, msg.data)
+	}
+	r.dec()
+}
+
 func (r *msgRef) fail(msg *message, err error) {
 	switch {
 	case errors.Is(err, sarama.ErrInvalidMessage):
 		r.client.log.Errorf("Kafka (topic=%v): dropping invalid message", msg.topic)
 		r.client.observer.PermanentErrors(1)
+		r.client.dl.Send(msg.data, err)
 
 	case errors.Is(err, sarama.ErrMessageSizeTooLarge) || errors.Is(err, sarama.ErrInvalidMessageSize):
 		r.client.log.Errorf("Kafka (topic=%v): dropping too large message of size %v.",
 			msg.topic,
 			len(msg.key)+len(msg.value))
 		r.client.observer.PermanentErrors(1)
+		r.client.dl.Send(msg.data, err)
 
 	// drop event if it exceeds size larger than max_message_bytes
 	case strings.Contains(err.Error(), "Attempt to produce message larger than configured Producer.MaxMessageBytes"):
 		r.client.log.Errorf("Kafka (topic=%v): dropping message as it exceeds max_mesage_bytes:", msg.topic)
 		r.client.observer.PermanentErrors(1)
+		r.client.dl.Send(msg.data, err)
 
 	case isAuthError(err):
 		r.client.log.Errorf("Kafka (topic=%v): authorisation error: %s", msg.topic, err)
 		r.client.observer.PermanentErrors(1)
+		r.client.dl.Send(msg.data, err)
 
 	case errors.Is(err, breaker.ErrBreakerOpen):
 		// Add this message to the failed list, but don't overwrite r.err since
@@ -456,6 +587,17 @@ func (r *msgRef) dec() {
 		return
 	}
 
+	// Transactional batches are finished by publishTxn, once the transaction
+	// is committed.
+	if r.settled != nil {
+		close(r.settled)
+		return
+	}
+	r.finish()
+}
+
+// finish acknowledges the batch, or retries the failed events.
+func (r *msgRef) finish() {
 	r.client.log.Debug("finished kafka batch")
 	stats := r.client.observer
 
@@ -477,6 +619,13 @@ func (r *msgRef) dec() {
 	}
 }
 
+// retry retries the events of a batch whose transaction failed with err.
+func (r *msgRef) retry(events []publisher.Event, err error) {
+	r.batch.RetryEvents(events)
+	r.client.observer.RetryableErrors(len(events))
+	r.client.log.Errorf("Kafka publish failed with: %v", err)
+}
+
 func (c *client) Test(d testing.Driver) {
 	if c.config.Net.TLS.Enable {
 		d.Warn("TLS", "Kafka output doesn't support TLS testing")
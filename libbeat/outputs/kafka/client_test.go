@@ -19,6 +19,7 @@ package kafka
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -32,6 +33,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/monitoring"
 	"github.com/elastic/elastic-agent-libs/paths"
 	"github.com/elastic/sarama"
@@ -108,6 +110,160 @@ func TestClientShutdownPanic(t *testing.T) {
 		"event dropped log not found")
 }
 
+func TestClientPublishTransaction(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+
+	cfg, err := config.NewConfigFrom(map[string]any{
+		"hosts":               []string{"localhost:9094"},
+		"topic":               "testTopic",
+		"idempotent":          true,
+		"transaction.enabled": true,
+	})
+	require.NoError(t, err, "could not create config")
+
+	outGroup, err := makeKafka(
+		nil,
+		beat.Info{
+			Beat:        "libbeat",
+			IndexPrefix: "testbeat",
+			Logger:      logger,
+			Paths:       paths.New()},
+		outputs.NewStats(monitoring.NewRegistry(), logger), cfg)
+	require.NoError(t, err, "could not create kafka output")
+
+	c, ok := outGroup.Clients[0].(*client)
+	require.Truef(t, ok, "Expected output to be of type %T", &client{})
+
+	newBatch := func() *outest.Batch {
+		return outest.NewBatch(
+			beat.Event{Fields: map[string]any{"msg": "message 1"}},
+			beat.Event{Fields: map[string]any{"msg": "message 2"}},
+		)
+	}
+
+	tests := map[string]struct {
+		failMsg   string
+		commitErr error
+		status    sarama.ProducerTxnStatusFlag
+		wantSig   outest.BatchSignalTag
+		wantRetry int
+		wantErr   bool
+		wantTxn   []string
+	}{
+		"committed": {
+			wantSig: outest.BatchACK,
+			wantTxn: []string{"begin", "commit"},
+		},
+		"failed event aborts": {
+			failMsg:   "message 2",
+			wantSig:   outest.BatchRetryEvents,
+			wantRetry: 2,
+			wantTxn:   []string{"begin", "abort"},
+		},
+		"failed commit aborts": {
+			commitErr: sarama.ErrOutOfOrderSequenceNumber,
+			wantSig:   outest.BatchRetryEvents,
+			wantRetry: 2,
+			wantTxn:   []string{"begin", "commit", "abort"},
+		},
+		"fatal error reconnects": {
+			commitErr: sarama.ErrProducerFenced,
+			status:    sarama.ProducerTxnFlagFatalError,
+			wantSig:   outest.BatchRetryEvents,
+			wantRetry: 2,
+			wantErr:   true,
+			wantTxn:   []string{"begin", "commit", "abort"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTxnProducerMock(tc.failMsg)
+			p.commitErr = tc.commitErr
+			p.status = tc.status
+			c.producer = p
+			c.wg.Add(2)
+			go c.successWorker(p.successes)
+			go c.errorWorker(p.errors)
+			defer func() {
+				p.AsyncClose()
+				c.wg.Wait()
+			}()
+
+			b := newBatch()
+			err := c.Publish(context.Background(), b)
+			if tc.wantErr {
+				require.Error(t, err, "publish must fail when the producer is in a fatal state")
+			} else {
+				require.NoError(t, err, "publish failed")
+			}
+
+			require.Len(t, b.Signals, 1, "batch must be signaled once")
+			assert.Equal(t, tc.wantSig, b.Signals[0].Tag)
+			assert.Len(t, b.Signals[0].Events, tc.wantRetry)
+			assert.Equal(t, tc.wantTxn, p.txn)
+		})
+	}
+}
+
+// txnProducerMock is a transactional producer that delivers all the messages
+// except the ones whose value contains failMsg.
+type txnProducerMock struct {
+	producerMock
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+	failMsg   string
+
+	commitErr error
+	status    sarama.ProducerTxnStatusFlag
+	txn       []string
+}
+
+func newTxnProducerMock(failMsg string) *txnProducerMock {
+	p := &txnProducerMock{
+		producerMock: producerMock{input: make(chan *sarama.ProducerMessage)},
+		successes:    make(chan *sarama.ProducerMessage),
+		errors:       make(chan *sarama.ProducerError),
+		failMsg:      failMsg,
+	}
+	go func() {
+		defer close(p.successes)
+		defer close(p.errors)
+		for msg := range p.input {
+			value, _ := msg.Value.Encode()
+			if p.failMsg != "" && strings.Contains(string(value), p.failMsg) {
+				p.errors <- &sarama.ProducerError{Msg: msg, Err: sarama.ErrNotEnoughReplicas}
+				continue
+			}
+			p.successes <- msg
+		}
+	}()
+	return p
+}
+
+func (p *txnProducerMock) IsTransactional() bool {
+	return true
+}
+
+func (p *txnProducerMock) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return p.status
+}
+
+func (p *txnProducerMock) BeginTxn() error {
+	p.txn = append(p.txn, "begin")
+	return nil
+}
+
+func (p *txnProducerMock) CommitTxn() error {
+	p.txn = append(p.txn, "commit")
+	return p.commitErr
+}
+
+func (p *txnProducerMock) AbortTxn() error {
+	p.txn = append(p.txn, "abort")
+	return nil
+}
+
 type producerMock struct {
 	input chan *sarama.ProducerMessage
 }
@@ -20,8 +20,10 @@ package kafka
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand/v2"
+	"slices"
 	"strings"
 	"time"
 
@@ -60,6 +62,8 @@ type KafkaConfig struct {
 	KeepAlive          time.Duration             `config:"keep_alive"          validate:"min=0"`
 	MaxMessageBytes    *int                      `config:"max_message_bytes"   validate:"min=1"`
 	RequiredACKs       *int                      `config:"required_acks"       validate:"min=-1"`
+	Idempotent         bool                      `config:"idempotent"`
+	Transaction        transactionConfig         `config:"transaction"`
 	BrokerTimeout      time.Duration             `config:"broker_timeout"      validate:"min=1"`
 	Compression        string                    `config:"compression"`
 	CompressionLevel   int                       `config:"compression_level"`
@@ -77,6 +81,7 @@ type KafkaConfig struct {
 	Sasl               kafka.SaslConfig          `config:"sasl"`
 	EnableFAST         bool                      `config:"enable_krb5_fast"`
 	Queue              config.Namespace          `config:"queue"`
+	DeadLetter         config.Namespace          `config:"dead_letter"`
 
 	// Currently only used for validation. Those values are later
 	// unpacked into temporary structs whenever they're necessary.
@@ -84,6 +89,34 @@ type KafkaConfig struct {
 	Topics []any  `config:"topics"`
 }
 
+// transactionConfig configures the transactional producer, which publishes
+// each batch in a Kafka transaction.
+type transactionConfig struct {
+	Enabled bool `config:"enabled"`
+	// IDPrefix is the prefix of the transactional ID, which is followed by
+	// the Beat UUID and a hash of the output. It defaults to the client ID.
+	IDPrefix string        `config:"id_prefix"`
+	Timeout  time.Duration `config:"timeout"   validate:"min=0"`
+}
+
+// transactionalID returns the transactional ID of the output of the Beat
+// identified by beatUUID. The ID ends with a hash of the hosts and topics of
+// the output, so the Kafka outputs of a Beat, like the members of a failover
+// output or the outputs of different routes, don't fence each other.
+func (c *KafkaConfig) transactionalID(beatUUID string) string {
+	prefix := c.Transaction.IDPrefix
+	if prefix == "" {
+		prefix = c.ClientID
+	}
+
+	hosts := slices.Clone(c.Hosts)
+	slices.Sort(hosts)
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%q %q %v", hosts, c.Topic, c.Topics)
+
+	return fmt.Sprintf("%s-%s-%08x", prefix, beatUUID, h.Sum32())
+}
+
 type metaConfig struct {
 	Retry       metaRetryConfig `config:"retry"`
 	RefreshFreq time.Duration   `config:"refresh_frequency" validate:"min=0"`
@@ -189,6 +222,19 @@ func (c *KafkaConfig) Validate() error {
 		return errors.New("including headers is not supported for kafka versions < 0.11")
 	}
 
+	if c.Idempotent {
+		if c.Version < kafka.Version("0.11") {
+			return errors.New("idempotent producer is not supported for kafka versions < 0.11")
+		}
+		if c.RequiredACKs != nil && *c.RequiredACKs != int(sarama.WaitForAll) {
+			return errors.New("idempotent producer requires required_acks to be -1")
+		}
+	}
+
+	if c.Transaction.Enabled && !c.Idempotent {
+		return errors.New("transaction.enabled requires idempotent to be true")
+	}
+
 	// When running under Elastic-Agent we do not support dynamic topic
 	// selection, so `topics` is not supported and `topic` is treated as an
 	// plain string
@@ -201,7 +247,9 @@ func (c *KafkaConfig) Validate() error {
 	return nil
 }
 
-func newSaramaConfig(log *logp.Logger, config *KafkaConfig) (*sarama.Config, error) {
+// newSaramaConfig builds the sarama configuration of the output. beatUUID
+// is used to build the transactional ID of the transactional producer.
+func newSaramaConfig(log *logp.Logger, config *KafkaConfig, beatUUID string) (*sarama.Config, error) {
 	partitioner, err := makePartitioner(log, config.Partition)
 	if err != nil {
 		return nil, err
@@ -277,6 +325,21 @@ func newSaramaConfig(log *logp.Logger, config *KafkaConfig) (*sarama.Config, err
 		k.Producer.RequiredAcks = sarama.RequiredAcks(*config.RequiredACKs)
 	}
 
+	// The idempotent producer deduplicates the retried messages, it requires
+	// the acknowledgement of all the replicas and a single in-flight request
+	// per broker to keep the messages ordered.
+	if config.Idempotent {
+		k.Producer.Idempotent = true
+		k.Producer.RequiredAcks = sarama.WaitForAll
+		k.Net.MaxOpenRequests = 1
+	}
+	if config.Transaction.Enabled {
+		k.Producer.Transaction.ID = config.transactionalID(beatUUID)
+		if config.Transaction.Timeout > 0 {
+			k.Producer.Transaction.Timeout = config.Transaction.Timeout
+		}
+	}
+
 	compressionMode, ok := compressionModes[strings.ToLower(config.Compression)]
 	if !ok {
 		return nil, fmt.Errorf("Unknown compression mode: '%v'", config.Compression)
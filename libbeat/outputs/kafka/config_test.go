@@ -20,6 +20,7 @@ package kafka
 import (
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/sarama"
 )
 
 func TestConfigAcceptValid(t *testing.T) {
@@ -45,6 +47,14 @@ func TestConfigAcceptValid(t *testing.T) {
 			"version":     "1.0.0",
 			"topic":       "foo",
 		},
+		"transactional producer": mapstr.M{
+			"idempotent":    true,
+			"required_acks": -1,
+			"transaction": mapstr.M{
+				"enabled": true,
+			},
+			"topic": "foo",
+		},
 	}
 
 	for name, test := range tests {
@@ -58,7 +68,7 @@ func TestConfigAcceptValid(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Can not create test configuration: %v", err)
 			}
-			if _, err := newSaramaConfig(logger, cfg); err != nil {
+			if _, err := newSaramaConfig(logger, cfg, ""); err != nil {
 				t.Fatalf("Failure creating sarama config: %v", err)
 			}
 		})
@@ -77,6 +87,22 @@ func TestConfigInvalid(t *testing.T) {
 		},
 		// The default config does not set `topic` nor `topics`.
 		"No topics or topic provided": mapstr.M{},
+		"idempotent with 0.10": mapstr.M{
+			"idempotent": true,
+			"version":    "0.10",
+			"topic":      "foo",
+		},
+		"idempotent with required_acks 1": mapstr.M{
+			"idempotent":    true,
+			"required_acks": 1,
+			"topic":         "foo",
+		},
+		"transaction without idempotent": mapstr.M{
+			"transaction": mapstr.M{
+				"enabled": true,
+			},
+			"topic": "foo",
+		},
 	}
 
 	for name, test := range tests {
@@ -167,7 +193,7 @@ topic: foo`)
 			t.Fatalf("Can not create test configuration: %v", err)
 		}
 
-		sc, err := newSaramaConfig(logger, cfg)
+		sc, err := newSaramaConfig(logger, cfg, "")
 		if err != nil {
 			t.Fatalf("Failure creating sarama config: %v", err)
 		}
@@ -187,7 +213,7 @@ topic: foo`)
 			t.Fatalf("Can not create test configuration: %v", err)
 		}
 
-		sc, err := newSaramaConfig(logger, cfg)
+		sc, err := newSaramaConfig(logger, cfg, "")
 		if err != nil {
 			t.Fatalf("Failure creating sarama config: %v", err)
 		}
@@ -195,6 +221,73 @@ topic: foo`)
 		assert.False(t, sc.ApiVersionsRequest,
 			"ApiVersionsRequest should be false")
 	})
+
+	t.Run("Transaction_ID_is_derived_from_beat_UUID", func(t *testing.T) {
+		c := config.MustNewConfigFrom(`
+hosts: localhost
+topic: foo
+idempotent: true
+transaction.enabled: true`)
+		logger := logptest.NewTestingLogger(t, "")
+
+		cfg, err := ReadConfig(c)
+		if err != nil {
+			t.Fatalf("Can not create test configuration: %v", err)
+		}
+
+		sc, err := newSaramaConfig(logger, cfg, "a1b2c3")
+		if err != nil {
+			t.Fatalf("Failure creating sarama config: %v", err)
+		}
+
+		assert.True(t, sc.Producer.Idempotent, "Producer.Idempotent should be set")
+		assert.Equal(t, sarama.WaitForAll, sc.Producer.RequiredAcks)
+		assert.Equal(t, 1, sc.Net.MaxOpenRequests)
+		assert.Regexp(t, `^beats-a1b2c3-[0-9a-f]{8}$`, sc.Producer.Transaction.ID)
+		id := sc.Producer.Transaction.ID
+
+		cfg.Transaction.IDPrefix = "filebeat-eu"
+		sc, err = newSaramaConfig(logger, cfg, "a1b2c3")
+		if err != nil {
+			t.Fatalf("Failure creating sarama config: %v", err)
+		}
+		assert.Equal(t, "filebeat-eu"+strings.TrimPrefix(id, "beats"), sc.Producer.Transaction.ID)
+	})
+
+	t.Run("Transaction_ID_is_unique_per_output", func(t *testing.T) {
+		read := func(s string) *KafkaConfig {
+			cfg, err := ReadConfig(config.MustNewConfigFrom(s))
+			if err != nil {
+				t.Fatalf("Can not create test configuration: %v", err)
+			}
+			return cfg
+		}
+		primary := read(`
+hosts: [kafka1, kafka2]
+topic: foo
+idempotent: true
+transaction.enabled: true`)
+		reordered := read(`
+hosts: [kafka2, kafka1]
+topic: foo
+idempotent: true
+transaction.enabled: true`)
+		backup := read(`
+hosts: [kafka3]
+topic: foo
+idempotent: true
+transaction.enabled: true`)
+		otherTopic := read(`
+hosts: [kafka1, kafka2]
+topic: bar
+idempotent: true
+transaction.enabled: true`)
+
+		id := primary.transactionalID("a1b2c3")
+		assert.Equal(t, id, reordered.transactionalID("a1b2c3"))
+		assert.NotEqual(t, id, backup.transactionalID("a1b2c3"))
+		assert.NotEqual(t, id, otherTopic.transactionalID("a1b2c3"))
+	})
 }
 
 func TestBackoffFunc(t *testing.T) {
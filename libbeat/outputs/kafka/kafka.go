@@ -38,7 +38,7 @@ func init() {
 }
 
 func makeKafka(
-	_ outputs.IndexManager,
+	im outputs.IndexManager,
 	beat beat.Info,
 	observer outputs.Observer,
 	cfg *config.C,
@@ -58,7 +58,7 @@ func makeKafka(
 		return outputs.Fail(err)
 	}
 
-	libCfg, err := newSaramaConfig(log, kConfig)
+	libCfg, err := newSaramaConfig(log, kConfig, beat.ID.String())
 	if err != nil {
 		return outputs.Fail(err)
 	}
@@ -73,16 +73,22 @@ func makeKafka(
 		return outputs.Fail(err)
 	}
 
-	client, err := newKafkaClient(observer, hosts, beat.IndexPrefix, kConfig.Key, topic, kConfig.Headers, codec, libCfg, beat.Logger)
+	dl, err := outputs.NewDeadLetter(im, beat, observer, "kafka", kConfig.DeadLetter)
 	if err != nil {
 		return outputs.Fail(err)
 	}
 
+	client, err := newKafkaClient(observer, hosts, beat.IndexPrefix, kConfig.Key, topic, kConfig.Headers, codec, dl, libCfg, beat.Logger)
+	if err != nil {
+		_ = dl.Close()
+		return outputs.Fail(err)
+	}
+
 	retry := 0
 	if kConfig.MaxRetries < 0 {
 		retry = -1
 	}
-	return outputs.Success(kConfig.Queue, kConfig.BulkMaxSize, retry, nil, beat.Logger, beat.Paths, client)
+	return dl.Attach(outputs.Success(kConfig.Queue, kConfig.BulkMaxSize, retry, nil, beat.Logger, beat.Paths, client))
 }
 
 // buildTopicSelector builds the topic selector for standalone Beat and when
@@ -37,6 +37,8 @@ type asyncClient struct {
 	*transport.Client
 	observer outputs.Observer
 	client   *v2.AsyncClient
+	enc      func(any) ([]byte, error)
+	dl       *outputs.DeadLetter
 	win      *window
 
 	connect func(ctx context.Context) error
@@ -62,11 +64,13 @@ func newAsyncClient(
 	conn *transport.Client,
 	observer outputs.Observer,
 	config *Config,
+	dl *outputs.DeadLetter,
 ) (*asyncClient, error) {
 	c := &asyncClient{
 		log:      log,
 		Client:   conn,
 		observer: observer,
+		dl:       dl,
 	}
 
 	if config.SlowStart {
@@ -77,12 +81,12 @@ func newAsyncClient(
 		log.Warn(`The async Logstash client does not support the "ttl" option`)
 	}
 
-	enc := makeLogstashEventEncoder(log, beatVersion, config.EscapeHTML, config.Index)
+	c.enc = makeLogstashEventEncoder(log, beatVersion, config.EscapeHTML, config.Index)
 
 	queueSize := config.Pipelining - 1
 	timeout := config.Timeout
 	compressLvl := config.CompressionLevel
-	clientFactory := makeClientFactory(queueSize, timeout, enc, compressLvl)
+	clientFactory := makeClientFactory(queueSize, timeout, c.enc, compressLvl)
 
 	var err error
 	c.client, err = clientFactory(c.Client)
@@ -143,6 +147,7 @@ func (c *asyncClient) Publish(_ context.Context, batch publisher.Batch) error {
 	events := batch.Events()
 	st.NewBatch(len(events))
 
+	events, window := encodeEvents(c.log, c.enc, c.dl, st, events)
 	if len(events) == 0 {
 		batch.ACK()
 		return nil
@@ -167,16 +172,17 @@ func (c *asyncClient) Publish(_ context.Context, batch publisher.Batch) error {
 		)
 
 		if c.win == nil {
-			n = len(events)
-			err = c.sendEvents(ref, events)
+			n = len(window)
+			err = c.sendEvents(ref, window)
 		} else {
-			n, err = c.publishWindowed(ref, events)
+			n, err = c.publishWindowed(ref, window)
 		}
 
 		c.log.Debugf("%v events out of %v events sent to logstash host %s. Continue sending",
 			n, len(events), c.Host())
 
 		events = events[n:]
+		window = window[n:]
 		if err != nil {
 			_ = c.Close()
 			return err
@@ -192,9 +198,9 @@ func (c *asyncClient) String() string {
 
 func (c *asyncClient) publishWindowed(
 	ref *msgRef,
-	events []publisher.Event,
+	window []any,
 ) (int, error) {
-	batchSize := len(events)
+	batchSize := len(window)
 	windowSize := c.win.get()
 
 	c.log.Debugf("Try to publish %v events to logstash host %s with window size %v",
@@ -202,28 +208,25 @@ func (c *asyncClient) publishWindowed(
 
 	// prepare message payload
 	if batchSize > windowSize {
-		events = events[:windowSize]
+		window = window[:windowSize]
 	}
 
-	err := c.sendEvents(ref, events)
+	err := c.sendEvents(ref, window)
 	if err != nil {
 		return 0, err
 	}
 
-	return len(events), nil
+	return len(window), nil
 }
 
-func (c *asyncClient) sendEvents(ref *msgRef, events []publisher.Event) error {
+// sendEvents sends the encoded events of window.
+func (c *asyncClient) sendEvents(ref *msgRef, window []any) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	if c.client == nil {
 		return errors.New("connection closed")
 	}
-	window := make([]any, len(events))
-	for i := range events {
-		window[i] = &events[i].Content
-	}
 	ref.count.Add(1)
 
 	return c.client.Send(ref.customizedCallback(), window)
@@ -30,6 +30,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/elastic/elastic-agent-libs/transport"
 
@@ -55,6 +56,16 @@ func TestAsyncStructuredEvent(t *testing.T) {
 	testStructuredEvent(t, makeAsyncTestClient)
 }
 
+func TestAsyncDeadLetterEvent(t *testing.T) {
+	testDeadLetterEvent(t, func(conn *transport.Client, dl *outputs.DeadLetter) outputs.NetworkClient {
+		config := DefaultConfig()
+		config.Timeout = 1 * time.Second
+		client, err := newAsyncClient(logptest.NewTestingLogger(t, ""), "beat_version", conn, outputs.NewNilObserver(), &config, dl)
+		require.NoError(t, err)
+		return client
+	})
+}
+
 func makeAsyncTestClient(conn *transport.Client) testClientDriver {
 	config := DefaultConfig()
 	config.Timeout = 1 * time.Second
@@ -63,7 +74,7 @@ func makeAsyncTestClient(conn *transport.Client) testClientDriver {
 	if err != nil {
 		panic(err)
 	}
-	client, err := newAsyncClient(logger, "beat_version", conn, outputs.NewNilObserver(), &config)
+	client, err := newAsyncClient(logger, "beat_version", conn, outputs.NewNilObserver(), &config, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -90,7 +101,7 @@ func testClientSendCloseDoesNotPanic(t *testing.T) {
 	logger, err := logp.NewDevelopmentLogger("")
 	require.NoError(t, err)
 
-	asyncClient, err := newAsyncClient(logger, "beat_version", transp, outputs.NewNilObserver(), &config)
+	asyncClient, err := newAsyncClient(logger, "beat_version", transp, outputs.NewNilObserver(), &config, nil)
 	require.NoError(t, err)
 
 	event := beat.Event{
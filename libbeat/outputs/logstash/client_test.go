@@ -20,15 +20,22 @@
 package logstash
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/common/transport/transptest"
+	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/elastic/elastic-agent-libs/transport"
 	v2 "github.com/elastic/go-lumber/server/v2"
@@ -229,3 +236,59 @@ func eventGet(event interface{}, path string) interface{} {
 	}
 	return doc[elems[len(elems)-1]]
 }
+
+func testDeadLetterEvent(t *testing.T, newClient func(*transport.Client, *outputs.DeadLetter) outputs.NetworkClient) {
+	mock := transptest.NewMockServerTCP(t, 1*time.Second, "", nil)
+	server, _ := v2.NewWithListener(mock.Listener)
+	defer server.Close()
+
+	transp, err := mock.Connect()
+	require.NoError(t, err)
+	defer transp.Close()
+
+	dir := t.TempDir()
+	var ns conf.Namespace
+	require.NoError(t, conf.MustNewConfigFrom(mapstr.M{"file.path": dir}).Unpack(&ns))
+	dl, err := outputs.NewDeadLetter(nil, beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}, nil, "logstash", ns)
+	require.NoError(t, err)
+	defer dl.Close()
+
+	client := newClient(transp, dl)
+	defer client.Close()
+
+	// The channel can't be encoded to JSON.
+	batch := outest.NewBatch(
+		beat.Event{Fields: mapstr.M{"message": "first"}},
+		beat.Event{Fields: mapstr.M{"message": "second", "ch": make(chan int)}},
+		beat.Event{Fields: mapstr.M{"message": "third"}},
+	)
+	signals := make(chan outest.BatchSignal, 1)
+	batch.OnSignal = func(sig outest.BatchSignal) { signals <- sig }
+	go func() { _ = client.Publish(t.Context(), batch) }()
+
+	received := server.Receive()
+	received.ACK()
+	require.Len(t, received.Events, 2)
+	assert.Equal(t, "first", received.Events[0].(map[string]any)["message"])
+	assert.Equal(t, "third", received.Events[1].(map[string]any)["message"])
+
+	select {
+	case sig := <-signals:
+		assert.Equal(t, outest.BatchACK, sig.Tag)
+	case <-time.After(10 * time.Second):
+		t.Fatal("batch wasn't acknowledged")
+	}
+
+	require.NoError(t, dl.Close())
+	files, err := filepath.Glob(filepath.Join(dir, "testbeat*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &doc))
+	assert.Equal(t, map[string]any{"output": "logstash"}, doc["dead_letter"])
+	assert.Equal(t, map[string]any{"message": "unsupported"}, doc["error"])
+}
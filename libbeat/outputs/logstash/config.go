@@ -43,6 +43,7 @@ type Config struct {
 	Backoff          Backoff               `config:"backoff"`
 	EscapeHTML       bool                  `config:"escape_html"`
 	Queue            config.Namespace      `config:"queue"`
+	DeadLetter       config.Namespace      `config:"dead_letter"`
 }
 
 type Backoff struct {
@@ -18,11 +18,14 @@
 package logstash
 
 import (
+	"bytes"
 	"errors"
 	"strings"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
@@ -33,6 +36,12 @@ func makeLogstashEventEncoder(log *logp.Logger, beatVersion string, escapeHTML b
 	})
 	index = strings.ToLower(index)
 	return func(event any) ([]byte, error) {
+		// The clients encode the events before sending them, see
+		// encodeEvents.
+		if encoded, ok := event.([]byte); ok {
+			return encoded, nil
+		}
+
 		beatEvent, ok := event.(*beat.Event)
 		if !ok {
 			return nil, errors.New("event is not of type *beat.Event")
@@ -46,3 +55,35 @@ func makeLogstashEventEncoder(log *logp.Logger, beatVersion string, escapeHTML b
 		return d, nil
 	}
 }
+
+// encodeEvents encodes events with enc before they're sent, so an event that
+// can't be encoded doesn't fail the whole batch. The events that can't be
+// encoded are sent to the dead-letter sink and left out of the returned
+// events, window holds the encodings of the returned events.
+func encodeEvents(
+	log *logp.Logger,
+	enc func(any) ([]byte, error),
+	dl *outputs.DeadLetter,
+	observer outputs.Observer,
+	events []publisher.Event,
+) (encoded []publisher.Event, window []any) {
+	encoded = make([]publisher.Event, 0, len(events))
+	window = make([]any, 0, len(events))
+	dropped := 0
+	for i := range events {
+		data, err := enc(&events[i].Content)
+		if err != nil {
+			log.Errorf("Dropping event that couldn't be encoded: %+v", err)
+			dl.Send(events[i], err)
+			dropped++
+			continue
+		}
+		encoded = append(encoded, events[i])
+		// The encoder reuses its buffer.
+		window = append(window, bytes.Clone(data))
+	}
+	if dropped > 0 {
+		observer.PermanentErrors(dropped)
+	}
+	return encoded, window
+}
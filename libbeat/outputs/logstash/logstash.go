@@ -38,15 +38,27 @@ func init() {
 }
 
 func makeLogstash(
-	_ outputs.IndexManager,
+	im outputs.IndexManager,
 	beat beat.Info,
 	observer outputs.Observer,
 	cfg *conf.C,
 ) (outputs.Group, error) {
 	log := beat.Logger.Named("logstash")
-	return MakeLogstashClients(beat.Version, log, observer, cfg, beat.IndexPrefix, beat.Paths)
+	config, err := readConfig(cfg, beat.IndexPrefix)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	dl, err := outputs.NewDeadLetter(im, beat, observer, "logstash", config.DeadLetter)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	return dl.Attach(makeLogstashClients(beat.Version, log, observer, cfg, config, dl, beat.Paths))
 }
 
+// MakeLogstashClients returns the clients of a Logstash output configured by
+// rawCfg. The events that can't be encoded are dropped, the dead_letter
+// setting is only supported by the Logstash output of the Beats.
 func MakeLogstashClients(
 	beatVersion string,
 	logger *logp.Logger,
@@ -59,7 +71,18 @@ func MakeLogstashClients(
 	if err != nil {
 		return outputs.Fail(err)
 	}
+	return makeLogstashClients(beatVersion, logger, observer, rawCfg, config, nil, beatPaths)
+}
 
+func makeLogstashClients(
+	beatVersion string,
+	logger *logp.Logger,
+	observer outputs.Observer,
+	rawCfg *conf.C,
+	config *Config,
+	dl *outputs.DeadLetter,
+	beatPaths *paths.Path,
+) (outputs.Group, error) {
 	hosts, err := outputs.ReadHostList(rawCfg)
 	if err != nil {
 		return outputs.Fail(err)
@@ -87,9 +110,9 @@ func MakeLogstashClients(
 		}
 
 		if config.Pipelining > 0 {
-			client, err = newAsyncClient(logger, beatVersion, conn, observer, config)
+			client, err = newAsyncClient(logger, beatVersion, conn, observer, config, dl)
 		} else {
-			client, err = newSyncClient(logger, beatVersion, conn, observer, config)
+			client, err = newSyncClient(logger, beatVersion, conn, observer, config, dl)
 		}
 		if err != nil {
 			return outputs.Fail(err)
@@ -33,6 +33,8 @@ type syncClient struct {
 	*transport.Client
 	client   *v2.SyncClient
 	observer outputs.Observer
+	enc      func(any) ([]byte, error)
+	dl       *outputs.DeadLetter
 	win      *window
 	ttl      time.Duration
 	ticker   *time.Ticker
@@ -44,11 +46,13 @@ func newSyncClient(
 	conn *transport.Client,
 	observer outputs.Observer,
 	config *Config,
+	dl *outputs.DeadLetter,
 ) (*syncClient, error) {
 	c := &syncClient{
 		log:      log,
 		Client:   conn,
 		observer: observer,
+		dl:       dl,
 		ttl:      config.TTL,
 	}
 
@@ -60,9 +64,9 @@ func newSyncClient(
 	}
 
 	var err error
-	enc := makeLogstashEventEncoder(log, beatVersion, config.EscapeHTML, config.Index)
+	c.enc = makeLogstashEventEncoder(log, beatVersion, config.EscapeHTML, config.Index)
 	c.client, err = v2.NewSyncClientWithConn(conn,
-		v2.JSONEncoder(enc),
+		v2.JSONEncoder(c.enc),
 		v2.Timeout(config.Timeout),
 		v2.CompressionLevel(config.CompressionLevel),
 	)
@@ -107,6 +111,7 @@ func (c *syncClient) Publish(_ context.Context, batch publisher.Batch) error {
 
 	st.NewBatch(len(events))
 
+	events, window := encodeEvents(c.log, c.enc, c.dl, st, events)
 	if len(events) == 0 {
 		batch.ACK()
 		return nil
@@ -140,9 +145,9 @@ func (c *syncClient) Publish(_ context.Context, batch publisher.Batch) error {
 
 		begin := time.Now()
 		if c.win == nil {
-			n, err = c.sendEvents(events)
+			n, err = c.sendEvents(window)
 		} else {
-			n, err = c.publishWindowed(events)
+			n, err = c.publishWindowed(window)
 		}
 		took := time.Since(begin)
 		st.ReportLatency(took)
@@ -150,6 +155,7 @@ func (c *syncClient) Publish(_ context.Context, batch publisher.Batch) error {
 			n, len(events), c.Host())
 
 		events = events[n:]
+		window = window[n:]
 		st.AckedEvents(n)
 		deadlockListener.ack(n)
 		if err != nil {
@@ -172,18 +178,18 @@ func (c *syncClient) Publish(_ context.Context, batch publisher.Batch) error {
 	return nil
 }
 
-func (c *syncClient) publishWindowed(events []publisher.Event) (int, error) {
-	batchSize := len(events)
+func (c *syncClient) publishWindowed(window []any) (int, error) {
+	batchSize := len(window)
 	windowSize := c.win.get()
 	c.log.Debugf("Try to publish %v events to logstash host %s with window size %v",
 		batchSize, c.Host(), windowSize)
 
 	// prepare message payload
 	if batchSize > windowSize {
-		events = events[:windowSize]
+		window = window[:windowSize]
 	}
 
-	n, err := c.sendEvents(events)
+	n, err := c.sendEvents(window)
 	if err != nil {
 		c.win.shrinkWindow()
 		return n, err
@@ -193,10 +199,7 @@ func (c *syncClient) publishWindowed(events []publisher.Event) (int, error) {
 	return n, nil
 }
 
-func (c *syncClient) sendEvents(events []publisher.Event) (int, error) {
-	window := make([]any, len(events))
-	for i := range events {
-		window[i] = &events[i].Content
-	}
+// sendEvents sends the encoded events of window.
+func (c *syncClient) sendEvents(window []any) (int, error) {
 	return c.client.Send(window)
 }
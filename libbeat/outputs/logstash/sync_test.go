@@ -25,10 +25,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/elastic/beats/v7/libbeat/common/transport/transptest"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/transport"
 )
 
@@ -59,6 +62,16 @@ func TestClientStructuredEvent(t *testing.T) {
 	testStructuredEvent(t, makeTestClient)
 }
 
+func TestClientDeadLetterEvent(t *testing.T) {
+	testDeadLetterEvent(t, func(conn *transport.Client, dl *outputs.DeadLetter) outputs.NetworkClient {
+		config := DefaultConfig()
+		config.Timeout = 1 * time.Second
+		client, err := newSyncClient(logptest.NewTestingLogger(t, ""), "beat_version", conn, outputs.NewNilObserver(), &config, dl)
+		require.NoError(t, err)
+		return client
+	})
+}
+
 func newClientServerTCP(t *testing.T, to time.Duration) *clientServer {
 	return &clientServer{transptest.NewMockServerTCP(t, to, "", nil)}
 }
@@ -71,7 +84,7 @@ func makeTestClient(conn *transport.Client) testClientDriver {
 	if err != nil {
 		panic(err)
 	}
-	client, err := newSyncClient(logger, "beat_version", conn, outputs.NewNilObserver(), &config)
+	client, err := newSyncClient(logger, "beat_version", conn, outputs.NewNilObserver(), &config, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -83,6 +83,8 @@ type Stats struct {
 
 	sendLatencyLifetimeMillis metrics.Sample // output latency in milliseconds for lifetime of connection
 	sendLatencyDeltaMillis    metrics.Sample // output latency in milliseconds, cleared each time "Visit" is used to report the metric
+
+	registry *monitoring.Registry
 }
 
 // NewStats creates a new Stats instance using a backing monitoring registry.
@@ -111,12 +113,20 @@ func NewStats(reg *monitoring.Registry, logger *logp.Logger) *Stats {
 
 		sendLatencyLifetimeMillis: metrics.NewUniformSample(1024),
 		sendLatencyDeltaMillis:    metrics.NewUniformSample(1024),
+
+		registry: reg,
 	}
 	_ = adapter.NewGoMetrics(reg, "write.latency", logger, adapter.Accept).Register("histogram", metrics.NewHistogram(obj.sendLatencyLifetimeMillis))
 	_ = adapter.NewGoMetrics(reg, "write.latency_delta", logger, adapter.Accept).Register("histogram", adapter.NewClearOnVisitHistogram(obj.sendLatencyDeltaMillis))
 	return obj
 }
 
+// Registry returns the monitoring registry the stats are reported in, for
+// outputs that report metrics of their own.
+func (s *Stats) Registry() *monitoring.Registry {
+	return s.registry
+}
+
 // NewBatch updates active batch and event metrics.
 func (s *Stats) NewBatch(n int) {
 	if s != nil {
@@ -0,0 +1,472 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/otel/otelmap"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+// documentIDAttribute is the attribute key the elasticsearchexporter uses
+// to set the final document ID in Elasticsearch.
+const documentIDAttribute = "elasticsearch.document_id"
+
+var errPayloadTooLarge = errors.New("the OTLP endpoint rejected the batch as too large, and it could not be split further; dropping")
+
+type clientSettings struct {
+	endpoint    string
+	protocol    string
+	tls         *tlscommon.TLSConfig
+	timeout     time.Duration
+	compression string
+	headers     map[string]string
+	beatInfo    beat.Info
+	observer    outputs.Observer
+}
+
+type client struct {
+	clientSettings
+	log    *logp.Logger
+	sender sender
+}
+
+// sender abstracts the OTLP transport used to export a request.
+type sender interface {
+	connect(ctx context.Context) error
+	export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error)
+	close() error
+}
+
+// exportError is returned by a sender when the endpoint answered, but did
+// not accept the request. It carries enough information to decide whether
+// the events can be retried.
+type exportError struct {
+	err        error
+	retryable  bool
+	tooLarge   bool
+	retryAfter time.Duration
+}
+
+func (e *exportError) Error() string { return e.err.Error() }
+func (e *exportError) Unwrap() error { return e.err }
+
+func newClient(s clientSettings) (*client, error) {
+	c := &client{
+		clientSettings: s,
+		log:            s.beatInfo.Logger.Named("otlp"),
+	}
+
+	switch s.protocol {
+	case protocolGRPC:
+		c.sender = &grpcSender{settings: &c.clientSettings}
+	case protocolHTTP:
+		if _, err := url.Parse(s.endpoint); err != nil {
+			return nil, fmt.Errorf("invalid otlp endpoint %q: %w", s.endpoint, err)
+		}
+		c.sender = &httpSender{settings: &c.clientSettings}
+	default:
+		return nil, fmt.Errorf("otlp protocol %q not supported", s.protocol)
+	}
+	return c, nil
+}
+
+func (c *client) Connect(ctx context.Context) error {
+	c.log.Debugf("connect to otlp endpoint %v", c.endpoint)
+	return c.sender.connect(ctx)
+}
+
+func (c *client) Close() error {
+	return c.sender.close()
+}
+
+func (c *client) String() string {
+	return "otlp(" + c.protocol + "://" + c.endpoint + ")"
+}
+
+// Publish converts the batch to OTLP logs and exports them. Events that
+// cannot be converted, or that the endpoint rejects permanently, are dropped
+// and reported as permanent errors. Events failing with a retryable error are
+// handed back to the pipeline, and the error is returned so the connection is
+// re-established with backoff.
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	logs, converted := c.eventsToLogs(events)
+	c.observer.PermanentErrors(len(events) - len(converted))
+	if len(converted) == 0 {
+		batch.ACK()
+		return nil
+	}
+
+	begin := time.Now()
+	resp, err := c.sender.export(ctx, plogotlp.NewExportRequestFromLogs(logs))
+	if err != nil {
+		return c.handleExportError(batch, converted, err)
+	}
+	c.observer.ReportLatency(time.Since(begin))
+
+	acked := len(converted)
+	if rejected := int(resp.PartialSuccess().RejectedLogRecords()); rejected > 0 {
+		// The OTLP specification states that rejected records must not be
+		// retried, so they are accounted for as permanently failed.
+		rejected = min(rejected, acked)
+		c.log.Warnf("otlp endpoint rejected %d of %d log records: %s",
+			rejected, acked, resp.PartialSuccess().ErrorMessage())
+		c.observer.PermanentErrors(rejected)
+		acked -= rejected
+	}
+	c.observer.AckedEvents(acked)
+	batch.ACK()
+	return nil
+}
+
+func (c *client) handleExportError(batch publisher.Batch, events []publisher.Event, err error) error {
+	var expErr *exportError
+	if errors.As(err, &expErr) {
+		if expErr.tooLarge {
+			if batch.SplitRetry() {
+				c.observer.BatchSplit()
+				c.observer.RetryableErrors(len(events))
+			} else {
+				batch.Drop()
+				c.observer.PermanentErrors(len(events))
+				c.log.Error(errPayloadTooLarge)
+			}
+			return nil
+		}
+		if !expErr.retryable {
+			c.log.Errorf("otlp endpoint permanently rejected %d events, dropping: %v", len(events), err)
+			batch.Drop()
+			c.observer.PermanentErrors(len(events))
+			return nil
+		}
+	}
+
+	c.log.Errorf("failed to export events to otlp endpoint: %v", err)
+	batch.RetryEvents(events)
+	c.observer.RetryableErrors(len(events))
+	if expErr != nil && expErr.retryAfter > 0 {
+		return outputs.RetryAfter(err, expErr.retryAfter)
+	}
+	return err
+}
+
+// eventsToLogs converts the events to a single plog.Logs. It returns the
+// subset of events that was successfully converted.
+func (c *client) eventsToLogs(events []publisher.Event) (plog.Logs, []publisher.Event) {
+	logs := plog.NewLogs()
+	resourceLogs := logs.ResourceLogs().AppendEmpty()
+	attrs := resourceLogs.Resource().Attributes()
+	attrs.PutStr("service.name", c.beatInfo.Beat)
+	attrs.PutStr("service.version", c.beatInfo.Version)
+	attrs.PutStr("service.instance.id", c.beatInfo.ID.String())
+	if c.beatInfo.Hostname != "" {
+		attrs.PutStr("host.name", c.beatInfo.Hostname)
+	}
+
+	scopeLogs := resourceLogs.ScopeLogs().AppendEmpty()
+	// Keep the event structure intact when the collector ships to Elasticsearch.
+	scopeLogs.Scope().Attributes().PutStr("elastic.mapping.mode", "bodymap")
+
+	logRecords := scopeLogs.LogRecords()
+	logRecords.EnsureCapacity(len(events))
+	observed := pcommon.NewTimestampFromTime(time.Now())
+
+	converted := events[:0]
+	for _, event := range events {
+		record := plog.NewLogRecord()
+		if err := c.fillLogRecord(record, &event.Content); err != nil {
+			c.log.Errorf("failed to convert event to otlp log record, dropping: %v", err)
+			continue
+		}
+		record.SetObservedTimestamp(observed)
+		record.MoveTo(logRecords.AppendEmpty())
+		converted = append(converted, event)
+	}
+	return logs, converted
+}
+
+func (c *client) fillLogRecord(record plog.LogRecord, event *beat.Event) error {
+	record.SetTimestamp(pcommon.NewTimestampFromTime(event.Timestamp))
+
+	if id, ok := event.Meta["_id"].(string); ok {
+		record.Attributes().PutStr(documentIDAttribute, id)
+	}
+
+	fields := event.Fields
+	if fields == nil {
+		fields = mapstr.M{}
+	}
+	if ds, ok := fields["data_stream"].(mapstr.M); ok {
+		for _, sub := range [...]string{"dataset", "namespace", "type"} {
+			if v, ok := ds[sub].(string); ok {
+				record.Attributes().PutStr("data_stream."+sub, v)
+			}
+		}
+	}
+
+	body := record.Body().SetEmptyMap()
+	if err := otelmap.FromMapstr(body, fields); err != nil {
+		return err
+	}
+	body.PutStr("@timestamp", otelmap.FormatTimestamp(event.Timestamp))
+
+	if c.beatInfo.IncludeMetadata {
+		meta := body.PutEmptyMap("@metadata")
+		if err := otelmap.FromMapstr(meta, event.Meta); err != nil {
+			return err
+		}
+		meta.PutStr("beat", c.beatInfo.Beat)
+		meta.PutStr("version", c.beatInfo.Version)
+	}
+	return nil
+}
+
+type grpcSender struct {
+	settings *clientSettings
+	conn     *grpc.ClientConn
+	client   plogotlp.GRPCClient
+}
+
+func (s *grpcSender) connect(_ context.Context) error {
+	creds := insecure.NewCredentials()
+	if s.settings.tls != nil {
+		host, _, err := net.SplitHostPort(s.settings.endpoint)
+		if err != nil {
+			host = s.settings.endpoint
+		}
+		creds = credentials.NewTLS(s.settings.tls.BuildModuleClientConfig(host))
+	}
+
+	conn, err := grpc.NewClient(s.settings.endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to create grpc connection to %v: %w", s.settings.endpoint, err)
+	}
+	s.conn = conn
+	s.client = plogotlp.NewGRPCClient(conn)
+	return nil
+}
+
+func (s *grpcSender) export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	if s.client == nil {
+		return plogotlp.ExportResponse{}, errors.New("grpc connection is not established")
+	}
+	if s.settings.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.settings.timeout)
+		defer cancel()
+	}
+	if len(s.settings.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(s.settings.headers))
+	}
+
+	var opts []grpc.CallOption
+	if s.settings.compression == compressionGzip {
+		opts = append(opts, grpc.UseCompressor(grpcgzip.Name))
+	}
+
+	resp, err := s.client.Export(ctx, req, opts...)
+	if err != nil {
+		return resp, classifyGRPCError(err)
+	}
+	return resp, nil
+}
+
+func (s *grpcSender) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn, s.client = nil, nil
+	return err
+}
+
+// classifyGRPCError maps a gRPC status to an exportError following the
+// retry semantics of the OTLP/gRPC specification. ResourceExhausted is only
+// retryable when the server attached a RetryInfo telling when to retry.
+func classifyGRPCError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	retryInfo := grpcRetryInfo(st)
+	switch st.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+		codes.Unavailable, codes.DataLoss:
+	case codes.ResourceExhausted:
+		if retryInfo == nil {
+			return &exportError{err: err}
+		}
+	default:
+		return &exportError{err: err}
+	}
+	expErr := &exportError{err: err, retryable: true}
+	if retryInfo != nil {
+		expErr.retryAfter = retryInfo.GetRetryDelay().AsDuration()
+	}
+	return expErr
+}
+
+func grpcRetryInfo(st *status.Status) *errdetails.RetryInfo {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+type httpSender struct {
+	settings *clientSettings
+	client   *http.Client
+}
+
+func (s *httpSender) connect(_ context.Context) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // DefaultTransport is always an *http.Transport
+	if s.settings.tls != nil {
+		u, err := url.Parse(s.settings.endpoint)
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = s.settings.tls.BuildModuleClientConfig(u.Hostname())
+	}
+	s.client = &http.Client{
+		Transport: transport,
+		Timeout:   s.settings.timeout,
+	}
+	return nil
+}
+
+func (s *httpSender) export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	resp := plogotlp.NewExportResponse()
+	if s.client == nil {
+		return resp, errors.New("http client is not initialized")
+	}
+
+	body, err := req.MarshalProto()
+	if err != nil {
+		return resp, &exportError{err: fmt.Errorf("failed to marshal otlp request: %w", err)}
+	}
+
+	if s.settings.compression == compressionGzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return resp, err
+		}
+		if err := gz.Close(); err != nil {
+			return resp, err
+		}
+		body = buf.Bytes()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.settings.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	if s.settings.compression == compressionGzip {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range s.settings.headers {
+		httpReq.Header.Set(k, v)
+	}
+	s.settings.observer.WriteBytes(len(body))
+
+	httpResp, err := s.client.Do(httpReq)
+	if err != nil {
+		s.settings.observer.WriteError(err)
+		return resp, err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		s.settings.observer.ReadError(err)
+		return resp, err
+	}
+	s.settings.observer.ReadBytes(len(respBody))
+
+	if httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+		if len(respBody) > 0 {
+			// The response is only informative, so a body we cannot decode
+			// does not invalidate the successful status code.
+			_ = resp.UnmarshalProto(respBody)
+		}
+		return resp, nil
+	}
+	return resp, classifyHTTPStatus(httpResp.StatusCode, respBody)
+}
+
+func (s *httpSender) close() error {
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// classifyHTTPStatus maps an unsuccessful HTTP status to an exportError
+// following the retry semantics of the OTLP/HTTP specification.
+func classifyHTTPStatus(code int, body []byte) error {
+	err := fmt.Errorf("otlp endpoint returned HTTP status %d: %s", code, truncate(body, 256))
+	switch code {
+	case http.StatusRequestEntityTooLarge:
+		return &exportError{err: err, tooLarge: true}
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return &exportError{err: err, retryable: true}
+	default:
+		return &exportError{err: err}
+	}
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
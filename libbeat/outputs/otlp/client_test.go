@@ -0,0 +1,267 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func testEvent(msg string) beat.Event {
+	return beat.Event{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Meta:      mapstr.M{"_id": "id-" + msg},
+		Fields: mapstr.M{
+			"message":     msg,
+			"data_stream": mapstr.M{"type": "logs", "dataset": "generic", "namespace": "default"},
+		},
+	}
+}
+
+func newTestClient(t *testing.T, protocol, endpoint string) *client {
+	t.Helper()
+	c, err := newClient(clientSettings{
+		endpoint:    endpoint,
+		protocol:    protocol,
+		timeout:     5 * time.Second,
+		compression: compressionGzip,
+		headers:     map[string]string{"x-test": "yes"},
+		beatInfo:    beat.Info{Beat: "testbeat", Version: "9.9.9", Logger: logptest.NewTestingLogger(t, "")},
+		observer:    outputs.NewNilObserver(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(context.Background()))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func signalTags(b *outest.Batch) []outest.BatchSignalTag {
+	tags := make([]outest.BatchSignalTag, len(b.Signals))
+	for i, s := range b.Signals {
+		tags[i] = s.Tag
+	}
+	return tags
+}
+
+func TestHTTPPublish(t *testing.T) {
+	cases := map[string]struct {
+		status      int
+		rejected    int64
+		wantErr     bool
+		wantSignals []outest.BatchSignalTag
+	}{
+		"success":             {status: http.StatusOK, wantSignals: []outest.BatchSignalTag{outest.BatchACK}},
+		"partial success":     {status: http.StatusOK, rejected: 1, wantSignals: []outest.BatchSignalTag{outest.BatchACK}},
+		"payload too large":   {status: http.StatusRequestEntityTooLarge, wantSignals: []outest.BatchSignalTag{outest.BatchSplitRetry}},
+		"permanent error":     {status: http.StatusBadRequest, wantSignals: []outest.BatchSignalTag{outest.BatchDrop}},
+		"retryable error":     {status: http.StatusServiceUnavailable, wantErr: true, wantSignals: []outest.BatchSignalTag{outest.BatchRetryEvents}},
+		"throttled by server": {status: http.StatusTooManyRequests, wantErr: true, wantSignals: []outest.BatchSignalTag{outest.BatchRetryEvents}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var received plogotlp.ExportRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/logs", r.URL.Path)
+				assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+				assert.Equal(t, "yes", r.Header.Get("x-test"))
+
+				gz, err := gzip.NewReader(r.Body)
+				require.NoError(t, err)
+				body, err := io.ReadAll(gz)
+				require.NoError(t, err)
+				received = plogotlp.NewExportRequest()
+				require.NoError(t, received.UnmarshalProto(body))
+
+				if tc.status != http.StatusOK {
+					w.WriteHeader(tc.status)
+					return
+				}
+				resp := plogotlp.NewExportResponse()
+				resp.PartialSuccess().SetRejectedLogRecords(tc.rejected)
+				out, err := resp.MarshalProto()
+				require.NoError(t, err)
+				w.Header().Set("Content-Type", "application/x-protobuf")
+				_, _ = w.Write(out)
+			}))
+			defer srv.Close()
+
+			endpoint, _, err := parseEndpoint(srv.URL, protocolHTTP, "/v1/logs", false)
+			require.NoError(t, err)
+			c := newTestClient(t, protocolHTTP, endpoint)
+
+			batch := outest.NewBatch(testEvent("a"), testEvent("b"))
+			err = c.Publish(context.Background(), batch)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantSignals, signalTags(batch))
+
+			require.Equal(t, 2, received.Logs().LogRecordCount())
+			rl := received.Logs().ResourceLogs().At(0)
+			svc, _ := rl.Resource().Attributes().Get("service.name")
+			assert.Equal(t, "testbeat", svc.Str())
+
+			record := rl.ScopeLogs().At(0).LogRecords().At(0)
+			msg, ok := record.Body().Map().Get("message")
+			require.True(t, ok)
+			assert.Equal(t, "a", msg.Str())
+			id, _ := record.Attributes().Get(documentIDAttribute)
+			assert.Equal(t, "id-a", id.Str())
+			ds, _ := record.Attributes().Get("data_stream.dataset")
+			assert.Equal(t, "generic", ds.Str())
+		})
+	}
+}
+
+type testGRPCServer struct {
+	plogotlp.UnimplementedGRPCServer
+	err      error
+	requests chan plogotlp.ExportRequest
+}
+
+func (s *testGRPCServer) Export(_ context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	s.requests <- req
+	return plogotlp.NewExportResponse(), s.err
+}
+
+func TestGRPCPublish(t *testing.T) {
+	cases := map[string]struct {
+		err         error
+		wantErr     bool
+		wantSignals []outest.BatchSignalTag
+	}{
+		"success":         {wantSignals: []outest.BatchSignalTag{outest.BatchACK}},
+		"permanent error": {err: status.Error(codes.InvalidArgument, "bad data"), wantSignals: []outest.BatchSignalTag{outest.BatchDrop}},
+		"retryable error": {err: status.Error(codes.Unavailable, "try later"), wantErr: true, wantSignals: []outest.BatchSignalTag{outest.BatchRetryEvents}},
+		"exhausted":       {err: status.Error(codes.ResourceExhausted, "quota exceeded"), wantSignals: []outest.BatchSignalTag{outest.BatchDrop}},
+		"throttled":       {err: throttledError(time.Millisecond), wantErr: true, wantSignals: []outest.BatchSignalTag{outest.BatchRetryEvents}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			impl := &testGRPCServer{err: tc.err, requests: make(chan plogotlp.ExportRequest, 1)}
+			srv := grpc.NewServer()
+			plogotlp.RegisterGRPCServer(srv, impl)
+			go func() { _ = srv.Serve(lis) }()
+			defer srv.Stop()
+
+			c := newTestClient(t, protocolGRPC, lis.Addr().String())
+			batch := outest.NewBatch(testEvent("a"))
+			err = c.Publish(context.Background(), batch)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantSignals, signalTags(batch))
+
+			req := <-impl.requests
+			assert.Equal(t, 1, req.Logs().LogRecordCount())
+		})
+	}
+}
+
+func throttledError(delay time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, "slow down").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		panic(err)
+	}
+	return st.Err()
+}
+
+func TestGRPCPublishRetryInfoDelay(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	impl := &testGRPCServer{err: throttledError(10 * time.Millisecond), requests: make(chan plogotlp.ExportRequest, 1)}
+	srv := grpc.NewServer()
+	plogotlp.RegisterGRPCServer(srv, impl)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	// The backoff would block the test for at least half an hour, unless
+	// the delay requested by the server is used instead.
+	c := outputs.WithBackoff(newTestClient(t, protocolGRPC, lis.Addr().String()), time.Hour, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	batch := outest.NewBatch(testEvent("a"))
+	begin := time.Now()
+	assert.Error(t, c.Publish(ctx, batch))
+	assert.NoError(t, ctx.Err())
+	assert.GreaterOrEqual(t, time.Since(begin), 10*time.Millisecond)
+	assert.Equal(t, []outest.BatchSignalTag{outest.BatchRetryEvents}, signalTags(batch))
+}
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		host, protocol string
+		tlsConfigured  bool
+		want           string
+		wantTLS        bool
+		wantErr        bool
+	}{
+		{host: "localhost:4317", protocol: protocolGRPC, want: "localhost:4317"},
+		{host: "localhost:4317", protocol: protocolGRPC, tlsConfigured: true, want: "localhost:4317", wantTLS: true},
+		{host: "https://collector:4317", protocol: protocolGRPC, want: "collector:4317", wantTLS: true},
+		{host: "localhost:4318", protocol: protocolHTTP, want: "http://localhost:4318/v1/logs"},
+		{host: "https://collector:4318/custom", protocol: protocolHTTP, want: "https://collector:4318/custom", wantTLS: true},
+		{host: "ftp://collector:4318", protocol: protocolHTTP, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.protocol+"/"+tc.host, func(t *testing.T) {
+			got, useTLS, err := parseEndpoint(tc.host, tc.protocol, "/v1/logs", tc.tlsConfigured)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantTLS, useTLS)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package otlp implements an output that ships events as OpenTelemetry logs
+// to an OTLP endpoint, using either the gRPC or the HTTP/protobuf transport.
+package otlp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+func init() {
+	outputs.RegisterType("otlp", makeOTLP)
+}
+
+func makeOTLP(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	oConfig := defaultConfig()
+	if err := cfg.Unpack(&oConfig); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(oConfig.TLS, beat.Logger)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, h := range hosts {
+		endpoint, useTLS, err := parseEndpoint(h, oConfig.Protocol, oConfig.Path, tls != nil)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		var clientTLS *tlscommon.TLSConfig
+		if useTLS {
+			clientTLS = tls
+			if clientTLS == nil {
+				clientTLS = &tlscommon.TLSConfig{} // enable with system defaults if TLS was requested by the scheme only
+			}
+		}
+
+		client, err := newClient(clientSettings{
+			endpoint:    endpoint,
+			protocol:    oConfig.Protocol,
+			tls:         clientTLS,
+			timeout:     oConfig.Timeout,
+			compression: oConfig.Compression,
+			headers:     oConfig.Headers,
+			beatInfo:    beat,
+			observer:    observer,
+		})
+		if err != nil {
+			return outputs.Fail(err)
+		}
+		clients[i] = outputs.WithBackoff(client, oConfig.Backoff.Init, oConfig.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(oConfig.Queue,
+		oConfig.LoadBalance,
+		oConfig.BulkMaxSize,
+		oConfig.MaxRetries,
+		nil,
+		beat.Logger,
+		beat.Paths,
+		outputs.NumofWorker(cfg), clients)
+}
+
+// parseEndpoint normalizes a configured host into the endpoint used by the
+// client. gRPC clients dial a plain host:port, while HTTP clients post to a
+// full URL with the configured path appended. The returned flag reports
+// whether TLS must be used to reach the endpoint.
+func parseEndpoint(host, protocol, path string, tlsConfigured bool) (string, bool, error) {
+	if !strings.Contains(host, "://") {
+		scheme := "http"
+		if tlsConfigured {
+			scheme = "https"
+		}
+		host = scheme + "://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid otlp host %q: %w", host, err)
+	}
+	if u.Host == "" {
+		return "", false, fmt.Errorf("invalid otlp host %q: missing host", host)
+	}
+
+	var useTLS bool
+	switch u.Scheme {
+	case "http":
+	case "https":
+		useTLS = true
+	default:
+		return "", false, fmt.Errorf("invalid otlp url scheme %q", u.Scheme)
+	}
+
+	if protocol == protocolGRPC {
+		return u.Host, useTLS, nil
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = path
+	}
+	return u.String(), useTLS, nil
+}
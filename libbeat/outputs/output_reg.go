@@ -18,7 +18,9 @@
 package outputs
 
 import (
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/publisher"
@@ -77,6 +79,27 @@ type Group struct {
 	//   and clear Content anyway. Metadata about the error should be saved in
 	//   EncodedEvent and reported when Publish is called.
 	EncoderFactory queue.EncoderFactory[publisher.Event]
+
+	// Shared holds what the clients share and must outlive their reconnects,
+	// like a dead-letter sink. Clients can be closed and reconnected several
+	// times, so they leave these open; whoever tears the group down closes
+	// them with CloseShared, after the clients.
+	Shared []io.Closer
+
+	// DeadLetter is the dead-letter sink of the output, if any. The pipeline
+	// also sends it the events it drops for being too old.
+	DeadLetter *DeadLetter
+}
+
+// CloseShared closes the resources shared by the clients of the group.
+func (g Group) CloseShared() error {
+	var errs []error
+	for _, c := range g.Shared {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // RegisterType registers a new output type.
@@ -0,0 +1,137 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"context"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// BatchResult records how an output signaled a batch published with
+// PublishSync.
+type BatchResult struct {
+	// Retry holds the events the output asked to publish again.
+	Retry []publisher.Event
+	// Split is set if the output asked to split the batch in halves.
+	Split bool
+	// Cancelled is set if the output returned the batch without trying to
+	// publish it. Retry then holds all of its events.
+	Cancelled bool
+	// Dropped is the number of events the output dropped.
+	Dropped int
+}
+
+// PublishSync publishes events through a client outside of the publisher
+// pipeline. It sends one batch at a time and waits for the output to
+// signal it before sending the next one, so the events are published in
+// order.
+//
+// Batches the output asks to split are split in halves. When the output
+// asks to retry events, onRetry is called with the number of consecutive
+// failed attempts, the result and the error returned by Publish. The
+// events are published again if it returns nil; otherwise PublishSync
+// gives up and returns its error. Cancelled batches don't count as
+// failed attempts.
+//
+// It returns the number of events the output acknowledged and dropped,
+// and ctx.Err() if ctx ends while waiting for the output.
+func PublishSync(
+	ctx context.Context,
+	client Client,
+	events []publisher.Event,
+	onRetry func(failures int, result BatchResult, err error) error,
+) (acked, dropped int, err error) {
+	pending := [][]publisher.Event{events}
+	failures := 0
+	for len(pending) > 0 {
+		batch := &syncBatch{events: pending[0], done: make(chan BatchResult, 1)}
+		pending = pending[1:]
+
+		publishErr := client.Publish(ctx, batch)
+		var result BatchResult
+		select {
+		case result = <-batch.done:
+		case <-ctx.Done():
+			return acked, dropped, ctx.Err()
+		}
+
+		switch {
+		case result.Split:
+			half := len(batch.events) / 2
+			pending = append([][]publisher.Event{batch.events[:half], batch.events[half:]}, pending...)
+		case len(result.Retry) > 0:
+			acked += len(batch.events) - len(result.Retry)
+			if !result.Cancelled {
+				failures++
+			}
+			if err := onRetry(failures, result, publishErr); err != nil {
+				return acked, dropped, err
+			}
+			pending = append([][]publisher.Event{result.Retry}, pending...)
+		default:
+			acked += len(batch.events) - result.Dropped
+			dropped += result.Dropped
+			failures = 0
+		}
+	}
+	return acked, dropped, nil
+}
+
+// syncBatch is a publisher.Batch that reports its outcome on a channel,
+// so PublishSync can wait for outputs that acknowledge asynchronously.
+type syncBatch struct {
+	events []publisher.Event
+	done   chan BatchResult
+}
+
+func (b *syncBatch) Events() []publisher.Event {
+	return b.events
+}
+
+func (b *syncBatch) ACK() {
+	b.done <- BatchResult{}
+}
+
+func (b *syncBatch) Drop() {
+	b.done <- BatchResult{Dropped: len(b.events)}
+}
+
+func (b *syncBatch) Retry() {
+	b.done <- BatchResult{Retry: b.events}
+}
+
+func (b *syncBatch) RetryEvents(events []publisher.Event) {
+	if len(events) == 0 {
+		b.ACK()
+		return
+	}
+	b.done <- BatchResult{Retry: events}
+}
+
+func (b *syncBatch) SplitRetry() bool {
+	if len(b.events) <= 1 {
+		return false
+	}
+	b.done <- BatchResult{Split: true}
+	return true
+}
+
+func (b *syncBatch) Cancelled() {
+	b.done <- BatchResult{Retry: b.events, Cancelled: true}
+}
@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+type funcClient func(batch publisher.Batch) error
+
+func (f funcClient) Publish(_ context.Context, batch publisher.Batch) error { return f(batch) }
+func (f funcClient) Close() error                                           { return nil }
+func (f funcClient) String() string                                         { return "func" }
+
+func TestPublishSync(t *testing.T) {
+	events := []publisher.Event{deadLetterTestEvent("a"), deadLetterTestEvent("b"), deadLetterTestEvent("c")}
+	calls := 0
+	client := funcClient(func(batch publisher.Batch) error {
+		calls++
+		switch calls {
+		case 1:
+			batch.SplitRetry()
+		case 2:
+			batch.Drop()
+		case 3:
+			batch.RetryEvents(batch.Events()[1:])
+			return errors.New("partial failure")
+		default:
+			batch.ACK()
+		}
+		return nil
+	})
+
+	var retries []int
+	acked, dropped, err := PublishSync(context.Background(), client, events,
+		func(failures int, result BatchResult, err error) error {
+			retries = append(retries, failures)
+			assert.Len(t, result.Retry, 1)
+			assert.EqualError(t, err, "partial failure")
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, 2, acked)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, []int{1}, retries)
+	assert.Equal(t, 4, calls)
+}
+
+func TestPublishSyncGivesUp(t *testing.T) {
+	client := funcClient(func(batch publisher.Batch) error {
+		batch.Retry()
+		return nil
+	})
+	errGiveUp := errors.New("give up")
+	_, _, err := PublishSync(context.Background(), client, []publisher.Event{deadLetterTestEvent("a")},
+		func(failures int, _ BatchResult, _ error) error {
+			if failures == 3 {
+				return errGiveUp
+			}
+			return nil
+		})
+	assert.ErrorIs(t, err, errGiveUp)
+}
+
+func TestPublishSyncCancelled(t *testing.T) {
+	// The output never signals the batch.
+	client := funcClient(func(publisher.Batch) error { return nil })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, err := PublishSync(ctx, client, []publisher.Event{deadLetterTestEvent("a")},
+		func(int, BatchResult, error) error { return nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -58,6 +58,7 @@ type client struct {
 	password string
 	publish  publishFn
 	codec    codec.Codec
+	dl       *outputs.DeadLetter
 	timeout  time.Duration
 }
 
@@ -75,6 +76,7 @@ func newClient(
 	pass string,
 	db int, key outil.Selector, dt redisDataType,
 	index string, codec codec.Codec,
+	dl *outputs.DeadLetter,
 	logger *logp.Logger,
 ) *client {
 	return &client{
@@ -88,6 +90,7 @@ func newClient(
 		dataType: dt,
 		key:      key,
 		codec:    codec,
+		dl:       dl,
 	}
 }
 
@@ -229,7 +232,7 @@ func (c *client) publishEventsBulk(conn redis.Conn, command string) publishFn {
 		args := make([]any, 1, len(data)+1)
 		args[0] = dest
 
-		okEvents, args := serializeEvents(c.log, args, 1, data, c.index, c.codec)
+		okEvents, args := serializeEvents(c.log, args, 1, data, c.index, c.codec, c.dl)
 		c.observer.PermanentErrors(len(data) - len(okEvents))
 		if (len(args) - 1) == 0 {
 			return nil, nil
@@ -255,7 +258,7 @@ func (c *client) publishEventsPipeline(conn redis.Conn, command string) publishF
 	return func(key outil.Selector, data []publisher.Event) ([]publisher.Event, error) {
 		var okEvents []publisher.Event
 		serialized := make([]any, 0, len(data))
-		okEvents, serialized = serializeEvents(c.log, serialized, 0, data, c.index, c.codec)
+		okEvents, serialized = serializeEvents(c.log, serialized, 0, data, c.index, c.codec, c.dl)
 		c.observer.PermanentErrors(len(data) - len(okEvents))
 		if len(serialized) == 0 {
 			return nil, nil
@@ -267,6 +270,7 @@ func (c *client) publishEventsPipeline(conn redis.Conn, command string) publishF
 			eventKey, err := key.Select(&okEvents[i].Content)
 			if err != nil {
 				c.log.Errorf("Failed to set redis key: %+v", err)
+				c.dl.Send(okEvents[i], err)
 				dropped++
 				continue
 			}
@@ -315,6 +319,7 @@ func serializeEvents(
 	data []publisher.Event,
 	index string,
 	codec codec.Codec,
+	dl *outputs.DeadLetter,
 ) ([]publisher.Event, []any) {
 
 	succeeded := data
@@ -323,6 +328,7 @@ func serializeEvents(
 		if err != nil {
 			log.Errorf("Encoding event failed with error: %+v. Check the event_data log (configured by logging.event_data.files.path) to view the event", err)
 			log.Errorw(fmt.Sprintf("Failed event: %v", d.Content), logp.TypeKey, logp.EventType)
+			dl.Send(d, err)
 			goto failLoop
 		}
 
@@ -341,6 +347,7 @@ failLoop:
 		if err != nil {
 			log.Errorf("Encoding event failed with error: %+v. Check the event_data log (configured by logging.event_data.files.path) to view the event", err)
 			log.Errorw(fmt.Sprintf("Failed event: %v", d.Content), logp.TypeKey, logp.EventType)
+			dl.Send(d, err)
 			i++
 			continue
 		}
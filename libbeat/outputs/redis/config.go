@@ -42,6 +42,7 @@ type redisConfig struct {
 	DataType    string                `config:"datatype"`
 	Backoff     backoff               `config:"backoff"`
 	Queue       config.Namespace      `config:"queue"`
+	DeadLetter  config.Namespace      `config:"dead_letter"`
 }
 
 type backoff struct {
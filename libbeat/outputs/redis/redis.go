@@ -48,7 +48,7 @@ func init() {
 }
 
 func makeRedis(
-	_ outputs.IndexManager,
+	im outputs.IndexManager,
 	beat beat.Info,
 	observer outputs.Observer,
 	cfg *config.C,
@@ -106,6 +106,19 @@ func makeRedis(
 		return outputs.Fail(err)
 	}
 
+	dl, err := outputs.NewDeadLetter(im, beat, observer, "redis", rConfig.DeadLetter)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	// Close the sink if a client can't be built, the output group owns it
+	// otherwise.
+	attached := false
+	defer func() {
+		if !attached {
+			_ = dl.Close()
+		}
+	}()
+
 	clients := make([]outputs.NetworkClient, len(hosts))
 	for i, h := range hosts {
 		hasScheme := true
@@ -162,18 +175,19 @@ func makeRedis(
 		}
 
 		client := newClient(conn, observer, rConfig.Timeout,
-			pass, rConfig.Db, key, dataType, rConfig.Index, enc, beat.Logger)
+			pass, rConfig.Db, key, dataType, rConfig.Index, enc, dl, beat.Logger)
 		clients[i] = newBackoffClient(client, rConfig.Backoff.Init, rConfig.Backoff.Max)
 	}
 
-	return outputs.SuccessNet(rConfig.Queue,
+	attached = true
+	return dl.Attach(outputs.SuccessNet(rConfig.Queue,
 		rConfig.LoadBalance,
 		rConfig.BulkMaxSize,
 		rConfig.MaxRetries,
 		nil,
 		beat.Logger,
 		beat.Paths,
-		outputs.NumofWorker(cfg), clients)
+		outputs.NumofWorker(cfg), clients))
 }
 
 func buildKeySelector(cfg *config.C, logger *logp.Logger) (outil.Selector, error) {
@@ -0,0 +1,306 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/cache"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	procName = "aggregate"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique logger and store ID.
+var instanceID atomic.Uint32
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+// aggregate collapses the events with the same key fields within a time
+// window into one summary event. The open windows are held in a memory
+// cache store, which closes them when they expire or to stay under
+// capacity. The summaries of closed windows are returned in place of the
+// events that are aggregated, and by Flush, which the pipeline client calls
+// periodically so windows are closed even if no new events arrive.
+type aggregate struct {
+	config config
+	log    *logp.Logger
+
+	mu    sync.Mutex
+	store *cache.MemStore
+	// ready holds the summaries of the closed windows, in the order
+	// they were closed. It holds at most config.Capacity summaries, the
+	// oldest are dropped to make room.
+	ready []*beat.Event
+	// dropped counts the summaries dropped since the last Flush.
+	dropped int
+}
+
+// window holds the aggregation of the events with the same key.
+type window struct {
+	// event is the first event of the window, which is the base of the
+	// summary event.
+	event   *beat.Event
+	count   int64
+	last    time.Time
+	numbers map[string]*number
+}
+
+// number holds the statistics of a numeric field.
+type number struct {
+	min, max, sum float64
+	// float is set if any of the values wasn't an integer.
+	float bool
+}
+
+// New constructs a new aggregate processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	id := int(instanceID.Add(1))
+	p := &aggregate{
+		config: c,
+		log:    log.Named(logName).With("instance_id", id),
+	}
+	p.store = cache.NewMemStore(fmt.Sprintf("%s-%d", procName, id), c.Window, c.Capacity, p.closeWindow)
+	return p, nil
+}
+
+// Run adds the event to the window of its key and drops it. It returns the
+// summary of a closed window if there is any. Events without all the key
+// fields are returned unchanged.
+func (p *aggregate) Run(event *beat.Event) (*beat.Event, error) {
+	key, ok := p.key(event)
+	if !ok {
+		return event, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return event, processors.ErrClosed
+	}
+
+	p.store.Expire()
+	if v, err := p.store.Get(key); err == nil {
+		v.(*window).add(event, p.config.NumericFields) //nolint:errcheck // The store only holds windows.
+	} else if err := p.store.Put(key, p.newWindow(event)); err != nil {
+		return event, fmt.Errorf("failed to open %v window: %w", procName, err)
+	}
+
+	if len(p.ready) == 0 {
+		return nil, nil
+	}
+	summary := p.ready[0]
+	p.ready[0] = nil
+	p.ready = p.ready[1:]
+
+	// The summary replaces the event in the pipeline, so it inherits the
+	// private data used to acknowledge the event.
+	summary.Private = event.Private
+	return summary, nil
+}
+
+// Flush closes the expired windows, or all open windows if final is set, and
+// returns the summaries that have not been returned yet.
+func (p *aggregate) Flush(final bool) []*beat.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return nil
+	}
+
+	if final {
+		p.store.Drain()
+	} else {
+		p.store.Expire()
+	}
+	if p.dropped != 0 {
+		p.log.Warnw("dropped summaries of closed windows that were not published in time", "dropped", p.dropped)
+		p.dropped = 0
+	}
+	ready := p.ready
+	p.ready = nil
+	for _, summary := range ready {
+		summary.Private = nil
+	}
+	return ready
+}
+
+// HoldsEvents reports that the processor holds back events, the summaries of
+// its windows.
+func (p *aggregate) HoldsEvents() bool {
+	return true
+}
+
+// Close releases the open windows. Their summaries are lost unless Flush is
+// called before.
+func (p *aggregate) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == nil {
+		return nil
+	}
+	if n := p.store.Len() + len(p.ready); n != 0 {
+		p.log.Debugw("closing with windows not flushed", "windows", n)
+	}
+	p.store = nil
+	p.ready = nil
+	return nil
+}
+
+// Unshareable opts aggregate out of process-wide processor sharing, so
+// the events of different owners are not aggregated together.
+func (p *aggregate) Unshareable() {}
+
+func (p *aggregate) String() string {
+	return fmt.Sprintf(
+		"%v=[key_fields=[%v],numeric_fields=[%v],window=[%v],target_field=[%v],capacity=[%v]]",
+		procName, p.config.KeyFields, p.config.NumericFields, p.config.Window, p.config.TargetField, p.config.Capacity,
+	)
+}
+
+// key returns the key of the window of the event. ok is false if the event
+// doesn't have all the key fields.
+func (p *aggregate) key(event *beat.Event) (key string, ok bool) {
+	var b strings.Builder
+	for _, field := range p.config.KeyFields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(&b, "%v\x00", v)
+	}
+	return b.String(), true
+}
+
+func (p *aggregate) newWindow(event *beat.Event) *window {
+	w := &window{
+		event:   event.Clone(),
+		numbers: map[string]*number{},
+	}
+	w.event.Private = nil
+	w.add(event, p.config.NumericFields)
+	return w
+}
+
+// closeWindow is called by the store when a window is closed.
+func (p *aggregate) closeWindow(_ string, v any) {
+	w := v.(*window) //nolint:errcheck // The store only holds windows.
+	if len(p.ready) >= p.config.Capacity {
+		p.ready[0] = nil
+		p.ready = p.ready[1:]
+		p.dropped++
+	}
+	p.ready = append(p.ready, w.summary(p.config.TargetField))
+}
+
+func (w *window) add(event *beat.Event, fields []string) {
+	w.count++
+	w.last = event.Timestamp
+	for _, field := range fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+		f, integer, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+
+		n := w.numbers[field]
+		if n == nil {
+			w.numbers[field] = &number{min: f, max: f, sum: f, float: !integer}
+			continue
+		}
+		n.min = min(n.min, f)
+		n.max = max(n.max, f)
+		n.sum += f
+		n.float = n.float || !integer
+	}
+}
+
+// summary returns the summary event of the window.
+func (w *window) summary(target string) *beat.Event {
+	event := w.event
+	_, _ = event.PutValue(target+".count", w.count)
+	_, _ = event.PutValue(target+".first", common.Time(event.Timestamp))
+	_, _ = event.PutValue(target+".last", common.Time(w.last))
+	for field, n := range w.numbers {
+		prefix := target + "." + field + "."
+		if n.float {
+			_, _ = event.PutValue(prefix+"min", n.min)
+			_, _ = event.PutValue(prefix+"max", n.max)
+			_, _ = event.PutValue(prefix+"sum", n.sum)
+		} else {
+			_, _ = event.PutValue(prefix+"min", int64(n.min))
+			_, _ = event.PutValue(prefix+"max", int64(n.max))
+			_, _ = event.PutValue(prefix+"sum", int64(n.sum))
+		}
+	}
+	return event
+}
+
+// toFloat returns the value of a numeric field as a float64, and whether it
+// is an integer.
+func toFloat(v any) (f float64, integer bool, ok bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true, true
+	case int8:
+		return float64(v), true, true
+	case int16:
+		return float64(v), true, true
+	case int32:
+		return float64(v), true, true
+	case int64:
+		return float64(v), true, true
+	case uint:
+		return float64(v), true, true
+	case uint8:
+		return float64(v), true, true
+	case uint16:
+		return float64(v), true, true
+	case uint32:
+		return float64(v), true, true
+	case uint64:
+		return float64(v), true, true
+	case float32:
+		return float64(v), false, true
+	case float64:
+		return v, false, true
+	default:
+		return 0, false, false
+	}
+}
@@ -0,0 +1,213 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var ts = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newProcessor(t *testing.T, config map[string]any) *aggregate {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, processors.Close(p)) })
+	return p.(*aggregate) //nolint:errcheck // New always returns an aggregate.
+}
+
+func flow(src, dst string, bytes any, offset time.Duration) *beat.Event {
+	return &beat.Event{
+		Timestamp: ts.Add(offset),
+		Fields: mapstr.M{
+			"source":      mapstr.M{"ip": src, "bytes": bytes},
+			"destination": mapstr.M{"ip": dst},
+		},
+	}
+}
+
+func TestAggregateFlush(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields":     []string{"source.ip", "destination.ip"},
+		"numeric_fields": []string{"source.bytes", "missing"},
+	})
+
+	events := []*beat.Event{
+		flow("10.0.0.1", "10.0.0.2", 100, 0),
+		flow("10.0.0.1", "10.0.0.2", 50, time.Second),
+		flow("10.0.0.3", "10.0.0.2", 1.5, 2*time.Second),
+		flow("10.0.0.1", "10.0.0.2", 300, 3*time.Second),
+	}
+	for _, e := range events {
+		out, err := p.Run(e)
+		require.NoError(t, err)
+		assert.Nil(t, out, "aggregated events must be dropped")
+	}
+
+	unkeyed := &beat.Event{Fields: mapstr.M{"message": "hello"}}
+	out, err := p.Run(unkeyed)
+	require.NoError(t, err)
+	assert.Equal(t, unkeyed, out)
+
+	summaries := p.Flush(true)
+	require.Len(t, summaries, 2)
+
+	assert.Equal(t, ts, summaries[0].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"source":      mapstr.M{"ip": "10.0.0.1", "bytes": 100},
+		"destination": mapstr.M{"ip": "10.0.0.2"},
+		"aggregate": mapstr.M{
+			"count": int64(3),
+			"first": common.Time(ts),
+			"last":  common.Time(ts.Add(3 * time.Second)),
+			"source": mapstr.M{"bytes": mapstr.M{
+				"min": int64(50),
+				"max": int64(300),
+				"sum": int64(450),
+			}},
+		},
+	}, summaries[0].Fields)
+
+	assert.Equal(t, mapstr.M{
+		"count": int64(1),
+		"first": common.Time(ts.Add(2 * time.Second)),
+		"last":  common.Time(ts.Add(2 * time.Second)),
+		"source": mapstr.M{"bytes": mapstr.M{
+			"min": 1.5,
+			"max": 1.5,
+			"sum": 1.5,
+		}},
+	}, summaries[1].Fields["aggregate"])
+
+	assert.Empty(t, p.Flush(true))
+}
+
+func TestAggregateWindowExpiry(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields":   []string{"source.ip"},
+		"window":       "10ms",
+		"target_field": "rollup",
+	})
+
+	out, err := p.Run(flow("10.0.0.1", "10.0.0.2", 1, 0))
+	require.NoError(t, err)
+	assert.Nil(t, out)
+
+	time.Sleep(50 * time.Millisecond)
+
+	// The next event closes the expired window and its summary takes
+	// the place of the event.
+	next := flow("10.0.0.3", "10.0.0.2", 1, time.Second)
+	next.Private = "ack"
+	out, err = p.Run(next)
+	require.NoError(t, err)
+	require.NotNil(t, out)
+	assert.Equal(t, "10.0.0.1", out.Fields["source"].(mapstr.M)["ip"])
+	assert.Equal(t, int64(1), out.Fields["rollup"].(mapstr.M)["count"])
+	assert.Equal(t, "ack", out.Private)
+
+	summaries := p.Flush(true)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "10.0.0.3", summaries[0].Fields["source"].(mapstr.M)["ip"])
+	assert.Nil(t, summaries[0].Private)
+}
+
+func TestAggregateFlushExpired(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields": []string{"source.ip"},
+		"window":     "10ms",
+	})
+
+	_, err := p.Run(flow("10.0.0.1", "10.0.0.2", 1, 0))
+	require.NoError(t, err)
+	assert.Empty(t, p.Flush(false), "open windows must not be flushed")
+
+	// Expired windows are flushed without waiting for the next event.
+	time.Sleep(50 * time.Millisecond)
+	summaries := p.Flush(false)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "10.0.0.1", summaries[0].Fields["source"].(mapstr.M)["ip"])
+	assert.Empty(t, p.Flush(true))
+}
+
+func TestAggregateCapsReadySummaries(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields": []string{"source.ip"},
+		"capacity":   2,
+	})
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		p.closeWindow(ip, p.newWindow(flow(ip, "10.0.0.9", 1, 0)))
+	}
+
+	// The oldest summary is dropped to hold at most capacity summaries.
+	summaries := p.Flush(false)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "10.0.0.2", summaries[0].Fields["source"].(mapstr.M)["ip"])
+	assert.Equal(t, "10.0.0.3", summaries[1].Fields["source"].(mapstr.M)["ip"])
+}
+
+func TestAggregateCapacity(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields": []string{"source.ip"},
+		"capacity":   2,
+	})
+
+	var summaries []*beat.Event
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		out, err := p.Run(flow(ip, "10.0.0.9", 1, 0))
+		require.NoError(t, err)
+		if out != nil {
+			summaries = append(summaries, out)
+		}
+	}
+
+	// The oldest windows are closed to stay under capacity.
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "10.0.0.1", summaries[0].Fields["source"].(mapstr.M)["ip"])
+	assert.Equal(t, "10.0.0.2", summaries[1].Fields["source"].(mapstr.M)["ip"])
+	assert.Len(t, p.Flush(true), 2)
+}
+
+func TestAggregateConfig(t *testing.T) {
+	testCases := map[string]map[string]any{
+		"missing key fields": {"window": "1m"},
+		"empty key field":    {"key_fields": []string{""}},
+		"zero window":        {"key_fields": []string{"a"}, "window": "0s"},
+		"zero capacity":      {"key_fields": []string{"a"}, "capacity": 0},
+		"empty target field": {"key_fields": []string{"a"}, "target_field": ""},
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
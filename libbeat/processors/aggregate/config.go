@@ -0,0 +1,63 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"errors"
+	"time"
+)
+
+type config struct {
+	// KeyFields are the fields whose values identify the events that are
+	// aggregated together.
+	KeyFields []string `config:"key_fields" validate:"required"`
+
+	// NumericFields are the fields whose min, max and sum are computed.
+	NumericFields []string `config:"numeric_fields"`
+
+	// Window is how long events are aggregated after the first event.
+	Window time.Duration `config:"window" validate:"nonzero,positive"`
+
+	// TargetField is where the aggregation results are added to the
+	// summary event.
+	TargetField string `config:"target_field"`
+
+	// Capacity is the maximum number of open windows. The oldest windows
+	// are closed early to stay under capacity.
+	Capacity int `config:"capacity" validate:"min=1"`
+}
+
+func defaultConfig() config {
+	return config{
+		Window:      time.Minute,
+		TargetField: "aggregate",
+		Capacity:    10000,
+	}
+}
+
+func (c *config) Validate() error {
+	for _, f := range c.KeyFields {
+		if f == "" {
+			return errors.New("key_fields must not contain empty field names")
+		}
+	}
+	if c.TargetField == "" {
+		return errors.New("target_field is required")
+	}
+	return nil
+}
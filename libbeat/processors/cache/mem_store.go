@@ -80,6 +80,10 @@ type memStore struct {
 	// effort is the number of entries to examine during
 	// expired element eviction. If not positive, full effort.
 	effort int
+
+	// evict, if not nil, is called with the entries removed
+	// from the cache by expiry or to keep it under capacity.
+	evict func(*CacheEntry)
 }
 
 // newMemStore returns a new memStore configured to apply the give TTL duration.
@@ -158,7 +162,9 @@ func (c *memStore) Get(key string) (any, error) {
 		return nil, ErrNoData
 	}
 	if time.Now().After(v.Expires) {
+		heap.Remove(&c.expiries, v.index)
 		delete(c.cache, key)
+		c.evicted(v)
 		return nil, ErrNoData
 	}
 	return v.Value, nil
@@ -196,13 +202,7 @@ func (c *memStore) Put(key string, val any) error {
 // cache is at or above capacity, the oldest elements are removed to bring
 // it under the capacity limit.
 func (c *memStore) evictExpired(now time.Time) {
-	for n := 0; (c.effort <= 0 || n < c.effort) && len(c.cache) != 0; n++ {
-		if c.expiries[0].Expires.After(now) {
-			break
-		}
-		e := c.expiries.pop()
-		delete(c.cache, e.Key)
-	}
+	c.expire(now)
 	if c.cap <= 0 {
 		// No cap, so depend on effort.
 		return
@@ -210,6 +210,27 @@ func (c *memStore) evictExpired(now time.Time) {
 	for len(c.cache) >= c.cap {
 		e := c.expiries.pop()
 		delete(c.cache, e.Key)
+		c.evicted(e)
+	}
+}
+
+// expire removes up to effort expired elements from the cache.
+func (c *memStore) expire(now time.Time) {
+	for n := 0; (c.effort <= 0 || n < c.effort) && len(c.cache) != 0; n++ {
+		if c.expiries[0].Expires.After(now) {
+			break
+		}
+		e := c.expiries.pop()
+		delete(c.cache, e.Key)
+		c.evicted(e)
+	}
+}
+
+// evicted calls the evict callback, if any, for an entry that was removed
+// from the cache by expiry or to keep it under capacity.
+func (c *memStore) evicted(e *CacheEntry) {
+	if c.evict != nil {
+		c.evict(e)
 	}
 }
 
@@ -228,6 +249,66 @@ func (c *memStore) Delete(key string) error {
 	return nil
 }
 
+// MemStore is a memory-backed Store that is private to its owner. It allows
+// other stateful processors to keep their state bounded the same way as the
+// cache processor.
+type MemStore struct {
+	store *memStore
+}
+
+var _ Store = (*MemStore)(nil)
+
+// NewMemStore returns a MemStore whose entries expire ttl after they were
+// first put or last overwritten. If capacity is positive, the oldest entries
+// are removed to hold at most capacity entries. If evict is not nil, it is
+// called with the key and value of every entry removed by expiry, by Drain or
+// to keep the store under capacity, but not of deleted entries. evict is
+// called with the store locked, so it must not use the store.
+func NewMemStore(id string, ttl time.Duration, capacity int, evict func(key string, val any)) *MemStore {
+	s := &memStore{
+		id:     id,
+		cache:  make(map[string]*CacheEntry),
+		ttl:    ttl,
+		cap:    capacity,
+		effort: -1,
+	}
+	if evict != nil {
+		s.evict = func(e *CacheEntry) { evict(e.Key, e.Value) }
+	}
+	return &MemStore{store: s}
+}
+
+func (s *MemStore) String() string                { return s.store.String() }
+func (s *MemStore) Get(key string) (any, error)   { return s.store.Get(key) }
+func (s *MemStore) Put(key string, val any) error { return s.store.Put(key, val) }
+func (s *MemStore) Delete(key string) error       { return s.store.Delete(key) }
+
+// Len returns the number of entries in the store, including expired entries
+// that have not been removed yet.
+func (s *MemStore) Len() int {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	return len(s.store.cache)
+}
+
+// Expire removes all expired entries from the store.
+func (s *MemStore) Expire() {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	s.store.expire(time.Now())
+}
+
+// Drain removes all entries from the store, oldest first.
+func (s *MemStore) Drain() {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	for len(s.store.expiries) != 0 {
+		e := s.store.expiries.pop()
+		delete(s.store.cache, e.Key)
+		s.store.evicted(e)
+	}
+}
+
 var _ heap.Interface = (*expiryHeap)(nil)
 
 // expiryHeap is a min-date heap.
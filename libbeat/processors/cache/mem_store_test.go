@@ -465,6 +465,47 @@ func TestMemStore(t *testing.T) {
 	}
 }
 
+func TestMemStoreEvict(t *testing.T) {
+	var evicted []string
+	store := NewMemStore("test", time.Hour, 2, func(key string, _ any) {
+		evicted = append(evicted, key)
+	})
+
+	for _, key := range []string{"one", "two", "three"} {
+		if err := store.Put(key, key); err != nil {
+			t.Fatalf("unexpected error putting %q: %v", key, err)
+		}
+	}
+	if err := store.Delete("three"); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+	if want := []string{"one"}; !cmp.Equal(want, evicted) {
+		t.Errorf("unexpected evictions at capacity: got %v, want %v", evicted, want)
+	}
+
+	store.Expire()
+	if store.Len() != 1 {
+		t.Errorf("unexpected expiry of valid entries: %d entries left", store.Len())
+	}
+
+	store.Drain()
+	if want := []string{"one", "two"}; !cmp.Equal(want, evicted) {
+		t.Errorf("unexpected evictions after drain: got %v, want %v", evicted, want)
+	}
+	if store.Len() != 0 {
+		t.Errorf("unexpected entries left after drain: %d", store.Len())
+	}
+
+	expiring := NewMemStore("test", -time.Second, 0, func(key string, _ any) {
+		evicted = append(evicted, key)
+	})
+	_ = expiring.Put("four", 4)
+	expiring.Expire()
+	if want := []string{"one", "two", "four"}; !cmp.Equal(want, evicted) {
+		t.Errorf("unexpected evictions after expiry: got %v, want %v", evicted, want)
+	}
+}
+
 // add adds the store to the set. It is used only for testing.
 func (s *memStoreSet) add(store *memStore) {
 	s.mu.Lock()
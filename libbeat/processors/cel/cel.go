@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cel
+
+import (
+	"errors"
+	"fmt"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/checks"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "cel"
+
+type processor struct {
+	config  celConfig
+	program celgo.Program
+	log     *logp.Logger
+}
+
+type celConfig struct {
+	Expression    string `config:"expression" validate:"required"`
+	TargetField   string `config:"target_field"`
+	IgnoreMissing bool   `config:"ignore_missing"`
+	FailOnError   bool   `config:"fail_on_error"`
+}
+
+func init() {
+	processors.RegisterPlugin(procName,
+		checks.ConfigChecked(New,
+			checks.RequireFields("expression"),
+			checks.AllowedFields("expression", "target_field", "ignore_missing", "fail_on_error")))
+}
+
+// New creates a processor that evaluates a CEL expression over the event and
+// writes the result back to the event.
+func New(c *config.C, log *logp.Logger) (beat.Processor, error) {
+	config := celConfig{
+		FailOnError: true,
+	}
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("failed to unpack the %v configuration: %w", procName, err)
+	}
+
+	// Without a target field the expression returns the fields to update.
+	var outputType *celgo.Type
+	if config.TargetField == "" {
+		outputType = celgo.MapType(celgo.StringType, celgo.DynType)
+	}
+	program, err := conditions.CompileCEL(config.Expression, outputType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %v processor: %w", procName, err)
+	}
+
+	return &processor{
+		config:  config,
+		program: program,
+		log:     log.Named(procName),
+	}, nil
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	err := p.run(event)
+	if err == nil {
+		return event, nil
+	}
+
+	if p.config.IgnoreMissing && isMissingKey(err) {
+		return event, nil
+	}
+
+	err = fmt.Errorf("failed in %v processor: %w", procName, err)
+	p.log.Debugw(err.Error(), logp.TypeKey, logp.EventType)
+	if p.config.FailOnError {
+		_, _ = event.PutValue("error.message", err.Error())
+		return event, err
+	}
+	return event, nil
+}
+
+func (p *processor) run(event *beat.Event) error {
+	val, err := conditions.EvalCEL(p.program, event)
+	if err != nil {
+		return err
+	}
+	result, err := toNative(val)
+	if err != nil {
+		return err
+	}
+
+	if p.config.TargetField != "" {
+		return put(event, p.config.TargetField, result)
+	}
+
+	fields, ok := result.(mapstr.M)
+	if !ok {
+		return fmt.Errorf("expression returned %T instead of a map", result)
+	}
+
+	// Apply the changes to a copy, so that the event is unchanged when one
+	// of them fails.
+	backup := event
+	if p.config.FailOnError && len(fields) > 1 {
+		backup = event.Clone()
+	}
+	for key, value := range fields {
+		if err := put(backup, key, value); err != nil {
+			return err
+		}
+	}
+	*event = *backup
+	return nil
+}
+
+// put sets the field, or deletes it if value is nil.
+func put(event *beat.Event, key string, value any) error {
+	if value == nil {
+		err := event.Delete(key)
+		if errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}
+	_, err := event.PutValue(key, value)
+	return err
+}
+
+// toNative converts the result of an expression into the types used in
+// events.
+func toNative(val ref.Val) (any, error) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, nil
+	case types.Timestamp:
+		return v.Time, nil
+	case types.Duration:
+		return v.Duration, nil
+	case traits.Mapper:
+		m := mapstr.M{}
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			key, ok := k.(types.String)
+			if !ok {
+				return nil, fmt.Errorf("map key %v is not a string", k)
+			}
+			native, err := toNative(v.Get(k))
+			if err != nil {
+				return nil, err
+			}
+			m[string(key)] = native
+		}
+		return m, nil
+	case traits.Lister:
+		list := make([]any, 0, int64(v.Size().(types.Int)))
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			native, err := toNative(it.Next())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, native)
+		}
+		return list, nil
+	case *types.Err:
+		return nil, v
+	}
+	return val.Value(), nil
+}
+
+func isMissingKey(err error) bool {
+	return errors.Is(err, conditions.ErrCELNoSuchKey)
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%v=[expression=%v, target_field=%v]", procName, p.config.Expression, p.config.TargetField)
+}
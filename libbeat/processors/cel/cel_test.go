@@ -0,0 +1,173 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestCEL(t *testing.T) {
+	testCases := []struct {
+		description string
+		config      mapstr.M
+		input       mapstr.M
+		output      mapstr.M
+		error       bool
+	}{
+		{
+			description: "set fields",
+			config: mapstr.M{
+				"expression": `{
+					"http.response.status_class": string(int(event.http.response.status_code / 100)) + "xx",
+					"tags": event.tags + ["cel"],
+					"user.password": null,
+				}`,
+			},
+			input: mapstr.M{
+				"http": mapstr.M{"response": mapstr.M{"status_code": 503}},
+				"tags": []string{"web"},
+				"user": mapstr.M{"password": "secret"},
+			},
+			output: mapstr.M{
+				"http": mapstr.M{"response": mapstr.M{"status_code": 503, "status_class": "5xx"}},
+				"tags": []any{"web", "cel"},
+				"user": mapstr.M{},
+			},
+		},
+		{
+			description: "drop fields with null",
+			config: mapstr.M{
+				"expression": `{"user.password": null, "missing": null}`,
+			},
+			input: mapstr.M{
+				"user": mapstr.M{"name": "alice", "password": "secret"},
+			},
+			output: mapstr.M{
+				"user": mapstr.M{"name": "alice"},
+			},
+		},
+		{
+			description: "transform into target field",
+			config: mapstr.M{
+				"expression":   `event.message.lowerAscii().split(" ")`,
+				"target_field": "words",
+			},
+			input: mapstr.M{"message": "Hello World"},
+			output: mapstr.M{
+				"message": "Hello World",
+				"words":   []any{"hello", "world"},
+			},
+		},
+		{
+			description: "missing field fails",
+			config: mapstr.M{
+				"expression":   `event.missing + 1`,
+				"target_field": "x",
+			},
+			input: mapstr.M{"a": 1},
+			output: mapstr.M{
+				"a":     1,
+				"error": mapstr.M{"message": "failed in cel processor: no such key: missing"},
+			},
+			error: true,
+		},
+		{
+			description: "missing field ignored",
+			config: mapstr.M{
+				"expression":     `{"x": event.missing + 1}`,
+				"ignore_missing": true,
+			},
+			input:  mapstr.M{"a": 1},
+			output: mapstr.M{"a": 1},
+		},
+		{
+			description: "missing nested field ignored",
+			config: mapstr.M{
+				"expression":     `{"x": event.a.missing + 1}`,
+				"ignore_missing": true,
+			},
+			input:  mapstr.M{"a": mapstr.M{"b": 1}},
+			output: mapstr.M{"a": mapstr.M{"b": 1}},
+		},
+		{
+			description: "nested objects",
+			config: mapstr.M{
+				"expression": `{
+					"has_b": has(event.a.b),
+					"has_c": has(event.a.c),
+					"size": size(event.a),
+					"in": "b" in event.a,
+					"equal": event.a == {"b": 1},
+					"keys": event.a.map(k, k),
+					"copy": event.a,
+				}`,
+			},
+			input: mapstr.M{"a": mapstr.M{"b": 1}},
+			output: mapstr.M{
+				"a":     mapstr.M{"b": 1},
+				"has_b": true,
+				"has_c": false,
+				"size":  int64(1),
+				"in":    true,
+				"equal": true,
+				"keys":  []any{"b"},
+				"copy":  mapstr.M{"b": 1},
+			},
+		},
+		{
+			description: "errors not reported",
+			config: mapstr.M{
+				"expression":    `{"x": event.a / 0}`,
+				"fail_on_error": false,
+			},
+			input:  mapstr.M{"a": 1},
+			output: mapstr.M{"a": 1},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.description, func(t *testing.T) {
+			p, err := New(config.MustNewConfigFrom(test.config), logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: test.input})
+			if test.error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.output, event.Fields)
+		})
+	}
+}
+
+func TestCELInvalidConfig(t *testing.T) {
+	_, err := New(config.MustNewConfigFrom(mapstr.M{"expression": `"not a map"`}), logptest.NewTestingLogger(t, ""))
+	assert.ErrorContains(t, err, "must return map(string, dyn)")
+
+	_, err = New(config.MustNewConfigFrom(mapstr.M{"expression": `event.`}), logptest.NewTestingLogger(t, ""))
+	assert.ErrorContains(t, err, "failed to compile CEL expression")
+}
@@ -107,6 +107,17 @@ func (r *WhenProcessor) SetPaths(paths *paths.Path) error {
 	return nil
 }
 
+// Flush returns the events held back by the processor. They are returned
+// whatever the condition, as the processor held them back after checking it.
+func (r *WhenProcessor) Flush(final bool) []*beat.Event {
+	return Flush(r.p, final)
+}
+
+// HoldsEvents reports whether the processor holds back events.
+func (r *WhenProcessor) HoldsEvents() bool {
+	return HoldsEvents(r.p)
+}
+
 func (r *WhenProcessor) String() string {
 	return fmt.Sprintf("%v, condition=%v", r.p.String(), r.condition.String())
 }
@@ -288,6 +299,20 @@ func (p *IfThenElseProcessor) SetPaths(paths *paths.Path) error {
 	return err
 }
 
+// Flush returns the events held back by the then and else processors.
+func (p *IfThenElseProcessor) Flush(final bool) []*beat.Event {
+	flushed := p.then.Flush(final)
+	if p.els != nil {
+		flushed = append(flushed, p.els.Flush(final)...)
+	}
+	return flushed
+}
+
+// HoldsEvents reports whether the then or else processors hold back events.
+func (p *IfThenElseProcessor) HoldsEvents() bool {
+	return p.then.HoldsEvents() || (p.els != nil && p.els.HoldsEvents())
+}
+
 func (p *IfThenElseProcessor) String() string {
 	var sb strings.Builder
 	sb.WriteString("if ")
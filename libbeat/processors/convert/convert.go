@@ -162,6 +162,24 @@ func (p *processor) writeToEvent(event *beat.Event, converted []any) error {
 	return nil
 }
 
+// Converter converts a value to a data type.
+type Converter func(value any) (any, error)
+
+// NewConverter returns the Converter for the data type with the given name.
+// The names are the ones accepted by the type setting of the processor.
+func NewConverter(typeName string) (Converter, error) {
+	var typ dataType
+	if err := typ.Unpack(typeName); err != nil {
+		return nil, err
+	}
+	if typ == unset {
+		return nil, fmt.Errorf("invalid data type: %v", typeName)
+	}
+	return func(value any) (any, error) {
+		return transformType(typ, value)
+	}, nil
+}
+
 func transformType(typ dataType, value any) (any, error) {
 	switch typ {
 	case String:
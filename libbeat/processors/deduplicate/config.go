@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type config struct {
+	// KeyFields are the fields whose values identify duplicate events.
+	KeyFields []string `config:"key_fields" validate:"required"`
+
+	// TTL is how long a key is remembered after it was first seen.
+	TTL time.Duration `config:"ttl" validate:"nonzero,positive"`
+
+	// Capacity is the maximum number of keys remembered. The oldest keys
+	// are forgotten to stay under capacity.
+	Capacity int `config:"capacity" validate:"min=1"`
+
+	// IgnoreMissing passes events without all the key fields unchanged.
+	IgnoreMissing bool `config:"ignore_missing"`
+
+	Store storeConfig `config:"store"`
+}
+
+type storeConfig struct {
+	// Type is either memory or file.
+	Type string `config:"type"`
+
+	// ID is the name of the file store. It is required for file stores.
+	ID string `config:"id"`
+
+	// AtMostOnce must be set for file stores, to acknowledge that keys are
+	// persisted before their events are acknowledged by the output.
+	AtMostOnce bool `config:"at_most_once"`
+}
+
+func defaultConfig() config {
+	return config{
+		TTL:           24 * time.Hour,
+		Capacity:      100000,
+		IgnoreMissing: true,
+		Store:         storeConfig{Type: "memory"},
+	}
+}
+
+func (c *config) Validate() error {
+	for _, f := range c.KeyFields {
+		if f == "" {
+			return errors.New("key_fields must not contain empty field names")
+		}
+	}
+	return nil
+}
+
+func (c *storeConfig) Validate() error {
+	switch c.Type {
+	case "memory":
+		if c.ID != "" {
+			return errors.New("store.id can only be used with file stores")
+		}
+		if c.AtMostOnce {
+			return errors.New("store.at_most_once can only be used with file stores")
+		}
+	case "file":
+		if c.ID == "" {
+			return errors.New("store.id is required for file stores")
+		}
+		if !c.AtMostOnce {
+			return errors.New("file stores persist keys before their events are acknowledged, " +
+				"events not delivered before a restart are dropped as duplicates afterwards: " +
+				"set store.at_most_once to true to use a file store")
+		}
+	default:
+		return fmt.Errorf("unknown store type %q, must be memory or file", c.Type)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/cache"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	procName = "deduplicate"
+	logName  = "processor." + procName
+
+	// storeDir is the directory of the file stores, in the data path.
+	storeDir = "deduplicate_processor"
+)
+
+var (
+	// instanceID is used to assign each instance a unique monitoring namespace.
+	instanceID atomic.Uint32
+
+	// openIDs holds the IDs of the file stores in use, as each file store can
+	// only be used by a single processor.
+	openIDs   = map[string]bool{}
+	openIDsMu sync.Mutex
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type metrics struct {
+	Duplicates *monitoring.Int
+	Unique     *monitoring.Int
+}
+
+// deduplicate drops events whose key was seen within the TTL. The seen keys
+// are held in a memory cache store, and in a file store if configured, so
+// they survive restarts. Processors don't see the acknowledgement of events,
+// so keys are persisted when they are first seen, and events that are not
+// delivered before a restart are dropped as duplicates afterwards. File
+// stores require store.at_most_once to opt in to this.
+type deduplicate struct {
+	config  config
+	log     *logp.Logger
+	metrics metrics
+
+	mu sync.Mutex
+	// seen maps the seen keys to the time they expire.
+	seen *cache.MemStore
+	// registry and store persist the seen keys for file stores. They are
+	// opened by SetPaths.
+	registry *statestore.Registry
+	store    *statestore.Store
+}
+
+// entry is a seen key as it is persisted in the file store.
+type entry struct {
+	Expires time.Time `json:"expires"`
+}
+
+// New constructs a new deduplicate processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	var (
+		id  = int(instanceID.Add(1))
+		reg = monitoring.Default.GetOrCreateRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	p := &deduplicate{
+		config: c,
+		log:    log.Named(logName).With("instance_id", id),
+		metrics: metrics{
+			Duplicates: monitoring.NewInt(reg, "duplicates"),
+			Unique:     monitoring.NewInt(reg, "unique"),
+		},
+	}
+	p.seen = cache.NewMemStore(fmt.Sprintf("%s-%d", procName, id), c.TTL, c.Capacity, p.forget)
+	return p, nil
+}
+
+// SetPaths opens the file store and loads the seen keys, if the processor
+// uses a file store.
+func (p *deduplicate) SetPaths(path *paths.Path) error {
+	if p.config.Store.Type != "file" {
+		return nil
+	}
+
+	id := p.config.Store.ID
+	openIDsMu.Lock()
+	defer openIDsMu.Unlock()
+	if openIDs[id] {
+		return fmt.Errorf("%s store %q is already in use by another processor", procName, id)
+	}
+
+	root := path.Resolve(paths.Data, storeDir)
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return fmt.Errorf("%s processor could not create store directory: %w", procName, err)
+	}
+	backend, err := memlog.New(p.log.Named("memlog"), memlog.Settings{
+		Root:     root,
+		FileMode: 0o600,
+	})
+	if err != nil {
+		return fmt.Errorf("%s processor could not create store registry: %w", procName, err)
+	}
+	registry := statestore.NewRegistry(backend)
+	store, err := registry.Get(id)
+	if err != nil {
+		_ = registry.Close()
+		return fmt.Errorf("%s processor could not open store %q: %w", procName, id, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.registry = registry
+	p.store = store
+	if err := p.load(); err != nil {
+		_ = p.closeStore()
+		return fmt.Errorf("%s processor could not load store %q: %w", procName, id, err)
+	}
+	openIDs[id] = true
+	return nil
+}
+
+// load loads the keys of the file store that have not expired, and removes
+// the expired keys.
+func (p *deduplicate) load() error {
+	now := time.Now()
+	var expired []string
+	live := map[string]time.Time{}
+	err := p.store.Each(func(key string, dec statestore.ValueDecoder) (bool, error) {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			return false, fmt.Errorf("failed to decode key %q: %w", key, err)
+		}
+		if now.After(e.Expires) {
+			expired = append(expired, key)
+		} else {
+			live[key] = e.Expires
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		if err := p.store.Remove(key); err != nil {
+			return err
+		}
+	}
+	for key, expires := range live {
+		if err := p.seen.Put(key, expires); err != nil {
+			return err
+		}
+	}
+	p.log.Debugw("loaded seen keys", "store", p.config.Store.ID, "keys", len(live), "expired", len(expired))
+	return nil
+}
+
+// Run drops the event if its key was seen within the TTL.
+func (p *deduplicate) Run(event *beat.Event) (*beat.Event, error) {
+	key, err := p.key(event)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return event, nil
+		}
+		return event, fmt.Errorf("could not make key: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if v, err := p.seen.Get(key); err == nil && now.Before(v.(time.Time)) { //nolint:errcheck // The store only holds expiry times.
+		p.metrics.Duplicates.Inc()
+		p.log.Debugw("dropped duplicate event", "key", key)
+		return nil, nil
+	}
+
+	expires := now.Add(p.config.TTL)
+	if err := p.seen.Put(key, expires); err != nil {
+		return event, fmt.Errorf("failed to store key: %w", err)
+	}
+	if p.store != nil {
+		if err := p.store.Set(key, entry{Expires: expires}); err != nil {
+			return event, fmt.Errorf("failed to persist key: %w", err)
+		}
+	}
+	p.metrics.Unique.Inc()
+	return event, nil
+}
+
+// forget is called by the memory store when a key is removed because it
+// expired or to stay under capacity. It removes the key from the file store.
+func (p *deduplicate) forget(key string, _ any) {
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Remove(key); err != nil {
+		p.log.Warnw("failed to remove key from store", "key", key, "error", err)
+	}
+}
+
+// key returns the key of the event. A single string key field is used as is,
+// multiple or non-string fields are hashed.
+func (p *deduplicate) key(event *beat.Event) (string, error) {
+	h := sha256.New()
+	for _, field := range p.config.KeyFields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			return "", fmt.Errorf("failed to get key field %q: %w", field, err)
+		}
+		if s, ok := v.(string); ok && len(p.config.KeyFields) == 1 {
+			return s, nil
+		}
+		fmt.Fprintf(h, "%s=%v\x00", field, v)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Close closes the file store. The seen keys are kept in the file store.
+func (p *deduplicate) Close() error {
+	p.mu.Lock()
+	if p.store == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	err := p.closeStore()
+	p.mu.Unlock()
+
+	openIDsMu.Lock()
+	delete(openIDs, p.config.Store.ID)
+	openIDsMu.Unlock()
+	return err
+}
+
+func (p *deduplicate) closeStore() error {
+	err := errors.Join(p.store.Close(), p.registry.Close())
+	p.store = nil
+	p.registry = nil
+	return err
+}
+
+func (p *deduplicate) String() string {
+	return fmt.Sprintf(
+		"%v=[key_fields=[%v],ttl=[%v],capacity=[%v],store=[%v]]",
+		procName, p.config.KeyFields, p.config.TTL, p.config.Capacity, p.config.Store.Type,
+	)
+}
@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deduplicate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func newProcessor(t *testing.T, config map[string]any) *deduplicate {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, processors.Close(p)) })
+	return p.(*deduplicate) //nolint:errcheck // New always returns a deduplicate.
+}
+
+func event(fields mapstr.M) *beat.Event {
+	return &beat.Event{Fields: fields}
+}
+
+// run runs the events through the processor and returns the ones that are
+// not dropped.
+func run(t *testing.T, p beat.Processor, events ...*beat.Event) []*beat.Event {
+	t.Helper()
+	var kept []*beat.Event
+	for _, e := range events {
+		out, err := p.Run(e)
+		require.NoError(t, err)
+		if out != nil {
+			kept = append(kept, out)
+		}
+	}
+	return kept
+}
+
+func TestDeduplicate(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields": []string{"event.id"},
+	})
+
+	kept := run(t, p,
+		event(mapstr.M{"event": mapstr.M{"id": "a"}, "n": 1}),
+		event(mapstr.M{"event": mapstr.M{"id": "b"}, "n": 2}),
+		event(mapstr.M{"event": mapstr.M{"id": "a"}, "n": 3}),
+		event(mapstr.M{"message": "no key"}),
+		event(mapstr.M{"message": "no key"}),
+	)
+	require.Len(t, kept, 4)
+	assert.Equal(t, 1, kept[0].Fields["n"])
+	assert.Equal(t, 2, kept[1].Fields["n"])
+	assert.Equal(t, int64(1), p.metrics.Duplicates.Get())
+	assert.Equal(t, int64(2), p.metrics.Unique.Get())
+}
+
+func TestDeduplicateMultipleFields(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields": []string{"source.ip", "source.port"},
+	})
+
+	kept := run(t, p,
+		event(mapstr.M{"source": mapstr.M{"ip": "10.0.0.1", "port": 80}}),
+		event(mapstr.M{"source": mapstr.M{"ip": "10.0.0.1", "port": 443}}),
+		event(mapstr.M{"source": mapstr.M{"ip": "10.0.0.1", "port": 80}}),
+	)
+	assert.Len(t, kept, 2)
+}
+
+func TestDeduplicateTTL(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields": []string{"id"},
+		"ttl":        "10ms",
+	})
+
+	assert.Len(t, run(t, p, event(mapstr.M{"id": "a"}), event(mapstr.M{"id": "a"})), 1)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, run(t, p, event(mapstr.M{"id": "a"})), 1)
+}
+
+func TestDeduplicateMissingKey(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"key_fields":     []string{"id"},
+		"ignore_missing": false,
+	})
+
+	e := event(mapstr.M{"message": "no key"})
+	out, err := p.Run(e)
+	assert.ErrorIs(t, err, mapstr.ErrKeyNotFound)
+	assert.Equal(t, e, out)
+}
+
+func TestDeduplicateFileStore(t *testing.T) {
+	path := &paths.Path{Data: t.TempDir()}
+	config := map[string]any{
+		"key_fields": []string{"id"},
+		"store":      map[string]any{"type": "file", "id": "test", "at_most_once": true},
+	}
+
+	p, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(path))
+	assert.Len(t, run(t, p, event(mapstr.M{"id": "a"}), event(mapstr.M{"id": "b"})), 2)
+
+	// The store can't be used by two processors.
+	other, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	assert.Error(t, other.(processors.PathSetter).SetPaths(path))
+	require.NoError(t, processors.Close(p))
+
+	// The seen keys survive a restart.
+	p = newProcessor(t, config)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(path))
+	kept := run(t, p, event(mapstr.M{"id": "a"}), event(mapstr.M{"id": "b"}), event(mapstr.M{"id": "c"}))
+	require.Len(t, kept, 1)
+	assert.Equal(t, "c", kept[0].Fields["id"])
+}
+
+func TestDeduplicateConfig(t *testing.T) {
+	testCases := map[string]map[string]any{
+		"missing key fields":        {"ttl": "1h"},
+		"empty key field":           {"key_fields": []string{""}},
+		"zero ttl":                  {"key_fields": []string{"id"}, "ttl": "0s"},
+		"zero capacity":             {"key_fields": []string{"id"}, "capacity": 0},
+		"unknown store":             {"key_fields": []string{"id"}, "store.type": "redis"},
+		"file store without id":     {"key_fields": []string{"id"}, "store.type": "file", "store.at_most_once": true},
+		"file store without opt-in": {"key_fields": []string{"id"}, "store.type": "file", "store.id": "test"},
+		"memory store with id":      {"key_fields": []string{"id"}, "store.id": "test"},
+		"memory store at most once": {"key_fields": []string{"id"}, "store.at_most_once": true},
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fieldcrypt
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// keyIDPattern restricts the key IDs to characters that don't clash with
+// the separators of the encrypted values.
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+type keyConfig struct {
+	// ID identifies the key in the encrypted values.
+	ID string `config:"id" validate:"required"`
+
+	// Key is the base64 encoded AES key, usually a reference to a keystore
+	// entry.
+	Key string `config:"key" validate:"required"`
+}
+
+type encryptConfig struct {
+	// Fields are the fields encrypted in place.
+	Fields []string `config:"fields" validate:"required"`
+
+	// Keys are the keys available to the processor.
+	Keys []keyConfig `config:"keys" validate:"required"`
+
+	// KeyID is the ID of the key used to encrypt the fields. It defaults
+	// to the first key.
+	KeyID string `config:"key_id"`
+
+	// Deterministic encrypts equal values to equal ciphertexts, so that
+	// the encrypted fields can be searched for equality.
+	Deterministic bool `config:"deterministic"`
+
+	IgnoreMissing bool   `config:"ignore_missing"`
+	FailOnError   bool   `config:"fail_on_error"`
+	Tag           string `config:"tag"`
+}
+
+type decryptConfig struct {
+	// Fields are the fields decrypted in place.
+	Fields []string `config:"fields" validate:"required"`
+
+	// Keys are the keys available to the processor.
+	Keys []keyConfig `config:"keys" validate:"required"`
+
+	IgnoreMissing bool   `config:"ignore_missing"`
+	FailOnError   bool   `config:"fail_on_error"`
+	Tag           string `config:"tag"`
+}
+
+func defaultEncryptConfig() encryptConfig {
+	return encryptConfig{
+		IgnoreMissing: true,
+		FailOnError:   true,
+	}
+}
+
+func defaultDecryptConfig() decryptConfig {
+	return decryptConfig{
+		IgnoreMissing: true,
+		FailOnError:   true,
+	}
+}
+
+func (c *encryptConfig) Validate() error {
+	if err := validateFields(c.Fields); err != nil {
+		return err
+	}
+	if _, err := newKeyring(c.Keys); err != nil {
+		return err
+	}
+	if c.KeyID == "" {
+		return nil
+	}
+	for _, k := range c.Keys {
+		if k.ID == c.KeyID {
+			return nil
+		}
+	}
+	return fmt.Errorf("key_id %q is not one of the keys", c.KeyID)
+}
+
+func (c *decryptConfig) Validate() error {
+	if err := validateFields(c.Fields); err != nil {
+		return err
+	}
+	_, err := newKeyring(c.Keys)
+	return err
+}
+
+func validateFields(fields []string) error {
+	for _, f := range fields {
+		if f == "" {
+			return errors.New("fields must not contain empty field names")
+		}
+	}
+	return nil
+}
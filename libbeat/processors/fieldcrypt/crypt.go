@@ -0,0 +1,280 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fieldcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// The encrypted values are strings of the form
+//
+//	enc1:<mode>:<key ID>:<data>
+//
+// where data is the unpadded base64url encoding of
+//
+//	random mode (r):        wrapped data key | nonce | ciphertext
+//	deterministic mode (d): nonce | ciphertext
+//
+// In the random mode, each value is encrypted with a new random data key
+// that is wrapped with the key identified by the key ID. In the
+// deterministic mode, the values are encrypted with a key derived from the
+// key identified by the key ID, and the nonce is derived from the value,
+// so equal values give equal ciphertexts. The header before the data is
+// authenticated in both modes.
+const (
+	formatVersion     = "enc1"
+	modeRandom        = "r"
+	modeDeterministic = "d"
+
+	dataKeySize = 32
+)
+
+var errNotEncrypted = errors.New("value is not an encrypted value")
+
+// key is a key of the keyring with the ciphers derived from it.
+type key struct {
+	id string
+
+	// wrap encrypts the data keys of the random mode.
+	wrap cipher.AEAD
+
+	// det encrypts the values of the deterministic mode, and nonceKey
+	// derives their nonces.
+	det      cipher.AEAD
+	nonceKey []byte
+}
+
+// keyring holds the keys by ID.
+type keyring map[string]*key
+
+func newKeyring(configs []keyConfig) (keyring, error) {
+	r := make(keyring, len(configs))
+	for _, c := range configs {
+		if !keyIDPattern.MatchString(c.ID) {
+			return nil, fmt.Errorf("invalid key ID %q, only letters, digits, '_', '.' and '-' are allowed", c.ID)
+		}
+		if _, ok := r[c.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", c.ID)
+		}
+		k, err := newKey(c.ID, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", c.ID, err)
+		}
+		r[c.ID] = k
+	}
+	return r, nil
+}
+
+func newKey(id, encoded string) (*key, error) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key must be base64 encoded: %w", err)
+	}
+	switch len(secret) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes long, got %d bytes", len(secret))
+	}
+
+	wrap, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	detKey, err := hkdf.Key(sha256.New, secret, nil, "fieldcrypt deterministic encryption key", len(secret))
+	if err != nil {
+		return nil, err
+	}
+	det, err := newGCM(detKey)
+	if err != nil {
+		return nil, err
+	}
+	nonceKey, err := hkdf.Key(sha256.New, secret, nil, "fieldcrypt deterministic nonce key", sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &key{id: id, wrap: wrap, det: det, nonceKey: nonceKey}, nil
+}
+
+func newGCM(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt returns the encrypted value of plaintext.
+func (k *key) encrypt(plaintext []byte, deterministic bool) (string, error) {
+	mode := modeRandom
+	if deterministic {
+		mode = modeDeterministic
+	}
+	header := formatVersion + ":" + mode + ":" + k.id
+	aad := []byte(header)
+
+	var data []byte
+	if deterministic {
+		mac := hmac.New(sha256.New, k.nonceKey)
+		mac.Write(aad)
+		mac.Write([]byte{0})
+		mac.Write(plaintext)
+		nonce := mac.Sum(nil)[:k.det.NonceSize()]
+		data = k.det.Seal(nonce, nonce, plaintext, aad)
+	} else {
+		dataKey := make([]byte, dataKeySize)
+		if _, err := rand.Read(dataKey); err != nil {
+			return "", err
+		}
+		wrapped, err := seal(k.wrap, dataKey, aad)
+		if err != nil {
+			return "", err
+		}
+		aead, err := newGCM(dataKey)
+		if err != nil {
+			return "", err
+		}
+		ciphertext, err := seal(aead, plaintext, aad)
+		if err != nil {
+			return "", err
+		}
+		data = append(wrapped, ciphertext...)
+	}
+	return header + ":" + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decrypt returns the plaintext of the encrypted value s.
+func (r keyring) decrypt(s string) ([]byte, error) {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) != 4 || parts[0] != formatVersion {
+		return nil, errNotEncrypted
+	}
+	mode, id, encoded := parts[1], parts[2], parts[3]
+	k, ok := r[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", id)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	aad := []byte(s[:len(s)-len(encoded)-1])
+
+	var plaintext []byte
+	switch mode {
+	case modeRandom:
+		wrappedSize := k.wrap.NonceSize() + dataKeySize + k.wrap.Overhead()
+		if len(data) < wrappedSize {
+			return nil, errors.New("invalid encrypted value: data is too short")
+		}
+		dataKey, err := open(k.wrap, data[:wrappedSize], aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap the data key with key %q: %w", id, err)
+		}
+		aead, err := newGCM(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err = open(aead, data[wrappedSize:], aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt value: %w", err)
+		}
+	case modeDeterministic:
+		plaintext, err = open(k.det, data, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt value with key %q: %w", id, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown encryption mode %q", mode)
+	}
+	return plaintext, nil
+}
+
+// seal encrypts plaintext with a random nonce and returns the nonce
+// followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts data returned by seal.
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("data is too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+// encodeValue encodes an event value before it is encrypted, keeping its
+// type so that it can be restored by decodeValue.
+func encodeValue(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// decodeValue decodes a value encoded by encodeValue.
+func decodeValue(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+// normalize converts the objects to mapstr.M and the numbers to int64 or
+// float64.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(mapstr.M, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte, size int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), size)))
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		keys, err := newKeyring([]keyConfig{{ID: "k1", Key: testKey('a', size)}})
+		require.NoError(t, err)
+
+		for _, deterministic := range []bool{false, true} {
+			s, err := keys["k1"].encrypt([]byte(`"alice"`), deterministic)
+			require.NoError(t, err)
+			mode := "enc1:r:k1:"
+			if deterministic {
+				mode = "enc1:d:k1:"
+			}
+			assert.True(t, strings.HasPrefix(s, mode), s)
+
+			plaintext, err := keys.decrypt(s)
+			require.NoError(t, err)
+			assert.Equal(t, `"alice"`, string(plaintext))
+		}
+	}
+}
+
+func TestKeyringDeterministic(t *testing.T) {
+	keys, err := newKeyring([]keyConfig{
+		{ID: "k1", Key: testKey('a', 32)},
+		{ID: "k2", Key: testKey('b', 32)},
+	})
+	require.NoError(t, err)
+
+	encrypt := func(id, plaintext string, deterministic bool) string {
+		s, err := keys[id].encrypt([]byte(plaintext), deterministic)
+		require.NoError(t, err)
+		return s
+	}
+
+	assert.Equal(t, encrypt("k1", "alice", true), encrypt("k1", "alice", true))
+	assert.NotEqual(t, encrypt("k1", "alice", true), encrypt("k1", "bob", true))
+	assert.NotEqual(t, encrypt("k1", "alice", true), encrypt("k2", "alice", true))
+	assert.NotEqual(t, encrypt("k1", "alice", false), encrypt("k1", "alice", false))
+}
+
+func TestKeyringDecryptErrors(t *testing.T) {
+	keys, err := newKeyring([]keyConfig{
+		{ID: "k1", Key: testKey('a', 32)},
+		{ID: "k2", Key: testKey('b', 32)},
+	})
+	require.NoError(t, err)
+	random, err := keys["k1"].encrypt([]byte("alice"), false)
+	require.NoError(t, err)
+	deterministic, err := keys["k1"].encrypt([]byte("alice"), true)
+	require.NoError(t, err)
+
+	// tamper flips a bit of the last byte of the data.
+	tamper := func(s string) string {
+		i := strings.LastIndexByte(s, ':')
+		data, err := base64.RawURLEncoding.DecodeString(s[i+1:])
+		require.NoError(t, err)
+		data[len(data)-1] ^= 1
+		return s[:i+1] + base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	testCases := map[string]string{
+		"clear text":             "alice",
+		"unknown version":        "enc2" + random[4:],
+		"unknown key":            strings.Replace(random, ":k1:", ":k3:", 1),
+		"other key":              strings.Replace(random, ":k1:", ":k2:", 1),
+		"mode changed":           strings.Replace(random, ":r:", ":d:", 1),
+		"unknown mode":           strings.Replace(random, ":r:", ":x:", 1),
+		"tampered random":        tamper(random),
+		"tampered deterministic": tamper(deterministic),
+		"truncated random":       random[:len(random)-40],
+		"invalid base64":         random + "!",
+	}
+	for name, s := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := keys.decrypt(s)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	testCases := map[string][]keyConfig{
+		"invalid base64": {{ID: "k1", Key: "not base64!"}},
+		"invalid size":   {{ID: "k1", Key: testKey('a', 20)}},
+		"invalid ID":     {{ID: "k:1", Key: testKey('a', 32)}},
+		"duplicate ID":   {{ID: "k1", Key: testKey('a', 32)}, {ID: "k1", Key: testKey('b', 32)}},
+	}
+	for name, configs := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := newKeyring(configs)
+			assert.Error(t, err)
+		})
+	}
+}
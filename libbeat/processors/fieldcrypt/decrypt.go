@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fieldcrypt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	decryptProcName = "decrypt_fields"
+	decryptLogName  = "processor." + decryptProcName
+)
+
+func init() {
+	processors.RegisterPlugin(decryptProcName, NewDecryptFields)
+}
+
+type decryptFields struct {
+	config decryptConfig
+	keys   keyring
+	log    *logp.Logger
+}
+
+// NewDecryptFields constructs a new decrypt_fields processor.
+func NewDecryptFields(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultDecryptConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", decryptProcName, err)
+	}
+
+	keys, err := newKeyring(c.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	log = log.Named(decryptLogName).With("instance_id", instanceID.Add(1))
+	if c.Tag != "" {
+		log = log.With("tag", c.Tag)
+	}
+	return &decryptFields{config: c, keys: keys, log: log}, nil
+}
+
+// Run replaces the encrypted values of the fields with their decrypted
+// values. The fields that can't be decrypted are left unchanged.
+func (p *decryptFields) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []error
+	for _, field := range p.config.Fields {
+		if err := p.decryptField(event, field); err != nil {
+			errs = append(errs, fmt.Errorf("failed to decrypt field %q: %w", field, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		p.log.Debugw(err.Error(), logp.TypeKey, logp.EventType)
+		if p.config.FailOnError {
+			_, _ = event.PutValue("error.message", err.Error())
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+func (p *decryptFields) decryptField(event *beat.Event, field string) error {
+	v, err := event.GetValue(field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("%w, got %T", errNotEncrypted, v)
+	}
+	plaintext, err := p.keys.decrypt(s)
+	if err != nil {
+		return err
+	}
+	decrypted, err := decodeValue(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to decode the decrypted value: %w", err)
+	}
+	_, err = event.PutValue(field, decrypted)
+	return err
+}
+
+func (p *decryptFields) String() string {
+	return fmt.Sprintf("%v=[fields=%v]", decryptProcName, p.config.Fields)
+}
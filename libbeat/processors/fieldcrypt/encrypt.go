@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fieldcrypt
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	encryptProcName = "encrypt_fields"
+	encryptLogName  = "processor." + encryptProcName
+)
+
+// instanceID is used to assign each instance a unique logging namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	processors.RegisterPlugin(encryptProcName, NewEncryptFields)
+}
+
+type encryptFields struct {
+	config encryptConfig
+	key    *key
+	log    *logp.Logger
+}
+
+// NewEncryptFields constructs a new encrypt_fields processor.
+func NewEncryptFields(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultEncryptConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", encryptProcName, err)
+	}
+
+	keys, err := newKeyring(c.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if c.KeyID == "" {
+		c.KeyID = c.Keys[0].ID
+	}
+
+	log = log.Named(encryptLogName).With("instance_id", instanceID.Add(1))
+	if c.Tag != "" {
+		log = log.With("tag", c.Tag)
+	}
+	return &encryptFields{config: c, key: keys[c.KeyID], log: log}, nil
+}
+
+// Run replaces the values of the fields with their encrypted values. The
+// fields that can't be encrypted are removed from the event, so that their
+// values are never published in clear text.
+func (p *encryptFields) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []error
+	for _, field := range p.config.Fields {
+		if err := p.encryptField(event, field); err != nil {
+			_ = event.Delete(field)
+			errs = append(errs, fmt.Errorf("failed to encrypt field %q: %w", field, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		p.log.Debugw(err.Error(), logp.TypeKey, logp.EventType)
+		if p.config.FailOnError {
+			_, _ = event.PutValue("error.message", err.Error())
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+func (p *encryptFields) encryptField(event *beat.Event, field string) error {
+	v, err := event.GetValue(field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}
+	plaintext, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+	encrypted, err := p.key.encrypt(plaintext, p.config.Deterministic)
+	if err != nil {
+		return err
+	}
+	_, err = event.PutValue(field, encrypted)
+	return err
+}
+
+func (p *encryptFields) String() string {
+	return fmt.Sprintf("%v=[fields=%v, key_id=%v, deterministic=%v]",
+		encryptProcName, p.config.Fields, p.config.KeyID, p.config.Deterministic)
+}
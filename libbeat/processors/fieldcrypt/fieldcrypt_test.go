@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fieldcrypt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var testKeys = []map[string]any{
+	{"id": "k1", "key": testKey('a', 32)},
+	{"id": "k2", "key": testKey('b', 16)},
+}
+
+func newProcessor(t *testing.T, newFn func(*conf.C, *logp.Logger) (beat.Processor, error), config map[string]any) beat.Processor {
+	t.Helper()
+	p, err := newFn(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	return p
+}
+
+func TestEncryptDecryptFields(t *testing.T) {
+	fields := []string{"user.name", "client.ip", "client.port", "labels", "tags", "score", "enabled"}
+	original := mapstr.M{
+		"user":    mapstr.M{"name": "alice"},
+		"client":  mapstr.M{"ip": "10.1.2.3", "port": 443},
+		"labels":  mapstr.M{"team": "red", "level": 2},
+		"tags":    []any{"a", "b"},
+		"score":   0.5,
+		"enabled": true,
+		"message": "login",
+	}
+
+	for _, deterministic := range []bool{false, true} {
+		encrypt := newProcessor(t, NewEncryptFields, map[string]any{
+			"fields":        fields,
+			"keys":          testKeys,
+			"key_id":        "k2",
+			"deterministic": deterministic,
+		})
+		decrypt := newProcessor(t, NewDecryptFields, map[string]any{
+			"fields": fields,
+			"keys":   testKeys,
+		})
+
+		event, err := encrypt.Run(&beat.Event{Fields: original.Clone()})
+		require.NoError(t, err)
+		for _, f := range fields {
+			v, err := event.GetValue(f)
+			require.NoError(t, err)
+			require.IsType(t, "", v, f)
+			assert.True(t, strings.HasPrefix(v.(string), "enc1:"), v)
+			assert.Contains(t, v, ":k2:")
+		}
+		assert.Equal(t, "login", event.Fields["message"])
+
+		event, err = decrypt.Run(event)
+		require.NoError(t, err)
+		assert.Equal(t, mapstr.M{
+			"user":    mapstr.M{"name": "alice"},
+			"client":  mapstr.M{"ip": "10.1.2.3", "port": int64(443)},
+			"labels":  mapstr.M{"team": "red", "level": int64(2)},
+			"tags":    []any{"a", "b"},
+			"score":   0.5,
+			"enabled": true,
+			"message": "login",
+		}, event.Fields)
+	}
+}
+
+func TestEncryptFieldsDeterministic(t *testing.T) {
+	p := newProcessor(t, NewEncryptFields, map[string]any{
+		"fields":        []string{"user.name"},
+		"keys":          testKeys,
+		"deterministic": true,
+	})
+
+	encrypted := func(name string) any {
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": name}}})
+		require.NoError(t, err)
+		v, _ := event.GetValue("user.name")
+		return v
+	}
+	assert.Equal(t, encrypted("alice"), encrypted("alice"))
+	assert.NotEqual(t, encrypted("alice"), encrypted("bob"))
+	assert.Contains(t, encrypted("alice"), "enc1:d:k1:")
+}
+
+func TestEncryptFieldsMissing(t *testing.T) {
+	config := map[string]any{
+		"fields": []string{"user.name"},
+		"keys":   testKeys,
+	}
+
+	p := newProcessor(t, NewEncryptFields, config)
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "login"}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"message": "login"}, event.Fields)
+
+	config["ignore_missing"] = false
+	p = newProcessor(t, NewEncryptFields, config)
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"message": "login"}})
+	assert.Error(t, err)
+	assert.Contains(t, event.Fields["error"], "message")
+}
+
+func TestDecryptFieldsErrors(t *testing.T) {
+	encrypt := newProcessor(t, NewEncryptFields, map[string]any{
+		"fields": []string{"user.name"},
+		"keys":   testKeys[:1],
+	})
+	event, err := encrypt.Run(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": "alice"}}})
+	require.NoError(t, err)
+	encrypted, _ := event.GetValue("user.name")
+
+	testCases := map[string]struct {
+		keys  []map[string]any
+		value any
+	}{
+		"clear text":   {keys: testKeys, value: "alice"},
+		"not a string": {keys: testKeys, value: 42},
+		"unknown key":  {keys: testKeys[1:], value: encrypted},
+		"wrong key":    {keys: []map[string]any{{"id": "k1", "key": testKey('c', 32)}}, value: encrypted},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := map[string]any{"fields": []string{"user.name"}, "keys": tc.keys}
+
+			p := newProcessor(t, NewDecryptFields, config)
+			event, err := p.Run(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": tc.value}}})
+			assert.Error(t, err)
+			v, _ := event.GetValue("user.name")
+			assert.Equal(t, tc.value, v)
+			assert.Contains(t, event.Fields, "error")
+
+			config["fail_on_error"] = false
+			p = newProcessor(t, NewDecryptFields, config)
+			event, err = p.Run(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": tc.value}}})
+			assert.NoError(t, err)
+			assert.NotContains(t, event.Fields, "error")
+		})
+	}
+}
+
+func TestEncryptFieldsConfig(t *testing.T) {
+	testCases := map[string]map[string]any{
+		"missing fields":   {"keys": testKeys},
+		"missing keys":     {"fields": []string{"user.name"}},
+		"empty field name": {"fields": []string{""}, "keys": testKeys},
+		"unknown key_id":   {"fields": []string{"user.name"}, "keys": testKeys, "key_id": "k3"},
+		"invalid key":      {"fields": []string{"user.name"}, "keys": []map[string]any{{"id": "k1", "key": "secret"}}},
+		"missing key":      {"fields": []string{"user.name"}, "keys": []map[string]any{{"id": "k1"}}},
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewEncryptFields(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+
+			delete(config, "key_id")
+			if name != "unknown key_id" {
+				_, err = NewDecryptFields(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -59,6 +59,16 @@ func (f *namedHashMethod) Unpack(str string) error {
 	return nil
 }
 
+// HashMethod returns the constructor of the hash method with the given name.
+// The names are the ones accepted by the method setting of the processor.
+func HashMethod(name string) (func() hash.Hash, error) {
+	var m namedHashMethod
+	if err := m.Unpack(name); err != nil {
+		return nil, err
+	}
+	return m.Hash, nil
+}
+
 // newXxHash returns a hash.Hash instead of the *Digest which implements the same
 func newXxHash() hash.Hash {
 	return xxhash.New()
@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/conditions"
+)
+
+type config struct {
+	// Databases are the paths of the MaxMind DB files. City, country and
+	// ASN databases are supported.
+	Databases []string `config:"databases" validate:"required"`
+
+	// Fields are the IP address fields to enrich, defaultFields if not set.
+	Fields []fieldConfig `config:"fields"`
+
+	// InternalNetworks are the networks whose addresses are not looked up,
+	// defaultInternalNetworks if not set. They use the same named networks
+	// and CIDRs as add_network_direction.
+	InternalNetworks []string `config:"internal_networks"`
+
+	// Language is the language of the names, like city names.
+	Language string `config:"language"`
+
+	// ReloadInterval is how often the database files are checked for
+	// changes.
+	ReloadInterval time.Duration `config:"reload_interval" validate:"nonzero,positive"`
+
+	IgnoreMissing bool   `config:"ignore_missing"`
+	Tag           string `config:"tag"`
+}
+
+type fieldConfig struct {
+	// From is the field holding the IP address.
+	From string `config:"from" validate:"required"`
+
+	// To is the field the geo and as objects are written to.
+	To string `config:"to" validate:"required"`
+}
+
+// The default lists are set after unpacking, as the configured lists would
+// otherwise be merged into them.
+var (
+	defaultFields = []fieldConfig{
+		{From: "source.ip", To: "source"},
+		{From: "destination.ip", To: "destination"},
+		{From: "client.ip", To: "client"},
+		{From: "server.ip", To: "server"},
+	}
+	defaultInternalNetworks = []string{"private", "loopback", "link_local_unicast", "unspecified"}
+)
+
+func defaultConfig() config {
+	return config{
+		Language:       "en",
+		ReloadInterval: time.Minute,
+		IgnoreMissing:  true,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Language == "" {
+		return errors.New("language must not be empty")
+	}
+	// Matching no address checks all the networks.
+	if _, err := conditions.NetworkContains(nil, c.InternalNetworks...); err != nil {
+		return fmt.Errorf("invalid internal_networks: %w", err)
+	}
+	return nil
+}
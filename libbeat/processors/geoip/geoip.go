@@ -0,0 +1,285 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	procName = "geoip"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique logging namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type geoip struct {
+	config    config
+	log       *logp.Logger
+	databases []*database
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// database is a MaxMind DB file reloaded when it changes.
+type database struct {
+	path    string
+	reader  atomic.Pointer[mmdbReader]
+	modTime time.Time
+	size    int64
+}
+
+// New constructs a new geoip processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+	if len(c.Fields) == 0 {
+		c.Fields = defaultFields
+	}
+	if len(c.InternalNetworks) == 0 {
+		c.InternalNetworks = defaultInternalNetworks
+	}
+
+	log = log.Named(logName).With("instance_id", instanceID.Add(1))
+	if c.Tag != "" {
+		log = log.With("tag", c.Tag)
+	}
+
+	p := &geoip{config: c, log: log, done: make(chan struct{})}
+	for _, path := range c.Databases {
+		db := &database{path: path}
+		if err := db.load(); err != nil {
+			return nil, fmt.Errorf("failed to open the %v database: %w", procName, err)
+		}
+		p.databases = append(p.databases, db)
+	}
+
+	p.wg.Add(1)
+	go p.reloadLoop()
+	return p, nil
+}
+
+// load opens the database if the file changed since it was last opened.
+func (db *database) load() error {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	if db.reader.Load() != nil && info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+		return nil
+	}
+	r, err := openMMDB(db.path)
+	if err != nil {
+		return err
+	}
+	db.reader.Store(r)
+	db.modTime, db.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// reloadLoop reloads the changed databases until the processor is closed.
+// The databases in use are kept if their files can't be loaded.
+func (p *geoip) reloadLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		for _, db := range p.databases {
+			modTime := db.modTime
+			if err := db.load(); err != nil {
+				p.log.Warnw("Failed to reload database, the previous version is kept.", "path", db.path, "error", err)
+			} else if !db.modTime.Equal(modTime) {
+				p.log.Infow("Reloaded database.", "path", db.path, "database_type", db.reader.Load().databaseType)
+			}
+		}
+	}
+}
+
+// Run enriches the configured IP address fields with their geo and as
+// objects.
+func (p *geoip) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []error
+	for _, field := range p.config.Fields {
+		v, err := event.GetValue(field.From)
+		if err != nil {
+			if !p.config.IgnoreMissing {
+				errs = append(errs, fmt.Errorf("failed to get field %q: %w", field.From, err))
+			}
+			continue
+		}
+
+		ip := toIP(v)
+		if ip == nil {
+			errs = append(errs, fmt.Errorf("field %q is not an IP address: %v", field.From, v))
+			continue
+		}
+		internal, err := conditions.NetworkContains(ip, p.config.InternalNetworks...)
+		if err != nil {
+			return event, err
+		}
+		if internal {
+			continue
+		}
+
+		geo, as, err := p.lookup(ip)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to look up %q: %w", field.From, err))
+			continue
+		}
+		if len(geo) != 0 {
+			_, _ = event.PutValue(field.To+".geo", geo)
+		}
+		if len(as) != 0 {
+			_, _ = event.PutValue(field.To+".as", as)
+		}
+	}
+	return event, errors.Join(errs...)
+}
+
+func toIP(v any) net.IP {
+	switch v := v.(type) {
+	case string:
+		return net.ParseIP(v)
+	case net.IP:
+		return v
+	}
+	return nil
+}
+
+// lookup returns the geo and as objects of ip from all the databases.
+func (p *geoip) lookup(ip net.IP) (geo, as mapstr.M, err error) {
+	geo, as = mapstr.M{}, mapstr.M{}
+	for _, db := range p.databases {
+		rec, err := db.reader.Load().lookup(ip)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", db.path, err)
+		}
+		m, ok := rec.(map[string]any)
+		if !ok {
+			continue
+		}
+		p.geoFields(geo, m)
+		asFields(as, m)
+	}
+	return geo, as, nil
+}
+
+// geoFields sets the ECS geo fields of a city or country record.
+func (p *geoip) geoFields(geo mapstr.M, rec map[string]any) {
+	put := func(key string, v any) {
+		if s, ok := v.(string); ok && s != "" {
+			geo[key] = s
+		}
+	}
+
+	continent := getMap(rec, "continent")
+	put("continent_code", continent["code"])
+	put("continent_name", p.name(continent))
+
+	country := getMap(rec, "country")
+	put("country_iso_code", country["iso_code"])
+	put("country_name", p.name(country))
+
+	if subdivisions, _ := rec["subdivisions"].([]any); len(subdivisions) != 0 {
+		region, _ := subdivisions[0].(map[string]any)
+		if code, ok := region["iso_code"].(string); ok {
+			if countryCode, ok := country["iso_code"].(string); ok {
+				code = countryCode + "-" + code
+			}
+			put("region_iso_code", code)
+		}
+		put("region_name", p.name(region))
+	}
+
+	put("city_name", p.name(getMap(rec, "city")))
+	put("postal_code", getMap(rec, "postal")["code"])
+
+	location := getMap(rec, "location")
+	put("timezone", location["time_zone"])
+	lat, latOK := location["latitude"].(float64)
+	lon, lonOK := location["longitude"].(float64)
+	if latOK && lonOK {
+		geo["location"] = mapstr.M{"lat": lat, "lon": lon}
+	}
+}
+
+// asFields sets the ECS as fields of an ASN record.
+func asFields(as mapstr.M, rec map[string]any) {
+	if n, ok := rec["autonomous_system_number"].(uint64); ok {
+		as["number"] = n
+	}
+	if org, ok := rec["autonomous_system_organization"].(string); ok && org != "" {
+		as["organization"] = mapstr.M{"name": org}
+	}
+}
+
+// name returns the name of a record in the configured language.
+func (p *geoip) name(rec map[string]any) any {
+	return getMap(rec, "names")[p.config.Language]
+}
+
+func getMap(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
+
+// Close stops the reloading of the databases.
+func (p *geoip) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	p.wg.Wait()
+	return nil
+}
+
+func (p *geoip) String() string {
+	fields := make([]string, len(p.config.Fields))
+	for i, f := range p.config.Fields {
+		fields[i] = f.From + ":" + f.To
+	}
+	return fmt.Sprintf("%v=[databases=%v, fields=%v, internal_networks=%v, language=%v, reload_interval=%v]",
+		procName, p.config.Databases, fields, p.config.InternalNetworks, p.config.Language, p.config.ReloadInterval)
+}
@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var cityRecord = map[string]any{
+	"city":      map[string]any{"names": map[string]any{"en": "London", "de": "London"}},
+	"continent": map[string]any{"code": "EU", "names": map[string]any{"en": "Europe", "de": "Europa"}},
+	"country":   map[string]any{"iso_code": "GB", "names": map[string]any{"en": "United Kingdom", "de": "Vereinigtes Königreich"}},
+	"location": map[string]any{
+		"latitude":  51.5142,
+		"longitude": -0.0931,
+		"time_zone": "Europe/London",
+	},
+	"postal": map[string]any{"code": "EC2V"},
+	"subdivisions": []any{
+		map[string]any{"iso_code": "ENG", "names": map[string]any{"en": "England", "de": "England"}},
+	},
+}
+
+var asnRecord = map[string]any{
+	"autonomous_system_number":       uint32(15169),
+	"autonomous_system_organization": "Google LLC",
+}
+
+// writeMMDB writes a test database to a temporary file.
+func writeMMDB(t *testing.T, name, databaseType string, networks ...testNetwork) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, buildMMDB(t, 6, 28, databaseType, networks...), 0o600))
+	return path
+}
+
+func newProcessor(t *testing.T, config map[string]any) *geoip {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.(*geoip).Close() }) //nolint:errcheck // New always returns a geoip.
+	return p.(*geoip)                            //nolint:errcheck // New always returns a geoip.
+}
+
+func TestGeoIP(t *testing.T) {
+	city := writeMMDB(t, "city.mmdb", "GeoLite2-City",
+		testNetwork{"81.2.69.0/24", cityRecord},
+		testNetwork{"2a02:c7c::/32", cityRecord},
+	)
+	asn := writeMMDB(t, "asn.mmdb", "GeoLite2-ASN",
+		testNetwork{"81.2.69.0/24", asnRecord},
+		testNetwork{"8.8.8.0/24", asnRecord},
+	)
+	p := newProcessor(t, map[string]any{"databases": []string{city, asn}})
+
+	out, err := p.Run(&beat.Event{Fields: mapstr.M{
+		"source":      mapstr.M{"ip": "81.2.69.142"},
+		"destination": mapstr.M{"ip": "192.168.1.1"},
+		"client":      mapstr.M{"ip": "8.8.8.8"},
+		"server":      mapstr.M{"ip": "2a02:c7c::1"},
+	}})
+	require.NoError(t, err)
+
+	geo := mapstr.M{
+		"continent_code":   "EU",
+		"continent_name":   "Europe",
+		"country_iso_code": "GB",
+		"country_name":     "United Kingdom",
+		"region_iso_code":  "GB-ENG",
+		"region_name":      "England",
+		"city_name":        "London",
+		"postal_code":      "EC2V",
+		"timezone":         "Europe/London",
+		"location":         mapstr.M{"lat": 51.5142, "lon": -0.0931},
+	}
+	as := mapstr.M{
+		"number":       uint64(15169),
+		"organization": mapstr.M{"name": "Google LLC"},
+	}
+	assert.Equal(t, mapstr.M{
+		"source":      mapstr.M{"ip": "81.2.69.142", "geo": geo, "as": as},
+		"destination": mapstr.M{"ip": "192.168.1.1"},
+		"client":      mapstr.M{"ip": "8.8.8.8", "as": as},
+		"server":      mapstr.M{"ip": "2a02:c7c::1", "geo": geo},
+	}, out.Fields)
+}
+
+func TestGeoIPOptions(t *testing.T) {
+	city := writeMMDB(t, "city.mmdb", "GeoLite2-City", testNetwork{"10.1.0.0/16", cityRecord})
+	p := newProcessor(t, map[string]any{
+		"databases":         []string{city},
+		"fields":            []map[string]any{{"from": "host.ip", "to": "host"}},
+		"internal_networks": []string{"loopback"},
+		"language":          "de",
+	})
+
+	out, err := p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"ip": "10.1.2.3"}}})
+	require.NoError(t, err)
+	name, err := out.GetValue("host.geo.country_name")
+	require.NoError(t, err)
+	assert.Equal(t, "Vereinigtes Königreich", name)
+
+	// The configured lists replace the defaults.
+	assert.Equal(t, []fieldConfig{{From: "host.ip", To: "host"}}, p.config.Fields)
+	assert.Equal(t, []string{"loopback"}, p.config.InternalNetworks)
+}
+
+func TestGeoIPErrors(t *testing.T) {
+	city := writeMMDB(t, "city.mmdb", "GeoLite2-City", testNetwork{"81.2.69.0/24", cityRecord})
+
+	t.Run("missing field", func(t *testing.T) {
+		p := newProcessor(t, map[string]any{"databases": []string{city}, "ignore_missing": false})
+		_, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.142"}}})
+		assert.ErrorContains(t, err, `failed to get field "destination.ip"`)
+	})
+
+	t.Run("not an IP", func(t *testing.T) {
+		p := newProcessor(t, map[string]any{"databases": []string{city}})
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "example.com"}}})
+		assert.ErrorContains(t, err, `field "source.ip" is not an IP address`)
+		assert.Equal(t, mapstr.M{"source": mapstr.M{"ip": "example.com"}}, out.Fields)
+	})
+}
+
+func TestGeoIPReload(t *testing.T) {
+	path := writeMMDB(t, "city.mmdb", "GeoLite2-City", testNetwork{"81.2.69.0/24", cityRecord})
+	p := newProcessor(t, map[string]any{
+		"databases":       []string{path},
+		"reload_interval": "10ms",
+	})
+
+	countryCode := func() any {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.142"}}})
+		require.NoError(t, err)
+		v, _ := out.GetValue("source.geo.country_iso_code")
+		return v
+	}
+	require.Equal(t, "GB", countryCode())
+
+	// A corrupted file keeps the previous database.
+	require.NoError(t, os.WriteFile(path, []byte("corrupted"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, "GB", countryCode())
+
+	updated := map[string]any{"country": map[string]any{"iso_code": "FR"}}
+	require.NoError(t, os.WriteFile(path, buildMMDB(t, 6, 28, "GeoLite2-City", testNetwork{"81.2.69.0/24", updated}), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	assert.Eventually(t, func() bool { return countryCode() == "FR" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestGeoIPConfig(t *testing.T) {
+	city := writeMMDB(t, "city.mmdb", "GeoLite2-City", testNetwork{"81.2.69.0/24", cityRecord})
+	invalid := filepath.Join(t.TempDir(), "invalid.mmdb")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a database"), 0o600))
+
+	testCases := map[string]map[string]any{
+		"no databases":             {},
+		"missing database":         {"databases": []string{filepath.Join(t.TempDir(), "missing.mmdb")}},
+		"invalid database":         {"databases": []string{invalid}},
+		"invalid internal network": {"databases": []string{city}, "internal_networks": []string{"intranet"}},
+		"missing target field":     {"databases": []string{city}, "fields": []map[string]any{{"from": "source.ip"}}},
+		"zero reload interval":     {"databases": []string{city}, "reload_interval": 0},
+		"empty language":           {"databases": []string{city}, "language": ""},
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
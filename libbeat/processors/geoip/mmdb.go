@@ -0,0 +1,410 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// This file implements a reader of the MaxMind DB file format, described in
+// https://maxmind.github.io/MaxMind-DB/.
+
+// metadataMarker precedes the metadata section at the end of the file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const (
+	// maxMetadataSize is how far from the end of the file the metadata
+	// marker is searched for.
+	maxMetadataSize = 128 * 1024
+
+	// dataSectionSeparator is the size of the zeros between the search
+	// tree and the data section.
+	dataSectionSeparator = 16
+
+	// maxDecodeDepth limits the nesting of decoded values, to protect
+	// against corrupted databases.
+	maxDecodeDepth = 64
+)
+
+// Data types of the data section.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+var errInvalidDatabase = errors.New("invalid MaxMind database")
+
+// mmdbReader looks up IP addresses in a MaxMind DB file loaded in memory.
+type mmdbReader struct {
+	databaseType string
+	ipVersion    uint
+	nodeCount    uint
+	recordSize   uint
+	nodeSize     uint
+	tree         []byte
+	data         decoder
+	// ipv4Start is the node IPv4 addresses start from in IPv6 databases.
+	ipv4Start uint
+}
+
+// openMMDB reads the database in path.
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newMMDBReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	from := max(len(buf)-maxMetadataSize, 0)
+	i := bytes.LastIndex(buf[from:], metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", errInvalidDatabase)
+	}
+	metaStart := from + i + len(metadataMarker)
+
+	v, _, err := decoder{buf: buf[metaStart:]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %w", errInvalidDatabase, err)
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", errInvalidDatabase)
+	}
+
+	r := &mmdbReader{}
+	r.databaseType, _ = meta["database_type"].(string)
+	r.ipVersion = metadataUint(meta, "ip_version")
+	r.nodeCount = metadataUint(meta, "node_count")
+	r.recordSize = metadataUint(meta, "record_size")
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", errInvalidDatabase, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", errInvalidDatabase, r.ipVersion)
+	}
+	r.nodeSize = r.recordSize / 4
+
+	treeSize := r.nodeCount * r.nodeSize
+	dataStart := treeSize + dataSectionSeparator
+	if dataStart > uint(from+i) {
+		return nil, fmt.Errorf("%w: search tree exceeds the file size", errInvalidDatabase)
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[dataStart : from+i]}
+
+	if r.ipVersion == 6 {
+		// IPv4 addresses are looked up as the IPv6 addresses ::a.b.c.d,
+		// so the first 96 bits are zeros.
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start, err = r.record(r.ipv4Start, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func metadataUint(meta map[string]any, key string) uint {
+	switch v := meta[key].(type) {
+	case uint64:
+		return uint(v)
+	case int64:
+		if v > 0 {
+			return uint(v)
+		}
+	}
+	return 0
+}
+
+// lookup returns the record of the network containing ip, or nil if the
+// database has no record for ip.
+func (r *mmdbReader) lookup(ip net.IP) (any, error) {
+	node, bits := uint(0), 128
+	switch {
+	case ip.To4() != nil:
+		ip, bits = ip.To4(), 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	case ip.To16() == nil, r.ipVersion == 4:
+		return nil, nil
+	default:
+		ip = ip.To16()
+	}
+
+	var err error
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node, err = r.record(node, bit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, fmt.Errorf("%w: search tree is deeper than the address", errInvalidDatabase)
+	}
+	if node-r.nodeCount < dataSectionSeparator {
+		return nil, fmt.Errorf("%w: invalid data pointer %d", errInvalidDatabase, node)
+	}
+	v, _, err := r.data.decode(node-r.nodeCount-dataSectionSeparator, 0)
+	return v, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (r *mmdbReader) record(node, bit uint) (uint, error) {
+	off := node * r.nodeSize
+	if off+r.nodeSize > uint(len(r.tree)) {
+		return 0, fmt.Errorf("%w: node %d out of the search tree", errInvalidDatabase, node)
+	}
+	b := r.tree[off : off+r.nodeSize]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:])), nil
+	}
+}
+
+// decoder decodes the values of a data or metadata section. Pointers are
+// offsets from the start of buf.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset following it.
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("values are nested too deeply")
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		if d.isPointer(size) {
+			return nil, 0, errors.New("pointer to a pointer")
+		}
+		v, _, err := d.decode(size, depth+1)
+		return v, offset, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for range size {
+			var k, v any
+			k, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key of type %T", k)
+			}
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for range size {
+			var v any
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid boolean size %d", size)
+		}
+		return size == 1, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("value of size %d at offset %d exceeds the section", size, offset)
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return bytes.Clone(b), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), next, nil //nolint:gosec // Two's complement conversion.
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid uint128 size %d", size)
+		}
+		return bytes.Clone(b), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+// isPointer reports whether the value at offset is a pointer.
+func (d decoder) isPointer(offset uint) bool {
+	return offset < uint(len(d.buf)) && d.buf[offset]>>5 == typePointer
+}
+
+// control decodes the control bytes at offset. It returns the data type, the
+// size of the value, or the target of a pointer, and the offset of the value.
+func (d decoder) control(offset uint) (typ, size, next uint, err error) {
+	read := func(n uint) ([]byte, error) {
+		if offset+n > uint(len(d.buf)) {
+			return nil, fmt.Errorf("control bytes at offset %d exceed the section", offset)
+		}
+		b := d.buf[offset : offset+n]
+		offset += n
+		return b, nil
+	}
+
+	b, err := read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := uint(b[0])
+	typ = ctrl >> 5
+
+	if typ == typePointer {
+		n := (ctrl>>3)&0x3 + 1
+		p, err := read(n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var v uint
+		if n < 4 {
+			v = ctrl & 0x7
+		}
+		for _, c := range p {
+			v = v<<8 | uint(c)
+		}
+		switch n {
+		case 2:
+			v += 2048
+		case 3:
+			v += 526336
+		}
+		return typ, v, offset, nil
+	}
+
+	if typ == typeExtended {
+		b, err := read(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		typ = 7 + uint(b[0])
+		if typ < typeInt32 {
+			return 0, 0, 0, fmt.Errorf("invalid extended type %d", typ)
+		}
+	}
+
+	size = ctrl & 0x1f
+	if size >= 29 {
+		n := size - 28
+		ext, err := read(n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var v uint
+		for _, c := range ext {
+			v = v<<8 | uint(c)
+		}
+		switch n {
+		case 1:
+			size = 29 + v
+		case 2:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+	}
+	return typ, size, offset, nil
+}
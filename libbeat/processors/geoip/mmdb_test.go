@@ -0,0 +1,360 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNetwork is a network and its record in a test database.
+type testNetwork struct {
+	cidr   string
+	record any
+}
+
+// testPointer is encoded as a pointer to an offset of the data section.
+type testPointer uint
+
+type testNode struct {
+	child [2]*testNode
+	// data are the data offsets of the records, plus one.
+	data [2]int
+}
+
+// buildMMDB returns a MaxMind DB with the networks, which must not overlap.
+func buildMMDB(t *testing.T, ipVersion, recordSize int, databaseType string, networks ...testNetwork) []byte {
+	t.Helper()
+
+	var data bytes.Buffer
+	root := &testNode{}
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		require.NoError(t, err)
+		ip, ones := ipNet.IP, 0
+		prefix, _ := ipNet.Mask.Size()
+		if ip4 := ip.To4(); ip4 != nil && ipVersion == 6 {
+			ip, ones = make(net.IP, 16), 96
+			copy(ip[12:], ip4)
+		} else if ip4 != nil {
+			ip = ip4
+		}
+		require.False(t, ipVersion == 4 && len(ip) == 16, "IPv6 network in IPv4 database")
+		prefix += ones
+
+		node := root
+		for i := range prefix - 1 {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if node.child[bit] == nil {
+				node.child[bit] = &testNode{}
+			}
+			node = node.child[bit]
+		}
+		last := prefix - 1
+		node.data[ip[last/8]>>(7-last%8)&1] = data.Len() + 1
+		encodeTestValue(&data, n.record)
+	}
+
+	// Number the nodes breadth first, the root being 0.
+	nodes := []*testNode{root}
+	for i := 0; i < len(nodes); i++ {
+		for _, c := range nodes[i].child {
+			if c != nil {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+	record := func(n *testNode, bit int) uint32 {
+		switch {
+		case n.child[bit] != nil:
+			return uint32(slices.Index(nodes, n.child[bit]))
+		case n.data[bit] != 0:
+			return uint32(nodeCount + dataSectionSeparator + n.data[bit] - 1)
+		default:
+			return uint32(nodeCount)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		left, right := record(n, 0), record(n, 1)
+		switch recordSize {
+		case 24:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left)})
+			buf.Write([]byte{byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left)})
+			buf.WriteByte(byte(left>>24)<<4 | byte(right>>24)&0x0F)
+			buf.Write([]byte{byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			buf.Write(binary.BigEndian.AppendUint32(nil, left))
+			buf.Write(binary.BigEndian.AppendUint32(nil, right))
+		}
+	}
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data.Bytes())
+	buf.Write(metadataMarker)
+	encodeTestValue(&buf, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"database_type":               databaseType,
+		"ip_version":                  uint16(ipVersion),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+	return buf.Bytes()
+}
+
+// encodeTestValue appends the encoding of v to buf.
+func encodeTestValue(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		writeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case []byte:
+		writeControl(buf, typeBytes, len(v))
+		buf.Write(v)
+	case float64:
+		writeControl(buf, typeDouble, 8)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case float32:
+		writeControl(buf, typeFloat, 4)
+		buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(v)))
+	case uint16:
+		writeControl(buf, typeUint16, 2)
+		buf.Write(binary.BigEndian.AppendUint16(nil, v))
+	case uint32:
+		writeControl(buf, typeUint32, 4)
+		buf.Write(binary.BigEndian.AppendUint32(nil, v))
+	case uint64:
+		writeControl(buf, typeUint64, 8)
+		buf.Write(binary.BigEndian.AppendUint64(nil, v))
+	case int32:
+		writeControl(buf, typeInt32, 4)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(buf, typeBool, size)
+	case []any:
+		writeControl(buf, typeArray, len(v))
+		for _, e := range v {
+			encodeTestValue(buf, e)
+		}
+	case map[string]any:
+		writeControl(buf, typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			encodeTestValue(buf, k)
+			encodeTestValue(buf, v[k])
+		}
+	case testPointer:
+		switch {
+		case v < 2048:
+			buf.Write([]byte{typePointer<<5 | byte(v>>8), byte(v)})
+		case v < 526336:
+			v -= 2048
+			buf.Write([]byte{typePointer<<5 | 1<<3 | byte(v>>16), byte(v >> 8), byte(v)})
+		case v < 134744064:
+			v -= 526336
+			buf.Write([]byte{typePointer<<5 | 2<<3 | byte(v>>24), byte(v >> 16), byte(v >> 8), byte(v)})
+		default:
+			buf.WriteByte(typePointer<<5 | 3<<3)
+			buf.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+		}
+	default:
+		panic("unsupported test value type")
+	}
+}
+
+func writeControl(buf *bytes.Buffer, typ byte, size int) {
+	var sizeBits byte
+	var ext []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits, ext = 29, []byte{byte(size - 29)}
+	case size < 65821:
+		sizeBits, ext = 30, binary.BigEndian.AppendUint16(nil, uint16(size-285))
+	default:
+		size -= 65821
+		sizeBits, ext = 31, []byte{byte(size >> 16), byte(size >> 8), byte(size)}
+	}
+	if typ < typeInt32 {
+		buf.WriteByte(typ<<5 | sizeBits)
+	} else {
+		buf.Write([]byte{sizeBits, typ - 7})
+	}
+	buf.Write(ext)
+}
+
+func TestMMDBLookup(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			networks := []testNetwork{
+				{"8.8.8.0/24", map[string]any{"name": "a"}},
+				// A pointer to the record of the previous network.
+				{"9.9.0.0/16", testPointer(0)},
+				{"81.2.69.160/27", map[string]any{"name": "b"}},
+			}
+			if ipVersion == 6 {
+				networks = append(networks, testNetwork{"2001:db8::/32", map[string]any{"name": "c"}})
+			}
+			r, err := newMMDBReader(buildMMDB(t, ipVersion, recordSize, "Test", networks...))
+			require.NoError(t, err)
+			assert.Equal(t, "Test", r.databaseType)
+
+			testCases := map[string]any{
+				"8.8.8.8":        map[string]any{"name": "a"},
+				"9.9.9.9":        map[string]any{"name": "a"},
+				"81.2.69.170":    map[string]any{"name": "b"},
+				"81.2.69.100":    nil,
+				"10.0.0.1":       nil,
+				"2001:db8::1":    nil,
+				"2001:db9::1":    nil,
+				"::ffff:8.8.8.8": map[string]any{"name": "a"},
+			}
+			if ipVersion == 6 {
+				testCases["2001:db8::1"] = map[string]any{"name": "c"}
+			}
+			for ip, want := range testCases {
+				got, err := r.lookup(net.ParseIP(ip))
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "IPv%d database, record size %d, %s", ipVersion, recordSize, ip)
+			}
+		}
+	}
+}
+
+func TestMMDBDecode(t *testing.T) {
+	values := []any{
+		"short",
+		strings.Repeat("x", 100),
+		strings.Repeat("x", 1000),
+		strings.Repeat("x", 70000),
+		[]byte{1, 2, 3},
+		1.5,
+		float32(2.5),
+		uint16(443),
+		uint32(15169),
+		uint64(1 << 40),
+		int32(-42),
+		true,
+		false,
+		[]any{"a", uint32(1)},
+		map[string]any{"a": map[string]any{"b": []any{"c"}}},
+	}
+	want := []any{
+		"short",
+		strings.Repeat("x", 100),
+		strings.Repeat("x", 1000),
+		strings.Repeat("x", 70000),
+		[]byte{1, 2, 3},
+		1.5,
+		2.5,
+		uint64(443),
+		uint64(15169),
+		uint64(1 << 40),
+		int64(-42),
+		true,
+		false,
+		[]any{"a", uint64(1)},
+		map[string]any{"a": map[string]any{"b": []any{"c"}}},
+	}
+
+	var buf bytes.Buffer
+	for _, v := range values {
+		encodeTestValue(&buf, v)
+	}
+	d := decoder{buf: buf.Bytes()}
+	offset := uint(0)
+	for i := range values {
+		got, next, err := d.decode(offset, 0)
+		require.NoError(t, err)
+		assert.Equal(t, want[i], got)
+		offset = next
+	}
+	assert.Equal(t, uint(buf.Len()), offset)
+}
+
+func TestMMDBDecodePointers(t *testing.T) {
+	for _, target := range []uint{10, 3000, 600000, 140000000} {
+		var buf bytes.Buffer
+		encodeTestValue(&buf, testPointer(target))
+		pointerSize := uint(buf.Len())
+
+		typ, size, next, err := decoder{buf: buf.Bytes()}.control(0)
+		require.NoError(t, err)
+		assert.Equal(t, uint(typePointer), typ)
+		assert.Equal(t, target, size)
+		assert.Equal(t, pointerSize, next)
+	}
+
+	var buf bytes.Buffer
+	// The array takes 2 bytes and each pointer 2 bytes.
+	encodeTestValue(&buf, []any{testPointer(6), testPointer(6)})
+	encodeTestValue(&buf, "target")
+	got, _, err := decoder{buf: buf.Bytes()}.decode(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"target", "target"}, got)
+}
+
+func TestMMDBInvalid(t *testing.T) {
+	valid := buildMMDB(t, 4, 24, "Test", testNetwork{"8.8.8.0/24", "a"})
+	marker := bytes.LastIndex(valid, metadataMarker)
+
+	testCases := map[string][]byte{
+		"empty":              nil,
+		"no metadata":        valid[:marker],
+		"truncated metadata": valid[:len(valid)-3],
+		"truncated tree":     append(bytes.Clone(valid[:10]), valid[marker:]...),
+		"bad record size":    buildMMDBWithMetadata(t, valid[:marker], map[string]any{"node_count": uint32(32), "record_size": uint16(20), "ip_version": uint16(4)}),
+		"bad ip version":     buildMMDBWithMetadata(t, valid[:marker], map[string]any{"node_count": uint32(32), "record_size": uint16(24), "ip_version": uint16(5)}),
+	}
+	for name, buf := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := newMMDBReader(buf)
+			assert.ErrorIs(t, err, errInvalidDatabase)
+		})
+	}
+}
+
+func buildMMDBWithMetadata(t *testing.T, sections []byte, metadata map[string]any) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(bytes.Clone(sections))
+	buf.Write(metadataMarker)
+	encodeTestValue(buf, metadata)
+	return buf.Bytes()
+}
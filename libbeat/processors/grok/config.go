@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"errors"
+	"fmt"
+)
+
+type config struct {
+	Field              string            `config:"field"`
+	Patterns           []string          `config:"patterns" validate:"required"`
+	PatternDefinitions map[string]string `config:"pattern_definitions"`
+	IgnoreMissing      bool              `config:"ignore_missing"`
+	FailOnError        bool              `config:"fail_on_error"`
+	TraceMatch         bool              `config:"trace_match"`
+	Tag                string            `config:"tag"`
+}
+
+func defaultConfig() config {
+	return config{
+		Field:       "message",
+		FailOnError: true,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Field == "" {
+		return errors.New("field is required")
+	}
+	for i, p := range c.Patterns {
+		if p == "" {
+			return fmt.Errorf("pattern %d is empty", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/elastic/go-grok"
+	"github.com/elastic/go-grok/patterns"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	procName = "grok"
+	logName  = "processor.grok"
+
+	// matchIndexField holds the index of the matching pattern if trace_match
+	// is enabled.
+	matchIndexField = "grok.match_index"
+)
+
+// library is the standard pattern library, with the ECS field names used by
+// the Elasticsearch grok processor.
+var library = mergePatterns(
+	patterns.Default,
+	patterns.AWS,
+	patterns.Bind9,
+	patterns.Bro,
+	patterns.Exim,
+	patterns.HAProxy,
+	patterns.Httpd,
+	patterns.Firewalls,
+	patterns.Java,
+	patterns.Junos,
+	patterns.Maven,
+	patterns.MCollective,
+	patterns.MongoDB,
+	patterns.PostgreSQL,
+	patterns.Rails,
+	patterns.Redis,
+	patterns.Ruby,
+	patterns.Squid,
+	patterns.Syslog,
+)
+
+// reference matches %{SYNTAX}, %{SYNTAX:SEMANTIC} and %{SYNTAX:SEMANTIC:TYPE}.
+var reference = regexp.MustCompile(`%{(\w+(?::[\w+.]+(?::\w+)?)?)}`)
+
+// typeNames maps the grok type names to the types of the convert processor.
+var typeNames = map[string]string{
+	"int":  "integer",
+	"bool": "boolean",
+}
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type processor struct {
+	config
+	log      *logp.Logger
+	matchers []*matcher
+}
+
+type matcher struct {
+	grok       *grok.Grok
+	captures   bool
+	converters map[string]convert.Converter
+}
+
+// New constructs a new grok processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	log = log.Named(logName)
+	if c.Tag != "" {
+		log = log.With("instance_id", c.Tag)
+	}
+
+	p := &processor{config: c, log: log}
+	for _, pattern := range c.Patterns {
+		m, err := newMatcher(pattern, c.PatternDefinitions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile grok pattern '%v': %w", pattern, err)
+		}
+		p.matchers = append(p.matchers, m)
+	}
+	return p, nil
+}
+
+func newMatcher(pattern string, definitions map[string]string) (*matcher, error) {
+	g, err := grok.NewComplete(definitions)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Compile(pattern, true); err != nil {
+		return nil, err
+	}
+
+	lookup := func(name string) (string, bool) {
+		if def, ok := definitions[name]; ok {
+			return def, true
+		}
+		def, ok := library[name]
+		return def, ok
+	}
+	hints := map[string]string{}
+	collectTypeHints(pattern, lookup, hints, map[string]bool{})
+
+	m := &matcher{
+		grok:       g,
+		captures:   g.HasCaptureGroups(),
+		converters: map[string]convert.Converter{},
+	}
+	for field, typ := range hints {
+		if name, ok := typeNames[typ]; ok {
+			typ = name
+		}
+		conv, err := convert.NewConverter(typ)
+		if err != nil {
+			return nil, fmt.Errorf("field '%v': %w", field, err)
+		}
+		m.converters[field] = conv
+	}
+	return m, nil
+}
+
+// collectTypeHints finds the types of all fields captured by the pattern and
+// the patterns it references.
+func collectTypeHints(pattern string, lookup func(string) (string, bool), hints map[string]string, seen map[string]bool) {
+	for _, ref := range reference.FindAllStringSubmatch(pattern, -1) {
+		parts := strings.Split(ref[1], ":")
+		if len(parts) == 3 {
+			hints[parts[1]] = strings.ToLower(parts[2])
+		}
+		if seen[parts[0]] {
+			continue
+		}
+		seen[parts[0]] = true
+		if def, ok := lookup(parts[0]); ok {
+			collectTypeHints(def, lookup, hints, seen)
+		}
+	}
+}
+
+// match returns the captured fields, converted to their types. ok is false
+// if the pattern doesn't match.
+func (m *matcher) match(text string) (fields map[string]any, ok bool, err error) {
+	if !m.captures {
+		return nil, m.grok.MatchString(text), nil
+	}
+
+	captures, err := m.grok.ParseString(text)
+	if err != nil || len(captures) == 0 {
+		return nil, false, err
+	}
+
+	fields = make(map[string]any, len(captures))
+	for field, value := range captures {
+		if conv := m.converters[field]; conv != nil {
+			converted, err := conv(value)
+			if err != nil {
+				return nil, false, fmt.Errorf("unable to convert field '%v': %w", field, err)
+			}
+			fields[field] = converted
+			continue
+		}
+		fields[field] = value
+	}
+	return fields, true, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	err := p.run(event)
+	if err == nil {
+		return event, nil
+	}
+	if p.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+		return event, nil
+	}
+
+	err = fmt.Errorf("failed in %v processor: %w", procName, err)
+	p.log.Debugw(err.Error(), logp.TypeKey, logp.EventType)
+	if p.FailOnError {
+		_, _ = event.PutValue("error.message", err.Error())
+		return event, err
+	}
+	return event, nil
+}
+
+func (p *processor) run(event *beat.Event) error {
+	value, err := event.GetValue(p.Field)
+	if err != nil {
+		return fmt.Errorf("could not fetch value for key: %v: %w", p.Field, err)
+	}
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("field '%v' is not a string but %T", p.Field, value)
+	}
+
+	for i, m := range p.matchers {
+		fields, ok, err := m.match(text)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		for field, v := range fields {
+			if _, err := event.PutValue(field, v); err != nil {
+				return fmt.Errorf("could not put value for key: %v: %w", field, err)
+			}
+		}
+		if p.TraceMatch {
+			_, _ = event.PutValue(matchIndexField, i)
+		}
+		return nil
+	}
+	return fmt.Errorf("provided grok patterns do not match field value: [%v]", text)
+}
+
+func mergePatterns(sets ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, set := range sets {
+		for name, def := range set {
+			merged[name] = def
+		}
+	}
+	return merged
+}
@@ -0,0 +1,196 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestGrok(t *testing.T) {
+	testCases := []struct {
+		description string
+		config      map[string]any
+		input       mapstr.M
+		output      mapstr.M
+		error       bool
+	}{
+		{
+			description: "standard library pattern with typed fields",
+			config: map[string]any{
+				"patterns": []string{"%{HTTPD_COMMONLOG}"},
+			},
+			input: mapstr.M{
+				"message": `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			},
+			output: mapstr.M{
+				"message":   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+				"source":    mapstr.M{"address": "127.0.0.1"},
+				"user":      mapstr.M{"name": "frank"},
+				"timestamp": "10/Oct/2000:13:55:36 -0700",
+				"http": mapstr.M{
+					"request":  mapstr.M{"method": "GET"},
+					"response": mapstr.M{"status_code": int32(200), "body": mapstr.M{"size": int64(2326)}},
+					"version":  "1.0",
+				},
+				"url": mapstr.M{"original": "/apache_pb.gif"},
+			},
+		},
+		{
+			description: "custom pattern definitions",
+			config: map[string]any{
+				"field":    "msg",
+				"patterns": []string{"%{SEVERITY:log.level} %{NUMBER:duration:float}ms"},
+				"pattern_definitions": map[string]string{
+					"SEVERITY": "(?:DEBUG|INFO|WARN|ERROR)",
+				},
+			},
+			input: mapstr.M{"msg": "WARN 12.5ms"},
+			output: mapstr.M{
+				"msg":      "WARN 12.5ms",
+				"log":      mapstr.M{"level": "WARN"},
+				"duration": float32(12.5),
+			},
+		},
+		{
+			description: "patterns are tried in order",
+			config: map[string]any{
+				"patterns": []string{
+					"user=%{USERNAME:user.name}",
+					"%{IP:source.ip} %{WORD:event.action}",
+					"%{IP:client.ip} %{GREEDYDATA:event.action}",
+				},
+				"trace_match": true,
+			},
+			input: mapstr.M{"message": "10.0.0.1 login"},
+			output: mapstr.M{
+				"message": "10.0.0.1 login",
+				"source":  mapstr.M{"ip": "10.0.0.1"},
+				"event":   mapstr.M{"action": "login"},
+				"grok":    mapstr.M{"match_index": 1},
+			},
+		},
+		{
+			description: "no match",
+			config: map[string]any{
+				"patterns": []string{"%{IP:source.ip}"},
+			},
+			input: mapstr.M{"message": "hello"},
+			output: mapstr.M{
+				"message": "hello",
+				"error":   mapstr.M{"message": "failed in grok processor: provided grok patterns do not match field value: [hello]"},
+			},
+			error: true,
+		},
+		{
+			description: "no match without fail_on_error",
+			config: map[string]any{
+				"patterns":      []string{"%{IP:source.ip}"},
+				"fail_on_error": false,
+			},
+			input:  mapstr.M{"message": "hello"},
+			output: mapstr.M{"message": "hello"},
+		},
+		{
+			description: "failed conversion leaves the event untouched",
+			config: map[string]any{
+				"patterns": []string{"%{WORD:event.action} %{WORD:count:int}"},
+			},
+			input: mapstr.M{"message": "login many"},
+			output: mapstr.M{
+				"message": "login many",
+				"error":   mapstr.M{"message": "failed in grok processor: unable to convert field 'count': strconv.ParseInt: parsing \"many\": invalid syntax"},
+			},
+			error: true,
+		},
+		{
+			description: "missing field",
+			config: map[string]any{
+				"patterns": []string{"%{IP:source.ip}"},
+			},
+			input: mapstr.M{"other": "hello"},
+			output: mapstr.M{
+				"other": "hello",
+				"error": mapstr.M{"message": "failed in grok processor: could not fetch value for key: message: key not found"},
+			},
+			error: true,
+		},
+		{
+			description: "ignore missing field",
+			config: map[string]any{
+				"patterns":       []string{"%{IP:source.ip}"},
+				"ignore_missing": true,
+			},
+			input:  mapstr.M{"other": "hello"},
+			output: mapstr.M{"other": "hello"},
+		},
+		{
+			description: "field is not a string",
+			config: map[string]any{
+				"patterns": []string{"%{IP:source.ip}"},
+			},
+			input: mapstr.M{"message": 42},
+			output: mapstr.M{
+				"message": 42,
+				"error":   mapstr.M{"message": "failed in grok processor: field 'message' is not a string but int"},
+			},
+			error: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.description, func(t *testing.T) {
+			p, err := New(conf.MustNewConfigFrom(test.config), logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+
+			event := &beat.Event{Fields: test.input}
+			newEvent, err := p.Run(event)
+			if test.error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.output, newEvent.Fields)
+		})
+	}
+}
+
+func TestGrokConfig(t *testing.T) {
+	testCases := map[string]map[string]any{
+		"missing patterns":  {"field": "message"},
+		"empty pattern":     {"patterns": []string{""}},
+		"unknown pattern":   {"patterns": []string{"%{NOT_A_PATTERN:foo}"}},
+		"invalid regexp":    {"patterns": []string{"(%{WORD:foo}"}},
+		"invalid type hint": {"patterns": []string{"%{WORD:foo:date}"}},
+		"empty field":       {"field": "", "patterns": []string{"%{WORD:foo}"}},
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
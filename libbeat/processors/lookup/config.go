@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	matchExact = "exact"
+	matchCIDR  = "cidr"
+)
+
+type config struct {
+	// File is the path of the table.
+	File string `config:"file" validate:"required"`
+
+	// Format is the format of the file, csv or ndjson. It is guessed from
+	// the file extension if not set.
+	Format string `config:"format"`
+
+	// Separator is the CSV field separator.
+	Separator string `config:"separator"`
+
+	// KeyColumn is the column of the table holding the keys.
+	KeyColumn string `config:"key_column" validate:"required"`
+
+	// KeyField is the event field matched against the keys.
+	KeyField string `config:"key_field" validate:"required"`
+
+	// Match is how the keys are matched, exact or cidr.
+	Match string `config:"match"`
+
+	// Columns are the columns copied to the event.
+	Columns []columnConfig `config:"columns" validate:"required"`
+
+	// ReloadInterval is how often the file is checked for changes.
+	ReloadInterval time.Duration `config:"reload_interval" validate:"nonzero,positive"`
+
+	IgnoreMissing bool   `config:"ignore_missing"`
+	OverwriteKeys bool   `config:"overwrite_keys"`
+	Tag           string `config:"tag"`
+}
+
+type columnConfig struct {
+	// From is the column of the table.
+	From string `config:"from" validate:"required"`
+
+	// To is the event field the value is copied to.
+	To string `config:"to" validate:"required"`
+}
+
+func defaultConfig() config {
+	return config{
+		Separator:      ",",
+		Match:          matchExact,
+		ReloadInterval: time.Minute,
+		IgnoreMissing:  true,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Format == "" {
+		switch strings.ToLower(filepath.Ext(c.File)) {
+		case ".csv":
+			c.Format = formatCSV
+		case ".ndjson", ".jsonl", ".json":
+			c.Format = formatNDJSON
+		default:
+			return fmt.Errorf("format is required for file %q", c.File)
+		}
+	}
+	switch c.Format {
+	case formatCSV, formatNDJSON:
+	default:
+		return fmt.Errorf("unknown format %q, must be %s or %s", c.Format, formatCSV, formatNDJSON)
+	}
+	if utf8.RuneCountInString(c.Separator) != 1 {
+		return errors.New("separator must be a single character")
+	}
+	switch c.Match {
+	case matchExact, matchCIDR:
+	default:
+		return fmt.Errorf("unknown match %q, must be %s or %s", c.Match, matchExact, matchCIDR)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	procName = "lookup"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique logging namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type lookup struct {
+	config config
+	log    *logp.Logger
+
+	// table is swapped when the file changes.
+	table   atomic.Pointer[table]
+	modTime time.Time
+	size    int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New constructs a new lookup processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	log = log.Named(logName).With("instance_id", instanceID.Add(1))
+	if c.Tag != "" {
+		log = log.With("tag", c.Tag)
+	}
+
+	p := &lookup{config: c, log: log, done: make(chan struct{})}
+	if err := p.load(); err != nil {
+		return nil, fmt.Errorf("failed to load the %v table: %w", procName, err)
+	}
+
+	p.wg.Add(1)
+	go p.reloadLoop()
+	return p, nil
+}
+
+// load loads the table if the file changed since it was last loaded.
+func (p *lookup) load() error {
+	info, err := os.Stat(p.config.File)
+	if err != nil {
+		return err
+	}
+	if p.table.Load() != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return nil
+	}
+	t, err := loadTable(p.config)
+	if err != nil {
+		return err
+	}
+	p.table.Store(t)
+	p.modTime, p.size = info.ModTime(), info.Size()
+
+	p.log.Infow("Loaded lookup table.", "path", p.config.File, "rows", t.rows)
+	if t.duplicates != 0 {
+		p.log.Warnw("Lookup table has duplicate keys, the last rows are used.", "path", p.config.File, "duplicates", t.duplicates)
+	}
+	return nil
+}
+
+// reloadLoop reloads the table when the file changes until the processor is
+// closed. The table in use is kept if the file can't be loaded.
+func (p *lookup) reloadLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		if err := p.load(); err != nil {
+			p.log.Warnw("Failed to reload lookup table, the previous version is kept.", "path", p.config.File, "error", err)
+		}
+	}
+}
+
+// Run copies the columns of the row matching the key field to the event.
+func (p *lookup) Run(event *beat.Event) (*beat.Event, error) {
+	key, err := event.GetValue(p.config.KeyField)
+	if err != nil {
+		if p.config.IgnoreMissing {
+			return event, nil
+		}
+		return event, fmt.Errorf("failed to get key field %q: %w", p.config.KeyField, err)
+	}
+
+	r := p.table.Load().lookup(key)
+	if r == nil {
+		return event, nil
+	}
+	for _, col := range p.config.Columns {
+		v, ok := r[col.From]
+		if !ok {
+			continue
+		}
+		if !p.config.OverwriteKeys {
+			if _, err := event.GetValue(col.To); err == nil {
+				continue
+			}
+		}
+		if _, err := event.PutValue(col.To, cloneValue(v)); err != nil {
+			return event, fmt.Errorf("failed to put column %q in field %q: %w", col.From, col.To, err)
+		}
+	}
+	return event, nil
+}
+
+// Close stops the reloading of the table.
+func (p *lookup) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	p.wg.Wait()
+	return nil
+}
+
+func (p *lookup) String() string {
+	columns := make([]string, len(p.config.Columns))
+	for i, c := range p.config.Columns {
+		columns[i] = c.From + ":" + c.To
+	}
+	return fmt.Sprintf("%v=[file=%v, format=%v, key_column=%v, key_field=%v, match=%v, columns=%v]",
+		procName, p.config.File, p.config.Format, p.config.KeyColumn, p.config.KeyField, p.config.Match, columns)
+}
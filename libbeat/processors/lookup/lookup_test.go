@@ -0,0 +1,280 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const assetsCSV = `host,owner,business_unit,criticality
+web-01,alice,sales,high
+db-01, bob,finance,critical
+,nobody,none,none
+`
+
+const networksNDJSON = `{"network": "10.0.0.0/8", "owner": "it", "tags": ["internal"]}
+{"network": "10.1.0.0/16", "owner": "dev", "site": {"name": "lab"}}
+{"network": "10.1.2.3", "owner": "alice"}
+{"network": "2001:db8::/32", "owner": "v6"}
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func newProcessor(t *testing.T, config map[string]any) *lookup {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.(*lookup).Close() }) //nolint:errcheck // New always returns a lookup.
+	return p.(*lookup)                            //nolint:errcheck // New always returns a lookup.
+}
+
+func TestLookupExact(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"file":       writeFile(t, "assets.csv", assetsCSV),
+		"key_column": "host",
+		"key_field":  "host.name",
+		"columns": []map[string]any{
+			{"from": "owner", "to": "asset.owner"},
+			{"from": "business_unit", "to": "asset.business_unit"},
+			{"from": "criticality", "to": "asset.criticality"},
+			{"from": "missing", "to": "asset.missing"},
+		},
+	})
+
+	testCases := map[string]struct {
+		in, want mapstr.M
+	}{
+		"match": {
+			in: mapstr.M{"host": mapstr.M{"name": "db-01"}},
+			want: mapstr.M{
+				"host":  mapstr.M{"name": "db-01"},
+				"asset": mapstr.M{"owner": "bob", "business_unit": "finance", "criticality": "critical"},
+			},
+		},
+		"no match": {
+			in:   mapstr.M{"host": mapstr.M{"name": "web-02"}},
+			want: mapstr.M{"host": mapstr.M{"name": "web-02"}},
+		},
+		"empty key is not a key": {
+			in:   mapstr.M{"host": mapstr.M{"name": ""}},
+			want: mapstr.M{"host": mapstr.M{"name": ""}},
+		},
+		"missing key field": {
+			in:   mapstr.M{"message": "hello"},
+			want: mapstr.M{"message": "hello"},
+		},
+		"existing field is kept": {
+			in: mapstr.M{"host": mapstr.M{"name": "web-01"}, "asset": mapstr.M{"owner": "carol"}},
+			want: mapstr.M{
+				"host":  mapstr.M{"name": "web-01"},
+				"asset": mapstr.M{"owner": "carol", "business_unit": "sales", "criticality": "high"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out, err := p.Run(&beat.Event{Fields: tc.in})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, out.Fields)
+		})
+	}
+}
+
+func TestLookupCIDR(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"file":           writeFile(t, "networks.ndjson", networksNDJSON),
+		"key_column":     "network",
+		"key_field":      "source.ip",
+		"match":          "cidr",
+		"overwrite_keys": true,
+		"columns": []map[string]any{
+			{"from": "owner", "to": "network.owner"},
+			{"from": "site", "to": "network.site"},
+			{"from": "tags", "to": "network.tags"},
+		},
+	})
+
+	testCases := map[string]any{
+		"10.1.2.3":        mapstr.M{"owner": "alice"},
+		"::ffff:10.1.2.3": mapstr.M{"owner": "alice"},
+		"10.1.9.9":        mapstr.M{"owner": "dev", "site": mapstr.M{"name": "lab"}},
+		"10.200.0.1":      mapstr.M{"owner": "it", "tags": []any{"internal"}},
+		"2001:db8::1":     mapstr.M{"owner": "v6"},
+		"192.168.0.1":     nil,
+		"not an ip":       nil,
+	}
+	for ip, want := range testCases {
+		t.Run(ip, func(t *testing.T) {
+			out, err := p.Run(&beat.Event{Fields: mapstr.M{
+				"source":  mapstr.M{"ip": ip},
+				"network": mapstr.M{"owner": "overwritten"},
+			}})
+			require.NoError(t, err)
+			got, _ := out.GetValue("network")
+			if want == nil {
+				want = mapstr.M{"owner": "overwritten"}
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("net.IP", func(t *testing.T) {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": net.ParseIP("10.1.2.3")}}})
+		require.NoError(t, err)
+		owner, _ := out.GetValue("network.owner")
+		assert.Equal(t, "alice", owner)
+	})
+
+	t.Run("values are copied", func(t *testing.T) {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "10.1.9.9"}}})
+		require.NoError(t, err)
+		_, err = out.PutValue("network.site.name", "changed")
+		require.NoError(t, err)
+
+		out, err = p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "10.1.9.9"}}})
+		require.NoError(t, err)
+		name, _ := out.GetValue("network.site.name")
+		assert.Equal(t, "lab", name)
+	})
+}
+
+func TestLookupNumericKeys(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"file":       writeFile(t, "users.ndjson", `{"uid": 1000000, "name": "alice"}`+"\n"+`{"uid": 2.5, "name": "bob"}`),
+		"key_column": "uid",
+		"key_field":  "user.id",
+		"columns":    []map[string]any{{"from": "name", "to": "user.name"}},
+	})
+
+	testCases := map[string]struct {
+		id   any
+		want any
+	}{
+		"int":          {1000000, "alice"},
+		"float":        {float64(1000000), "alice"},
+		"string":       {"1000000", "alice"},
+		"fraction":     {2.5, "bob"},
+		"not exponent": {"1e+06", nil},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out, err := p.Run(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"id": tc.id}}})
+			require.NoError(t, err)
+			got, _ := out.GetValue("user.name")
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"file":           writeFile(t, "assets.csv", assetsCSV),
+		"key_column":     "host",
+		"key_field":      "host.name",
+		"ignore_missing": false,
+		"columns":        []map[string]any{{"from": "owner", "to": "asset.owner"}},
+	})
+
+	_, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+	assert.ErrorContains(t, err, `failed to get key field "host.name"`)
+}
+
+func TestLookupReload(t *testing.T) {
+	path := writeFile(t, "assets.csv", assetsCSV)
+	p := newProcessor(t, map[string]any{
+		"file":            path,
+		"key_column":      "host",
+		"key_field":       "host.name",
+		"reload_interval": "10ms",
+		"columns":         []map[string]any{{"from": "owner", "to": "asset.owner"}},
+	})
+
+	owner := func() any {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"name": "web-01"}}})
+		require.NoError(t, err)
+		v, _ := out.GetValue("asset.owner")
+		return v
+	}
+	require.Equal(t, "alice", owner())
+
+	// A file that can't be loaded keeps the previous table.
+	require.NoError(t, os.WriteFile(path, []byte("host,owner\n\"web-01,broken\n"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, "alice", owner())
+
+	require.NoError(t, os.WriteFile(path, []byte("host,owner\nweb-01,carol\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	assert.Eventually(t, func() bool { return owner() == "carol" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLookupConfig(t *testing.T) {
+	csvFile := writeFile(t, "assets.csv", assetsCSV)
+	valid := func(overrides map[string]any) map[string]any {
+		config := map[string]any{
+			"file":       csvFile,
+			"key_column": "host",
+			"key_field":  "host.name",
+			"columns":    []map[string]any{{"from": "owner", "to": "asset.owner"}},
+		}
+		for k, v := range overrides {
+			config[k] = v
+		}
+		return config
+	}
+
+	testCases := map[string]map[string]any{
+		"missing file":          valid(map[string]any{"file": filepath.Join(t.TempDir(), "missing.csv")}),
+		"unknown extension":     valid(map[string]any{"file": writeFile(t, "assets.txt", assetsCSV)}),
+		"unknown format":        valid(map[string]any{"format": "xml"}),
+		"long separator":        valid(map[string]any{"separator": "||"}),
+		"unknown match":         valid(map[string]any{"match": "prefix"}),
+		"invalid CIDR":          valid(map[string]any{"match": "cidr"}),
+		"invalid CSV":           valid(map[string]any{"file": writeFile(t, "bad.csv", "host,owner\nweb-01\n")}),
+		"empty CSV":             valid(map[string]any{"file": writeFile(t, "empty.csv", "")}),
+		"invalid NDJSON":        valid(map[string]any{"file": writeFile(t, "bad.ndjson", "{\"host\": \n")}),
+		"missing key column":    valid(map[string]any{"key_column": ""}),
+		"missing columns":       valid(map[string]any{"columns": nil}),
+		"column without target": valid(map[string]any{"columns": []map[string]any{{"from": "owner"}}}),
+		"zero reload interval":  valid(map[string]any{"reload_interval": 0}),
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
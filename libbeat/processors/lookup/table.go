@@ -0,0 +1,287 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// row is a row of a table, by column name.
+type row map[string]any
+
+// table is a keyed table loaded from a file. It is not modified once
+// loaded.
+type table struct {
+	exact map[string]row
+
+	// networks are the rows keyed by network. lens4 and lens6 are the
+	// prefix lengths of the IPv4 and IPv6 networks, from the longest, so
+	// the most specific network is matched first.
+	networks     map[netip.Prefix]row
+	lens4, lens6 []int
+
+	// rows is the number of rows with a key, and duplicates the number of
+	// those whose key was already in the table.
+	rows, duplicates int
+}
+
+// loadTable loads the table in the file of the config.
+func loadTable(c config) (*table, error) {
+	f, err := os.Open(c.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := &table{}
+	if c.Match == matchCIDR {
+		t.networks = map[netip.Prefix]row{}
+	} else {
+		t.exact = map[string]row{}
+	}
+	add := func(r row) error {
+		return t.add(c, r)
+	}
+
+	switch c.Format {
+	case formatCSV:
+		err = readCSV(f, []rune(c.Separator)[0], add) // Validated by config.
+	default:
+		err = readNDJSON(f, add)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.File, err)
+	}
+
+	slices.Sort(t.lens4)
+	slices.Reverse(t.lens4)
+	slices.Sort(t.lens6)
+	slices.Reverse(t.lens6)
+	return t, nil
+}
+
+// add adds r to the table. Rows without a key are ignored.
+func (t *table) add(c config, r row) error {
+	k, ok := r[c.KeyColumn]
+	if !ok || k == nil || k == "" {
+		return nil
+	}
+	key, ok := keyString(k)
+	if !ok {
+		key = fmt.Sprint(k)
+	}
+	t.rows++
+
+	if t.exact != nil {
+		if _, dup := t.exact[key]; dup {
+			t.duplicates++
+		}
+		t.exact[key] = r
+		return nil
+	}
+
+	prefix, err := parsePrefix(key)
+	if err != nil {
+		return err
+	}
+	if _, dup := t.networks[prefix]; dup {
+		t.duplicates++
+	}
+	t.networks[prefix] = r
+	lens := &t.lens6
+	if prefix.Addr().Is4() {
+		lens = &t.lens4
+	}
+	if !slices.Contains(*lens, prefix.Bits()) {
+		*lens = append(*lens, prefix.Bits())
+	}
+	return nil
+}
+
+// parsePrefix parses a CIDR, or an IP address as the network of only this
+// address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if p.Addr().Is4In6() && p.Bits() >= 96 {
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// lookup returns the row matching the key value v, or nil.
+func (t *table) lookup(v any) row {
+	if t.exact != nil {
+		if s, ok := keyString(v); ok {
+			return t.exact[s]
+		}
+		return nil
+	}
+
+	var addr netip.Addr
+	switch v := v.(type) {
+	case string:
+		addr, _ = netip.ParseAddr(v)
+	case net.IP:
+		addr, _ = netip.AddrFromSlice(v)
+	case netip.Addr:
+		addr = v
+	}
+	if !addr.IsValid() {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	lens := t.lens6
+	if addr.Is4() {
+		lens = t.lens4
+	}
+	for _, bits := range lens {
+		prefix, _ := addr.Prefix(bits)
+		if r, ok := t.networks[prefix]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// keyString returns the string of a scalar key value. Floats are formatted
+// without exponent, so whole numbers decoded from JSON match integers.
+func keyString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, net.IP:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// readCSV reads a CSV file whose first record is the header with the
+// column names.
+func readCSV(r io.Reader, sep rune, add func(row) error) error {
+	cr := csv.NewReader(r)
+	cr.Comma = sep
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("missing CSV header")
+		}
+		return err
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r := make(row, len(header))
+		for i, name := range header {
+			r[name] = record[i]
+		}
+		if err := add(r); err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// readNDJSON reads a file of JSON objects, one per line.
+func readNDJSON(r io.Reader, add func(row) error) error {
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var obj map[string]any
+		err := dec.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("object %d: %w", n, err)
+		}
+		r := make(row, len(obj))
+		for k, v := range obj {
+			r[k] = toMapStr(v)
+		}
+		if err := add(r); err != nil {
+			return fmt.Errorf("object %d: %w", n, err)
+		}
+	}
+}
+
+// toMapStr converts the JSON objects in v to mapstr.M.
+func toMapStr(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(mapstr.M, len(v))
+		for k, e := range v {
+			m[k] = toMapStr(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = toMapStr(e)
+		}
+	}
+	return v
+}
+
+// cloneValue returns a deep copy of a table value, so that events don't
+// share the values of the table.
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case mapstr.M:
+		return v.Clone()
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = cloneValue(e)
+		}
+		return c
+	}
+	return v
+}
@@ -45,6 +45,17 @@ type Closer interface {
 	Close() error
 }
 
+// Flusher defines the interface for processors that hold back events, like
+// the aggregate processor. Flush returns the events held back that are ready
+// to be published, like the summaries of closed windows, or all of them if
+// final is set, before the processor is closed. HoldsEvents reports whether
+// Flush needs to be called at all, processors wrapping others report it for
+// the wrapped processors.
+type Flusher interface {
+	Flush(final bool) []*beat.Event
+	HoldsEvents() bool
+}
+
 // PathSetter is an interface for processors that support lazy initialization
 // with beat-specific paths. This method must be called before the processor can be used.
 type PathSetter interface {
@@ -77,6 +88,22 @@ func Close(p beat.Processor) error {
 	return nil
 }
 
+// Flush returns the events held back by a processor if it implements the
+// Flusher interface.
+func Flush(p beat.Processor, final bool) []*beat.Event {
+	if flusher, ok := p.(Flusher); ok {
+		return flusher.Flush(final)
+	}
+	return nil
+}
+
+// HoldsEvents reports whether a processor holds back events that must be
+// collected with Flush.
+func HoldsEvents(p beat.Processor) bool {
+	flusher, ok := p.(Flusher)
+	return ok && flusher.HoldsEvents()
+}
+
 // NewList creates a new empty processor list.
 // Additional processors can be added to the List field.
 func NewList(log *logp.Logger) *Processors {
@@ -189,12 +216,45 @@ func (procs *Processors) Close() error {
 	return errors.Join(errs...)
 }
 
+// Flush returns the events held back by the processors in the list, after
+// running them through the processors following the one that held them back.
+func (procs *Processors) Flush(final bool) []*beat.Event {
+	var flushed []*beat.Event
+	for i, p := range procs.List {
+		for _, event := range Flush(p, final) {
+			event, err := runList(procs.List[i+1:], event)
+			if err != nil {
+				procs.log.Debugf("Fail to apply processors to flushed event: %s", err)
+			}
+			if event != nil {
+				flushed = append(flushed, event)
+			}
+		}
+	}
+	return flushed
+}
+
+// HoldsEvents reports whether any of the processors in the list holds back
+// events.
+func (procs *Processors) HoldsEvents() bool {
+	for _, p := range procs.List {
+		if HoldsEvents(p) {
+			return true
+		}
+	}
+	return false
+}
+
 // Run executes the all processors serially and returns the event and possibly
 // an error. If the event has been dropped (canceled) by a processor in the
 // list then a nil event is returned.
 func (procs *Processors) Run(event *beat.Event) (*beat.Event, error) {
+	return runList(procs.List, event)
+}
+
+func runList(list []beat.Processor, event *beat.Event) (*beat.Event, error) {
 	var err error
-	for _, p := range procs.List {
+	for _, p := range list {
 		event, err = p.Run(event)
 		if err != nil {
 			return event, fmt.Errorf("failed applying processor %v: %w", p, err)
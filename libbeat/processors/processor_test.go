@@ -788,3 +788,42 @@ func generateFields(t require.TestingT, m mapstr.M, count, nesting int) {
 		require.NoError(t, err)
 	}
 }
+
+func TestFlush(t *testing.T) {
+	held := &beat.Event{Fields: mapstr.M{"held": true}}
+	list := processors.NewList(logptest.NewTestingLogger(t, ""))
+	list.AddProcessor(&flushProcessor{held: []*beat.Event{held}})
+	list.AddProcessor(&fnProcessor{fn: func(event *beat.Event) (*beat.Event, error) {
+		_, err := event.PutValue("after", true)
+		return event, err
+	}})
+
+	assert.True(t, list.HoldsEvents())
+	flushed := list.Flush(true)
+	require.Len(t, flushed, 1)
+	assert.Equal(t, mapstr.M{"held": true, "after": true}, flushed[0].Fields)
+	assert.Empty(t, list.Flush(true))
+
+	assert.False(t, processors.NewList(logptest.NewTestingLogger(t, "")).HoldsEvents())
+}
+
+// flushProcessor drops all events and returns held on Flush.
+type flushProcessor struct {
+	held []*beat.Event
+}
+
+func (p *flushProcessor) String() string                       { return "flush" }
+func (p *flushProcessor) Run(*beat.Event) (*beat.Event, error) { return nil, nil }
+func (p *flushProcessor) Flush(bool) []*beat.Event {
+	held := p.held
+	p.held = nil
+	return held
+}
+func (p *flushProcessor) HoldsEvents() bool { return true }
+
+type fnProcessor struct {
+	fn func(*beat.Event) (*beat.Event, error)
+}
+
+func (p *fnProcessor) String() string                             { return "fn" }
+func (p *fnProcessor) Run(event *beat.Event) (*beat.Event, error) { return p.fn(event) }
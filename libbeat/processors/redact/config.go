@@ -0,0 +1,113 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+)
+
+const (
+	actionMask = "mask"
+	actionHash = "hash"
+	actionDrop = "drop"
+)
+
+type config struct {
+	// Fields are the fields to redact. Objects are redacted recursively.
+	Fields []string `config:"fields"`
+
+	// ExcludeFields are the fields that are never redacted, including the
+	// fields they contain.
+	ExcludeFields []string `config:"exclude_fields"`
+
+	// Detectors are the detectors applied in order. All the built-in
+	// detectors are applied if not set.
+	Detectors []detectorConfig `config:"detectors"`
+
+	Tag string `config:"tag"`
+}
+
+type detectorConfig struct {
+	// Name is the name of a built-in detector, or of a custom detector
+	// if Pattern is set.
+	Name string `config:"name" validate:"required"`
+
+	// Pattern is the regular expression of a custom detector.
+	Pattern string `config:"pattern"`
+
+	// Action is what is done with the detected values: mask, hash or
+	// drop the field.
+	Action string `config:"action"`
+
+	// Mask replaces the detected values with the mask action.
+	Mask string `config:"mask"`
+
+	// HashMethod is the fingerprint hash method of the hash action.
+	HashMethod string `config:"hash_method"`
+}
+
+func defaultConfig() config {
+	return config{
+		Fields: []string{"message"},
+	}
+}
+
+func (c *config) Validate() error {
+	if len(c.Fields) == 0 {
+		return errors.New("at least one field is required")
+	}
+	if slices.Contains(c.Fields, "") {
+		return errors.New("field names must not be empty")
+	}
+	seen := map[string]bool{}
+	for _, d := range c.Detectors {
+		if seen[d.Name] {
+			return fmt.Errorf("detector %q is configured more than once", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	return nil
+}
+
+func (c *detectorConfig) Validate() error {
+	if c.Pattern != "" {
+		if _, err := regexp.Compile(c.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern of detector %q: %w", c.Name, err)
+		}
+	} else if !slices.ContainsFunc(builtinDetectors, func(b namedDetector) bool { return b.name == c.Name }) {
+		return fmt.Errorf("unknown detector %q, a pattern is required for custom detectors", c.Name)
+	}
+
+	switch c.Action {
+	case "", actionMask, actionDrop:
+	case actionHash:
+		if c.HashMethod != "" {
+			if _, err := fingerprint.HashMethod(c.HashMethod); err != nil {
+				return fmt.Errorf("detector %q: %w", c.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown action %q of detector %q, must be one of %s, %s or %s", c.Action, c.Name, actionMask, actionHash, actionDrop)
+	}
+	return nil
+}
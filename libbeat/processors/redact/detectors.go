@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// detector finds sensitive values in text.
+type detector struct {
+	re *regexp.Regexp
+	// valid, if not nil, filters out the matches of re that are not
+	// sensitive values, like numbers failing a checksum.
+	valid func(string) bool
+	// separators are the characters separating the groups of a value. If a
+	// match is not valid, the spans of it delimited by separators are
+	// checked, as the pattern also matches groups around the value, like
+	// the expiry date after a card number.
+	separators string
+}
+
+// find returns the start and end of the longest valid span of the match.
+// ok is false if there is none.
+func (d *detector) find(match string) (start, end int, ok bool) {
+	if d.valid == nil || d.valid(match) {
+		return 0, len(match), true
+	}
+	if d.separators == "" {
+		return 0, 0, false
+	}
+
+	starts := []int{0}
+	ends := []int{len(match)}
+	for i := range len(match) {
+		if strings.IndexByte(d.separators, match[i]) >= 0 {
+			starts = append(starts, i+1)
+			ends = append(ends, i)
+		}
+	}
+	best := -1
+	for _, s := range starts {
+		for _, e := range ends {
+			if e-s > best && d.valid(match[s:e]) {
+				start, end, best = s, e, e-s
+			}
+		}
+	}
+	return start, end, best >= 0
+}
+
+type namedDetector struct {
+	name     string
+	detector detector
+}
+
+// builtinDetectors are the detectors available by name, in the order they
+// are applied by default.
+var builtinDetectors = []namedDetector{
+	{"jwt", detector{
+		re:    regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		valid: validJWT,
+	}},
+	{"api_key", detector{
+		re: regexp.MustCompile(`\b(?:` + strings.Join([]string{
+			`(?:AKIA|ASIA)[0-9A-Z]{16}`,            // AWS access key IDs
+			`gh[pousr]_[A-Za-z0-9]{36}`,            // GitHub tokens
+			`github_pat_[A-Za-z0-9_]{82}`,          // GitHub fine-grained tokens
+			`xox[abposr]-[A-Za-z0-9-]{10,}`,        // Slack tokens
+			`[sr]k_(?:live|test)_[A-Za-z0-9]{16,}`, // Stripe keys
+			`AIza[0-9A-Za-z_-]{35}`,                // Google API keys
+			`glpat-[A-Za-z0-9_-]{20}`,              // GitLab tokens
+		}, "|") + `)\b`),
+	}},
+	{"email", detector{
+		re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`),
+	}},
+	{"iban", detector{
+		re:         regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]){11,30}\b`),
+		valid:      validIBAN,
+		separators: " ",
+	}},
+	{"credit_card", detector{
+		re:         regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`),
+		valid:      validPAN,
+		separators: " -",
+	}},
+}
+
+// validPAN reports whether s is a payment card number of valid length that
+// passes the Luhn check.
+func validPAN(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range len(digits) {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether s is an IBAN of valid length that passes the
+// ISO 7064 mod 97 check.
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 || !isIBANPrefix(iban) {
+		return false
+	}
+	// Move the country code and check digits to the end, and compute the
+	// remainder with the letters replaced by numbers, A being 10.
+	rem := 0
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			rem = (rem*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			rem = (rem*100 + int(r-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// isIBANPrefix reports whether s starts with a country code and check digits.
+func isIBANPrefix(s string) bool {
+	isLetter := func(b byte) bool { return b >= 'A' && b <= 'Z' }
+	isDigit := func(b byte) bool { return b >= '0' && b <= '9' }
+	return isLetter(s[0]) && isLetter(s[1]) && isDigit(s[2]) && isDigit(s[3])
+}
+
+// validJWT reports whether the header of the JWT s is a JSON object with
+// an alg claim.
+func validJWT(s string) bool {
+	header, _, _ := strings.Cut(s, ".")
+	b, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+	var h struct {
+		Alg *string `json:"alg"`
+	}
+	return json.Unmarshal(b, &h) == nil && h.Alg != nil
+}
@@ -0,0 +1,267 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+const (
+	procName = "redact"
+	logName  = "processor." + procName
+
+	defaultMask       = "[REDACTED]"
+	defaultHashMethod = "sha256"
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type redact struct {
+	config    config
+	log       *logp.Logger
+	detectors []*activeDetector
+}
+
+// activeDetector is a detector with its configured action.
+type activeDetector struct {
+	detector
+	name   string
+	action string
+	mask   string
+	hash   func() hash.Hash
+	// redactions counts the detected values.
+	redactions *monitoring.Int
+}
+
+// New constructs a new redact processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	detectors := c.Detectors
+	if len(detectors) == 0 {
+		for _, b := range builtinDetectors {
+			detectors = append(detectors, detectorConfig{Name: b.name})
+		}
+	}
+
+	var (
+		id  = int(instanceID.Add(1))
+		reg = monitoring.Default.GetOrCreateRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+	redactions := reg.GetOrCreateRegistry("redactions")
+
+	log = log.Named(logName).With("instance_id", id)
+	if c.Tag != "" {
+		log = log.With("tag", c.Tag)
+	}
+	p := &redact{config: c, log: log}
+	for _, dc := range detectors {
+		d, err := newDetector(dc)
+		if err != nil {
+			return nil, err
+		}
+		d.redactions = monitoring.NewInt(redactions, dc.Name)
+		p.detectors = append(p.detectors, d)
+	}
+	return p, nil
+}
+
+func newDetector(c detectorConfig) (*activeDetector, error) {
+	d := &activeDetector{
+		name:   c.Name,
+		action: c.Action,
+		mask:   c.Mask,
+	}
+	if c.Pattern != "" {
+		d.re = regexp.MustCompile(c.Pattern) // Validated by config.
+	} else {
+		for _, b := range builtinDetectors {
+			if b.name == c.Name {
+				d.detector = b.detector
+			}
+		}
+	}
+	if d.action == "" {
+		d.action = actionMask
+	}
+	if d.mask == "" {
+		d.mask = defaultMask
+	}
+	if d.action == actionHash {
+		method := c.HashMethod
+		if method == "" {
+			method = defaultHashMethod
+		}
+		h, err := fingerprint.HashMethod(method)
+		if err != nil {
+			return nil, fmt.Errorf("detector %q: %w", c.Name, err)
+		}
+		d.hash = h
+	}
+	return d, nil
+}
+
+// Run redacts the sensitive values of the configured fields.
+func (p *redact) Run(event *beat.Event) (*beat.Event, error) {
+	for _, field := range p.config.Fields {
+		if p.excluded(field) {
+			continue
+		}
+		value, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+
+		redacted, drop := p.redactValue(field, value)
+		if drop {
+			_ = event.Delete(field)
+			continue
+		}
+		switch value.(type) {
+		case mapstr.M, map[string]any:
+			// Redacted in place.
+		default:
+			if _, err := event.PutValue(field, redacted); err != nil {
+				return event, fmt.Errorf("failed to put redacted value of field %q: %w", field, err)
+			}
+		}
+	}
+	return event, nil
+}
+
+// redactValue returns the redacted value of the field at path, and whether
+// the field must be dropped. Objects are redacted in place.
+func (p *redact) redactValue(path string, value any) (redacted any, drop bool) {
+	switch v := value.(type) {
+	case string:
+		return p.redactString(v)
+	case mapstr.M:
+		p.redactMap(path, v)
+	case map[string]any:
+		p.redactMap(path, v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			r, drop := p.redactString(s)
+			if drop {
+				return nil, true
+			}
+			out[i] = r
+		}
+		return out, false
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			r, drop := p.redactValue(path, e)
+			if drop {
+				return nil, true
+			}
+			out[i] = r
+		}
+		return out, false
+	}
+	return value, false
+}
+
+func (p *redact) redactMap(path string, m map[string]any) {
+	for k, v := range m {
+		sub := path + "." + k
+		if p.excluded(sub) {
+			continue
+		}
+		redacted, drop := p.redactValue(sub, v)
+		if drop {
+			delete(m, k)
+			continue
+		}
+		m[k] = redacted
+	}
+}
+
+// redactString applies the detectors to s. drop is true if a detector with
+// the drop action found a value.
+func (p *redact) redactString(s string) (redacted string, drop bool) {
+	for _, d := range p.detectors {
+		found := false
+		s = d.re.ReplaceAllStringFunc(s, func(match string) string {
+			start, end, ok := d.find(match)
+			if !ok {
+				return match
+			}
+			found = true
+			d.redactions.Inc()
+			value := match[start:end]
+			switch d.action {
+			case actionHash:
+				h := d.hash()
+				h.Write([]byte(value))
+				value = hex.EncodeToString(h.Sum(nil))
+			case actionDrop:
+			default:
+				value = d.mask
+			}
+			return match[:start] + value + match[end:]
+		})
+		if found && d.action == actionDrop {
+			return "", true
+		}
+	}
+	return s, false
+}
+
+// excluded reports whether the field is in the exclude list, or is
+// contained by an excluded field.
+func (p *redact) excluded(field string) bool {
+	for _, ex := range p.config.ExcludeFields {
+		if field == ex || strings.HasPrefix(field, ex+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *redact) String() string {
+	names := make([]string, len(p.detectors))
+	for i, d := range p.detectors {
+		names[i] = d.name + ":" + d.action
+	}
+	return fmt.Sprintf("%v=[fields=[%v],exclude_fields=[%v],detectors=[%v]]",
+		procName, p.config.Fields, p.config.ExcludeFields, strings.Join(names, ","))
+}
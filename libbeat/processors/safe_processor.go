@@ -92,6 +92,22 @@ func (p *SafeProcessor) Run(event *beat.Event) (*beat.Event, error) {
 	return p.Processor.Run(event)
 }
 
+// Flush delegates to the underlying processor if it implements Flusher.
+// Returns no events if the processor has been closed.
+func (p *SafeProcessor) Flush(final bool) []*beat.Event {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.state == stateClosed {
+		return nil
+	}
+	return Flush(p.Processor, final)
+}
+
+// HoldsEvents delegates to the underlying processor if it implements Flusher.
+func (p *SafeProcessor) HoldsEvents() bool {
+	return HoldsEvents(p.Processor)
+}
+
 // SetPaths delegates to the underlying processor if it implements PathSetter.
 // Returns ErrPathsAlreadySet if called more than once, or ErrSetPathsOnClosed
 // if the processor has been closed.
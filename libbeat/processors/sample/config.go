@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	modeRandom   = "random"
+	modeHash     = "hash"
+	modeAdaptive = "adaptive"
+)
+
+type config struct {
+	// Mode is the sampling mode, one of random, hash or adaptive.
+	Mode string `config:"mode"`
+
+	// Rate is the probability of keeping an event in the random and hash
+	// modes.
+	Rate float64 `config:"rate"`
+
+	// KeyFields are the fields whose values are hashed in the hash mode,
+	// and which group the events sampled together in the adaptive mode.
+	KeyFields []string `config:"key_fields"`
+
+	// TargetRate is the number of events per second to keep for each key
+	// in the adaptive mode.
+	TargetRate float64 `config:"target_rate"`
+
+	// Interval is how often the probabilities of the adaptive mode are
+	// adjusted.
+	Interval time.Duration `config:"interval" validate:"nonzero,positive"`
+
+	// Capacity is the maximum number of keys tracked in the adaptive mode.
+	Capacity int `config:"capacity" validate:"min=1"`
+
+	// TargetField is where the sample rate of kept events is written.
+	TargetField string `config:"target_field"`
+}
+
+func defaultConfig() config {
+	return config{
+		Mode:        modeRandom,
+		Interval:    time.Minute,
+		Capacity:    10000,
+		TargetField: "sample_rate",
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Mode {
+	case modeRandom, modeHash:
+		if c.Rate <= 0 || c.Rate > 1 {
+			return fmt.Errorf("rate must be greater than 0 and at most 1 in %s mode", c.Mode)
+		}
+		if c.Mode == modeHash && len(c.KeyFields) == 0 {
+			return errors.New("key_fields is required in hash mode")
+		}
+	case modeAdaptive:
+		if c.TargetRate <= 0 {
+			return errors.New("target_rate must be greater than 0 in adaptive mode")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q, must be one of %s, %s or %s", c.Mode, modeRandom, modeHash, modeAdaptive)
+	}
+	if c.TargetField == "" {
+		return errors.New("target_field is required")
+	}
+	return nil
+}
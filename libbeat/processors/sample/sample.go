@@ -0,0 +1,195 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/jonboulle/clockwork"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/cache"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	procName = "sample"
+	logName  = "processor." + procName
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+// sample keeps a fraction of the events and adds the inverse of the
+// probability of keeping them, so downstream aggregations can re-weight
+// the kept events.
+type sample struct {
+	config config
+	log    *logp.Logger
+
+	// random returns a number in [0, 1).
+	random func() float64
+	clock  clockwork.Clock
+
+	mu sync.Mutex
+	// keys holds the state of each key in the adaptive mode.
+	keys *cache.MemStore
+}
+
+// keyState is the state of a key in the adaptive mode.
+type keyState struct {
+	// count is the number of events seen in the current interval.
+	count int64
+	// start is the start of the current interval.
+	start int64
+	// probability is the probability of keeping an event in the current
+	// interval, computed from the count of the previous interval.
+	probability float64
+}
+
+// New constructs a new sample processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	p := &sample{
+		config: c,
+		log:    log.Named(logName),
+		random: rand.Float64,
+		clock:  clockwork.NewRealClock(),
+	}
+	if c.Mode == modeAdaptive {
+		// Keys that are not seen for a while are forgotten, and start
+		// over with all their events kept.
+		p.keys = cache.NewMemStore(procName, 2*c.Interval, c.Capacity, nil)
+	}
+	return p, nil
+}
+
+// Run keeps the event with the probability of its sampling mode and adds the
+// sample rate to it, or drops it.
+func (p *sample) Run(event *beat.Event) (*beat.Event, error) {
+	var probability float64
+	switch p.config.Mode {
+	case modeRandom:
+		if p.random() >= p.config.Rate {
+			return nil, nil
+		}
+		probability = p.config.Rate
+
+	case modeHash:
+		key, err := p.key(event)
+		if err != nil {
+			return event, err
+		}
+		// Map the hash to [0, 1) so events with the same key are
+		// consistently kept or dropped.
+		if float64(xxhash.Sum64String(key)>>11)/(1<<53) >= p.config.Rate {
+			return nil, nil
+		}
+		probability = p.config.Rate
+
+	case modeAdaptive:
+		key, err := p.key(event)
+		if err != nil {
+			return event, err
+		}
+		probability = p.adaptiveProbability(key)
+		if p.random() >= probability {
+			return nil, nil
+		}
+	}
+
+	if _, err := event.PutValue(p.config.TargetField, 1/probability); err != nil {
+		return event, fmt.Errorf("failed to set %v: %w", p.config.TargetField, err)
+	}
+	return event, nil
+}
+
+// adaptiveProbability counts the event for the key and returns the
+// probability of keeping it. The probability of each interval is chosen so
+// that the number of events kept in the previous interval would have
+// matched the target rate.
+func (p *sample) adaptiveProbability(key string) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now().UnixNano()
+	var state *keyState
+	if v, err := p.keys.Get(key); err == nil {
+		state = v.(*keyState) //nolint:errcheck // The store only holds key states.
+	} else {
+		state = &keyState{start: now, probability: 1}
+		_ = p.keys.Put(key, state)
+	}
+
+	if elapsed := now - state.start; elapsed >= int64(p.config.Interval) {
+		target := p.config.TargetRate * p.config.Interval.Seconds()
+		state.probability = math.Min(1, target/float64(state.count))
+		state.count = 0
+		state.start = now
+		// Refresh the key, so it is forgotten only when not seen for a
+		// whole interval.
+		_ = p.keys.Put(key, state)
+	}
+	state.count++
+	return state.probability
+}
+
+// key returns the key of the event. Missing key fields have an empty value.
+func (p *sample) key(event *beat.Event) (string, error) {
+	var b strings.Builder
+	for _, field := range p.config.KeyFields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			if !errors.Is(err, mapstr.ErrKeyNotFound) {
+				return "", fmt.Errorf("error getting value of field '%v': %w", field, err)
+			}
+			v = ""
+		}
+		fmt.Fprintf(&b, "%v\x00", v)
+	}
+	return b.String(), nil
+}
+
+// Unshareable opts sample out of process-wide processor sharing, so the
+// adaptive mode targets the rate of each owner.
+func (p *sample) Unshareable() {}
+
+func (p *sample) String() string {
+	switch p.config.Mode {
+	case modeAdaptive:
+		return fmt.Sprintf("%v=[mode=[%v],key_fields=[%v],target_rate=[%v],interval=[%v]]",
+			procName, p.config.Mode, p.config.KeyFields, p.config.TargetRate, p.config.Interval)
+	default:
+		return fmt.Sprintf("%v=[mode=[%v],rate=[%v],key_fields=[%v]]",
+			procName, p.config.Mode, p.config.Rate, p.config.KeyFields)
+	}
+}
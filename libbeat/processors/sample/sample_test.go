@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func newProcessor(t *testing.T, config map[string]any) *sample {
+	t.Helper()
+	p, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	return p.(*sample) //nolint:errcheck // New always returns a sample.
+}
+
+// sequence returns a random source cycling through values.
+func sequence(values ...float64) func() float64 {
+	i := 0
+	return func() float64 {
+		v := values[i%len(values)]
+		i++
+		return v
+	}
+}
+
+// run runs n events with the given key through the processor and returns
+// the kept events.
+func run(t *testing.T, p *sample, key string, n int) []*beat.Event {
+	t.Helper()
+	var kept []*beat.Event
+	for range n {
+		out, err := p.Run(&beat.Event{Fields: mapstr.M{"trace": mapstr.M{"id": key}}})
+		require.NoError(t, err)
+		if out != nil {
+			kept = append(kept, out)
+		}
+	}
+	return kept
+}
+
+func TestSampleRandom(t *testing.T) {
+	p := newProcessor(t, map[string]any{"rate": 0.25})
+	p.random = sequence(0.1, 0.3, 0.6, 0.9)
+
+	kept := run(t, p, "a", 8)
+	require.Len(t, kept, 2)
+	for _, e := range kept {
+		assert.Equal(t, 4.0, e.Fields["sample_rate"])
+	}
+}
+
+func TestSampleHash(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"mode":         "hash",
+		"rate":         0.5,
+		"key_fields":   []string{"trace.id"},
+		"target_field": "event.sample_rate",
+	})
+
+	keptKeys := 0
+	for i := range 1000 {
+		key := fmt.Sprintf("trace-%d", i)
+		kept := run(t, p, key, 3)
+		// All events of a key are kept or dropped together.
+		if len(kept) == 0 {
+			continue
+		}
+		require.Len(t, kept, 3, key)
+		assert.Equal(t, 2.0, kept[0].Fields["event"].(mapstr.M)["sample_rate"])
+		keptKeys++
+	}
+	assert.InDelta(t, 500, keptKeys, 100)
+}
+
+func TestSampleAdaptive(t *testing.T) {
+	p := newProcessor(t, map[string]any{
+		"mode":        "adaptive",
+		"key_fields":  []string{"trace.id"},
+		"target_rate": 1,
+		"interval":    "10s",
+	})
+	clock := clockwork.NewFakeClock()
+	p.clock = clock
+	p.random = sequence(0.05, 0.15, 0.25, 0.35, 0.45, 0.55, 0.65, 0.75, 0.85, 0.95)
+
+	// All events are kept in the first interval.
+	assert.Len(t, run(t, p, "busy", 100), 100)
+	assert.Len(t, run(t, p, "quiet", 5), 5)
+
+	// The busy key is sampled to 10 events per interval, the quiet key
+	// is kept.
+	clock.Advance(10 * time.Second)
+	kept := run(t, p, "busy", 100)
+	require.Len(t, kept, 10)
+	assert.Equal(t, 10.0, kept[0].Fields["sample_rate"])
+	kept = run(t, p, "quiet", 5)
+	require.Len(t, kept, 5)
+	assert.Equal(t, 1.0, kept[0].Fields["sample_rate"])
+}
+
+func TestSampleConfig(t *testing.T) {
+	testCases := map[string]map[string]any{
+		"missing rate":            {},
+		"rate above 1":            {"rate": 1.5},
+		"hash without key fields": {"mode": "hash", "rate": 0.5},
+		"adaptive without target": {"mode": "adaptive"},
+		"unknown mode":            {"mode": "reservoir", "rate": 0.5},
+		"empty target field":      {"rate": 0.5, "target_field": ""},
+	}
+
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(config), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lua
+
+import (
+	"fmt"
+	"slices"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// IMPORTANT:
+// This is the user-facing API within Lua processors. It mirrors the API of
+// the JavaScript processors. Do not make breaking changes to the Lua methods.
+// If you must make breaking changes, then create a new version and require the
+// user to specify an API version in their configuration (e.g. api_version: 2).
+
+// eventTypeName is the name of the metatable of the events.
+const eventTypeName = "beat.event"
+
+type beatEventV0 struct {
+	ud        *lua.LUserData
+	inner     *beat.Event
+	cancelled bool
+}
+
+// registerBeatEventV0 registers the event methods and the Event.new
+// constructor used by test() to create events.
+func registerBeatEventV0(L *lua.LState) {
+	mt := L.NewTypeMetatable(eventTypeName)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"Get":      eventGet,
+		"Put":      eventPut,
+		"Rename":   eventRename,
+		"Delete":   eventDelete,
+		"Cancel":   eventCancel,
+		"Tag":      eventTag,
+		"AppendTo": eventAppendTo,
+	}))
+
+	L.SetGlobal("Event", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"new": newBeatEventV0Constructor,
+	}))
+}
+
+func newBeatEventV0(L *lua.LState, b *beat.Event) *beatEventV0 {
+	e := &beatEventV0{inner: b}
+	e.ud = L.NewUserData()
+	e.ud.Value = e
+	L.SetMetatable(e.ud, L.GetTypeMetatable(eventTypeName))
+	return e
+}
+
+// newBeatEventV0Constructor creates an event from a table of fields.
+//
+//	-- lua
+//	local evt = Event.new({message = "hello"})
+func newBeatEventV0Constructor(L *lua.LState) int {
+	if L.GetTop() != 1 {
+		L.RaiseError("Event constructor requires one argument")
+	}
+	v, err := fromLua(L.CheckTable(1))
+	if err != nil {
+		L.RaiseError("Event constructor: %v", err)
+	}
+	fields, ok := v.(mapstr.M)
+	if !ok {
+		L.RaiseError("Event constructor requires a table of fields but got %T", v)
+	}
+	L.Push(newBeatEventV0(L, &beat.Event{Fields: fields}).ud)
+	return 1
+}
+
+// reset the event so that it can be reused to wrap another event.
+func (e *beatEventV0) reset(b *beat.Event) {
+	e.inner = b
+	e.cancelled = false
+}
+
+// Wrapped returns the wrapped beat.Event.
+func (e *beatEventV0) Wrapped() *beat.Event {
+	return e.inner
+}
+
+// IsCancelled returns true if the event has been canceled.
+func (e *beatEventV0) IsCancelled() bool {
+	return e.cancelled
+}
+
+// Cancel marks the event as cancelled. When the processor returns, the event
+// will be dropped.
+func (e *beatEventV0) Cancel() {
+	e.cancelled = true
+}
+
+// checkEvent returns the event the method is called on.
+func checkEvent(L *lua.LState, name string, args int) *beatEventV0 {
+	if e, ok := L.Get(1).(*lua.LUserData); ok {
+		if e, ok := e.Value.(*beatEventV0); ok {
+			if L.GetTop()-1 != args {
+				L.RaiseError("%s requires %d argument(s)", name, args)
+			}
+			return e
+		}
+	}
+	L.RaiseError("%s must be called on an event, like evt:%s()", name, name)
+	return nil
+}
+
+// eventGet returns the specified field. If the field does not exist, then nil
+// is returned. If no field is specified, then it returns all the fields. The
+// returned tables are copies of the event values.
+//
+//	-- lua
+//	local dataset = evt:Get("event.dataset")
+func eventGet(L *lua.LState) int {
+	var e *beatEventV0
+	if L.GetTop() == 1 {
+		e = checkEvent(L, "Get", 0)
+		L.Push(toLua(L, e.inner.Fields))
+		return 1
+	}
+	e = checkEvent(L, "Get", 1)
+
+	v, err := e.inner.GetValue(L.CheckString(2))
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(toLua(L, v))
+	return 1
+}
+
+// eventPut writes a value to the event. If there was a previous value
+// assigned to the given field, then the old value is returned. It raises an
+// error if you try to write a to a field where one of the intermediate values
+// is not an object.
+//
+//	-- lua
+//	evt:Put("event.action", "process-created")
+//	evt:Put("geo.location", {lon = -73.614830, lat = 45.505918})
+func eventPut(L *lua.LState) int {
+	e := checkEvent(L, "Put", 2)
+
+	key := L.CheckString(2)
+	value, err := fromLua(L.Get(3))
+	if err != nil {
+		L.RaiseError("Put %v: %v", key, err)
+	}
+
+	old, err := e.inner.PutValue(key, value)
+	if err != nil {
+		L.RaiseError("Put %v: %v", key, err)
+	}
+	L.Push(toLua(L, old))
+	return 1
+}
+
+// eventRename moves a value from one key to another. It returns true on
+// success.
+//
+//	-- lua
+//	evt:Rename("src_ip", "source.ip")
+func eventRename(L *lua.LState) int {
+	e := checkEvent(L, "Rename", 2)
+
+	from := L.CheckString(2)
+	to := L.CheckString(3)
+	L.Push(lua.LBool(e.rename(from, to)))
+	return 1
+}
+
+func (e *beatEventV0) rename(from, to string) bool {
+	if _, err := e.inner.GetValue(to); err == nil {
+		// Fields cannot be overwritten. Either the target field has to be
+		// deleted or renamed.
+		return false
+	}
+
+	fromValue, err := e.inner.GetValue(from)
+	if err != nil {
+		return false
+	}
+
+	// Deletion must happen first to support cases where a becomes a.b.
+	if err = e.inner.Delete(from); err != nil {
+		return false
+	}
+
+	if _, err = e.inner.PutValue(to, fromValue); err != nil {
+		// Undo
+		_, _ = e.inner.PutValue(from, fromValue)
+		return false
+	}
+	return true
+}
+
+// eventDelete deletes a key from the object. If returns true on success.
+//
+//	-- lua
+//	evt:Delete("http.request.headers.authorization")
+func eventDelete(L *lua.LState) int {
+	e := checkEvent(L, "Delete", 1)
+
+	L.Push(lua.LBool(e.inner.Delete(L.CheckString(2)) == nil))
+	return 1
+}
+
+// eventCancel marks the event as cancelled.
+//
+//	-- lua
+//	evt:Cancel()
+func eventCancel(L *lua.LState) int {
+	checkEvent(L, "Cancel", 0).Cancel()
+	return 0
+}
+
+// eventTag adds a new value to the tags field if it is not already contained
+// in the set.
+//
+//	-- lua
+//	evt:Tag("_parse_failure")
+func eventTag(L *lua.LState) int {
+	e := checkEvent(L, "Tag", 1)
+
+	if err := appendString(e.inner.Fields, "tags", L.CheckString(2), true); err != nil {
+		L.RaiseError("Tag: %v", err)
+	}
+	return 0
+}
+
+// eventAppendTo is a specialized Put method that converts any existing value
+// to an array and appends the value if it does not already exist. If there is
+// an existing value that's not a string or array of strings, then an error is
+// raised.
+//
+//	-- lua
+//	evt:AppendTo("error.message", "invalid file hash")
+func eventAppendTo(L *lua.LState) int {
+	e := checkEvent(L, "AppendTo", 2)
+
+	if err := appendString(e.inner.Fields, L.CheckString(2), L.CheckString(3), false); err != nil {
+		L.RaiseError("AppendTo: %v", err)
+	}
+	return 0
+}
+
+func appendString(m mapstr.M, field, value string, alwaysArray bool) error {
+	list, _ := m.GetValue(field)
+	switch v := list.(type) {
+	case nil:
+		if alwaysArray {
+			m.Put(field, []string{value})
+		} else {
+			m.Put(field, value)
+		}
+	case string:
+		if value != v {
+			m.Put(field, []string{v, value})
+		}
+	case []string:
+		if slices.Contains(v, value) {
+			// Duplicate
+			return nil
+		}
+		m.Put(field, append(v, value))
+	case []any:
+		for _, existingTag := range v {
+			if value == existingTag {
+				// Duplicate
+				return nil
+			}
+		}
+		m.Put(field, append(v, value))
+	default:
+		return fmt.Errorf("unexpected type %T found for %v field", list, field)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// maxTableDepth limits the nesting of the tables converted to Go values, to
+// protect against tables that contain themselves.
+const maxTableDepth = 64
+
+// toLua converts a Go value to a Lua value. Objects and arrays are copied
+// to tables.
+func toLua(L *lua.LState, v any) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case lua.LValue:
+		return v
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case []byte:
+		return lua.LString(v)
+	case time.Time:
+		return lua.LString(v.UTC().Format(time.RFC3339Nano))
+	case mapstr.M:
+		return toLuaMap(L, v)
+	case map[string]any:
+		return toLuaMap(L, v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lua.LNumber(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return lua.LNumber(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return lua.LNumber(rv.Float())
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return lua.LString(s.String())
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		t := L.CreateTable(rv.Len(), 0)
+		for i := range rv.Len() {
+			t.Append(toLua(L, rv.Index(i).Interface()))
+		}
+		return t
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			t := L.CreateTable(0, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				t.RawSetString(iter.Key().String(), toLua(L, iter.Value().Interface()))
+			}
+			return t
+		}
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return lua.LNil
+		}
+		return toLua(L, rv.Elem().Interface())
+	}
+	return lua.LString(fmt.Sprint(v))
+}
+
+func toLuaMap(L *lua.LState, m map[string]any) *lua.LTable {
+	t := L.CreateTable(0, len(m))
+	for k, v := range m {
+		t.RawSetString(k, toLua(L, v))
+	}
+	return t
+}
+
+// fromLua converts a Lua value to a Go value. Tables with only the keys 1 to
+// n are converted to arrays, and the other tables to objects. Integral
+// numbers are converted to int64.
+func fromLua(v lua.LValue) (any, error) {
+	return fromLuaDepth(v, 0)
+}
+
+func fromLuaDepth(v lua.LValue, depth int) (any, error) {
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LString:
+		return string(v), nil
+	case lua.LNumber:
+		f := float64(v)
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return f, nil
+	case *lua.LTable:
+		if depth >= maxTableDepth {
+			return nil, errors.New("tables are nested too deeply")
+		}
+		return fromLuaTable(v, depth+1)
+	default:
+		return nil, fmt.Errorf("unsupported Lua type %s", v.Type())
+	}
+}
+
+func fromLuaTable(t *lua.LTable, depth int) (any, error) {
+	keys := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { keys++ })
+
+	if n := t.MaxN(); n > 0 && n == keys {
+		a := make([]any, n)
+		for i := range n {
+			v, err := fromLuaDepth(t.RawGetInt(i+1), depth)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+	}
+
+	m := make(mapstr.M, keys)
+	var err error
+	t.ForEach(func(k, v lua.LValue) {
+		if err != nil {
+			return
+		}
+		var key string
+		switch k := k.(type) {
+		case lua.LString:
+			key = string(k)
+		case lua.LNumber:
+			key = k.String()
+		default:
+			err = fmt.Errorf("unsupported Lua table key type %s", k.Type())
+			return
+		}
+		m[key], err = fromLuaDepth(v, depth)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.uber.org/zap"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	logName = "processor.lua"
+
+	registerFunction   = "register"
+	entryPointFunction = "process"
+	testFunction       = "test"
+
+	timeoutError = "lua processor execution timeout"
+)
+
+// session is a Lua state used throughout the life of the processor
+// instance.
+type session struct {
+	L              *lua.LState
+	log            *logp.Logger
+	evt            *beatEventV0
+	processFunc    *lua.LFunction
+	timeout        time.Duration
+	tagOnException string
+}
+
+func newSession(proto *lua.FunctionProto, conf Config, test bool, logger *logp.Logger) (*session, error) {
+	// Create a logger
+	logger = logger.Named(logName)
+	if conf.Tag != "" {
+		logger = logger.With("instance_id", conf.Tag)
+	}
+	// Measure load times
+	start := time.Now()
+	defer func() {
+		took := time.Since(start)
+		logger.Debugf("Load of lua pipeline took %v", took)
+	}()
+	// Setup Lua state.
+	s := &session{
+		L:              newState(logger),
+		log:            logger,
+		timeout:        conf.Timeout,
+		tagOnException: conf.TagOnException,
+	}
+	registerBeatEventV0(s.L)
+	s.evt = newBeatEventV0(s.L, nil)
+
+	s.L.Push(s.L.NewFunctionFromProto(proto))
+	if err := s.L.PCall(0, 0, nil); err != nil {
+		s.L.Close()
+		return nil, err
+	}
+
+	err := s.setProcessFunction()
+	if err == nil && len(conf.Params) > 0 {
+		err = s.registerScriptParams(conf.Params)
+	}
+	if err == nil && test {
+		err = s.executeTestFunction()
+	}
+	if err != nil {
+		s.L.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newState returns a Lua state with the base, table, string, math and
+// coroutine libraries. Scripts can't access the file system, and print
+// writes to the processor log.
+func newState(logger *logp.Logger) *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+		{lua.CoroutineLibName, lua.OpenCoroutine},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "module", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		args := make([]string, L.GetTop())
+		for i := range args {
+			args[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		logger.Info(strings.Join(args, "\t"))
+		return 0
+	}))
+	return L
+}
+
+// setProcessFunction validates that the process() function exists and stores
+// the handle.
+func (s *session) setProcessFunction() error {
+	processFunc := s.L.GetGlobal(entryPointFunction)
+	if processFunc == lua.LNil {
+		return errors.New("process function not found")
+	}
+	fn, ok := processFunc.(*lua.LFunction)
+	if !ok {
+		return errors.New("process is not a function")
+	}
+	s.processFunc = fn
+	return nil
+}
+
+// registerScriptParams calls the register() function and passes the params.
+func (s *session) registerScriptParams(params map[string]any) error {
+	registerFunc := s.L.GetGlobal(registerFunction)
+	if registerFunc == lua.LNil {
+		return errors.New("params were provided but no register function was found")
+	}
+	if registerFunc.Type() != lua.LTFunction {
+		return errors.New("register is not a function")
+	}
+	if err := s.L.CallByParam(lua.P{Fn: registerFunc, Protect: true}, toLua(s.L, params)); err != nil {
+		return fmt.Errorf("failed to register script_params: %w", err)
+	}
+	s.log.Debug("Registered params with processor")
+	return nil
+}
+
+// executeTestFunction executes the test() function if it exists. Any errors
+// will cause the processor to fail to load.
+func (s *session) executeTestFunction() error {
+	if testFunc := s.L.GetGlobal(testFunction); testFunc != lua.LNil {
+		if testFunc.Type() != lua.LTFunction {
+			return errors.New("test is not a function")
+		}
+		if err := s.L.CallByParam(lua.P{Fn: testFunc, Protect: true}); err != nil {
+			return fmt.Errorf("failed in test() function: %w", err)
+		}
+		s.log.Debugf("Successful test() execution for processor.")
+	}
+	return nil
+}
+
+// runProcessFunc executes process() from the Lua script.
+func (s *session) runProcessFunc(b *beat.Event) (out *beat.Event, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Errorw("The lua processor caused an unexpected panic "+
+				"while processing an event. Recovering, but please report this.",
+				"panic", r,
+				zap.Stack("stack"))
+			if !s.evt.IsCancelled() {
+				out = b
+			}
+			err = fmt.Errorf("unexpected panic in lua processor: %v", r)
+			if s.tagOnException != "" {
+				_ = mapstr.AddTags(b.Fields, []string{s.tagOnException})
+			}
+			_ = appendString(b.Fields, "error.message", err.Error(), false)
+		}
+	}()
+
+	s.evt.reset(b)
+
+	// Interrupt the Lua code if execution exceeds timeout.
+	var ctx context.Context
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		s.L.SetContext(ctx)
+		defer s.L.RemoveContext()
+	}
+
+	if err = s.L.CallByParam(lua.P{Fn: s.processFunc, Protect: true}, s.evt.ud); err != nil {
+		if ctx != nil && ctx.Err() != nil {
+			err = errors.New(timeoutError)
+		} else {
+			err = scriptError(err)
+		}
+		if s.tagOnException != "" {
+			_ = mapstr.AddTags(b.Fields, []string{s.tagOnException})
+		}
+		_ = appendString(b.Fields, "error.message", err.Error(), false)
+		return b, fmt.Errorf("failed in process function: %w", err)
+	}
+
+	if s.evt.IsCancelled() {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// scriptError returns the error raised by the script without the stack
+// traceback, which would be repeated in the events.
+func scriptError(err error) error {
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) && apiErr.Object != nil {
+		return errors.New(apiErr.Object.String())
+	}
+	return err
+}
+
+type sessionPool struct {
+	New                func() *session
+	C                  chan *session
+	NewSessionsAllowed bool
+}
+
+func newSessionPool(proto *lua.FunctionProto, c Config, logger *logp.Logger) (*sessionPool, error) {
+	s, err := newSession(proto, c, true, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := sessionPool{
+		New: func() *session {
+			s, _ := newSession(proto, c, false, logger)
+			return s
+		},
+		C:                  make(chan *session, c.MaxCachedSessions),
+		NewSessionsAllowed: !c.OnlyCachedSessions,
+	}
+	pool.Put(s)
+
+	// If we are not allowed to create new sessions, pre-cache requested sessions
+	if !pool.NewSessionsAllowed {
+		for i := 0; i < c.MaxCachedSessions-1; i++ {
+			pool.Put(pool.New())
+		}
+	}
+
+	return &pool, nil
+}
+
+func (p *sessionPool) Get() *session {
+	if !p.NewSessionsAllowed {
+		return <-p.C
+	}
+
+	// Try to get a session from the pool, if none is available, create a new one
+	select {
+	case s := <-p.C:
+		return s
+	default:
+		return p.New()
+	}
+}
+
+func (p *sessionPool) Put(s *session) {
+	if s != nil {
+		select {
+		case p.C <- s:
+		default:
+			s.L.Close()
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lua
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestSessionTagOnException(t *testing.T) {
+	p, err := NewFromConfig(Config{
+		Source:         `function process(event) error("this tags the event") end`,
+		TagOnException: defaultConfig().TagOnException,
+	}, nil, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	evt, err := p.Run(newTestEvent())
+	assert.ErrorContains(t, err, "this tags the event")
+
+	tags, _ := evt.GetValue("tags")
+	assert.Equal(t, []string{"_lua_exception"}, tags)
+	msg, _ := evt.GetValue("error.message")
+	assert.Equal(t, "inline.lua:1: this tags the event", msg)
+}
+
+func TestSessionScriptParams(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	t.Run("register method is optional", func(t *testing.T) {
+		_, err := NewFromConfig(Config{
+			Source: `function process(event) end`,
+		}, nil, logger)
+		require.NoError(t, err)
+	})
+
+	t.Run("register required for params", func(t *testing.T) {
+		_, err := NewFromConfig(Config{
+			Source: `function process(event) end`,
+			Params: map[string]any{
+				"threshold": 42,
+			},
+		}, nil, logger)
+		assert.ErrorContains(t, err, "params were provided")
+	})
+
+	t.Run("register params", func(t *testing.T) {
+		const script = `
+			local threshold
+			function register(params)
+				if params.threshold ~= 42 then
+					error("invalid threshold")
+				end
+				threshold = params.threshold
+			end
+			function process(event)
+				event:Put("threshold", threshold)
+			end
+		`
+		p, err := NewFromConfig(Config{
+			Source: script,
+			Params: map[string]any{
+				"threshold": 42,
+			},
+		}, nil, logger)
+		require.NoError(t, err)
+
+		evt, err := p.Run(newTestEvent())
+		require.NoError(t, err)
+		v, _ := evt.GetValue("threshold")
+		assert.Equal(t, int64(42), v)
+	})
+
+	t.Run("register error", func(t *testing.T) {
+		_, err := NewFromConfig(Config{
+			Source: `function register(params) error("bad params") end function process(event) end`,
+			Params: map[string]any{"threshold": 1},
+		}, nil, logger)
+		assert.ErrorContains(t, err, "bad params")
+	})
+}
+
+func TestSessionTestFunction(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	const script = `
+		function process(event)
+			if event:Get("x") == 1 then
+				event:Tag("one")
+			end
+		end
+		function test()
+			local e = Event.new({x = %s})
+			process(e)
+			if not e:Get("tags") then
+				error("expected tag")
+			end
+		end
+	`
+
+	t.Run("passing test", func(t *testing.T) {
+		_, err := NewFromConfig(Config{Source: fmt.Sprintf(script, "1")}, nil, logger)
+		require.NoError(t, err)
+	})
+
+	t.Run("failing test", func(t *testing.T) {
+		_, err := NewFromConfig(Config{Source: fmt.Sprintf(script, "2")}, nil, logger)
+		assert.ErrorContains(t, err, "failed in test() function")
+		assert.ErrorContains(t, err, "expected tag")
+	})
+}
+
+func TestSessionTimeout(t *testing.T) {
+	p, err := NewFromConfig(Config{
+		Source: `
+			function process(event)
+				if event:Get("stop") then
+					while true do end
+				end
+			end
+		`,
+		Timeout:        100 * time.Millisecond,
+		TagOnException: "_lua_exception",
+	}, nil, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	evt, err := p.Run(&beat.Event{Fields: mapstr.M{"stop": true}})
+	assert.ErrorContains(t, err, timeoutError)
+	tags, _ := evt.GetValue("tags")
+	assert.Equal(t, []string{"_lua_exception"}, tags)
+
+	// The session can be reused after a timeout.
+	_, err = p.Run(newTestEvent())
+	assert.NoError(t, err)
+}
+
+func TestSessionCancel(t *testing.T) {
+	p, err := NewFromConfig(Config{
+		Source: `
+			function process(event)
+				if event:Get("drop") then
+					event:Cancel()
+				end
+			end
+		`,
+	}, nil, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	evt, err := p.Run(&beat.Event{Fields: mapstr.M{"drop": true}})
+	require.NoError(t, err)
+	assert.Nil(t, evt)
+
+	evt, err = p.Run(newTestEvent())
+	require.NoError(t, err)
+	assert.NotNil(t, evt)
+}
+
+func TestSessionSandbox(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	for _, global := range []string{"io", "os", "debug", "dofile", "loadfile", "module", "require"} {
+		t.Run(global, func(t *testing.T) {
+			_, err := NewFromConfig(Config{
+				Source: fmt.Sprintf(`function process(event) end
+					if %s ~= nil then error("%s is available") end`, global, global),
+			}, nil, logger)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("print", func(t *testing.T) {
+		p, err := NewFromConfig(Config{
+			Source: `function process(event) print("hello", 1, true) end`,
+		}, nil, logger)
+		require.NoError(t, err)
+		_, err = p.Run(newTestEvent())
+		assert.NoError(t, err)
+	})
+}
+
+func TestSessionParallel(t *testing.T) {
+	for _, onlyCached := range []bool{false, true} {
+		p, err := NewFromConfig(Config{
+			Source: `
+				function process(event)
+					local n = event:Get("n")
+					event:Put("double", n * 2)
+				end
+			`,
+			MaxCachedSessions:  2,
+			OnlyCachedSessions: onlyCached,
+		}, nil, logptest.NewTestingLogger(t, ""))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					n := int64(i*1000 + j)
+					evt, err := p.Run(&beat.Event{Fields: mapstr.M{"n": n}})
+					if assert.NoError(t, err) {
+						v, _ := evt.GetValue("double")
+						assert.Equal(t, 2*n, v)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
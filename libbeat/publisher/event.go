@@ -18,6 +18,8 @@
 package publisher
 
 import (
+	"time"
+
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
@@ -60,6 +62,11 @@ type Event struct {
 	Flags   EventFlags
 	Cache   EventCache
 
+	// EnqueuedAt is the time the pipeline client added the event to the
+	// queue. It is zero for events restored from a disk queue written by a
+	// version that didn't record it.
+	EnqueuedAt time.Time
+
 	// If the output provides an early encoder for incoming events,
 	// it should store the encoded form in EncodedEvent and clear Content
 	// to free the unencoded data. The updated event will be provided to
@@ -20,6 +20,7 @@ package pipeline
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/processors"
@@ -30,6 +31,11 @@ import (
 
 var _ beat.Client = (*client)(nil)
 
+// processorFlushInterval is how often the events held back by the processors
+// that are ready, like the summaries of closed aggregation windows, are
+// published.
+var processorFlushInterval = time.Second
+
 // client implements beat.Client interface
 // client connects a beat with the processors and pipeline queue.
 //
@@ -62,6 +68,11 @@ type client struct {
 	// of lingering until the whole pipeline disconnects. Run once, from Close.
 	requestFinalize func()
 
+	// stopFlush stops publishing the events held back by the processors. It
+	// is nil if the processors don't hold back events.
+	stopFlush     chan struct{}
+	stopFlushOnce sync.Once
+
 	observer       observer
 	eventListener  beat.EventListener
 	clientListener beat.ClientListener
@@ -119,14 +130,20 @@ func (c *client) publish(e beat.Event) {
 		return
 	}
 
-	e = *event
+	c.enqueue(*event, c.canDrop)
+}
+
+// enqueue hands a processed event to the queue producer. If try is set, the
+// event is dropped instead of waiting for space in the queue.
+func (c *client) enqueue(e beat.Event, try bool) {
 	pubEvent := publisher.Event{
-		Content: e,
-		Flags:   c.eventFlags,
+		Content:    e,
+		Flags:      c.eventFlags,
+		EnqueuedAt: time.Now(),
 	}
 
 	var published bool
-	if c.canDrop {
+	if try {
 		_, published = c.producer.TryPublish(pubEvent)
 	} else {
 		_, published = c.producer.Publish(pubEvent)
@@ -148,12 +165,27 @@ func (c *client) publish(e beat.Event) {
 // pipeline-level shutdown (Pipeline.Disconnect, bounded by its context) is now
 // responsible for waiting on outstanding acknowledgments.
 func (c *client) Close() error {
+	if !c.isOpen.Load() {
+		return nil
+	}
+
+	// Collect the events held back by the processors, like open aggregation
+	// windows, before taking the mutex. They are published without waiting
+	// for space in the queue, so Close never blocks on a full queue.
+	c.stopFlushing()
+	var flushed []*beat.Event
+	if c.processors != nil {
+		flushed = processors.Flush(c.processors, true)
+	}
+
 	// Hold the mutex so any in-progress Publish finishes before we flip isOpen.
 	c.mutex.Lock()
-	if !c.isOpen.Swap(false) {
+	if !c.isOpen.Load() {
 		c.mutex.Unlock()
 		return nil
 	}
+	c.publishFlushed(flushed, true)
+	c.isOpen.Store(false)
 	c.onClosing()
 	c.mutex.Unlock()
 
@@ -182,6 +214,50 @@ func (c *client) Close() error {
 	return nil
 }
 
+// startFlushing periodically publishes the events held back by the
+// processors that are ready, if the processors hold back events.
+func (c *client) startFlushing() {
+	if c.processors == nil || !processors.HoldsEvents(c.processors) {
+		return
+	}
+	c.stopFlush = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(processorFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopFlush:
+				return
+			case <-ticker.C:
+			}
+
+			c.mutex.Lock()
+			if c.isOpen.Load() {
+				c.publishFlushed(processors.Flush(c.processors, false), c.canDrop)
+			}
+			c.mutex.Unlock()
+		}
+	}()
+}
+
+// stopFlushing stops the periodic publishing of held back events. It doesn't
+// wait for a publish in progress, which holds the mutex.
+func (c *client) stopFlushing() {
+	if c.stopFlush != nil {
+		c.stopFlushOnce.Do(func() { close(c.stopFlush) })
+	}
+}
+
+// publishFlushed publishes the events flushed from the processors. The mutex
+// must be held.
+func (c *client) publishFlushed(events []*beat.Event, try bool) {
+	for _, event := range events {
+		c.onNewEvent()
+		c.eventListener.AddEvent(*event, true)
+		c.enqueue(*event, try)
+	}
+}
+
 // disconnect performs stage two of shutdown: it stops accepting acknowledgments
 // and drops all references to the client so a restarting pipeline cannot collide
 // with it or leak it. It is invoked exactly once by the owning Pipeline (never
@@ -567,6 +567,127 @@ func testInputMetrics(t *testing.T, beatInfo beat.Info, clientCfg beat.ClientCon
 	}
 }
 
+// TestCloseFlushesProcessors verifies that Close publishes the events held
+// back by the processors before it closes the producer.
+func TestCloseFlushesProcessors(t *testing.T) {
+	var published []beat.Event
+	producerClosed := false
+	c := &client{
+		logger: logp.NewNopLogger(),
+		processors: &flushingProcessor{
+			flushed: []*beat.Event{{Fields: mapstr.M{"summary": true}}},
+		},
+		producer: &testProducer{
+			publish: func(try bool, event publisher.Event) (queue.EntryID, bool) {
+				assert.False(t, producerClosed, "event published after the producer was closed")
+				assert.True(t, try, "Close must not wait for space in the queue")
+				published = append(published, event.Content)
+				return 1, true
+			},
+			cancel: func() { producerClosed = true },
+		},
+		observer:       nilObserver,
+		eventListener:  acker.Nil(),
+		clientListener: &mockClientListener{},
+	}
+	c.isOpen.Store(true)
+
+	c.Publish(beat.Event{Fields: mapstr.M{"hello": "world"}})
+	assert.Empty(t, published, "the processor must hold back the event")
+
+	require.NoError(t, c.Close())
+	assert.True(t, producerClosed)
+	assert.Equal(t, []beat.Event{{Fields: mapstr.M{"summary": true}}}, published)
+
+	// A second Close must not flush again.
+	require.NoError(t, c.Close())
+	assert.Len(t, published, 1)
+}
+
+// TestClientPublishesReadyEvents verifies that the events held back by the
+// processors are published once they are ready, without waiting for new
+// events or Close.
+func TestClientPublishesReadyEvents(t *testing.T) {
+	defer func(interval time.Duration) { processorFlushInterval = interval }(processorFlushInterval)
+	processorFlushInterval = time.Millisecond
+
+	published := make(chan beat.Event, 1)
+	c := &client{
+		logger: logp.NewNopLogger(),
+		processors: &flushingProcessor{
+			ready: []*beat.Event{{Fields: mapstr.M{"summary": true}}},
+		},
+		producer: &testProducer{
+			publish: func(try bool, event publisher.Event) (queue.EntryID, bool) {
+				assert.False(t, try)
+				published <- event.Content
+				return 1, true
+			},
+		},
+		observer:       nilObserver,
+		eventListener:  acker.Nil(),
+		clientListener: &mockClientListener{},
+	}
+	c.isOpen.Store(true)
+	c.startFlushing()
+
+	select {
+	case event := <-published:
+		assert.Equal(t, beat.Event{Fields: mapstr.M{"summary": true}}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the ready event was not published")
+	}
+	require.NoError(t, c.Close())
+}
+
+// TestCloseDoesNotBlockOnFullQueue verifies that the events flushed on Close
+// are dropped instead of blocking Close if the queue is full.
+func TestCloseDoesNotBlockOnFullQueue(t *testing.T) {
+	listener := &mockClientListener{}
+	c := &client{
+		logger: logp.NewNopLogger(),
+		processors: &flushingProcessor{
+			flushed: []*beat.Event{{Fields: mapstr.M{"summary": true}}},
+		},
+		producer: &testProducer{
+			publish: func(try bool, _ publisher.Event) (queue.EntryID, bool) {
+				assert.True(t, try, "Close must not wait for space in the queue")
+				return 0, false
+			},
+		},
+		observer:       nilObserver,
+		eventListener:  acker.Nil(),
+		clientListener: listener,
+	}
+	c.isOpen.Store(true)
+
+	require.NoError(t, c.Close())
+	assert.Equal(t, 1, listener.eventsDroppedOnPublish)
+}
+
+// flushingProcessor drops all events. Flush returns ready, and flushed if
+// final is set.
+type flushingProcessor struct {
+	mu      sync.Mutex
+	ready   []*beat.Event
+	flushed []*beat.Event
+}
+
+func (p *flushingProcessor) String() string                       { return "flushingProcessor" }
+func (p *flushingProcessor) Run(*beat.Event) (*beat.Event, error) { return nil, nil }
+func (p *flushingProcessor) HoldsEvents() bool                    { return true }
+func (p *flushingProcessor) Flush(final bool) []*beat.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	events := p.ready
+	p.ready = nil
+	if final {
+		events = append(events, p.flushed...)
+		p.flushed = nil
+	}
+	return events
+}
+
 type testProcessor struct {
 	name        string
 	processorFn func(in *beat.Event) (event *beat.Event, err error)
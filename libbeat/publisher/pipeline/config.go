@@ -35,6 +35,12 @@ type Config struct {
 
 	// Event queue
 	Queue config.Namespace `config:"queue"`
+
+	// Drop events older than this
+	MaxEventAge EventAgeConfig `config:"max_event_age"`
+
+	// Named outputs events are routed to, instead of the single output
+	Outputs []RouteConfig `config:"outputs"`
 }
 
 // validateClientConfig checks a ClientConfig can be used with (*Pipeline).ConnectWith.
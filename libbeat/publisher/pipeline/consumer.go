@@ -18,8 +18,11 @@
 package pipeline
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/elastic-agent-libs/logp"
@@ -31,9 +34,14 @@ import (
 type eventConsumer struct {
 	logger *logp.Logger
 
-	// eventConsumer calls the retryObserver methods eventsRetry and eventsDropped.
+	// eventConsumer calls the retryObserver methods eventsRetry, eventsDropped
+	// and eventsExpired.
 	retryObserver retryObserver
 
+	// agePolicy, if set, drops events that are too old from the batches read
+	// from the queue and from the batches being retried.
+	agePolicy *eventAgePolicy
+
 	// When the output changes, the new target is sent to the worker routine
 	// on this channel. Clients should call eventConsumer.setTarget().
 	targetChan chan consumerTarget
@@ -62,6 +70,9 @@ type consumerTarget struct {
 	ch         chan publisher.Batch
 	timeToLive int
 	batchSize  int
+
+	// deadLetter, if set, receives the events dropped for being too old.
+	deadLetter *outputs.DeadLetter
 }
 
 // retryRequest is used by ttlBatch to add itself back to the eventConsumer
@@ -74,10 +85,12 @@ type retryRequest struct {
 func newEventConsumer(
 	log *logp.Logger,
 	observer retryObserver,
+	agePolicy *eventAgePolicy,
 ) *eventConsumer {
 	c := &eventConsumer{
 		logger:        log,
 		retryObserver: observer,
+		agePolicy:     agePolicy,
 		queueReader:   makeQueueReader(),
 
 		targetChan: make(chan consumerTarget),
@@ -172,6 +185,9 @@ outerLoop:
 
 		case queueBatch = <-c.queueReader.resp:
 			pendingRead = false
+			if queueBatch != nil && !c.dropExpired(queueBatch, target.deadLetter) {
+				queueBatch = nil
+			}
 
 		case req := <-c.retryChan:
 			if req.decreaseTTL {
@@ -190,6 +206,9 @@ outerLoop:
 					continue
 				}
 			}
+			if !c.dropExpired(req.batch, target.deadLetter) {
+				continue
+			}
 			retryBatches = append(retryBatches, req.batch)
 
 		case <-c.done:
@@ -217,6 +236,31 @@ outerLoop:
 	close(c.queueReader.req)
 }
 
+// dropExpired removes the expired events from the batch, reporting them to
+// the observer and handing them to the output's dead-letter sink, if any.
+// If no events are left, the batch is dropped and dropExpired returns
+// false.
+func (c *eventConsumer) dropExpired(batch *ttlBatch, dl *outputs.DeadLetter) bool {
+	expired := batch.dropExpired(c.agePolicy, time.Now())
+	if len(expired) == 0 {
+		return true
+	}
+	c.retryObserver.eventsExpired(len(expired))
+	c.logger.Debugf("Dropped %d events older than the configured max_event_age (%v)",
+		len(expired), c.agePolicy.limit)
+	if dl != nil {
+		reason := fmt.Errorf("event is older than the configured max_event_age (%v)", c.agePolicy.limit)
+		for _, event := range expired {
+			dl.Send(event, reason)
+		}
+	}
+	if len(batch.Events()) == 0 {
+		batch.Drop()
+		return false
+	}
+	return true
+}
+
 func (c *eventConsumer) setTarget(target consumerTarget) {
 	select {
 	case c.targetChan <- target:
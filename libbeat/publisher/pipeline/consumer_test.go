@@ -18,6 +18,10 @@
 package pipeline
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -25,12 +29,15 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/slabqueue"
+	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
 )
 
 func TestNoBatchAssemblyOnNilTarget(t *testing.T) {
@@ -84,7 +91,7 @@ func TestEventConsumerRetriesUntilTTLExhausted(t *testing.T) {
 	_, ok := producer.Publish(publisher.Event{Content: beat.Event{Private: 0}})
 	require.True(t, ok, "publish should succeed")
 
-	c := newEventConsumer(logp.NewNopLogger(), nilObserver)
+	c := newEventConsumer(logp.NewNopLogger(), nilObserver, nil)
 	ch := make(chan publisher.Batch)
 	defer func() {
 		q.Close(false)
@@ -123,7 +130,7 @@ func TestEventConsumerRetriesUntilTTLExhausted(t *testing.T) {
 // setTarget() return cleanly once the consumer is shut down, dropping any batch
 // handed to retry rather than blocking.
 func TestEventConsumerRetryAfterCloseDropsBatch(t *testing.T) {
-	c := newEventConsumer(logp.NewNopLogger(), nilObserver)
+	c := newEventConsumer(logp.NewNopLogger(), nilObserver, nil)
 	c.close()
 
 	dropped := false
@@ -155,7 +162,7 @@ func TestEventConsumerCloseReleasesHeldBatches(t *testing.T) {
 	}
 	require.Equal(t, 0, pool.Available(), "pool should be full after publishing capacity events")
 
-	c := newEventConsumer(logp.NewNopLogger(), nilObserver)
+	c := newEventConsumer(logp.NewNopLogger(), nilObserver, nil)
 
 	// Hand the consumer a target whose output channel is a real (but
 	// unread) channel. The run loop's request gate requires ch != nil
@@ -188,3 +195,101 @@ func TestEventConsumerCloseReleasesHeldBatches(t *testing.T) {
 		"all slots must return to the pool after consumer close (got %d/%d)",
 		pool.Available(), capacity)
 }
+
+// expiredObserver counts the events reported as expired.
+type expiredObserver struct {
+	*emptyObserver
+	expired atomic.Int64
+}
+
+func (o *expiredObserver) eventsExpired(n int) { o.expired.Add(int64(n)) }
+
+// TestEventConsumerDropsExpiredEvents verifies the max_event_age policy:
+// expired events are removed from batches read from the queue and from
+// retried batches, and acknowledged to the queue without being delivered.
+func TestEventConsumerDropsExpiredEvents(t *testing.T) {
+	q := memqueue.NewQueue[publisher.Event](
+		logp.NewNopLogger(), queue.NewQueueObserver(nil),
+		memqueue.Settings{Events: 10, MaxGetRequest: 3, FlushTimeout: 10 * time.Millisecond},
+		0, nil)
+
+	acked := make(chan int, 10)
+	producer := q.Producer(queue.ProducerConfig{ACK: func(n int) { acked <- n }})
+	now := time.Now()
+	for i, ts := range []time.Time{now.Add(-2 * time.Hour), now, now.Add(-50 * time.Minute)} {
+		_, ok := producer.Publish(publisher.Event{Content: beat.Event{Timestamp: ts, Private: i}})
+		require.True(t, ok, "publish should succeed")
+	}
+
+	observer := &expiredObserver{}
+	policy := newEventAgePolicy(EventAgeConfig{Limit: time.Hour})
+	c := newEventConsumer(logp.NewNopLogger(), observer, policy)
+	ch := make(chan publisher.Batch)
+	defer func() {
+		q.Close(false)
+		c.close()
+	}()
+	c.setTarget(consumerTarget{queue: q, ch: ch, batchSize: 3, timeToLive: -1})
+
+	batch := receiveBatch(t, ch)
+	require.Len(t, batch.Events(), 2, "the expired event should be removed")
+	assert.Equal(t, 1, batch.Events()[0].Content.Private)
+	assert.Equal(t, int64(1), observer.expired.Load())
+
+	// By the time the batch is retried, another event has expired.
+	policy.limit = 30 * time.Minute
+	batch.Retry()
+	batch = receiveBatch(t, ch)
+	require.Len(t, batch.Events(), 1)
+	assert.Equal(t, int64(2), observer.expired.Load())
+	batch.ACK()
+
+	select {
+	case n := <-acked:
+		assert.Equal(t, 3, n, "expired events should be acknowledged with the batch")
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the batch to be acked")
+	}
+}
+
+// TestEventConsumerDeadLettersExpiredEvents verifies that expired events
+// are handed to the output's dead-letter sink.
+func TestEventConsumerDeadLettersExpiredEvents(t *testing.T) {
+	q := memqueue.NewQueue[publisher.Event](
+		logp.NewNopLogger(), queue.NewQueueObserver(nil),
+		memqueue.Settings{Events: 10, MaxGetRequest: 2, FlushTimeout: 10 * time.Millisecond},
+		0, nil)
+	producer := q.Producer(queue.ProducerConfig{})
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-2 * time.Hour), now} {
+		_, ok := producer.Publish(publisher.Event{Content: beat.Event{Timestamp: ts, Fields: mapstr.M{"message": "hello"}}})
+		require.True(t, ok, "publish should succeed")
+	}
+
+	dir := t.TempDir()
+	var ns conf.Namespace
+	require.NoError(t, conf.MustNewConfigFrom(map[string]any{"file.path": dir}).Unpack(&ns))
+	dl, err := outputs.NewDeadLetter(nil, beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}, nil, "test", ns)
+	require.NoError(t, err)
+
+	c := newEventConsumer(logp.NewNopLogger(), &expiredObserver{}, newEventAgePolicy(EventAgeConfig{Limit: time.Hour}))
+	ch := make(chan publisher.Batch)
+	defer func() {
+		q.Close(false)
+		c.close()
+	}()
+	c.setTarget(consumerTarget{queue: q, ch: ch, batchSize: 2, timeToLive: -1, deadLetter: dl})
+
+	batch := receiveBatch(t, ch)
+	require.Len(t, batch.Events(), 1, "the expired event should be removed")
+	batch.ACK()
+	require.NoError(t, dl.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "testbeat*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"), "the expired event should be dead-lettered")
+	assert.Contains(t, string(data), "max_event_age")
+}
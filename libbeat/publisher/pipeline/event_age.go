@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+const (
+	// EventAgeTimestamp computes an event's age from its @timestamp.
+	EventAgeTimestamp = "timestamp"
+	// EventAgeEnqueueTime computes an event's age from the time it was
+	// added to the queue.
+	EventAgeEnqueueTime = "enqueue_time"
+)
+
+// EventAgeConfig configures the max_event_age policy, which drops events
+// that are too old to be worth sending, for example after a long output
+// outage. Events are checked when they are read from the queue and when
+// they are retried.
+type EventAgeConfig struct {
+	// Limit is the maximum age of an event. Zero disables the policy.
+	Limit time.Duration `config:"limit" validate:"min=0"`
+
+	// BasedOn selects the time the age is computed from, EventAgeTimestamp
+	// (the default) or EventAgeEnqueueTime.
+	BasedOn string `config:"based_on"`
+}
+
+func (c *EventAgeConfig) Validate() error {
+	switch c.BasedOn {
+	case "", EventAgeTimestamp, EventAgeEnqueueTime:
+		return nil
+	default:
+		return fmt.Errorf("max_event_age.based_on must be %q or %q, got %q",
+			EventAgeTimestamp, EventAgeEnqueueTime, c.BasedOn)
+	}
+}
+
+// eventAgePolicy decides whether an event is expired. A nil policy never
+// expires events.
+type eventAgePolicy struct {
+	limit       time.Duration
+	enqueueTime bool
+}
+
+func newEventAgePolicy(cfg EventAgeConfig) *eventAgePolicy {
+	if cfg.Limit <= 0 {
+		return nil
+	}
+	return &eventAgePolicy{
+		limit:       cfg.Limit,
+		enqueueTime: cfg.BasedOn == EventAgeEnqueueTime,
+	}
+}
+
+// expired returns true if the event is older than the limit at the given
+// time. Events without the selected time are never expired.
+func (p *eventAgePolicy) expired(event *publisher.Event, now time.Time) bool {
+	if p == nil {
+		return false
+	}
+	t := event.Content.Timestamp
+	if p.enqueueTime {
+		t = event.EnqueuedAt
+	}
+	if t.IsZero() {
+		return false
+	}
+	return now.Sub(t) > p.limit
+}
@@ -98,6 +98,8 @@ func LoadWithSettings(
 		return nil, err
 	}
 
+	settings.MaxEventAge = config.MaxEventAge
+	settings.Routes = config.Outputs
 	p, err := New(beatInfo, monitors, config.Queue, out, settings)
 	if err != nil {
 		return nil, err
@@ -25,6 +25,7 @@ type observer interface {
 	pipelineObserver
 	clientObserver
 	retryObserver
+	routeObserver
 
 	cleanup()
 }
@@ -53,6 +54,13 @@ type retryObserver interface {
 	eventsDropped(int)
 	// Events were sent back to an output worker after an earlier failure.
 	eventsRetry(int)
+	// Events were dropped because they were older than max_event_age.
+	eventsExpired(int)
+}
+
+type routeObserver interface {
+	// Events matched the condition of none of the configured outputs.
+	eventsUnrouted(int)
 }
 
 // metricsObserver is used by many components in the publisher pipeline, to report
@@ -74,6 +82,8 @@ type metricsObserverVars struct {
 	eventsTotal, eventsFiltered, eventsPublished, eventsFailed *monitoring.Uint
 
 	eventsDropped, eventsRetry *monitoring.Uint // (retryer) drop/retry counters
+	eventsExpired              *monitoring.Uint
+	eventsUnrouted             *monitoring.Uint
 	activeEvents               *monitoring.Uint
 }
 
@@ -111,6 +121,14 @@ func newMetricsObserver(metrics *monitoring.Registry) *metricsObserver {
 			// events.dropped counts events that were dropped because errors from
 			// the output workers exceeded the configured maximum retry count.
 			eventsDropped: monitoring.NewUint(reg, "events.dropped"),
+
+			// events.expired counts events that were dropped because they were
+			// older than the configured max_event_age.
+			eventsExpired: monitoring.NewUint(reg, "events.expired"),
+
+			// events.unrouted counts events that were dropped because they
+			// matched none of the outputs configured under outputs.
+			eventsUnrouted: monitoring.NewUint(reg, "events.unrouted"),
 		},
 	}
 }
@@ -177,6 +195,16 @@ func (o *metricsObserver) eventsRetry(n int) {
 	o.vars.eventsRetry.Add(uint64(n))
 }
 
+// (retryer) number of events dropped by the max_event_age policy
+func (o *metricsObserver) eventsExpired(n int) {
+	o.vars.eventsExpired.Add(uint64(n))
+}
+
+// (router) number of events that matched no output
+func (o *metricsObserver) eventsUnrouted(n int) {
+	o.vars.eventsUnrouted.Add(uint64(n))
+}
+
 type emptyObserver struct{}
 
 var nilObserver observer = (*emptyObserver)(nil)
@@ -191,3 +219,5 @@ func (*emptyObserver) failedPublishEvent() {}
 func (*emptyObserver) eventsACKed(n int)   {}
 func (*emptyObserver) eventsDropped(int)   {}
 func (*emptyObserver) eventsRetry(int)     {}
+func (*emptyObserver) eventsExpired(int)   {}
+func (*emptyObserver) eventsUnrouted(int)  {}
@@ -31,6 +31,7 @@ func newOTelOutputController(
 	retryObserver retryObserver,
 	queueFactory queue.QueueFactory[publisher.Event],
 	queueConfig any,
+	agePolicy *eventAgePolicy,
 ) (outputController, error) {
 	return nil, nil
 }
@@ -122,6 +122,7 @@ func newOTelOutputController(
 	retryObserver retryObserver,
 	queueFactory queue.QueueFactory[publisher.Event],
 	queueConfig any,
+	agePolicy *eventAgePolicy,
 ) (*otelOutputController, error) {
 	var (
 		pipelineQueue queue.Queue[publisher.Event]
@@ -167,7 +168,7 @@ func newOTelOutputController(
 		workers[i] = makeSpawningWorker(workerChan, client)
 	}
 
-	consumer := newEventConsumer(monitors.Logger, retryObserver)
+	consumer := newEventConsumer(monitors.Logger, retryObserver, agePolicy)
 	consumer.setTarget(consumerTarget{
 		queue:      pipelineQueue,
 		ch:         workerChan,
@@ -99,6 +99,7 @@ func TestOTelQueueMetrics(t *testing.T) {
 		nilObserver,
 		nil, // queueFactory unused on the slabqueue pool path
 		settings,
+		nil,
 	)
 	require.NoError(t, err, "creating OTel output controller should succeed")
 	defer controller.waitClose(context.Background(), true)
@@ -120,6 +121,7 @@ func TestReceiversShareGlobalPool(t *testing.T) {
 		nilObserver,
 		nil, // queueFactory unused on the slabqueue pool path
 		settings,
+		nil,
 	)
 	require.NoError(t, err)
 	defer c1.waitClose(cancelledContext(), false)
@@ -130,6 +132,7 @@ func TestReceiversShareGlobalPool(t *testing.T) {
 		nilObserver,
 		nil, // queueFactory unused on the slabqueue pool path
 		settings,
+		nil,
 	)
 	require.NoError(t, err)
 	defer c2.waitClose(cancelledContext(), false)
@@ -156,6 +159,7 @@ func TestSharedPoolBudgetIsolatesPipelines(t *testing.T) {
 		nilObserver,
 		nil, // queueFactory unused on the slabqueue pool path
 		settings,
+		nil,
 	)
 	require.NoError(t, err, "first controller creation should succeed")
 	defer c1.waitClose(cancelledContext(), false)
@@ -166,6 +170,7 @@ func TestSharedPoolBudgetIsolatesPipelines(t *testing.T) {
 		nilObserver,
 		nil, // queueFactory unused on the slabqueue pool path
 		settings,
+		nil,
 	)
 	require.NoError(t, err, "second controller creation should succeed")
 	defer c2.waitClose(cancelledContext(), false)
@@ -198,6 +203,7 @@ func TestSharedPoolGrowsToMax(t *testing.T) {
 		nilObserver,
 		nil, // queueFactory unused on the slabqueue pool path
 		memqueue.Settings{Events: 5},
+		nil,
 	)
 	require.NoError(t, err, "first output controller creation should succeed")
 	defer c1.waitClose(cancelledContext(), false)
@@ -212,6 +218,7 @@ func TestSharedPoolGrowsToMax(t *testing.T) {
 		nilObserver,
 		nil,
 		memqueue.Settings{Events: 10},
+		nil,
 	)
 	require.NoError(t, err, "a differing budget must grow the shared pool, not fail")
 	defer c2.waitClose(cancelledContext(), false)
@@ -227,6 +234,7 @@ func TestSharedPoolGrowsToMax(t *testing.T) {
 		nilObserver,
 		nil,
 		memqueue.Settings{Events: 3},
+		nil,
 	)
 	require.NoError(t, err)
 	defer c3.waitClose(cancelledContext(), false)
@@ -240,6 +248,7 @@ func TestSharedPoolShrinksWhenLargestLeaves(t *testing.T) {
 	c1, err := newOTelOutputController(
 		beatInfoForTest(t), monitorsForTest(), nilObserver, nil,
 		memqueue.Settings{Events: 4},
+		nil,
 	)
 	require.NoError(t, err)
 	defer c1.waitClose(cancelledContext(), false)
@@ -247,6 +256,7 @@ func TestSharedPoolShrinksWhenLargestLeaves(t *testing.T) {
 	c2, err := newOTelOutputController(
 		beatInfoForTest(t), monitorsForTest(), nilObserver, nil,
 		memqueue.Settings{Events: 20},
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -268,6 +278,7 @@ func TestSharedPoolCapsPerReceiver(t *testing.T) {
 	c1, err := newOTelOutputController(
 		beatInfoNoDrain(t), monitorsForTest(), nilObserver, nil,
 		memqueue.Settings{Events: 4},
+		nil,
 	)
 	require.NoError(t, err)
 	defer c1.waitClose(cancelledContext(), false)
@@ -275,6 +286,7 @@ func TestSharedPoolCapsPerReceiver(t *testing.T) {
 	c2, err := newOTelOutputController(
 		beatInfoNoDrain(t), monitorsForTest(), nilObserver, nil,
 		memqueue.Settings{Events: 8},
+		nil,
 	)
 	require.NoError(t, err)
 	defer c2.waitClose(cancelledContext(), false)
@@ -321,6 +333,7 @@ func TestNonMemQueueOptsOutOfPool(t *testing.T) {
 		nilObserver,
 		stubFactory,
 		fakeNonMemqueueSettings{Path: "/tmp/dq"},
+		nil,
 	)
 	require.NoError(t, err, "non-mem queue config must take the queueFactory path")
 	defer c.waitClose(cancelledContext(), false)
@@ -334,9 +347,9 @@ func TestNonMemQueueOptsOutOfPool(t *testing.T) {
 func TestSharedPoolRefCount(t *testing.T) {
 	settings := memqueue.Settings{Events: 4}
 
-	c1, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c1, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
-	c2, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c2, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 
 	pool := c1.poolForTest()
@@ -357,9 +370,9 @@ func TestSharedPoolRefCount(t *testing.T) {
 func TestPipelineQueuesAreIndependent(t *testing.T) {
 	settings := memqueue.Settings{Events: 10}
 
-	c1, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c1, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
-	c2, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c2, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 	defer c2.waitClose(cancelledContext(), false)
 
@@ -400,7 +413,7 @@ func TestConcurrentControllerCreate(t *testing.T) {
 	for i := range n {
 		go func() {
 			defer wg.Done()
-			c, err := newOTelOutputController(infos[i], mons[i], nilObserver, nil, settings)
+			c, err := newOTelOutputController(infos[i], mons[i], nilObserver, nil, settings, nil)
 			require.NoError(t, err)
 			controllers[i] = c
 		}()
@@ -425,7 +438,7 @@ func TestConcurrentControllerCreate(t *testing.T) {
 // controller does not hold or re-close producers that are already gone.
 func TestProducerTrackingUntracksOnClose(t *testing.T) {
 	settings := memqueue.Settings{Events: 8}
-	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 	defer c.waitClose(cancelledContext(), false)
 
@@ -445,7 +458,7 @@ func TestProducerTrackingUntracksOnClose(t *testing.T) {
 // tracking set is emptied and the closed producers reject further publishes.
 func TestWaitCloseClosesOpenProducers(t *testing.T) {
 	settings := memqueue.Settings{Events: 8}
-	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 
 	prod := c.queueProducer(queue.ProducerConfig{})
@@ -466,7 +479,7 @@ func TestWaitCloseClosesOpenProducers(t *testing.T) {
 // than via the force-close timeout path.
 func TestWaitCloseGracefulSuccess(t *testing.T) {
 	settings := memqueue.Settings{Events: 8}
-	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 
 	// A vended-but-unused producer: waitClose closes it (0 events, so its
@@ -488,9 +501,9 @@ func TestWaitCloseGracefulSuccess(t *testing.T) {
 // and releases the shared pool — without ever blocking on another pipeline.
 func TestWaitCloseIsBoundedByContext(t *testing.T) {
 	settings := memqueue.Settings{Events: 8}
-	c1, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c1, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
-	c2, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c2, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 	defer c2.waitClose(cancelledContext(), false)
 
@@ -524,7 +537,7 @@ func TestWaitCloseIsBoundedByContext(t *testing.T) {
 // not already closed earlier in waitClose).
 func TestCloseProducersClosesTracked(t *testing.T) {
 	settings := memqueue.Settings{Events: 4}
-	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings)
+	c, err := newOTelOutputController(beatInfoForTest(t), monitorsForTest(), nilObserver, nil, settings, nil)
 	require.NoError(t, err)
 	defer c.waitClose(cancelledContext(), false)
 
@@ -80,6 +80,10 @@ type processOutputController struct {
 	workers    []outputWorker
 	workerChan chan publisher.Batch
 
+	// output is the current output group. What its clients share is closed
+	// after the workers, when the group is replaced or the pipeline closes.
+	output outputs.Group
+
 	// The InputQueueSize can be set when the Beat is started, in
 	// libbeat/cmd/instance/Settings we need to preserve that
 	// value and pass it into the queue factory.  The queue
@@ -106,6 +110,7 @@ func newProcessOutputController(
 	retryObserver retryObserver,
 	queueFactory queue.QueueFactory[publisher.Event],
 	inputQueueSize int,
+	agePolicy *eventAgePolicy,
 ) (*processOutputController, error) {
 	controller := &processOutputController{
 		beat:           beat,
@@ -113,7 +118,7 @@ func newProcessOutputController(
 		monitors:       monitors,
 		queueFactory:   queueFactory,
 		workerChan:     make(chan publisher.Batch),
-		consumer:       newEventConsumer(monitors.Logger, retryObserver),
+		consumer:       newEventConsumer(monitors.Logger, retryObserver, agePolicy),
 		inputQueueSize: inputQueueSize,
 	}
 
@@ -135,6 +140,9 @@ func (c *processOutputController) waitClose(ctx context.Context, force bool) err
 	for _, out := range c.workers {
 		out.Close()
 	}
+	if err := c.output.CloseShared(); err != nil {
+		c.logger.Errorf("Failed to close the output: %v", err)
+	}
 
 	return nil
 }
@@ -150,6 +158,10 @@ func (c *processOutputController) Set(outGrp outputs.Group) {
 	for _, w := range c.workers {
 		w.Close()
 	}
+	if err := c.output.CloseShared(); err != nil {
+		c.logger.Errorf("Failed to close the previous output: %v", err)
+	}
+	c.output = outGrp
 
 	// create new output group with the shared work queue
 	clients := outGrp.Clients
@@ -177,6 +189,7 @@ func (c *processOutputController) Set(outGrp outputs.Group) {
 			ch:         targetChan,
 			batchSize:  outGrp.BatchSize,
 			timeToLive: outGrp.Retry + 1,
+			deadLetter: outGrp.DeadLetter,
 		})
 }
 
@@ -19,6 +19,7 @@ package pipeline
 
 import (
 	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -79,6 +80,7 @@ func TestOutputReload(t *testing.T) {
 					&emptyObserver{},
 					queueFactory,
 					0,
+					nil,
 				)
 				require.NoError(t, err)
 				var wg sync.WaitGroup
@@ -141,6 +143,31 @@ func TestSetEmptyOutputsSendsNilChannel(t *testing.T) {
 	assert.Nil(t, target.ch, "consumerTarget should receive a nil channel to block batch assembly")
 }
 
+type closeCounter struct{ closed atomic.Int32 }
+
+func (c *closeCounter) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestSharedClosedWithOutputGroup(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	controller := processOutputController{
+		beat:   beat.Info{Logger: logger},
+		logger: logger,
+		consumer: &eventConsumer{
+			targetChan: make(chan consumerTarget, 4),
+		},
+	}
+	shared := &closeCounter{}
+	controller.Set(outputs.Group{Shared: []io.Closer{shared}})
+	assert.Zero(t, shared.closed.Load(), "shared resources must stay open while the group is active")
+
+	// Replacing the output group tears the old one down.
+	controller.Set(outputs.Group{})
+	assert.Equal(t, int32(1), shared.closed.Load())
+}
+
 func TestQueueCreatedOnlyAfterOutputExists(t *testing.T) {
 	logger := logptest.NewTestingLogger(t, "")
 	controller := processOutputController{
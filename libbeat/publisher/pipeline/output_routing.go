@@ -0,0 +1,227 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// RouteConfig configures one of the named outputs of a pipeline. Events are
+// sent to every output whose condition they match.
+type RouteConfig struct {
+	Name   string             `config:"name" validate:"required"`
+	When   *conditions.Config `config:"when"`
+	Output conf.Namespace     `config:"output"`
+	Queue  conf.Namespace     `config:"queue"`
+}
+
+func (c *RouteConfig) Validate() error {
+	if strings.Contains(c.Name, ".") {
+		return fmt.Errorf("output name %q can't contain dots", c.Name)
+	}
+	if !c.Output.IsSet() {
+		return fmt.Errorf("output %q has no output configured", c.Name)
+	}
+	return nil
+}
+
+// OutputFactory creates the outputs.Group for an output configuration.
+type OutputFactory func(outputs.Observer, conf.Namespace) (outputs.Group, error)
+
+func validateRoutes(routes []RouteConfig) error {
+	names := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		if names[r.Name] {
+			return fmt.Errorf("duplicate output name %q", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+var _ outputController = (*routingOutputController)(nil)
+
+// routingOutputController implements outputController for pipelines with
+// several named outputs. Each output has its own processOutputController,
+// with its own queue, workers and metrics. Producers publish each event to
+// the queues of the outputs it is routed to, and acknowledge it to the client
+// once all of them have.
+type routingOutputController struct {
+	logger   *logp.Logger
+	observer observer
+	routes   []*outputRoute
+}
+
+type outputRoute struct {
+	name string
+	// condition is nil for outputs that receive every event.
+	condition  conditions.Condition
+	controller *processOutputController
+}
+
+func newRoutingOutputController(
+	beatInfo beat.Info,
+	monitors Monitors,
+	observer observer,
+	userQueueConfig conf.Namespace,
+	settings Settings,
+	agePolicy *eventAgePolicy,
+) (*routingOutputController, error) {
+	if err := validateRoutes(settings.Routes); err != nil {
+		return nil, err
+	}
+	if settings.OutputFactory == nil {
+		return nil, errors.New("the pipeline can't create outputs for its routes")
+	}
+
+	c := &routingOutputController{
+		logger:   beatInfo.Logger.Named("routingOutputController"),
+		observer: observer,
+	}
+	for _, cfg := range settings.Routes {
+		route, err := newOutputRoute(beatInfo, monitors, observer, userQueueConfig, cfg, settings, agePolicy)
+		if err != nil {
+			c.waitClose(context.Background(), true)
+			return nil, fmt.Errorf("error initializing output %q: %w", cfg.Name, err)
+		}
+		c.routes = append(c.routes, route)
+	}
+	return c, nil
+}
+
+func newOutputRoute(
+	beatInfo beat.Info,
+	monitors Monitors,
+	observer observer,
+	userQueueConfig conf.Namespace,
+	cfg RouteConfig,
+	settings Settings,
+	agePolicy *eventAgePolicy,
+) (*outputRoute, error) {
+	route := &outputRoute{name: cfg.Name}
+	if cfg.When != nil {
+		var err error
+		route.condition, err = conditions.NewCondition(cfg.When, beatInfo.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize condition: %w", err)
+		}
+	}
+
+	// Outputs use the pipeline queue settings unless they have their own.
+	queueConfig := userQueueConfig
+	if cfg.Queue.IsSet() {
+		queueConfig = cfg.Queue
+	}
+	queueFactory, err := routeQueueFactory(beatInfo, cfg.Name, queueConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each output reports its queue and output metrics in its own registry.
+	routeMonitors := Monitors{
+		Logger: monitors.Logger.With("output", cfg.Name),
+		Tracer: monitors.Tracer,
+	}
+	if monitors.Metrics != nil {
+		routeMonitors.Metrics = monitors.Metrics.GetOrCreateRegistry("outputs").GetOrCreateRegistry(cfg.Name)
+	}
+
+	route.controller, err = newProcessOutputController(beatInfo, routeMonitors, observer, queueFactory, settings.InputQueueSize, agePolicy)
+	if err != nil {
+		return nil, err
+	}
+	out, err := loadOutput(routeMonitors, func(stats outputs.Observer) (string, outputs.Group, error) {
+		group, err := settings.OutputFactory(stats, cfg.Output)
+		return cfg.Output.Name(), group, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	route.controller.Set(out)
+	return route, nil
+}
+
+// routeQueueFactory returns the queue factory for an output. Disk-backed
+// queues without an explicit path get a directory of their own, so the
+// queues of different outputs don't share files.
+func routeQueueFactory(beatInfo beat.Info, name string, userQueueConfig conf.Namespace) (queue.QueueFactory[publisher.Event], error) {
+	queueType := defaultQueueType
+	if b := userQueueConfig.Name(); b != "" {
+		queueType = b
+	}
+
+	queueConfig := conf.NewConfig()
+	if userQueueConfig.IsSet() {
+		if err := queueConfig.Merge(userQueueConfig.Config()); err != nil {
+			return nil, err
+		}
+	}
+	var pathKey string
+	switch queueType {
+	case diskqueue.QueueType:
+		pathKey = "path"
+	case spillqueue.QueueType:
+		pathKey = "disk.path"
+	}
+	if pathKey != "" {
+		if path, _ := queueConfig.String(pathKey, -1); path == "" {
+			beatPaths := beatInfo.Paths
+			if beatPaths == nil {
+				beatPaths = paths.Paths
+			}
+			path = beatPaths.Resolve(paths.Data, filepath.Join("diskqueue", name))
+			if err := queueConfig.SetString(pathKey, -1, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	factory, _, err := queueFactoryForUserConfig(queueType, queueConfig, beatInfo.Paths)
+	return factory, err
+}
+
+func (c *routingOutputController) queueProducer(config queue.ProducerConfig) queue.Producer[publisher.Event] {
+	return newRoutingProducer(c.routes, c.observer, config)
+}
+
+// waitClose closes the queues and outputs of every route, in parallel.
+func (c *routingOutputController) waitClose(ctx context.Context, force bool) error {
+	var wg sync.WaitGroup
+	for _, route := range c.routes {
+		wg.Go(func() {
+			_ = route.controller.waitClose(ctx, force)
+		})
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,241 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// routeTestClient hands the batches it receives to the test, which decides
+// when to acknowledge them.
+type routeTestClient struct {
+	batches chan publisher.Batch
+}
+
+func (c *routeTestClient) Close() error   { return nil }
+func (c *routeTestClient) String() string { return "route_test" }
+
+func (c *routeTestClient) Publish(_ context.Context, batch publisher.Batch) error {
+	c.batches <- batch
+	return nil
+}
+
+// receive returns the batches holding the next n events.
+func (c *routeTestClient) receive(t *testing.T, n int) []publisher.Batch {
+	t.Helper()
+	var batches []publisher.Batch
+	for n > 0 {
+		select {
+		case batch := <-c.batches:
+			batches = append(batches, batch)
+			n -= len(batch.Events())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for events")
+		}
+	}
+	require.Zero(t, n, "received more events than expected")
+	return batches
+}
+
+func routeConfigs(t *testing.T, cfg string) []RouteConfig {
+	t.Helper()
+	c, err := conf.NewConfigWithYAML([]byte(cfg), "")
+	require.NoError(t, err)
+	var pc Config
+	require.NoError(t, c.Unpack(&pc))
+	return pc.Outputs
+}
+
+func makeRoutingPipeline(t *testing.T, routes []RouteConfig, clients map[string]*routeTestClient) (*Pipeline, *monitoring.Registry) {
+	t.Helper()
+	reg := monitoring.NewRegistry()
+	settings := Settings{
+		Routes: routes,
+		OutputFactory: func(_ outputs.Observer, cfg conf.Namespace) (outputs.Group, error) {
+			var c struct {
+				ID string `config:"id"`
+			}
+			require.NoError(t, cfg.Config().Unpack(&c))
+			return outputs.Group{Clients: []outputs.Client{clients[c.ID]}, BatchSize: 10}, nil
+		},
+	}
+	// Don't wait for full batches.
+	var queueConfig conf.Namespace
+	require.NoError(t, conf.MustNewConfigFrom(map[string]any{
+		"mem.flush.timeout": 0,
+	}).Unpack(&queueConfig))
+
+	logger := logptest.NewTestingLogger(t, "")
+	p, err := New(beat.Info{Logger: logger}, Monitors{Metrics: reg, Logger: logger}, queueConfig, outputs.Group{}, settings)
+	require.NoError(t, err)
+	return p, reg
+}
+
+func TestRoutingACKsOnceEveryOutputACKs(t *testing.T) {
+	clients := map[string]*routeTestClient{
+		"audit": {batches: make(chan publisher.Batch, 10)},
+		"all":   {batches: make(chan publisher.Batch, 10)},
+	}
+	routes := routeConfigs(t, `
+outputs:
+  - name: audit
+    when.equals.kind: audit
+    output.route_test.id: audit
+  - name: all
+    output.route_test.id: all
+`)
+	p, reg := makeRoutingPipeline(t, routes, clients)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Disconnect(ctx)
+	}()
+
+	var acked atomic.Int64
+	client, err := p.ConnectWith(beat.ClientConfig{
+		EventListener: acker.RawCounting(func(n int) { acked.Add(int64(n)) }),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Publish(beat.Event{Fields: mapstr.M{"kind": "audit"}})
+	client.Publish(beat.Event{Fields: mapstr.M{"kind": "app"}})
+
+	// The first event waits for the audit output, which holds back the
+	// second one too.
+	for _, batch := range clients["all"].receive(t, 2) {
+		batch.ACK()
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, acked.Load())
+
+	auditBatches := clients["audit"].receive(t, 1)
+	assert.Equal(t, "audit", auditBatches[0].Events()[0].Content.Fields["kind"])
+	auditBatches[0].ACK()
+	require.Eventually(t, func() bool { return acked.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// Each output reports its own metrics.
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(1), snapshot.Ints["outputs.audit.pipeline.queue.acked"])
+	assert.Equal(t, int64(2), snapshot.Ints["outputs.all.pipeline.queue.acked"])
+}
+
+func TestRoutingDropsUnroutedEvents(t *testing.T) {
+	clients := map[string]*routeTestClient{
+		"audit": {batches: make(chan publisher.Batch, 10)},
+	}
+	routes := routeConfigs(t, `
+outputs:
+  - name: audit
+    when.equals.kind: audit
+    output.route_test.id: audit
+`)
+	p, reg := makeRoutingPipeline(t, routes, clients)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Disconnect(ctx)
+	}()
+
+	var acked atomic.Int64
+	client, err := p.ConnectWith(beat.ClientConfig{
+		EventListener: acker.RawCounting(func(n int) { acked.Add(int64(n)) }),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Publish(beat.Event{Fields: mapstr.M{"kind": "app"}})
+	require.Eventually(t, func() bool { return acked.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(1), snapshot.Ints["pipeline.events.unrouted"])
+	assert.Empty(t, clients["audit"].batches)
+}
+
+func TestRouteConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		cfg string
+		err string
+	}{
+		"valid": {
+			cfg: `
+outputs:
+  - name: a
+    output.console: {}
+  - name: b
+    when.has_fields: [tags]
+    output.console: {}
+    queue.mem.events: 64`,
+		},
+		"missing name": {
+			cfg: `
+outputs:
+  - output.console: {}`,
+			err: "string value is not set",
+		},
+		"missing output": {
+			cfg: `
+outputs:
+  - name: a`,
+			err: `output "a" has no output configured`,
+		},
+		"dotted name": {
+			cfg: `
+outputs:
+  - name: a.b
+    output.console: {}`,
+			err: "can't contain dots",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := conf.NewConfigWithYAML([]byte(tc.cfg), "")
+			require.NoError(t, err)
+			var pc Config
+			err = c.Unpack(&pc)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	err := validateRoutes(routeConfigs(t, `
+outputs:
+  - name: a
+    output.console: {}
+  - name: a
+    output.console: {}`))
+	assert.ErrorContains(t, err, `duplicate output name "a"`)
+}
@@ -22,11 +22,13 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/beat/events"
 	"github.com/elastic/beats/v7/libbeat/common/acker"
 	"github.com/elastic/beats/v7/libbeat/common/reload"
 	"github.com/elastic/beats/v7/libbeat/outputs"
@@ -36,6 +38,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/slabqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/paths"
 )
@@ -117,6 +120,15 @@ type Settings struct {
 	Processors processing.Supporter
 
 	InputQueueSize int
+
+	// MaxEventAge configures dropping events that are too old to be worth
+	// sending.
+	MaxEventAge EventAgeConfig
+
+	// Routes configures several named outputs to use instead of the single
+	// output passed to New. OutputFactory creates their outputs.
+	Routes        []RouteConfig
+	OutputFactory OutputFactory
 }
 
 // WaitCloseMode enumerates the possible behaviors of WaitClose in a pipeline.
@@ -204,12 +216,23 @@ func New(
 		return nil, err
 	}
 
-	outputController, err := newProcessOutputController(beat, monitors, p.observer, queueFactory, settings.InputQueueSize)
-	if err != nil {
-		return nil, err
+	agePolicy := newEventAgePolicy(settings.MaxEventAge)
+	if len(settings.Routes) > 0 {
+		if len(out.Clients) > 0 {
+			return nil, errors.New("a pipeline can't have both an output and routes")
+		}
+		p.outputController, err = newRoutingOutputController(beat, monitors, p.observer, userQueueConfig, settings, agePolicy)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		outputController, err := newProcessOutputController(beat, monitors, p.observer, queueFactory, settings.InputQueueSize, agePolicy)
+		if err != nil {
+			return nil, err
+		}
+		outputController.Set(out)
+		p.outputController = outputController
 	}
-	outputController.Set(out)
-	p.outputController = outputController
 
 	p.startReaper()
 	return p, nil
@@ -221,6 +244,10 @@ func NewForReceiver(
 	userQueueConfig conf.Namespace,
 	settings Settings,
 ) (*Pipeline, error) {
+	if len(settings.Routes) > 0 {
+		return nil, errors.New("routing events to several outputs is not supported when running as a Beats receiver")
+	}
+
 	p := &Pipeline{
 		beatInfo:         beatInfo,
 		monitors:         monitors,
@@ -247,7 +274,7 @@ func NewForReceiver(
 		return nil, err
 	}
 
-	p.outputController, err = newOTelOutputController(beatInfo, monitors, p.observer, queueFactory, queueConfig)
+	p.outputController, err = newOTelOutputController(beatInfo, monitors, p.observer, queueFactory, queueConfig, newEventAgePolicy(settings.MaxEventAge))
 	if err != nil {
 		return nil, err
 	}
@@ -490,6 +517,7 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 	client.onRemove = func() { p.unregisterClient(client) }
 	client.requestFinalize = func() { p.finalizeWhenDrained(client) }
 	p.registerClient(client)
+	client.startFlushing()
 
 	p.observer.clientConnected()
 	return client, nil
@@ -530,18 +558,39 @@ func queueFactoryForUserConfig(queueType string, userConfig *conf.C, paths *path
 		if err != nil {
 			return nil, nil, err
 		}
-		return slabqueue.FactoryForSettings[publisher.Event](settings), settings, nil
+		return slabqueue.PrioritizedFactoryForSettings(settings, eventQueuePriority), settings, nil
 	case diskqueue.QueueType:
 		settings, err := diskqueue.SettingsForUserConfig(userConfig)
 		if err != nil {
 			return nil, nil, err
 		}
 		return diskqueue.FactoryForSettings(settings, paths), settings, nil
+	case spillqueue.QueueType:
+		settings, err := spillqueue.SettingsForUserConfig(userConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return spillqueue.FactoryForSettings(settings, paths), settings, nil
 	default:
 		return nil, nil, fmt.Errorf("unrecognized queue type '%v'", queueType)
 	}
 }
 
+// eventQueuePriority classifies an event for a prioritized slab queue from its
+// FieldMetaQueuePriority metadata, which is typically set by a processor.
+// Events without it, or with an unknown value, are queued as normal priority.
+func eventQueuePriority(e publisher.Event) slabqueue.Priority {
+	s, ok := e.Content.Meta[events.FieldMetaQueuePriority].(string)
+	if !ok {
+		return slabqueue.PriorityNormal
+	}
+	prio, err := slabqueue.ParsePriority(s)
+	if err != nil {
+		return slabqueue.PriorityNormal
+	}
+	return prio
+}
+
 type noopReloader struct{}
 
 func (n noopReloader) Reload(
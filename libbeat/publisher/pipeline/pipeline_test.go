@@ -27,8 +27,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/beat/events"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/slabqueue"
 	"github.com/elastic/beats/v7/libbeat/tests/resources"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
@@ -286,3 +288,22 @@ func blockingProducer(_ queue.ProducerConfig) queue.Producer[publisher.Event] {
 		},
 	}
 }
+
+func TestEventQueuePriority(t *testing.T) {
+	cases := map[string]struct {
+		meta mapstr.M
+		want slabqueue.Priority
+	}{
+		"no metadata":   {meta: nil, want: slabqueue.PriorityNormal},
+		"high":          {meta: mapstr.M{events.FieldMetaQueuePriority: "high"}, want: slabqueue.PriorityHigh},
+		"low":           {meta: mapstr.M{events.FieldMetaQueuePriority: "low"}, want: slabqueue.PriorityLow},
+		"unknown value": {meta: mapstr.M{events.FieldMetaQueuePriority: "urgent"}, want: slabqueue.PriorityNormal},
+		"wrong type":    {meta: mapstr.M{events.FieldMetaQueuePriority: 1}, want: slabqueue.PriorityNormal},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := eventQueuePriority(publisher.Event{Content: beat.Event{Meta: tc.meta}})
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
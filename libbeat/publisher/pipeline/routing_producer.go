@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// routingProducer publishes each event to the producers of the outputs whose
+// condition it matches. An event is acknowledged to the client once every
+// output it was routed to has acknowledged it; events that match no output
+// are dropped and acknowledged right away.
+//
+// Like the queue producers it wraps, a routingProducer must not be used by
+// concurrent Publish calls.
+type routingProducer struct {
+	routes    []*outputRoute
+	producers []queue.Producer[publisher.Event]
+	observer  routeObserver
+	ack       func(count int)
+
+	// matched is reused across calls to collect the routes of an event.
+	matched []int
+
+	mu sync.Mutex
+	// pending holds the events that haven't been acknowledged to the client
+	// yet, in publish order. pending[0] has sequence number firstSeq.
+	pending  []routedEvent
+	firstSeq uint64
+	// inflight holds, for each route, the sequence numbers of the events
+	// waiting for its ACK, in the order the route's queue acknowledges them.
+	inflight [][]uint64
+	closed   bool
+
+	// ackMu serializes the ACKs to the client, which the queues of different
+	// outputs trigger concurrently.
+	ackMu sync.Mutex
+
+	ackWait   chan struct{}
+	closeOnce sync.Once
+}
+
+type routedEvent struct {
+	// remaining is the number of outputs that haven't acknowledged the event.
+	remaining int
+	// published is false if no output accepted the event, in which case the
+	// client doesn't expect an ACK for it.
+	published bool
+}
+
+func newRoutingProducer(routes []*outputRoute, observer routeObserver, cfg queue.ProducerConfig) queue.Producer[publisher.Event] {
+	p := &routingProducer{
+		routes:    routes,
+		producers: make([]queue.Producer[publisher.Event], len(routes)),
+		observer:  observer,
+		ack:       cfg.ACK,
+		inflight:  make([][]uint64, len(routes)),
+		ackWait:   make(chan struct{}),
+	}
+	for i, route := range routes {
+		producer := route.controller.queueProducer(queue.ProducerConfig{
+			ACK: func(count int) { p.routeACK(i, count) },
+		})
+		if producer == nil {
+			// The pipeline is shutting down.
+			for _, other := range p.producers[:i] {
+				other.Close()
+			}
+			return nil
+		}
+		p.producers[i] = producer
+	}
+	return p
+}
+
+func (p *routingProducer) Publish(event publisher.Event) (queue.EntryID, bool) {
+	return p.publish(event, false)
+}
+
+// TryPublish publishes the event to the outputs whose queues have room for
+// it. It reports success if at least one of them accepted the event.
+func (p *routingProducer) TryPublish(event publisher.Event) (queue.EntryID, bool) {
+	return p.publish(event, true)
+}
+
+func (p *routingProducer) publish(event publisher.Event, try bool) (queue.EntryID, bool) {
+	p.matched = p.matched[:0]
+	for i, route := range p.routes {
+		if route.condition == nil || route.condition.Check(&event.Content) {
+			p.matched = append(p.matched, i)
+		}
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, false
+	}
+	seq := p.firstSeq + uint64(len(p.pending))
+	p.pending = append(p.pending, routedEvent{remaining: len(p.matched), published: true})
+	for _, i := range p.matched {
+		p.inflight[i] = append(p.inflight[i], seq)
+	}
+	p.mu.Unlock()
+
+	if len(p.matched) == 0 {
+		p.observer.eventsUnrouted(1)
+		p.acknowledge(nil)
+		return queue.EntryID(seq), true
+	}
+
+	var rejected []int
+	for n, i := range p.matched {
+		routed := event
+		if n > 0 {
+			// Outputs may modify the events they are given, so each one
+			// gets its own copy.
+			routed.Content = *event.Content.Clone()
+		}
+		var ok bool
+		if try {
+			_, ok = p.producers[i].TryPublish(routed)
+		} else {
+			_, ok = p.producers[i].Publish(routed)
+		}
+		if !ok {
+			rejected = append(rejected, i)
+		}
+	}
+	if len(rejected) == 0 {
+		return queue.EntryID(seq), true
+	}
+
+	published := len(rejected) < len(p.matched)
+	p.acknowledge(func() {
+		entry := &p.pending[seq-p.firstSeq]
+		entry.published = published
+		entry.remaining -= len(rejected)
+		for _, i := range rejected {
+			// Publish calls aren't concurrent, so the rejected event is
+			// still the last one routed to the output.
+			p.inflight[i] = p.inflight[i][:len(p.inflight[i])-1]
+		}
+	})
+	return queue.EntryID(seq), published
+}
+
+// routeACK handles the ACK of count events by the queue of a route.
+func (p *routingProducer) routeACK(route int, count int) {
+	p.acknowledge(func() {
+		for _, seq := range p.inflight[route][:count] {
+			p.pending[seq-p.firstSeq].remaining--
+		}
+		p.inflight[route] = p.inflight[route][count:]
+	})
+}
+
+// acknowledge runs update with the lock held, then acknowledges to the
+// client the events at the front of pending that no output is waiting on
+// anymore.
+func (p *routingProducer) acknowledge(update func()) {
+	p.ackMu.Lock()
+	defer p.ackMu.Unlock()
+
+	p.mu.Lock()
+	if update != nil {
+		update()
+	}
+	count := 0
+	for len(p.pending) > 0 && p.pending[0].remaining == 0 {
+		if p.pending[0].published {
+			count++
+		}
+		p.pending = p.pending[1:]
+		p.firstSeq++
+	}
+	p.mu.Unlock()
+
+	if count > 0 && p.ack != nil {
+		p.ack(count)
+	}
+}
+
+func (p *routingProducer) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, producer := range p.producers {
+		producer.Close()
+	}
+	p.closeOnce.Do(func() {
+		go func() {
+			for _, producer := range p.producers {
+				<-producer.ACKWaitChan()
+			}
+			close(p.ackWait)
+		}()
+	})
+}
+
+// ACKWaitChan is closed once the producer is closed and the producers of
+// every output have been acknowledged or force closed.
+func (p *routingProducer) ACKWaitChan() <-chan struct{} {
+	return p.ackWait
+}
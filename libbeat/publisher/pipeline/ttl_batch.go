@@ -19,6 +19,7 @@ package pipeline
 
 import (
 	"sync/atomic"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
@@ -260,6 +261,26 @@ func (b *ttlBatch) reduceTTL() bool {
 	return false
 }
 
+// dropExpired removes the events the age policy considers expired and
+// returns them. Expired events are dropped even if they have guaranteed
+// sending requirements.
+func (b *ttlBatch) dropExpired(policy *eventAgePolicy, now time.Time) []publisher.Event {
+	if policy == nil {
+		return nil
+	}
+	var expired []publisher.Event
+	events := b.events[:0]
+	for i := range b.events {
+		if policy.expired(&b.events[i], now) {
+			expired = append(expired, b.events[i])
+		} else {
+			events = append(events, b.events[i])
+		}
+	}
+	b.events = events
+	return expired
+}
+
 ///////////////////////////////////////////////////////////////////////
 // Testing support helpers
 
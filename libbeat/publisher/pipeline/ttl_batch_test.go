@@ -20,6 +20,7 @@ package pipeline
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -333,3 +334,54 @@ type mockRetryer struct {
 func (r *mockRetryer) retry(batch *ttlBatch, decreaseTTL bool) {
 	r.batches = append(r.batches, batch)
 }
+
+func TestBatchDropExpired(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	events := []publisher.Event{
+		{Content: beat.Event{Timestamp: old, Private: 0}, EnqueuedAt: now},
+		{Content: beat.Event{Timestamp: now, Private: 1}, EnqueuedAt: old},
+		// Guaranteed events expire too.
+		{Content: beat.Event{Timestamp: old, Private: 2}, Flags: publisher.GuaranteedSend},
+		// Events without the selected time never expire.
+		{Content: beat.Event{Private: 3}},
+	}
+	privates := func(events []publisher.Event) []any {
+		var result []any
+		for _, e := range events {
+			result = append(result, e.Content.Private)
+		}
+		return result
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		policy := newEventAgePolicy(EventAgeConfig{})
+		require.Nil(t, policy)
+		batch := &ttlBatch{events: append([]publisher.Event(nil), events...)}
+		assert.Empty(t, batch.dropExpired(policy, now))
+		assert.Len(t, batch.events, 4)
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		policy := newEventAgePolicy(EventAgeConfig{Limit: time.Hour})
+		batch := &ttlBatch{events: append([]publisher.Event(nil), events...)}
+		assert.Equal(t, []any{0, 2}, privates(batch.dropExpired(policy, now)))
+		assert.Equal(t, []any{1, 3}, privates(batch.events))
+	})
+
+	t.Run("enqueue time", func(t *testing.T) {
+		policy := newEventAgePolicy(EventAgeConfig{Limit: time.Hour, BasedOn: EventAgeEnqueueTime})
+		batch := &ttlBatch{events: append([]publisher.Event(nil), events...)}
+		assert.Equal(t, []any{1}, privates(batch.dropExpired(policy, now)))
+		assert.Equal(t, []any{0, 2, 3}, privates(batch.events))
+	})
+}
+
+func TestEventAgeConfigValidate(t *testing.T) {
+	for _, basedOn := range []string{"", EventAgeTimestamp, EventAgeEnqueueTime} {
+		cfg := EventAgeConfig{Limit: time.Hour, BasedOn: basedOn}
+		assert.NoError(t, cfg.Validate(), "based_on %q should be valid", basedOn)
+	}
+	cfg := EventAgeConfig{Limit: time.Hour, BasedOn: "ingest_time"}
+	assert.Error(t, cfg.Validate())
+}
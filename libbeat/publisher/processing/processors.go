@@ -128,12 +128,49 @@ func (p *group) SetPaths(paths *paths.Path) error {
 	return err
 }
 
+// Flush returns the events held back by the processors in the group, after
+// running them through the processors following the one that held them back.
+func (p *group) Flush(final bool) []*beat.Event {
+	if p == nil {
+		return nil
+	}
+
+	var flushed []*beat.Event
+	for i, processor := range p.list {
+		for _, event := range processors.Flush(processor, final) {
+			if event, _ = p.runFrom(i+1, event); event != nil {
+				flushed = append(flushed, event)
+			}
+		}
+	}
+	return flushed
+}
+
+// HoldsEvents reports whether any of the processors in the group holds back
+// events.
+func (p *group) HoldsEvents() bool {
+	if p == nil {
+		return false
+	}
+	for _, processor := range p.list {
+		if processors.HoldsEvents(processor) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *group) Run(event *beat.Event) (*beat.Event, error) {
 	if p == nil || len(p.list) == 0 {
 		return event, nil
 	}
+	return p.runFrom(0, event)
+}
 
-	for _, sub := range p.list {
+// runFrom runs the event through the processors of the group, starting at
+// the processor with index i.
+func (p *group) runFrom(i int, event *beat.Event) (*beat.Event, error) {
+	for _, sub := range p.list[i:] {
 		var err error
 
 		event, err = sub.Run(event)
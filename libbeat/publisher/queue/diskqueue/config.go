@@ -18,6 +18,7 @@
 package diskqueue
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -66,6 +67,12 @@ type Settings struct {
 
 	// UseCompression enables or disables LZ4 compression
 	UseCompression bool
+
+	// EncryptionKeys enables AES-GCM encryption of segment data when
+	// non-empty. New segments are encrypted with the first key, the
+	// others are only used to read segments written before the active
+	// key was rotated.
+	EncryptionKeys []EncryptionKey
 }
 
 // userConfig holds the parameters for a disk queue that are configurable
@@ -80,6 +87,58 @@ type userConfig struct {
 
 	RetryInterval    *time.Duration `config:"retry_interval" validate:"positive"`
 	MaxRetryInterval *time.Duration `config:"max_retry_interval" validate:"positive"`
+
+	Encryption *encryptionConfig `config:"encryption"`
+}
+
+// encryptionConfig lists the keys used for encryption at rest. Keys are
+// meant to be referenced from the keystore, e.g. `key: ${DISKQUEUE_KEY}`.
+type encryptionConfig struct {
+	Keys []encryptionKeyConfig `config:"keys" validate:"required"`
+}
+
+type encryptionKeyConfig struct {
+	ID string `config:"id" validate:"required"`
+
+	// Key is the base64 encoded AES-128, AES-192 or AES-256 key.
+	Key string `config:"key" validate:"required"`
+}
+
+func (c *encryptionConfig) Validate() error {
+	_, err := c.encryptionKeys()
+	return err
+}
+
+func (c *encryptionConfig) encryptionKeys() ([]EncryptionKey, error) {
+	keys := make([]EncryptionKey, 0, len(c.Keys))
+	seen := make(map[string]struct{}, len(c.Keys))
+	for _, kc := range c.Keys {
+		if len(kc.ID) > maxEncryptionKeyIDLength {
+			return nil, fmt.Errorf(
+				"disk queue encryption key ID %q is longer than %d bytes",
+				kc.ID, maxEncryptionKeyIDLength)
+		}
+		if _, ok := seen[kc.ID]; ok {
+			return nil, fmt.Errorf(
+				"disk queue encryption key ID %q is used more than once", kc.ID)
+		}
+		seen[kc.ID] = struct{}{}
+
+		key, err := base64.StdEncoding.DecodeString(kc.Key)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"disk queue encryption key %q is not valid base64: %w", kc.ID, err)
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf(
+				"disk queue encryption key %q must be 16, 24 or 32 bytes, got %d",
+				kc.ID, len(key))
+		}
+		keys = append(keys, EncryptionKey{ID: kc.ID, Key: key})
+	}
+	return keys, nil
 }
 
 func (c *userConfig) Validate() error {
@@ -164,6 +223,14 @@ func SettingsForUserConfig(config *config.C) (Settings, error) {
 		settings.MaxRetryInterval = *userConfig.MaxRetryInterval
 	}
 
+	if userConfig.Encryption != nil {
+		keys, err := userConfig.Encryption.encryptionKeys()
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.EncryptionKeys = keys
+	}
+
 	return settings, nil
 }
 
@@ -0,0 +1,240 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrDecryption is returned (wrapped) when an encrypted segment can't be
+// decrypted, either because the key it was written with is no longer
+// configured or because the data fails authentication.
+var ErrDecryption = errors.New("could not decrypt segment data")
+
+// maxEncryptionKeyIDLength is the longest key ID that fits in the one byte
+// length prefix of the encryption preamble.
+const maxEncryptionKeyIDLength = 255
+
+// encryptionNonceSize is the standard AES-GCM nonce size.
+const encryptionNonceSize = 12
+
+// EncryptionKey is an AES key used to encrypt segments on disk. The ID is
+// stored in every segment written with the key, so the segment can still
+// be decrypted after the active key is rotated.
+type EncryptionKey struct {
+	ID string
+
+	// Key is the raw AES key, 16, 24 or 32 bytes long.
+	Key []byte
+}
+
+func (k EncryptionKey) newAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key %q: %w", k.ID, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptionAdditionalData binds each record to the key ID and to its
+// position in the segment, so records can't be reordered or moved
+// between segments encrypted with different keys.
+func encryptionAdditionalData(dst []byte, keyID string, record uint64) []byte {
+	dst = append(dst[:0], keyID...)
+	return binary.BigEndian.AppendUint64(dst, record)
+}
+
+// EncryptionWriter encrypts a stream with AES-GCM. The stream starts with
+// a preamble holding the ID of the key, followed by one sealed record
+// per Write call: a 4-byte little-endian length, the nonce and the
+// ciphertext with its authentication tag.
+type EncryptionWriter struct {
+	dst    WriteCloseSyncer
+	aead   cipher.AEAD
+	keyID  string
+	record uint64
+	nonce  [encryptionNonceSize]byte
+	ad     []byte
+	buf    []byte
+}
+
+// NewEncryptionWriter writes the encryption preamble for the given key
+// to w and returns a writer that encrypts everything written to it.
+func NewEncryptionWriter(w WriteCloseSyncer, key EncryptionKey) (*EncryptionWriter, error) {
+	if len(key.ID) == 0 || len(key.ID) > maxEncryptionKeyIDLength {
+		return nil, fmt.Errorf("invalid encryption key ID length %d", len(key.ID))
+	}
+	aead, err := key.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	preamble := make([]byte, 0, 1+len(key.ID))
+	preamble = append(preamble, byte(len(key.ID)))
+	preamble = append(preamble, key.ID...)
+	if _, err := w.Write(preamble); err != nil {
+		return nil, fmt.Errorf("could not write encryption preamble: %w", err)
+	}
+	return &EncryptionWriter{
+		dst:   w,
+		aead:  aead,
+		keyID: key.ID,
+	}, nil
+}
+
+func (w *EncryptionWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	sealedLength := encryptionNonceSize + len(p) + w.aead.Overhead()
+	w.buf = binary.LittleEndian.AppendUint32(w.buf[:0], uint32(sealedLength)) //nolint:gosec // G115 frames are bounded by the segment size
+	if _, err := rand.Read(w.nonce[:]); err != nil {
+		return 0, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	w.buf = append(w.buf, w.nonce[:]...)
+	w.ad = encryptionAdditionalData(w.ad, w.keyID, w.record)
+	w.buf = w.aead.Seal(w.buf, w.nonce[:], p, w.ad)
+	if _, err := w.dst.Write(w.buf); err != nil {
+		return 0, err
+	}
+	w.record++
+	return len(p), nil
+}
+
+func (w *EncryptionWriter) Close() error {
+	return w.dst.Close()
+}
+
+func (w *EncryptionWriter) Sync() error {
+	return w.dst.Sync()
+}
+
+// EncryptionReader decrypts a stream written by EncryptionWriter, using
+// whichever of the configured keys matches the ID in the preamble.
+type EncryptionReader struct {
+	src    io.ReadCloser
+	keys   []EncryptionKey
+	aead   cipher.AEAD
+	keyID  string
+	record uint64
+	ad     []byte
+	sealed []byte
+	plain  []byte
+	offset int
+}
+
+// NewEncryptionReader reads the encryption preamble from r and returns a
+// reader for the decrypted stream. The error wraps ErrDecryption if none
+// of the given keys matches the one the stream was written with.
+func NewEncryptionReader(r io.ReadCloser, keys []EncryptionKey) (*EncryptionReader, error) {
+	er := &EncryptionReader{
+		src:  r,
+		keys: keys,
+	}
+	if err := er.Reset(); err != nil {
+		return nil, err
+	}
+	return er, nil
+}
+
+func (r *EncryptionReader) Read(buf []byte) (int, error) {
+	if r.offset >= len(r.plain) {
+		if err := r.nextRecord(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(buf, r.plain[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+func (r *EncryptionReader) nextRecord() error {
+	var sealedLength uint32
+	if err := binary.Read(r.src, binary.LittleEndian, &sealedLength); err != nil {
+		// A clean EOF between records is the end of the stream.
+		return err
+	}
+	if sealedLength < uint32(encryptionNonceSize+r.aead.Overhead()) { //nolint:gosec // G115 constant sizes
+		return fmt.Errorf("%w: record %d is too short (%d bytes)",
+			ErrDecryption, r.record, sealedLength)
+	}
+	if cap(r.sealed) < int(sealedLength) {
+		r.sealed = make([]byte, sealedLength)
+	}
+	r.sealed = r.sealed[:sealedLength]
+	if _, err := io.ReadFull(r.src, r.sealed); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	nonce := r.sealed[:encryptionNonceSize]
+	r.ad = encryptionAdditionalData(r.ad, r.keyID, r.record)
+	plain, err := r.aead.Open(r.plain[:0], nonce, r.sealed[encryptionNonceSize:], r.ad)
+	if err != nil {
+		return fmt.Errorf("%w: record %d with key %q failed authentication",
+			ErrDecryption, r.record, r.keyID)
+	}
+	r.plain = plain
+	r.offset = 0
+	r.record++
+	return nil
+}
+
+func (r *EncryptionReader) Close() error {
+	return r.src.Close()
+}
+
+// Reset sets up decryption again, assumes that caller has already set
+// the src to the position of the encryption preamble.
+func (r *EncryptionReader) Reset() error {
+	r.record = 0
+	r.plain = r.plain[:0]
+	r.offset = 0
+
+	var idLength [1]byte
+	if _, err := io.ReadFull(r.src, idLength[:]); err != nil {
+		return fmt.Errorf("could not read encryption preamble: %w", err)
+	}
+	id := make([]byte, idLength[0])
+	if _, err := io.ReadFull(r.src, id); err != nil {
+		return fmt.Errorf("could not read encryption key ID: %w", err)
+	}
+	keyID := string(id)
+	if r.aead != nil && keyID == r.keyID {
+		return nil
+	}
+	for _, key := range r.keys {
+		if key.ID != keyID {
+			continue
+		}
+		aead, err := key.newAEAD()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDecryption, err)
+		}
+		r.aead = aead
+		r.keyID = keyID
+		return nil
+	}
+	return fmt.Errorf("%w: key %q is not configured", ErrDecryption, keyID)
+}
@@ -0,0 +1,227 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+var testEncryptionKeys = []EncryptionKey{
+	{ID: "current", Key: []byte("0123456789abcdef0123456789abcdef")},
+	{ID: "previous", Key: []byte("fedcba9876543210")},
+}
+
+func encryptForTest(t *testing.T, key EncryptionKey, plaintexts ...[]byte) []byte {
+	t.Helper()
+	var dst bytes.Buffer
+	ew, err := NewEncryptionWriter(NopWriteCloseSyncer(NopWriteCloser(&dst)), key)
+	require.NoError(t, err)
+	for _, p := range plaintexts {
+		n, err := ew.Write(p)
+		require.NoError(t, err)
+		require.Equal(t, len(p), n)
+	}
+	require.NoError(t, ew.Close())
+	return dst.Bytes()
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	tests := map[string]struct {
+		key        EncryptionKey
+		plaintexts [][]byte
+	}{
+		"AES-256 single record": {
+			key:        testEncryptionKeys[0],
+			plaintexts: [][]byte{[]byte("abcdefghijklmnopqrstuvwxzy01234567890")},
+		},
+		"AES-128 multiple records": {
+			key:        testEncryptionKeys[1],
+			plaintexts: [][]byte{[]byte("abc"), []byte("defg"), bytes.Repeat([]byte("h"), 4096)},
+		},
+	}
+	for name, tc := range tests {
+		encrypted := encryptForTest(t, tc.key, tc.plaintexts...)
+		assert.NotContains(t, string(encrypted), "abc", name)
+
+		er, err := NewEncryptionReader(io.NopCloser(bytes.NewReader(encrypted)), testEncryptionKeys)
+		require.NoError(t, err, name)
+		var dst bytes.Buffer
+		_, err = io.Copy(&dst, er)
+		assert.NoError(t, err, name)
+		assert.Equal(t, bytes.Join(tc.plaintexts, nil), dst.Bytes(), name)
+	}
+}
+
+func TestEncryptionUnknownKey(t *testing.T) {
+	encrypted := encryptForTest(t, testEncryptionKeys[1], []byte("abc"))
+
+	// After rotating the previous key out of the configuration, segments
+	// written with it can no longer be read.
+	_, err := NewEncryptionReader(io.NopCloser(bytes.NewReader(encrypted)), testEncryptionKeys[:1])
+	assert.ErrorIs(t, err, ErrDecryption)
+	assert.ErrorContains(t, err, `"previous"`)
+}
+
+func TestEncryptionTamperedRecord(t *testing.T) {
+	encrypted := encryptForTest(t, testEncryptionKeys[0], []byte("abc"), []byte("defg"))
+	encrypted[len(encrypted)-1] ^= 0xff
+
+	er, err := NewEncryptionReader(io.NopCloser(bytes.NewReader(encrypted)), testEncryptionKeys)
+	require.NoError(t, err)
+	dst := make([]byte, 3)
+	_, err = er.Read(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), dst)
+	_, err = er.Read(dst)
+	assert.ErrorIs(t, err, ErrDecryption)
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	settings := DefaultSettings()
+	settings.Path = dir
+
+	// Write a segment with the key that is about to be rotated out.
+	settings.EncryptionKeys = testEncryptionKeys[1:]
+	old := &queueSegment{id: 0}
+	sw, err := old.getWriter(settings, nil)
+	require.NoError(t, err)
+	_, err = sw.Write([]byte("old key"))
+	require.NoError(t, err)
+	require.NoError(t, sw.Close())
+
+	// Rotate: new segments use the new active key, and the old segment
+	// is still readable.
+	settings.EncryptionKeys = testEncryptionKeys
+	current := &queueSegment{id: 1}
+	sw, err = current.getWriter(settings, nil)
+	require.NoError(t, err)
+	_, err = sw.Write([]byte("new key"))
+	require.NoError(t, err)
+	require.NoError(t, sw.Close())
+
+	for segment, expected := range map[*queueSegment]string{old: "old key", current: "new key"} {
+		sr, err := segment.getReader(settings, nil)
+		require.NoError(t, err)
+		data, err := io.ReadAll(sr)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+		sr.Close()
+	}
+
+	// Once the old key is dropped, reading the old segment reports a
+	// decryption error.
+	settings.EncryptionKeys = testEncryptionKeys[:1]
+	_, err = old.getReader(settings, nil)
+	assert.ErrorIs(t, err, ErrDecryption)
+
+	rl := newReaderLoop(settings, nil, nil)
+	response := rl.processRequest(readerLoopRequest{
+		segment:     old,
+		endPosition: segmentHeaderSize + 100,
+	})
+	assert.ErrorIs(t, response.err, ErrDecryption)
+	assert.ErrorContains(t, response.err, "segment 0 can't be decrypted")
+}
+
+func TestEncryptionConfig(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]any
+		keys   []EncryptionKey
+		err    string
+	}{
+		"no encryption": {
+			config: map[string]any{"max_size": "1GB"},
+		},
+		"rotated keys": {
+			config: map[string]any{
+				"max_size": "1GB",
+				"encryption.keys": []map[string]any{
+					{"id": "current", "key": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+					{"id": "previous", "key": "ZmVkY2JhOTg3NjU0MzIxMA=="},
+				},
+			},
+			keys: testEncryptionKeys,
+		},
+		"invalid key size": {
+			config: map[string]any{
+				"max_size":        "1GB",
+				"encryption.keys": []map[string]any{{"id": "short", "key": "YWJj"}},
+			},
+			err: "must be 16, 24 or 32 bytes",
+		},
+		"duplicate key ID": {
+			config: map[string]any{
+				"max_size": "1GB",
+				"encryption.keys": []map[string]any{
+					{"id": "a", "key": "ZmVkY2JhOTg3NjU0MzIxMA=="},
+					{"id": "a", "key": "ZmVkY2JhOTg3NjU0MzIxMA=="},
+				},
+			},
+			err: "used more than once",
+		},
+	}
+	for name, tc := range tests {
+		settings, err := SettingsForUserConfig(conf.MustNewConfigFrom(tc.config))
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err, name)
+			continue
+		}
+		require.NoError(t, err, name)
+		assert.Equal(t, tc.keys, settings.EncryptionKeys, name)
+	}
+}
+
+func TestEncryptionFrameCountScan(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKeys = testEncryptionKeys
+
+	// Write two frames without updating the header's frame count, as
+	// happens when the segment isn't closed cleanly.
+	segment := &queueSegment{id: 0}
+	sw, err := segment.getWriter(settings, nil)
+	require.NoError(t, err)
+	for _, data := range [][]byte{[]byte("abc"), []byte("defg")} {
+		var frame bytes.Buffer
+		frameSize := uint32(len(data) + frameMetadataSize)
+		require.NoError(t, binary.Write(&frame, binary.LittleEndian, frameSize))
+		frame.Write(data)
+		require.NoError(t, binary.Write(&frame, binary.LittleEndian, computeChecksum(data)))
+		require.NoError(t, binary.Write(&frame, binary.LittleEndian, frameSize))
+		_, err = sw.Write(frame.Bytes())
+		require.NoError(t, err)
+	}
+	require.NoError(t, sw.Close())
+
+	header, err := readSegmentHeaderWithFrameCount(settings.segmentPath(0, nil), settings.EncryptionKeys)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), header.frameCount)
+	assert.Equal(t, ENABLE_ENCRYPTION, header.options, "encryption must use its own option bit")
+
+	_, err = readSegmentHeaderWithFrameCount(settings.segmentPath(0, nil), testEncryptionKeys[1:])
+	assert.ErrorIs(t, err, ErrDecryption)
+}
@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// Inspector gives read-only access to the contents of a disk queue
+// directory without opening the queue, e.g. to look at the pending events
+// of a Beat that is stuck or has crashed. It never modifies the segment
+// files or the state file.
+type Inspector struct {
+	logger   *logp.Logger
+	settings Settings
+	paths    *paths.Path
+}
+
+// SegmentInfo describes a segment file found in the queue directory.
+type SegmentInfo struct {
+	ID            uint64
+	Path          string
+	SchemaVersion uint32
+	FrameCount    uint32
+	SizeOnDisk    uint64
+	Compressed    bool
+	Encrypted     bool
+}
+
+// Position is the position of the oldest unacknowledged event, as stored
+// in the queue's state file.
+type Position struct {
+	SegmentID  uint64
+	ByteIndex  uint64
+	FrameIndex uint64
+}
+
+// NewInspector returns an Inspector for the queue with the given settings.
+func NewInspector(logger *logp.Logger, settings Settings, paths *paths.Path) *Inspector {
+	return &Inspector{
+		logger:   logger.Named("diskqueue"),
+		settings: settings,
+		paths:    paths,
+	}
+}
+
+// Directory returns the path of the queue directory.
+func (i *Inspector) Directory() string {
+	return i.settings.directoryPath(i.paths)
+}
+
+// StateFile returns the path of the queue's state file.
+func (i *Inspector) StateFile() string {
+	return i.settings.stateFilePath(i.paths)
+}
+
+// Position returns the read position from the queue's state file. If
+// there is no state file yet, or nothing was acknowledged since it was
+// created, the queue starts at its oldest segment and a zero Position is
+// returned.
+func (i *Inspector) Position() (Position, error) {
+	position, err := queuePositionFromPath(i.StateFile())
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, io.EOF) {
+		return Position{}, fmt.Errorf("couldn't read state file: %w", err)
+	}
+	if position.frameIndex == 0 {
+		// Same as on queue startup: states from older versions lack the
+		// frame index, so the segment is read from its beginning.
+		position.byteIndex = 0
+	}
+	return Position{
+		SegmentID:  uint64(position.segmentID),
+		ByteIndex:  position.byteIndex,
+		FrameIndex: position.frameIndex,
+	}, nil
+}
+
+// Segments returns the segment files in the queue directory, sorted by ID.
+func (i *Inspector) Segments() ([]SegmentInfo, error) {
+	segments, err := scanExistingSegments(i.logger, i.Directory(), i.settings.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SegmentInfo, 0, len(segments))
+	for _, segment := range segments {
+		info := SegmentInfo{
+			ID:            uint64(segment.id),
+			Path:          i.settings.segmentPath(segment.id, i.paths),
+			SchemaVersion: *segment.schemaVersion,
+			FrameCount:    segment.frameCount,
+			SizeOnDisk:    segment.byteCount,
+		}
+		if header, err := readSegmentHeaderFromPath(info.Path); err == nil {
+			info.Compressed = header.options&ENABLE_COMPRESSION != 0
+			info.Encrypted = header.options&ENABLE_ENCRYPTION != 0
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// PendingEvents returns the number of events that haven't been
+// acknowledged yet, computed the same way as when the queue starts up.
+func (i *Inspector) PendingEvents() (uint64, error) {
+	position, err := i.Position()
+	if err != nil {
+		return 0, err
+	}
+	segments, err := i.Segments()
+	if err != nil {
+		return 0, err
+	}
+	var pending uint64
+	for _, segment := range segments {
+		switch {
+		case segment.ID < position.SegmentID:
+			// Already acknowledged, waiting to be deleted.
+		case segment.ID == position.SegmentID:
+			if uint64(segment.FrameCount) > position.FrameIndex {
+				pending += uint64(segment.FrameCount) - position.FrameIndex
+			}
+		default:
+			pending += uint64(segment.FrameCount)
+		}
+	}
+	return pending, nil
+}
+
+// ReadEvents decodes the events of the given segment, starting at frame
+// index firstFrame, and calls fn for each of them with its frame index
+// within the segment. Reading stops at the end of the segment or when fn
+// returns an error, which is then returned by ReadEvents.
+func (i *Inspector) ReadEvents(
+	id uint64,
+	firstFrame uint64,
+	fn func(frameIndex uint64, event publisher.Event) error,
+) error {
+	segment := &queueSegment{id: segmentID(id)}
+	path := i.settings.segmentPath(segment.id, i.paths)
+	header, err := readSegmentHeaderFromPath(path)
+	if err != nil {
+		return err
+	}
+	segment.schemaVersion = &header.version
+
+	handle, err := segment.getReader(i.settings, i.paths)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	if _, err := handle.Seek(int64(segment.headerSize()), io.SeekStart); err != nil { //nolint:gosec // G115 header size is at most 12
+		return err
+	}
+
+	rl := newReaderLoop(i.settings, nil, i.paths)
+	rl.decoder.serializationFormat = handle.serializationFormat
+	for frameIndex := uint64(0); ; frameIndex++ {
+		frame, err := rl.nextFrame(handle, ^uint64(0))
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// A clean end of the segment.
+				return nil
+			}
+			return rl.readError(segment, fmt.Errorf("frame %d: %w", frameIndex, err))
+		}
+		if frameIndex < firstFrame {
+			continue
+		}
+		if err := fn(frameIndex, frame.event); err != nil {
+			return err
+		}
+	}
+}
+
+func readSegmentHeaderFromPath(path string) (*segmentHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open segment file '%s': %w", path, err)
+	}
+	defer file.Close()
+	return readSegmentHeader(autoRetryReader{file})
+}
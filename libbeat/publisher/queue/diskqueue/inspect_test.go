@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func TestInspector(t *testing.T) {
+	for name, keys := range map[string][]EncryptionKey{
+		"plain":     nil,
+		"encrypted": testEncryptionKeys,
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger := logptest.NewTestingLogger(t, "")
+			settings := DefaultSettings()
+			settings.Path = t.TempDir()
+			settings.MaxSegmentSize = 4 * 1024
+			settings.EncryptionKeys = keys
+
+			q, err := NewQueue(logger, nil, settings, nil, &paths.Path{})
+			require.NoError(t, err)
+			// Wait for all events to be written before closing the queue.
+			written := make(chan int, 4)
+			producer := q.Producer(queue.ProducerConfig{ACK: func(count int) { written <- count }})
+			publishAndACKSingleEvent(t, q, producer, "event-1")
+			for _, msg := range []string{"event-2", "event-3", "event-4"} {
+				_, ok := producer.Publish(makeDiskQueueTestEvent(msg))
+				require.True(t, ok)
+			}
+			for total := 0; total < 4; {
+				total += <-written
+			}
+			producer.Close()
+			closeQueueAndWait(t, q)
+
+			inspector := NewInspector(logger, settings, &paths.Path{})
+			segments, err := inspector.Segments()
+			require.NoError(t, err)
+			require.NotEmpty(t, segments)
+			var frames uint32
+			for _, segment := range segments {
+				assert.Equal(t, keys != nil, segment.Encrypted)
+				frames += segment.FrameCount
+			}
+			assert.Equal(t, uint32(4), frames)
+
+			position, err := inspector.Position()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(1), position.FrameIndex)
+
+			pending, err := inspector.PendingEvents()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(3), pending)
+
+			var messages []any
+			for _, segment := range segments {
+				first := uint64(0)
+				if segment.ID == position.SegmentID {
+					first = position.FrameIndex
+				}
+				err := inspector.ReadEvents(segment.ID, first, func(_ uint64, event publisher.Event) error {
+					msg, _ := event.Content.Fields.GetValue("message")
+					messages = append(messages, msg)
+					return nil
+				})
+				require.NoError(t, err)
+			}
+			assert.Equal(t, []any{"event-2", "event-3", "event-4"}, messages)
+		})
+	}
+}
@@ -173,7 +173,7 @@ func NewQueue(
 
 	// Index any existing data segments to be placed in segments.reading.
 	initialSegments, err :=
-		scanExistingSegments(logger, settings.directoryPath(paths))
+		scanExistingSegments(logger, settings.directoryPath(paths), settings.EncryptionKeys)
 	if err != nil {
 		return nil, err
 	}
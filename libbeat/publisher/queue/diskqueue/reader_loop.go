@@ -19,6 +19,7 @@ package diskqueue
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
@@ -113,14 +114,14 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 	// Open the file and seek to the starting position.
 	handle, err := request.segment.getReader(rl.settings, rl.paths)
 	if err != nil {
-		return readerLoopResponse{err: err}
+		return readerLoopResponse{err: rl.readError(request.segment, err)}
 	}
 	defer handle.Close()
 	rl.decoder.serializationFormat = handle.serializationFormat
 
 	_, err = handle.Seek(int64(request.startPosition), io.SeekStart)
 	if err != nil {
-		return readerLoopResponse{err: err}
+		return readerLoopResponse{err: rl.readError(request.segment, err)}
 	}
 
 	targetLength := request.endPosition - request.startPosition
@@ -166,7 +167,7 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 			return readerLoopResponse{
 				frameCount: frameCount,
 				byteCount:  byteCount,
-				err:        err,
+				err:        rl.readError(request.segment, err),
 			}
 		}
 
@@ -185,6 +186,23 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 	}
 }
 
+// readError annotates errors that come from decrypting the segment, so
+// the log explains why its remaining events are being skipped rather
+// than reporting a generic framing error.
+func (rl *readerLoop) readError(segment *queueSegment, err error) error {
+	if !errors.Is(err, ErrDecryption) {
+		return err
+	}
+	keyIDs := make([]string, len(rl.settings.EncryptionKeys))
+	for i, key := range rl.settings.EncryptionKeys {
+		keyIDs[i] = key.ID
+	}
+	return fmt.Errorf(
+		"segment %d can't be decrypted with the configured encryption keys %q, "+
+			"its remaining events will be skipped: %w",
+		segment.id, keyIDs, err)
+}
+
 // nextFrame reads and decodes one frame from the given file handle, as long
 // it does not exceed the given length bound. The returned frame leaves the
 // segment and frame IDs unset.
@@ -154,6 +154,7 @@ const (
 	_                  uint32 = 1 << iota // 0x1
 	ENABLE_COMPRESSION                    // 0x2
 	ENABLE_PROTOBUF                       // 0x4
+	ENABLE_ENCRYPTION                     // 0x8
 )
 
 // Sort order: we store loaded segments in ascending order by their id.
@@ -164,8 +165,9 @@ func (s bySegmentID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s bySegmentID) Less(i, j int) bool { return s[i].id < s[j].id }
 
 // Scan the given path for segment files, and return them in a list
-// ordered by segment id.
-func scanExistingSegments(logger *logp.Logger, pathStr string) ([]*queueSegment, error) {
+// ordered by segment id. The encryption keys are needed to count the
+// frames of encrypted segments that weren't closed cleanly.
+func scanExistingSegments(logger *logp.Logger, pathStr string, keys []EncryptionKey) ([]*queueSegment, error) {
 	dirEntries, err := os.ReadDir(pathStr)
 	if err != nil {
 		return nil, fmt.Errorf("could not read queue directory '%s': %w", pathStr, err)
@@ -185,7 +187,7 @@ func scanExistingSegments(logger *logp.Logger, pathStr string) ([]*queueSegment,
 			// don't match the "[uint64].seg" pattern.
 			if id, err := strconv.ParseUint(components[0], 10, 64); err == nil {
 				fullPath := path.Join(pathStr, file.Name())
-				header, err := readSegmentHeaderWithFrameCount(fullPath)
+				header, err := readSegmentHeaderWithFrameCount(fullPath, keys)
 				if header == nil {
 					logger.Errorf("couldn't load segment file '%v': %v", fullPath, err)
 					continue
@@ -256,8 +258,19 @@ func (segment *queueSegment) getReader(queueSettings Settings, paths *paths.Path
 		sr.serializationFormat = SerializationCBOR
 	}
 
+	var src io.ReadCloser = sr.src
+	if (header.options & ENABLE_ENCRYPTION) == ENABLE_ENCRYPTION {
+		sr.er, err = NewEncryptionReader(sr.src, queueSettings.EncryptionKeys)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf(
+				"couldn't set up decryption for segment %d: %w", segment.id, err)
+		}
+		src = sr.er
+	}
+
 	if (header.options & ENABLE_COMPRESSION) == ENABLE_COMPRESSION {
-		sr.cr = NewCompressionReader(sr.src)
+		sr.cr = NewCompressionReader(src)
 	}
 	return sr, nil
 }
@@ -276,6 +289,9 @@ func (segment *queueSegment) getWriter(queueSettings Settings, paths *paths.Path
 	if queueSettings.UseCompression {
 		options = options | ENABLE_COMPRESSION
 	}
+	if len(queueSettings.EncryptionKeys) > 0 {
+		options = options | ENABLE_ENCRYPTION
+	}
 
 	sw := &segmentWriter{}
 	sw.dst = file
@@ -284,8 +300,19 @@ func (segment *queueSegment) getWriter(queueSettings Settings, paths *paths.Path
 		return nil, err
 	}
 
+	var dst WriteCloseSyncer = sw.dst
+	if (options & ENABLE_ENCRYPTION) == ENABLE_ENCRYPTION {
+		// New segments are always encrypted with the first (active) key.
+		sw.ew, err = NewEncryptionWriter(sw.dst, queueSettings.EncryptionKeys[0])
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		dst = sw.ew
+	}
+
 	if (options & ENABLE_COMPRESSION) == ENABLE_COMPRESSION {
-		sw.cw = NewCompressionWriter(sw.dst)
+		sw.cw = NewCompressionWriter(dst)
 	}
 
 	return sw, nil
@@ -317,7 +344,7 @@ func (segment *queueSegment) getWriterWithRetry(
 // file was not closed cleanly), it attempts to calculate it manually
 // by scanning the file, and returns a struct with the "correct"
 // frame count.
-func readSegmentHeaderWithFrameCount(path string) (*segmentHeader, error) {
+func readSegmentHeaderWithFrameCount(path string, keys []EncryptionKey) (*segmentHeader, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -344,6 +371,29 @@ func readSegmentHeaderWithFrameCount(path string) (*segmentHeader, error) {
 	//   and still has the placeholder value of 0.
 	// In either case, the right thing to do is to scan the file
 	// and fill in the frame count manually.
+	skip := func(n int64) error {
+		_, err := file.Seek(n, io.SeekCurrent)
+		return err
+	}
+	// Encrypted or compressed segments have to be scanned through their
+	// decoded stream, which can't seek, so frames are skipped by reading.
+	if header.options&(ENABLE_ENCRYPTION|ENABLE_COMPRESSION) != 0 {
+		var data io.ReadCloser = file
+		if (header.options & ENABLE_ENCRYPTION) == ENABLE_ENCRYPTION {
+			data, err = NewEncryptionReader(file, keys)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if (header.options & ENABLE_COMPRESSION) == ENABLE_COMPRESSION {
+			data = NewCompressionReader(data)
+		}
+		reader = autoRetryReader{data}
+		skip = func(n int64) error {
+			_, err := io.CopyN(io.Discard, data, n)
+			return err
+		}
+	}
 	for {
 		var frameLength uint32
 		err = binary.Read(reader, binary.LittleEndian, &frameLength)
@@ -360,7 +410,7 @@ func readSegmentHeaderWithFrameCount(path string) (*segmentHeader, error) {
 		// the current frame to make sure the trailing length matches before
 		// advancing to the next frame (otherwise we might accept an impossible
 		// length).
-		_, err = file.Seek(int64(frameLength-8), io.SeekCurrent)
+		err = skip(int64(frameLength - 8))
 		if err != nil {
 			break
 		}
@@ -444,26 +494,33 @@ func (segments *diskQueueSegments) sizeOnDisk() uint64 {
 // less compressable.
 type segmentReader struct {
 	src                 io.ReadSeekCloser
+	er                  *EncryptionReader
 	cr                  *CompressionReader
 	serializationFormat SerializationFormat
 }
 
-func (r *segmentReader) Read(p []byte) (int, error) {
+// data returns the reader for the segment's (decrypted, decompressed)
+// data region.
+func (r *segmentReader) data() io.ReadCloser {
 	if r.cr != nil {
-		return r.cr.Read(p)
+		return r.cr
 	}
-	return r.src.Read(p)
+	if r.er != nil {
+		return r.er
+	}
+	return r.src
+}
+
+func (r *segmentReader) Read(p []byte) (int, error) {
+	return r.data().Read(p)
 }
 
 func (r *segmentReader) Close() error {
-	if r.cr != nil {
-		return r.cr.Close()
-	}
-	return r.src.Close()
+	return r.data().Close()
 }
 
 func (r *segmentReader) Seek(offset int64, whence int) (int64, error) {
-	if r.cr != nil {
+	if r.cr != nil || r.er != nil {
 		//can't seek before segment header
 		if (offset + int64(whence)) < segmentHeaderSize {
 			return 0, fmt.Errorf("illegal seek offset %d, whence %d", offset, whence)
@@ -471,10 +528,17 @@ func (r *segmentReader) Seek(offset int64, whence int) (int64, error) {
 		if _, err := r.src.Seek(segmentHeaderSize, io.SeekStart); err != nil {
 			return 0, fmt.Errorf("could not seek past segment header: %w", err)
 		}
-		if err := r.cr.Reset(); err != nil {
-			return 0, fmt.Errorf("could not reset compression: %w", err)
+		if r.er != nil {
+			if err := r.er.Reset(); err != nil {
+				return 0, fmt.Errorf("could not reset encryption: %w", err)
+			}
+		}
+		if r.cr != nil {
+			if err := r.cr.Reset(); err != nil {
+				return 0, fmt.Errorf("could not reset compression: %w", err)
+			}
 		}
-		written, err := io.CopyN(io.Discard, r.cr, (offset+int64(whence))-segmentHeaderSize)
+		written, err := io.CopyN(io.Discard, r.data(), (offset+int64(whence))-segmentHeaderSize)
 		return written + segmentHeaderSize, err
 	}
 	return r.src.Seek(offset, whence)
@@ -489,28 +553,32 @@ func (r *segmentReader) Seek(offset int64, whence int) (int64, error) {
 // data less compressable.
 type segmentWriter struct {
 	dst *os.File
+	ew  *EncryptionWriter
 	cw  *CompressionWriter
 }
 
-func (w *segmentWriter) Write(p []byte) (int, error) {
+// data returns the writer for the segment's data region, which
+// compresses and / or encrypts as needed.
+func (w *segmentWriter) data() WriteCloseSyncer {
 	if w.cw != nil {
-		return w.cw.Write(p)
+		return w.cw
 	}
-	return w.dst.Write(p)
+	if w.ew != nil {
+		return w.ew
+	}
+	return w.dst
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	return w.data().Write(p)
 }
 
 func (w *segmentWriter) Close() error {
-	if w.cw != nil {
-		return w.cw.Close()
-	}
-	return w.dst.Close()
+	return w.data().Close()
 }
 
 func (w *segmentWriter) Sync() error {
-	if w.cw != nil {
-		return w.cw.Sync()
-	}
-	return w.dst.Sync()
+	return w.data().Sync()
 }
 
 func (w *segmentWriter) WriteHeader(options uint32) error {
@@ -60,6 +60,9 @@ type entry struct {
 	Flags     uint32
 	Meta      mapstr.M
 	Fields    mapstr.M
+	// Enqueued is the event's EnqueuedAt in Unix nanoseconds, or 0 if it
+	// is unknown.
+	Enqueued int64 `struct:",omitempty"`
 }
 
 func newEventEncoder(format SerializationFormat) *eventEncoder {
@@ -102,11 +105,16 @@ func (e *eventEncoder) encode(evt any) ([]byte, error) {
 func (e *eventEncoder) encode_publisher_event(event publisher.Event) ([]byte, error) {
 	e.buf.Reset()
 
+	var enqueued int64
+	if !event.EnqueuedAt.IsZero() {
+		enqueued = event.EnqueuedAt.UnixNano()
+	}
 	err := e.folder.Fold(entry{
 		Timestamp: event.Content.Timestamp.UTC().UnixNano(),
 		Flags:     uint32(event.Flags),
 		Meta:      event.Content.Meta,
 		Fields:    event.Content.Fields,
+		Enqueued:  enqueued,
 	})
 	if err != nil {
 		e.reset()
@@ -180,6 +188,10 @@ func (d *eventDecoder) decodeJSONAndCBOR() (publisher.Event, error) {
 		return publisher.Event{}, err
 	}
 
+	var enqueuedAt time.Time
+	if to.Enqueued != 0 {
+		enqueuedAt = time.Unix(0, to.Enqueued)
+	}
 	return publisher.Event{
 		Flags: publisher.EventFlags(to.Flags), //nolint:gosec // Flags field is uint32 on wire but valid values fit uint8
 		Content: beat.Event{
@@ -187,5 +199,6 @@ func (d *eventDecoder) decodeJSONAndCBOR() (publisher.Event, error) {
 			Fields:    to.Fields,
 			Meta:      to.Meta,
 		},
+		EnqueuedAt: enqueuedAt,
 	}, nil
 }
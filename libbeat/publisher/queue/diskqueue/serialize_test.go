@@ -0,0 +1,60 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestSerializeEnqueueTime(t *testing.T) {
+	enqueuedAt := time.Unix(1700000000, 123)
+	tests := map[string]time.Time{
+		"recorded": enqueuedAt,
+		"unknown":  {},
+	}
+	for name, enqueued := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoder := newEventEncoder(SerializationCBOR)
+			data, err := encoder.encode(publisher.Event{
+				Content: beat.Event{
+					Timestamp: time.Unix(1600000000, 0),
+					Fields:    mapstr.M{"message": "hello"},
+				},
+				EnqueuedAt: enqueued,
+			})
+			require.NoError(t, err)
+
+			decoder := newEventDecoder()
+			decoder.serializationFormat = SerializationCBOR
+			copy(decoder.Buffer(len(data)), data)
+			event, err := decoder.Decode()
+			require.NoError(t, err)
+			assert.True(t, enqueued.Equal(event.EnqueuedAt), "expected %v, got %v", enqueued, event.EnqueuedAt)
+			assert.Equal(t, enqueued.IsZero(), event.EnqueuedAt.IsZero())
+			assert.Equal(t, "hello", event.Content.Fields["message"])
+		})
+	}
+}
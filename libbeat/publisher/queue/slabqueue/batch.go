@@ -44,6 +44,11 @@ type batch[T any] struct {
 	done         bool
 	ackProducers []*producer[T]
 	ackCounts    []int
+
+	// ackSeqs holds, parallel to ackProducers, the publish sequence numbers of
+	// each producer's events in this batch. Only filled for prioritized queues,
+	// whose ACKs go through the producer's ackSequencer instead of ackCounts.
+	ackSeqs [][]uint64
 }
 
 // Count returns the number of events in the batch.
@@ -89,38 +94,8 @@ func (b *batch[T]) Done() {
 	}
 	pool := b.queue.pool
 
-	// Walk slots: collect per-producer counts, clear slot state. Most
-	// batches come from a single producer so the linear search stays
-	// small. Reuse b's own slice backing arrays (set by Get from a
-	// recycled batch) to avoid allocating per Done. The directory only
-	// grows under growMu and always covers an index this batch holds, so
-	// load it once instead of per slot.
-	var zero T
-	b.ackProducers = b.ackProducers[:0]
-	b.ackCounts = b.ackCounts[:0]
-	d := pool.dir.Load()
-	for _, i := range b.indices {
-		s := d.slot(i)
-		if s.producer != nil {
-			found := false
-			for j, p := range b.ackProducers {
-				if p == s.producer {
-					b.ackCounts[j]++
-					found = true
-					break
-				}
-			}
-			if !found {
-				b.ackProducers = append(b.ackProducers, s.producer)
-				b.ackCounts = append(b.ackCounts, 1)
-			}
-		}
-		if !b.freed {
-			s.event = zero
-		}
-		s.producer = nil
-		s.next = -1
-	}
+	// Walk slots: collect per-producer counts, clear slot state.
+	low := b.collectProducers()
 
 	// Return slots to the pool before doing anything else so blocked
 	// producers can make progress regardless of where this batch is in
@@ -128,6 +103,7 @@ func (b *batch[T]) Done() {
 	pool.observer.RemoveEvents(len(b.indices), 0)
 	n := len(b.indices)
 	pool.releaseSlots(b.indices)
+	pool.releaseLow(low)
 	// These events left circulation; return their per-queue budget so producers
 	// blocked on this queue's cap can proceed.
 	b.queue.releaseLive(n)
@@ -146,6 +122,59 @@ func (b *batch[T]) Done() {
 	pool.fireAndRecycle(toAck, forced)
 }
 
+// collectProducers walks the batch's slots, gathering per-producer event
+// counts (and, for prioritized producers, publish sequence numbers) into
+// ackProducers/ackCounts/ackSeqs, and clears each slot for reuse. Most batches
+// come from a single producer so the linear search stays small. The slices
+// reuse b's own backing arrays (kept across recycles) to avoid allocating per
+// batch. The directory only grows under growMu and always covers an index
+// this batch holds, so it is loaded once instead of per slot. It returns the
+// number of low priority events in the batch.
+func (b *batch[T]) collectProducers() int {
+	var zero T
+	low := 0
+	b.ackProducers = b.ackProducers[:0]
+	b.ackCounts = b.ackCounts[:0]
+	b.ackSeqs = b.ackSeqs[:0]
+	d := b.queue.pool.dir.Load()
+	for _, i := range b.indices {
+		s := d.slot(i)
+		if s.producer != nil {
+			j := -1
+			for k, pr := range b.ackProducers {
+				if pr == s.producer {
+					j = k
+					break
+				}
+			}
+			if j < 0 {
+				j = len(b.ackProducers)
+				b.ackProducers = append(b.ackProducers, s.producer)
+				b.ackCounts = append(b.ackCounts, 0)
+				if cap(b.ackSeqs) > j {
+					b.ackSeqs = b.ackSeqs[:j+1]
+					b.ackSeqs[j] = b.ackSeqs[j][:0]
+				} else {
+					b.ackSeqs = append(b.ackSeqs, nil)
+				}
+			}
+			b.ackCounts[j]++
+			if s.producer.seq != nil {
+				b.ackSeqs[j] = append(b.ackSeqs[j], s.seq)
+			}
+		}
+		if s.priority == PriorityLow {
+			low++
+		}
+		if !b.freed {
+			s.event = zero
+		}
+		s.producer = nil
+		s.next = -1
+	}
+	return low
+}
+
 // fireAndRecycle invokes producer ACK callbacks for each batch in the
 // publish-order list, then returns the batches to the recycle pool. The list
 // comes from Queue.drainReadyLocked and is no longer reachable from the queue,
@@ -167,10 +196,18 @@ func (p *Pool[T]) fireAndRecycle(head *batch[T], forced bool) {
 		// fan-out, so there is nothing to finish.
 		for ab := head; ab != nil; ab = ab.next {
 			for i, pr := range ab.ackProducers {
-				if pr.cfg.ACK != nil {
-					pr.cfg.ACK(ab.ackCounts[i])
+				count, finished := ab.ackCounts[i], ab.ackCounts[i]
+				if pr.seq != nil {
+					// Prioritized queue: only the part of the producer's publish
+					// sequence that is now contiguous may be reported.
+					count, finished = pr.seq.complete(ab.ackSeqs[i], true)
+				}
+				if pr.cfg.ACK != nil && count > 0 {
+					pr.cfg.ACK(count)
+				}
+				if finished > 0 {
+					pr.finishN(finished)
 				}
-				pr.finishN(ab.ackCounts[i])
 			}
 		}
 	}
@@ -212,37 +249,13 @@ func (b *batch[T]) Release() {
 	// counts so we can advance each producer's finished count below: an abandoned
 	// event is "finished" for ackWait purposes, so a producer whose tail batch is
 	// Released does not strand its ACKWaitChan.
-	var zero T
-	d := pool.dir.Load()
-	b.ackProducers = b.ackProducers[:0]
-	b.ackCounts = b.ackCounts[:0]
-	for _, i := range b.indices {
-		s := d.slot(i)
-		if s.producer != nil {
-			found := false
-			for j, pr := range b.ackProducers {
-				if pr == s.producer {
-					b.ackCounts[j]++
-					found = true
-					break
-				}
-			}
-			if !found {
-				b.ackProducers = append(b.ackProducers, s.producer)
-				b.ackCounts = append(b.ackCounts, 1)
-			}
-		}
-		if !b.freed {
-			s.event = zero
-		}
-		s.producer = nil
-		s.next = -1
-	}
+	low := b.collectProducers()
 
 	// Return slots to the pool.
 	pool.observer.RemoveEvents(len(b.indices), 0)
 	n := len(b.indices)
 	pool.releaseSlots(b.indices)
+	pool.releaseLow(low)
 	// Return the per-queue budget for the abandoned events.
 	b.queue.releaseLive(n)
 
@@ -281,6 +294,14 @@ func (b *batch[T]) Release() {
 	// force-close ackWait is already closed by Queue.Close, so finishN here is
 	// a harmless no-op.
 	for i, pr := range b.ackProducers {
+		if pr.seq != nil {
+			// Advance the sequence past the abandoned events without reporting
+			// them as acknowledged, so completions queued behind them can still
+			// finish.
+			_, finished := pr.seq.complete(b.ackSeqs[i], false)
+			pr.finishN(finished)
+			continue
+		}
 		pr.finishN(b.ackCounts[i])
 	}
 
@@ -30,8 +30,10 @@ import (
 // producer needed for ACK callbacks.
 type slot[T any] struct {
 	event    T
-	next     int // index of the next slot in the owning pipeline's FIFO, or -1
+	next     int // index of the next slot in the owning pipeline's FIFO lane, or -1
 	producer *producer[T]
+	seq      uint64   // producer publish sequence number, used by prioritized queues
+	priority Priority // lane the event was queued in
 }
 
 // Pool is the shared backing storage for events from multiple pipelines.
@@ -99,6 +101,21 @@ type Pool[T any] struct {
 	// Queue.Close calls remove themselves via disconnect.
 	mu     sync.Mutex
 	queues map[*Queue[T]]struct{}
+
+	// Low priority budget. Low priority events may occupy at most
+	// target - reserve*target slots (see lowLimit), so when the pool fills up
+	// they are held back first and the reserved remainder stays available to
+	// normal and high priority events. Only low priority publishes and releases
+	// touch these fields, so unprioritized traffic pays nothing for them.
+	//
+	//   lowLive: low priority events currently holding a slot.
+	//   lowWaiters/lowMu/lowCond: park point for low priority producers,
+	//          touched only on the blocking slow path.
+	reserve    float64
+	lowLive    atomic.Int64
+	lowWaiters atomic.Int64
+	lowMu      sync.Mutex
+	lowCond    *sync.Cond
 }
 
 // NewPool returns an initialized pool with all slots free.
@@ -119,7 +136,9 @@ func NewPool[T any](settings Settings, observer queue.Observer) *Pool[T] {
 		free:     newFreeList(),
 		closed:   make(chan struct{}),
 		queues:   make(map[*Queue[T]]struct{}),
+		reserve:  min(max(settings.PriorityReserve, 0), 1),
 	}
+	p.lowCond = sync.NewCond(&p.lowMu)
 	p.batchPool.New = func() any { return &batch[T]{} }
 	p.dir.Store(newDirectory[T](settings.Events))
 	for i := 0; i < settings.Events; i++ {
@@ -161,6 +180,8 @@ func (p *Pool[T]) setTarget(n int) {
 	case int64(n) < p.capacity.Load():
 		p.shrinkLocked()
 	}
+	// A raised target also raises the low priority limit.
+	p.wakeLowWaiters()
 }
 
 // setChunkCount swaps in a directory holding exactly n chunks, allocating fresh
@@ -267,6 +288,80 @@ func (p *Pool[T]) acquire(home int, closeCh <-chan struct{}) (int, bool) {
 	}
 }
 
+// lowLimit returns how many low priority events may hold a slot at once: the
+// pool's target minus the share reserved for higher priorities, never less
+// than one so low priority events always make progress.
+func (p *Pool[T]) lowLimit() int64 {
+	target := p.target.Load()
+	return max(target-int64(p.reserve*float64(target)), 1)
+}
+
+// tryReserveLow takes one unit of the low priority budget without blocking.
+func (p *Pool[T]) tryReserveLow() bool {
+	for {
+		cur := p.lowLive.Load()
+		if cur >= p.lowLimit() {
+			return false
+		}
+		if p.lowLive.CompareAndSwap(cur, cur+1) {
+			return true
+		}
+	}
+}
+
+// reserveLow takes one unit of the low priority budget, blocking until one is
+// available or the queue (closeCh) or pool is closed. It follows the same
+// register-then-recheck pattern as acquire so a concurrent release is not lost.
+func (p *Pool[T]) reserveLow(closeCh <-chan struct{}) bool {
+	if p.tryReserveLow() {
+		return true
+	}
+	for {
+		p.lowMu.Lock()
+		p.lowWaiters.Add(1)
+		if p.tryReserveLow() {
+			p.lowWaiters.Add(-1)
+			p.lowMu.Unlock()
+			return true
+		}
+		if p.isClosed() || chClosed(closeCh) {
+			p.lowWaiters.Add(-1)
+			p.lowMu.Unlock()
+			return false
+		}
+		p.lowCond.Wait()
+		p.lowWaiters.Add(-1)
+		p.lowMu.Unlock()
+		if p.isClosed() || chClosed(closeCh) {
+			return false
+		}
+		if p.tryReserveLow() {
+			return true
+		}
+	}
+}
+
+// releaseLow returns n units to the low priority budget and wakes parked low
+// priority producers.
+func (p *Pool[T]) releaseLow(n int) {
+	if n <= 0 {
+		return
+	}
+	p.lowLive.Add(int64(-n))
+	p.wakeLowWaiters()
+}
+
+// wakeLowWaiters wakes producers parked on the low priority budget, but only if
+// one might be waiting.
+func (p *Pool[T]) wakeLowWaiters() {
+	if p.lowWaiters.Load() == 0 {
+		return
+	}
+	p.lowMu.Lock()
+	p.lowCond.Broadcast()
+	p.lowMu.Unlock()
+}
+
 // releaseSlot returns slot index i to the free list. If a lazy shrink is in
 // progress (capacity > target) it then attempts to retire the top slot(s) now
 // that this release may have freed the current high-water index. The shrink
@@ -340,6 +435,7 @@ func (p *Pool[T]) putBatch(b *batch[T]) {
 	b.indices = b.indices[:0]
 	b.ackProducers = b.ackProducers[:0]
 	b.ackCounts = b.ackCounts[:0]
+	b.ackSeqs = b.ackSeqs[:0]
 	b.next = nil
 	b.done = false
 	b.freed = false
@@ -350,7 +446,13 @@ func (p *Pool[T]) putBatch(b *batch[T]) {
 // connected pipeline must call (*Queue).Close when it is finished; the pool is
 // only safe to call Shutdown on once every connected queue is closed.
 func (p *Pool[T]) Connect() *Queue[T] {
-	q := newQueue(p)
+	return p.ConnectWithPriority(nil)
+}
+
+// ConnectWithPriority is like Connect, but the returned Queue sorts published
+// entries into priority lanes using fn. A nil fn is equivalent to Connect.
+func (p *Pool[T]) ConnectWithPriority(fn PriorityFunc[T]) *Queue[T] {
+	q := newQueue(p, fn)
 	p.mu.Lock()
 	p.queues[q] = struct{}{}
 	p.mu.Unlock()
@@ -370,6 +472,7 @@ func (p *Pool[T]) Shutdown() {
 		// Wake any producers parked on a full pool so they observe the closed
 		// state and return instead of blocking forever.
 		p.free.wakeAll()
+		p.wakeLowWaiters()
 		p.mu.Lock()
 		queues := make([]*Queue[T], 0, len(p.queues))
 		for q := range p.queues {
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package slabqueue
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Priority is the class an event is queued under. A queue connected with a
+// PriorityFunc keeps one FIFO lane per class: Get hands out high priority
+// events before normal ones and normal before low, and when the pool runs
+// short of slots low priority events are the first to be held back (see
+// Settings.PriorityReserve).
+//
+// Reordering across lanes must not break the count-based producer ACKs that
+// order-sensitive consumers such as filestream's registry tracker rely on, so a
+// prioritized queue tracks a publish sequence number per event and only reports
+// an ACK for a producer once every event it published earlier has also been
+// acknowledged (see ackSequencer). Within a lane, events are always handed out
+// in publish order.
+type Priority uint8
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+
+	numPriorities = 3
+)
+
+// PriorityFunc classifies an entry. It is called once per published entry, on
+// the producer's goroutine, so it must be cheap and must not block.
+type PriorityFunc[T any] func(T) Priority
+
+var priorityNames = [numPriorities]string{
+	PriorityHigh:   "high",
+	PriorityNormal: "normal",
+	PriorityLow:    "low",
+}
+
+func (p Priority) String() string {
+	if int(p) < len(priorityNames) {
+		return priorityNames[p]
+	}
+	return fmt.Sprintf("priority(%d)", uint8(p))
+}
+
+// ParsePriority returns the Priority named by s. The empty string maps to
+// PriorityNormal, so events without an explicit class keep the default lane.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for i, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return Priority(i), nil //nolint:gosec // G115: bounded by numPriorities
+		}
+	}
+	return PriorityNormal, fmt.Errorf("unknown queue priority %q", s)
+}
+
+// ackSequencer restores a producer's publish order for ACK callbacks on a
+// prioritized queue. Batches are completed in lane order, not publish order, so
+// a producer's ACK count may only advance over the contiguous prefix of its
+// publish sequence numbers that have all completed. Completions past a gap are
+// parked in a min-heap until the gap closes; in the common in-order case the
+// heap is never touched.
+type ackSequencer struct {
+	mu      sync.Mutex
+	next    uint64 // next sequence number expected to complete; numbering starts at 1
+	pending seqHeap
+}
+
+func newAckSequencer() *ackSequencer {
+	return &ackSequencer{next: 1}
+}
+
+// complete records the completion of the given sequence numbers and returns how
+// many events became contiguous with the acknowledged prefix: acked counts the
+// ones completed with ack=true, finished counts all of them. Events completed
+// with ack=false (abandoned on shutdown via batch.Release) advance the prefix
+// without being reported as acknowledged.
+func (s *ackSequencer) complete(seqs []uint64, ack bool) (acked, finished int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, seq := range seqs {
+		if seq == s.next && len(s.pending) == 0 {
+			s.next++
+			finished++
+			if ack {
+				acked++
+			}
+			continue
+		}
+		heap.Push(&s.pending, seqEntry{seq: seq, ack: ack})
+	}
+	for len(s.pending) > 0 && s.pending[0].seq == s.next {
+		e := heap.Pop(&s.pending).(seqEntry) //nolint:errcheck // seqHeap only holds seqEntry
+		s.next++
+		finished++
+		if e.ack {
+			acked++
+		}
+	}
+	return acked, finished
+}
+
+type seqEntry struct {
+	seq uint64
+	ack bool
+}
+
+// seqHeap is a container/heap min-heap of completed sequence numbers.
+type seqHeap []seqEntry
+
+func (h seqHeap) Len() int           { return len(h) }
+func (h seqHeap) Less(i, j int) bool { return h[i].seq < h[j].seq }
+func (h seqHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *seqHeap) Push(x any)        { *h = append(*h, x.(seqEntry)) } //nolint:errcheck // seqHeap only holds seqEntry
+func (h *seqHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
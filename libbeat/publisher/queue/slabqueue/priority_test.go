@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package slabqueue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+// prioEvent is a test entry carrying its own priority class.
+type prioEvent struct {
+	id   int
+	prio Priority
+}
+
+func prioOf(e prioEvent) Priority { return e.prio }
+
+func batchIDs(b queue.Batch[prioEvent]) []int {
+	ids := make([]int, b.Count())
+	for i := range ids {
+		ids[i] = b.Entry(i).id
+	}
+	return ids
+}
+
+// TestPriorityGetOrder verifies Get hands out high priority events before
+// normal and low ones, keeping publish order within each lane.
+func TestPriorityGetOrder(t *testing.T) {
+	pool := NewPool[prioEvent](Settings{Events: 16}, nil)
+	defer pool.Shutdown()
+	q := pool.ConnectWithPriority(prioOf)
+	q.debounce = 0
+
+	p := q.Producer(queue.ProducerConfig{})
+	for i, prio := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow, PriorityHigh, PriorityNormal} {
+		_, ok := p.Publish(prioEvent{id: i, prio: prio})
+		require.True(t, ok)
+	}
+
+	b, err := q.Get(3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 1}, batchIDs(b))
+
+	b2, err := q.Get(0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 0, 3}, batchIDs(b2))
+
+	b.Done()
+	b2.Done()
+	assert.Equal(t, 16, pool.Available())
+}
+
+// TestPriorityACKPublishOrder verifies that a producer publishing mixed
+// priorities only sees ACKs once its publish-order prefix is complete, even
+// though Get reorders its events across lanes.
+func TestPriorityACKPublishOrder(t *testing.T) {
+	pool := NewPool[prioEvent](Settings{Events: 16}, nil)
+	defer pool.Shutdown()
+	q := pool.ConnectWithPriority(prioOf)
+	q.debounce = 0
+
+	var acked atomic.Int64
+	p := q.Producer(queue.ProducerConfig{ACK: func(n int) { acked.Add(int64(n)) }})
+	_, ok := p.Publish(prioEvent{id: 0, prio: PriorityLow})
+	require.True(t, ok)
+	_, ok = p.Publish(prioEvent{id: 1, prio: PriorityHigh})
+	require.True(t, ok)
+	_, ok = p.Publish(prioEvent{id: 2, prio: PriorityHigh})
+	require.True(t, ok)
+
+	high, err := q.Get(2)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, batchIDs(high))
+	high.Done()
+	assert.Equal(t, int64(0), acked.Load(), "high priority events must not be acked before the earlier low priority event")
+
+	low, err := q.Get(0)
+	require.NoError(t, err)
+	require.Equal(t, []int{0}, batchIDs(low))
+	low.Done()
+	assert.Equal(t, int64(3), acked.Load())
+
+	p.Close()
+	select {
+	case <-p.ACKWaitChan():
+	case <-time.After(time.Second):
+		t.Fatal("ACKWaitChan should close once every event is acked")
+	}
+}
+
+// TestPriorityLowHeldBackFirst verifies low priority events stop at the low
+// priority limit while higher priorities can still use the reserved slots.
+func TestPriorityLowHeldBackFirst(t *testing.T) {
+	pool := NewPool[prioEvent](Settings{Events: 10, PriorityReserve: 0.2}, nil)
+	defer pool.Shutdown()
+	q := pool.ConnectWithPriority(prioOf)
+	q.debounce = 0
+	p := q.Producer(queue.ProducerConfig{})
+
+	for i := range 8 {
+		_, ok := p.TryPublish(prioEvent{id: i, prio: PriorityLow})
+		require.True(t, ok)
+	}
+	_, ok := p.TryPublish(prioEvent{id: 8, prio: PriorityLow})
+	assert.False(t, ok, "low priority events must be held back once they fill the unreserved part of the pool")
+
+	_, ok = p.TryPublish(prioEvent{id: 9, prio: PriorityHigh})
+	assert.True(t, ok)
+	_, ok = p.TryPublish(prioEvent{id: 10, prio: PriorityNormal})
+	assert.True(t, ok)
+	assert.Equal(t, 0, pool.Available())
+
+	// A blocking low priority publish waits for a low priority slot to drain.
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		p.Publish(prioEvent{id: 11, prio: PriorityLow})
+	}()
+	select {
+	case <-published:
+		t.Fatal("low priority Publish should block while the low priority budget is used up")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	b, err := q.Get(0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{9, 10, 0, 1, 2, 3, 4, 5, 6, 7}, batchIDs(b))
+	b.Done()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("low priority Publish should unblock once low priority events are released")
+	}
+}
+
+// TestPriorityForceCloseReleasesLowBudget verifies that force-closing a queue
+// returns the low priority budget held by its queued events.
+func TestPriorityForceCloseReleasesLowBudget(t *testing.T) {
+	pool := NewPool[prioEvent](Settings{Events: 4, PriorityReserve: 0.5}, nil)
+	defer pool.Shutdown()
+	q := pool.ConnectWithPriority(prioOf)
+	p := q.Producer(queue.ProducerConfig{})
+	for i := range 2 {
+		_, ok := p.TryPublish(prioEvent{id: i, prio: PriorityLow})
+		require.True(t, ok)
+	}
+	require.NoError(t, q.Close(true))
+	assert.Equal(t, int64(0), pool.lowLive.Load())
+	assert.Equal(t, 4, pool.Available())
+}
+
+func TestParsePriority(t *testing.T) {
+	for s, want := range map[string]Priority{"": PriorityNormal, "high": PriorityHigh, "Normal": PriorityNormal, "LOW": PriorityLow} {
+		got, err := ParsePriority(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, s)
+	}
+	_, err := ParsePriority("urgent")
+	assert.Error(t, err)
+}
+
+func TestPrioritySettingsForUserConfig(t *testing.T) {
+	settings, err := SettingsForUserConfig(conf.MustNewConfigFrom(map[string]any{
+		"events":           512,
+		"priority.enabled": true,
+		"priority.reserve": 0.25,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, Settings{Events: 512, Priorities: true, PriorityReserve: 0.25}, settings)
+
+	settings, err = SettingsForUserConfig(nil)
+	require.NoError(t, err)
+	assert.False(t, settings.Priorities)
+
+	_, err = SettingsForUserConfig(conf.MustNewConfigFrom(map[string]any{"priority.reserve": 2}))
+	assert.Error(t, err)
+}
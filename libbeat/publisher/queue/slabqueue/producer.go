@@ -50,6 +50,10 @@ type producer[T any] struct {
 	finished  atomic.Uint64
 	ackWait   chan struct{}
 	ackOnce   sync.Once
+
+	// seq restores publish order for ACK callbacks when the queue is
+	// prioritized; nil otherwise. See ackSequencer.
+	seq *ackSequencer
 }
 
 // Publish adds an entry, blocking until both this queue is under its per-queue
@@ -57,11 +61,14 @@ type producer[T any] struct {
 //
 // The per-queue cap is reserved first, before acquiring a pool slot: a queue at
 // its cap must not consume pool slots it cannot keep, which would starve other
-// queues sharing the pool.
+// queues sharing the pool. Low priority entries additionally reserve a unit of
+// the pool's low priority budget, so they block before the pool is exhausted
+// and leave the remaining slots to higher priority entries.
 func (p *producer[T]) Publish(entry T) (queue.EntryID, bool) {
 	if p.closed.Load() {
 		return 0, false
 	}
+	prio := p.priorityOf(entry)
 	// Count the event before enqueuing it, else a concurrent Close could see
 	// finished >= published and close ackWait while it's still in flight. Each
 	// failure path below calls unpublish to undo this.
@@ -70,21 +77,28 @@ func (p *producer[T]) Publish(entry T) (queue.EntryID, bool) {
 		p.unpublish()
 		return 0, false
 	}
+	if prio == PriorityLow && !p.queue.pool.reserveLow(p.queue.closeCh) {
+		p.queue.releaseLive(1)
+		p.unpublish()
+		return 0, false
+	}
 	slotIdx, ok := p.queue.pool.acquire(p.home, p.queue.closeCh)
 	if !ok {
-		p.queue.releaseLive(1)
+		p.releaseReservations(prio)
 		p.unpublish()
 		return 0, false
 	}
-	return p.fill(entry, slotIdx)
+	return p.fill(entry, slotIdx, prio)
 }
 
-// TryPublish adds an entry only if this queue is under its per-queue cap and a
-// pool slot is immediately available; it never blocks.
+// TryPublish adds an entry only if this queue is under its per-queue cap, the
+// low priority budget has room (for low priority entries) and a pool slot is
+// immediately available; it never blocks.
 func (p *producer[T]) TryPublish(entry T) (queue.EntryID, bool) {
 	if p.closed.Load() {
 		return 0, false
 	}
+	prio := p.priorityOf(entry)
 	// Count the event before enqueuing it, else a concurrent Close could see
 	// finished >= published and close ackWait while it's still in flight. Each
 	// failure path below calls unpublish to undo this.
@@ -93,13 +107,40 @@ func (p *producer[T]) TryPublish(entry T) (queue.EntryID, bool) {
 		p.unpublish()
 		return 0, false
 	}
+	if prio == PriorityLow && !p.queue.pool.tryReserveLow() {
+		p.queue.releaseLive(1)
+		p.unpublish()
+		return 0, false
+	}
 	slotIdx, ok := p.queue.pool.free.tryGrab(p.home)
 	if !ok {
-		p.queue.releaseLive(1)
+		p.releaseReservations(prio)
 		p.unpublish()
 		return 0, false
 	}
-	return p.fill(entry, slotIdx)
+	return p.fill(entry, slotIdx, prio)
+}
+
+// priorityOf classifies entry with the queue's PriorityFunc, defaulting to
+// PriorityNormal for unprioritized queues and out-of-range classes.
+func (p *producer[T]) priorityOf(entry T) Priority {
+	if p.queue.priority == nil {
+		return PriorityNormal
+	}
+	prio := p.queue.priority(entry)
+	if prio >= numPriorities {
+		return PriorityNormal
+	}
+	return prio
+}
+
+// releaseReservations returns the per-queue and low priority budget units taken
+// for an entry that could not be enqueued.
+func (p *producer[T]) releaseReservations(prio Priority) {
+	p.queue.releaseLive(1)
+	if prio == PriorityLow {
+		p.queue.pool.releaseLow(1)
+	}
 }
 
 // Close marks the producer as closed. Subsequent Publish/TryPublish return
@@ -146,16 +187,16 @@ func (p *producer[T]) forceCloseAckWait() {
 	p.ackOnce.Do(func() { close(p.ackWait) })
 }
 
-// fill stores entry in the given slot and threads it onto the queue's FIFO.
-// If the queue is already closing, the slot is returned to the pool and the
-// publish fails.
-func (p *producer[T]) fill(entry T, slotIdx int) (queue.EntryID, bool) {
-	id := p.nextID.Add(1)
+// fill stores entry in the given slot and threads it onto the tail of its
+// priority lane. If the queue is already closing, the slot is returned to the
+// pool and the publish fails.
+func (p *producer[T]) fill(entry T, slotIdx int, prio Priority) (queue.EntryID, bool) {
 	pool := p.queue.pool
 	s := pool.slot(slotIdx)
 	s.event = entry
 	s.next = -1
 	s.producer = p
+	s.priority = prio
 
 	q := p.queue
 	q.mu.Lock()
@@ -168,16 +209,21 @@ func (p *producer[T]) fill(entry T, slotIdx int) (queue.EntryID, bool) {
 		s.producer = nil
 		q.mu.Unlock()
 		pool.releaseSlot(slotIdx)
-		q.releaseLive(1)
+		p.releaseReservations(prio)
 		p.unpublish()
 		return 0, false
 	}
-	if q.tail == -1 {
-		q.head = slotIdx
+	// The ID doubles as the ackSequencer's publish sequence number, so it is
+	// assigned under q.mu: within a lane, sequence order is FIFO order.
+	id := p.nextID.Add(1)
+	s.seq = id
+	l := &q.lanes[prio]
+	if l.tail == -1 {
+		l.head = slotIdx
 	} else {
-		pool.slot(q.tail).next = slotIdx
+		pool.slot(l.tail).next = slotIdx
 	}
-	q.tail = slotIdx
+	l.tail = slotIdx
 	q.count++
 	q.mu.Unlock()
 
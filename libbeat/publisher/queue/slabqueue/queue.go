@@ -50,11 +50,15 @@ type Queue[T any] struct {
 	pool *Pool[T]
 
 	mu      sync.Mutex
-	head    int // index of the head slot in this pipeline's FIFO, or -1
-	tail    int // index of the tail slot, or -1
-	count   int
+	lanes   [numPriorities]lane // per-priority FIFOs; only PriorityNormal is used when priority is nil
+	count   int                 // events queued across all lanes
 	closing bool
 
+	// priority classifies published entries into lanes. It is nil for a queue
+	// connected without priorities, in which case every event goes to the
+	// normal lane and producer ACKs take the plain count-based path.
+	priority PriorityFunc[T]
+
 	// producers is the set of open producers publishing into this queue. It
 	// exists so Close can fan out and unblock every producer's ACKWaitChan on
 	// (force-)close — force-close suppresses per-event ACK callbacks, so the
@@ -102,17 +106,25 @@ type Queue[T any] struct {
 	debounce time.Duration // coalescing window for Get
 }
 
-func newQueue[T any](pool *Pool[T]) *Queue[T] {
+// lane is one priority class's FIFO of slot indices, threaded through slot.next.
+type lane struct {
+	head int // index of the head slot, or -1
+	tail int // index of the tail slot, or -1
+}
+
+func newQueue[T any](pool *Pool[T], priority PriorityFunc[T]) *Queue[T] {
 	q := &Queue[T]{
 		pool:      pool,
-		head:      -1,
-		tail:      -1,
+		priority:  priority,
 		notify:    make(chan struct{}, 1),
 		closeCh:   make(chan struct{}),
 		doneCh:    make(chan struct{}),
 		producers: make(map[*producer[T]]struct{}),
 		debounce:  DefaultGetDebounce,
 	}
+	for i := range q.lanes {
+		q.lanes[i] = lane{head: -1, tail: -1}
+	}
 	q.limCond = sync.NewCond(&q.limMu)
 	return q
 }
@@ -225,6 +237,9 @@ func (q *Queue[T]) isClosing() bool { return chClosed(q.closeCh) }
 func (q *Queue[T]) Producer(cfg queue.ProducerConfig) queue.Producer[T] {
 	home := int((q.pool.homeCounter.Add(1) - 1) & uint64(q.pool.free.mask)) //nolint:gosec // G115: masked by the shard count, always a small non-negative index
 	p := &producer[T]{queue: q, cfg: cfg, home: home, ackWait: make(chan struct{})}
+	if q.priority != nil {
+		p.seq = newAckSequencer()
+	}
 	q.mu.Lock()
 	if q.closing {
 		// The queue is already (force-)closing. A producer created now will
@@ -299,22 +314,30 @@ func (q *Queue[T]) Get(maxEvents int) (queue.Batch[T], error) {
 	}
 }
 
-// buildBatchLocked removes the first n events from this pipeline's FIFO and
-// returns them as a recycled batch, appended to the pending-ack list so
-// producer ACK callbacks still fire in publish order. It must be called with
-// q.mu held and 0 < n <= q.count.
+// buildBatchLocked removes the first n events from this pipeline's FIFO lanes,
+// highest priority first, and returns them as a recycled batch, appended to the
+// pending-ack list so producer ACK callbacks still fire in publish order. It
+// must be called with q.mu held and 0 < n <= q.count.
 func (q *Queue[T]) buildBatchLocked(n int) *batch[T] {
 	b := q.pool.getBatch()
 	b.queue = q
 	d := q.pool.dir.Load()
-	cur := q.head
-	for range n {
-		b.indices = append(b.indices, cur)
-		cur = d.slot(cur).next
-	}
-	q.head = cur
-	if cur == -1 {
-		q.tail = -1
+	remaining := n
+	for i := range q.lanes {
+		l := &q.lanes[i]
+		cur := l.head
+		for remaining > 0 && cur != -1 {
+			b.indices = append(b.indices, cur)
+			cur = d.slot(cur).next
+			remaining--
+		}
+		l.head = cur
+		if cur == -1 {
+			l.tail = -1
+		}
+		if remaining == 0 {
+			break
+		}
 	}
 	q.count -= n
 	if q.pendingTail != nil {
@@ -367,13 +390,14 @@ func (q *Queue[T]) Close(force bool) error {
 		if q.count > 0 {
 			// Walk the FIFO and gather the slots so we can release them back to
 			// the pool below, outside the lock, to keep the critical section short.
-			cur := q.head
-			for cur != -1 {
-				releaseIndices = append(releaseIndices, cur)
-				cur = q.pool.slot(cur).next
+			for i := range q.lanes {
+				cur := q.lanes[i].head
+				for cur != -1 {
+					releaseIndices = append(releaseIndices, cur)
+					cur = q.pool.slot(cur).next
+				}
+				q.lanes[i] = lane{head: -1, tail: -1}
 			}
-			q.head = -1
-			q.tail = -1
 			q.count = 0
 		}
 		// Drop the in-flight batch list. The batches themselves are still
@@ -393,6 +417,7 @@ func (q *Queue[T]) Close(force bool) error {
 	q.closeOnce.Do(func() {
 		close(q.closeCh)
 		q.pool.free.wakeAll()
+		q.pool.wakeLowWaiters()
 		// Wake producers parked on this queue's per-queue cap so they observe
 		// closeCh and return.
 		q.wakeLimitWaiters()
@@ -407,14 +432,19 @@ func (q *Queue[T]) Close(force bool) error {
 
 	if len(releaseIndices) > 0 {
 		var zero T
+		low := 0
 		for _, i := range releaseIndices {
 			s := q.pool.slot(i)
+			if s.priority == PriorityLow {
+				low++
+			}
 			s.event = zero
 			s.producer = nil
 			s.next = -1
 		}
 		q.pool.observer.RemoveEvents(len(releaseIndices), 0)
 		q.pool.releaseSlots(releaseIndices)
+		q.pool.releaseLow(low)
 		// These FIFO events left circulation; return their per-queue budget.
 		q.releaseLive(len(releaseIndices))
 	}
@@ -34,6 +34,18 @@ func makeTestQueue(sz int) queuetest.QueueFactory {
 	}
 }
 
+func makeTestPrioritizedQueue(sz int) queuetest.QueueFactory {
+	return func(t *testing.T) queue.Queue[publisher.Event] {
+		pool := slabqueue.NewPool[publisher.Event](slabqueue.Settings{Events: sz}, nil)
+		t.Cleanup(func() { pool.Shutdown() })
+		return pool.ConnectWithPriority(func(e publisher.Event) slabqueue.Priority {
+			// Spread events across every lane so ACKs go through re-sequencing.
+			n, _ := e.Content.Fields["count"].(int)
+			return slabqueue.Priority(n % 3) //nolint:gosec // G115: bounded by the modulo
+		})
+	}
+}
+
 func TestSlabQueueConformance(t *testing.T) {
 	events := 4096
 	batchSize := 100
@@ -49,4 +61,8 @@ func TestSlabQueueConformance(t *testing.T) {
 		t.Parallel()
 		queuetest.TestSingleProducerConsumer(t, events, batchSize, makeTestQueue(bufferSize))
 	})
+	t.Run("slabqueue prioritized", func(t *testing.T) {
+		t.Parallel()
+		queuetest.TestSingleProducerConsumer(t, events, batchSize, makeTestPrioritizedQueue(bufferSize))
+	})
 }
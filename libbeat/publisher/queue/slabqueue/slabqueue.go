@@ -53,6 +53,11 @@
 // Get does not accumulate to a target batch size: it blocks until at least one
 // event is available, applies a short coalescing debounce so a trickle of events
 // does not produce one tiny batch per event, then returns everything currently queued.
+//
+// A Queue may optionally be connected with a PriorityFunc (see priority.go). Its
+// FIFO is then split into per-priority lanes: Get drains high priority events
+// first, low priority events are held back first when the pool runs short, and
+// producer ACKs are re-sequenced so they still fire in publish order.
 package slabqueue
 
 import (
@@ -76,17 +81,37 @@ type Settings struct {
 	// by the connected queues' caps (see Queue.SetTarget), and its storage grows
 	// and shrinks in chunks rather than being a single backing array.
 	Events int
+
+	// Priorities enables priority lanes for queues created by
+	// PrioritizedFactoryForSettings. It has no effect on FactoryForSettings.
+	Priorities bool
+
+	// PriorityReserve is the fraction of the pool, in [0, 1], that low priority
+	// events may not occupy. Once low priority events hold the rest of the
+	// pool their publishes block, while normal and high priority events can
+	// still use the reserved slots. Zero disables the reserve.
+	PriorityReserve float64
 }
 
 // userConfig is the YAML-facing shape of slabqueue settings. Kept separate
 // from Settings so we can attach struct tags without exposing them as part
 // of the public Settings type.
 type userConfig struct {
-	Events int `config:"events" validate:"min=32"`
+	Events   int            `config:"events" validate:"min=32"`
+	Priority priorityConfig `config:"priority"`
+}
+
+type priorityConfig struct {
+	Enabled bool    `config:"enabled"`
+	Reserve float64 `config:"reserve" validate:"min=0, max=1"`
 }
 
 var defaultUserConfig = userConfig{
 	Events: 3200, // matches memqueue's DefaultEvents
+	Priority: priorityConfig{
+		Enabled: false,
+		Reserve: 0.1,
+	},
 }
 
 // SettingsForUserConfig unpacks a ucfg config from a Beats queue
@@ -98,7 +123,11 @@ func SettingsForUserConfig(cfg *c.C) (Settings, error) {
 			return Settings{}, fmt.Errorf("couldn't unpack slabqueue config: %w", err)
 		}
 	}
-	return Settings(parsed), nil
+	return Settings{
+		Events:          parsed.Events,
+		Priorities:      parsed.Priority.Enabled,
+		PriorityReserve: parsed.Priority.Reserve,
+	}, nil
 }
 
 // FactoryForSettings returns a queue.QueueFactory[T] that gives each
@@ -112,6 +141,17 @@ func SettingsForUserConfig(cfg *c.C) (Settings, error) {
 // factory is for the standalone pipeline path where each queue is owned
 // by one pipeline.
 func FactoryForSettings[T any](settings Settings) queue.QueueFactory[T] {
+	return PrioritizedFactoryForSettings[T](settings, nil)
+}
+
+// PrioritizedFactoryForSettings is like FactoryForSettings, but when
+// settings.Priorities is set the queue classifies entries with fn into
+// priority lanes. With priorities disabled, or a nil fn, it behaves exactly
+// like FactoryForSettings.
+func PrioritizedFactoryForSettings[T any](settings Settings, fn PriorityFunc[T]) queue.QueueFactory[T] {
+	if !settings.Priorities {
+		fn = nil
+	}
 	return func(
 		_ *logp.Logger,
 		observer queue.Observer,
@@ -119,7 +159,7 @@ func FactoryForSettings[T any](settings Settings) queue.QueueFactory[T] {
 		_ queue.EncoderFactory[T],
 	) (queue.Queue[T], error) {
 		pool := NewPool[T](settings, observer)
-		return &slabBackedQueue[T]{Queue: pool.Connect(), pool: pool}, nil
+		return &slabBackedQueue[T]{Queue: pool.ConnectWithPriority(fn), pool: pool}, nil
 	}
 }
 
@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// memBatch is a batch of events sent from memory.
+type memBatch struct {
+	queue    *spillQueue
+	entries  []*entry
+	events   []publisher.Event
+	doneOnce sync.Once
+}
+
+func (b *memBatch) Count() int {
+	return len(b.entries)
+}
+
+func (b *memBatch) Entry(i int) publisher.Event {
+	return b.events[i]
+}
+
+func (b *memBatch) Done() {
+	b.doneOnce.Do(func() { b.queue.memDone(b) })
+}
+
+// Release is a no-op: the batch's events are still in flight, and Close
+// writes them to disk.
+func (b *memBatch) Release() {}
+
+func (b *memBatch) FreeEntries() {
+	b.events = nil
+}
+
+// diskBatch is a batch of events read back from disk.
+type diskBatch struct {
+	queue.Batch[publisher.Event]
+	queue    *spillQueue
+	doneOnce sync.Once
+}
+
+func (q *spillQueue) newDiskBatch(batch queue.Batch[publisher.Event]) *diskBatch {
+	q.observer.ConsumeEvents(batch.Count(), 0)
+	return &diskBatch{Batch: batch, queue: q}
+}
+
+func (b *diskBatch) Done() {
+	b.doneOnce.Do(func() {
+		b.Batch.Done()
+		b.queue.mu.Lock()
+		b.queue.progressLocked()
+		b.queue.mu.Unlock()
+		b.queue.observer.RemoveEvents(b.Count(), 0)
+	})
+}
@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+type producer struct {
+	queue      *spillQueue
+	ackHandler func(count int)
+
+	// mu serializes publishing, so sequence numbers follow publish order.
+	mu sync.Mutex
+	// closed is written with both mu and ackMu held, so either is enough
+	// to read it.
+	closed bool
+
+	// ackMu protects the fields below. Events complete out of order (an
+	// event spilled to disk can be written before an older one is sent from
+	// memory), so completions are tracked by sequence number and ACKs are
+	// only reported for the contiguous prefix.
+	ackMu     sync.Mutex
+	published uint64
+	acked     uint64
+	completed map[uint64]struct{}
+
+	ackWait chan struct{}
+	ackOnce sync.Once
+}
+
+func newProducer(q *spillQueue, ackHandler func(count int)) *producer {
+	return &producer{
+		queue:      q,
+		ackHandler: ackHandler,
+		completed:  make(map[uint64]struct{}),
+		ackWait:    make(chan struct{}),
+	}
+}
+
+func (p *producer) Publish(event publisher.Event) (queue.EntryID, bool) {
+	return p.publish(event, true)
+}
+
+func (p *producer) TryPublish(event publisher.Event) (queue.EntryID, bool) {
+	return p.publish(event, false)
+}
+
+func (p *producer) publish(event publisher.Event, block bool) (queue.EntryID, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, false
+	}
+
+	p.ackMu.Lock()
+	seq := p.published
+	p.ackMu.Unlock()
+
+	e := &entry{event: event, producer: p, seq: seq}
+	if !p.queue.publish(e, block) {
+		return 0, false
+	}
+	p.queue.observer.AddEvent(0)
+
+	p.ackMu.Lock()
+	p.published++
+	p.ackMu.Unlock()
+	return queue.EntryID(seq), true
+}
+
+func (p *producer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	p.ackMu.Lock()
+	p.closed = true
+	drained := p.ackHandler == nil || p.acked == p.published
+	p.ackMu.Unlock()
+	if drained {
+		p.queue.removeProducer(p)
+		p.closeACKWait()
+	}
+}
+
+func (p *producer) ACKWaitChan() <-chan struct{} {
+	return p.ackWait
+}
+
+func (p *producer) closeACKWait() {
+	p.ackOnce.Do(func() { close(p.ackWait) })
+}
+
+// complete records that the events with the given sequence numbers are
+// done and reports the newly contiguous ones to the ACK handler.
+func (p *producer) complete(seqs []uint64) {
+	p.ackMu.Lock()
+	defer p.ackMu.Unlock()
+	for _, seq := range seqs {
+		p.completed[seq] = struct{}{}
+	}
+	count := 0
+	for {
+		if _, ok := p.completed[p.acked]; !ok {
+			break
+		}
+		delete(p.completed, p.acked)
+		p.acked++
+		count++
+	}
+	if count == 0 {
+		return
+	}
+	// Called with ackMu held so ACKs are reported in order.
+	if p.ackHandler != nil {
+		p.ackHandler(count)
+	}
+	if p.closed && p.acked == p.published {
+		p.queue.removeProducer(p)
+		p.closeACKWait()
+	}
+}
+
+// completeEntries completes the given entries with their producers.
+func completeEntries(entries []*entry) {
+	if len(entries) == 0 {
+		return
+	}
+	seqs := make(map[*producer][]uint64)
+	for _, e := range entries {
+		seqs[e.producer] = append(seqs[e.producer], e.seq)
+	}
+	for p, s := range seqs {
+		p.complete(s)
+	}
+}
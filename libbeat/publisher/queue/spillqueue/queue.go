@@ -0,0 +1,513 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+type entryState uint8
+
+const (
+	// The entry is in memory, waiting for Get.
+	stateReady entryState = iota
+	// The entry is in memory and part of a batch handed out by Get.
+	stateInFlight
+	// The entry was handed to the disk queue; its producer ACK fires once
+	// it has been written.
+	stateSpilled
+	// The entry's batch is Done.
+	stateDone
+)
+
+// entry is an event held in memory. Its state is protected by spillQueue.mu.
+type entry struct {
+	event    publisher.Event
+	producer *producer
+	seq      uint64
+	state    entryState
+}
+
+type spillQueue struct {
+	logger   *logp.Logger
+	observer queue.Observer
+	settings Settings
+
+	// encoder, if set, is applied to in-memory events when they are handed
+	// out by Get. Spilled events are encoded by the disk queue's reader.
+	encoder queue.Encoder[publisher.Event]
+
+	disk queue.Queue[publisher.Event]
+
+	// diskMu serializes writes to diskProducer, so the order of written
+	// matches the order the disk queue acknowledges them in.
+	diskMu       sync.Mutex
+	diskProducer queue.Producer[publisher.Event]
+
+	// writesMu protects written and flushed.
+	writesMu sync.Mutex
+	// written are the entries handed to diskProducer whose write hasn't
+	// been acknowledged yet, in write order.
+	written []*entry
+	// flushed, if set, is closed once written is empty.
+	flushed chan struct{}
+
+	// mu protects the fields below and the state of every entry.
+	mu sync.Mutex
+	// ready are the in-memory entries waiting for Get, in publish order.
+	ready []*entry
+	// outstanding are the in-memory batches handed out by Get and not yet
+	// Done, in the order they were handed out.
+	outstanding []*memBatch
+	// inMemory counts the entries in ready and outstanding that are still
+	// held in memory, and is bounded by settings.Events.
+	inMemory int
+	// spilling is set while the output is stalled: new events then go
+	// straight to disk.
+	spilling bool
+	// lastProgress is the last time a batch was Done, or the time events
+	// started waiting in an idle queue.
+	lastProgress time.Time
+	// preferDisk alternates Get between memory and disk when both have
+	// events, so neither side starves.
+	preferDisk bool
+	closing    bool
+	producers  map[*producer]struct{}
+
+	// memReady is signaled when an event is added to ready.
+	memReady chan struct{}
+
+	// getSize is the batch size of the last Get call, used to read from
+	// the disk queue.
+	getSize         atomic.Int64
+	diskReaderOnce  sync.Once
+	diskBatches     chan queue.Batch[publisher.Event]
+	closeOnce       sync.Once
+	forceOnce       sync.Once
+	closeChan       chan struct{}
+	forceChan       chan struct{}
+	done            chan struct{}
+	monitorInterval time.Duration
+}
+
+// diskObserver forwards the disk queue's startup state (the size limit
+// and the events restored from a previous run) to the spill queue's
+// observer. Event traffic is reported by the spill queue itself, so
+// spilled events aren't counted twice.
+type diskObserver struct {
+	queue.Observer
+}
+
+func (diskObserver) AddEvent(int)           {}
+func (diskObserver) ConsumeEvents(int, int) {}
+func (diskObserver) RemoveEvents(int, int)  {}
+
+// NewQueue returns a spill queue with the given settings. Events spilled
+// by a previous run are restored from the disk queue.
+func NewQueue(
+	logger *logp.Logger,
+	observer queue.Observer,
+	settings Settings,
+	encoderFactory queue.EncoderFactory[publisher.Event],
+	paths *paths.Path,
+) (*spillQueue, error) {
+	if settings.Events <= 0 {
+		return nil, errors.New("spill queue needs a positive number of events")
+	}
+	if settings.SpillTimeout <= 0 {
+		return nil, errors.New("spill queue needs a positive spill timeout")
+	}
+	logger = logger.Named("spillqueue")
+	if observer == nil {
+		observer = queue.NewQueueObserver(nil)
+	}
+	observer.MaxEvents(settings.Events)
+
+	disk, err := diskqueue.NewQueue(logger, diskObserver{observer}, settings.Disk, encoderFactory, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &spillQueue{
+		logger:          logger,
+		observer:        observer,
+		settings:        settings,
+		disk:            disk,
+		producers:       make(map[*producer]struct{}),
+		memReady:        make(chan struct{}, 1),
+		diskBatches:     make(chan queue.Batch[publisher.Event]),
+		closeChan:       make(chan struct{}),
+		forceChan:       make(chan struct{}),
+		done:            make(chan struct{}),
+		monitorInterval: settings.SpillTimeout / 4,
+	}
+	if encoderFactory != nil {
+		q.encoder = encoderFactory()
+	}
+	q.diskProducer = disk.Producer(queue.ProducerConfig{ACK: q.diskWritten})
+
+	go q.runMonitor()
+	return q, nil
+}
+
+func (q *spillQueue) QueueType() string {
+	return QueueType
+}
+
+func (q *spillQueue) BufferConfig() queue.BufferConfig {
+	// Events beyond the memory budget go to disk, whose limit is in bytes.
+	return queue.BufferConfig{MaxEvents: 0}
+}
+
+func (q *spillQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *spillQueue) Producer(cfg queue.ProducerConfig) queue.Producer[publisher.Event] {
+	p := newProducer(q, cfg.ACK)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		p.closeACKWait()
+	} else {
+		q.producers[p] = struct{}{}
+	}
+	return p
+}
+
+// Get returns a batch of in-memory events, or of events read back from
+// disk, blocking until either is available.
+func (q *spillQueue) Get(eventCount int) (queue.Batch[publisher.Event], error) {
+	q.getSize.Store(int64(eventCount))
+	q.diskReaderOnce.Do(func() { go q.runDiskReader() })
+
+	for {
+		q.mu.Lock()
+		if q.closing {
+			q.mu.Unlock()
+			return nil, io.EOF
+		}
+		if len(q.ready) > 0 {
+			if q.preferDisk {
+				select {
+				case batch := <-q.diskBatches:
+					q.preferDisk = false
+					q.mu.Unlock()
+					return q.newDiskBatch(batch), nil
+				default:
+				}
+			}
+			batch := q.takeReadyLocked(eventCount)
+			q.preferDisk = true
+			q.mu.Unlock()
+			q.observer.ConsumeEvents(batch.Count(), 0)
+			return batch, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.memReady:
+		case batch := <-q.diskBatches:
+			q.mu.Lock()
+			q.preferDisk = false
+			q.mu.Unlock()
+			return q.newDiskBatch(batch), nil
+		case <-q.closeChan:
+			return nil, io.EOF
+		}
+	}
+}
+
+// Close flushes the events held in memory to disk and closes the disk
+// queue. With force set, events in memory are dropped and pending disk
+// writes are aborted.
+func (q *spillQueue) Close(force bool) error {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.closing = true
+		var flush []*entry
+		if !force {
+			// Batches handed out first hold the oldest events.
+			for _, batch := range q.outstanding {
+				for _, e := range batch.entries {
+					if e.state == stateInFlight {
+						e.state = stateSpilled
+						flush = append(flush, e)
+					}
+				}
+			}
+			for _, e := range q.ready {
+				e.state = stateSpilled
+				flush = append(flush, e)
+			}
+		}
+		q.ready = nil
+		q.inMemory = 0
+		q.mu.Unlock()
+		close(q.closeChan)
+		go q.shutdown(flush)
+	})
+	if force {
+		q.forceOnce.Do(func() {
+			close(q.forceChan)
+			// Unblocks writes waiting for room in the disk queue.
+			_ = q.disk.Close(true)
+		})
+	}
+	return nil
+}
+
+func (q *spillQueue) shutdown(flush []*entry) {
+	if len(flush) > 0 {
+		q.logger.Infof("Writing %d in-memory events to disk before shutdown", len(flush))
+		if !q.writeToDisk(flush, true) {
+			q.logger.Errorf("Couldn't write all in-memory events to disk before shutdown")
+		}
+	}
+
+	// The disk queue drops writes that are still pending when it closes,
+	// so wait until everything handed to it has been written.
+	q.writesMu.Lock()
+	flushed := make(chan struct{})
+	if len(q.written) == 0 {
+		close(flushed)
+	} else {
+		q.flushed = flushed
+	}
+	q.writesMu.Unlock()
+	select {
+	case <-flushed:
+	case <-q.forceChan:
+	}
+
+	_ = q.disk.Close(false)
+	<-q.disk.Done()
+
+	// Nothing is acknowledged after this point.
+	q.mu.Lock()
+	producers := q.producers
+	q.producers = nil
+	q.mu.Unlock()
+	for p := range producers {
+		p.closeACKWait()
+	}
+	close(q.done)
+}
+
+// takeReadyLocked removes up to count entries from ready and returns them
+// as a batch. Must be called with q.mu held.
+func (q *spillQueue) takeReadyLocked(count int) *memBatch {
+	n := len(q.ready)
+	if count > 0 && count < n {
+		n = count
+	}
+	batch := &memBatch{
+		queue:   q,
+		entries: append([]*entry(nil), q.ready[:n]...),
+		events:  make([]publisher.Event, n),
+	}
+	q.ready = append(q.ready[:0], q.ready[n:]...)
+	for i, e := range batch.entries {
+		e.state = stateInFlight
+		event := e.event
+		if q.encoder != nil {
+			event, _ = q.encoder.EncodeEntry(event)
+		}
+		batch.events[i] = event
+	}
+	q.outstanding = append(q.outstanding, batch)
+	return batch
+}
+
+// publish adds the entry to memory if there is room, otherwise writes it
+// to disk.
+func (q *spillQueue) publish(e *entry, block bool) bool {
+	q.mu.Lock()
+	if q.closing {
+		q.mu.Unlock()
+		return false
+	}
+	if !q.spilling && q.inMemory < q.settings.Events {
+		if q.inMemory == 0 {
+			// The stall timer starts when events start waiting.
+			q.lastProgress = time.Now()
+		}
+		q.ready = append(q.ready, e)
+		q.inMemory++
+		q.mu.Unlock()
+		select {
+		case q.memReady <- struct{}{}:
+		default:
+		}
+		return true
+	}
+	e.state = stateSpilled
+	q.mu.Unlock()
+	return q.writeToDisk([]*entry{e}, block)
+}
+
+// writeToDisk hands the entries to the disk queue, in order. It returns
+// false if not all of them could be written, either because the queue
+// is closed or, when block is false, because the disk queue is full.
+// Non-blocking writes still wait for concurrent writes to be handed over.
+func (q *spillQueue) writeToDisk(entries []*entry, block bool) bool {
+	q.diskMu.Lock()
+	defer q.diskMu.Unlock()
+	for i, e := range entries {
+		q.writesMu.Lock()
+		q.written = append(q.written, e)
+		q.writesMu.Unlock()
+
+		var ok bool
+		if block {
+			_, ok = q.diskProducer.Publish(e.event)
+		} else {
+			_, ok = q.diskProducer.TryPublish(e.event)
+		}
+		if !ok {
+			// Not written, so it can't have been acknowledged: it is still
+			// the last entry.
+			q.writesMu.Lock()
+			q.written = q.written[:len(q.written)-1]
+			q.writesMu.Unlock()
+			if i > 0 || len(entries) > 1 {
+				q.logger.Errorf("Lost %d events that couldn't be written to disk", len(entries)-i)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// diskWritten is the ACK callback of diskProducer.
+func (q *spillQueue) diskWritten(count int) {
+	q.writesMu.Lock()
+	entries := q.written[:count:count]
+	q.written = q.written[count:]
+	if len(q.written) == 0 {
+		q.written = nil
+		if q.flushed != nil {
+			close(q.flushed)
+			q.flushed = nil
+		}
+	}
+	q.writesMu.Unlock()
+	completeEntries(entries)
+}
+
+// memDone handles a Done in-memory batch.
+func (q *spillQueue) memDone(batch *memBatch) {
+	q.mu.Lock()
+	for i, b := range q.outstanding {
+		if b == batch {
+			q.outstanding = append(q.outstanding[:i], q.outstanding[i+1:]...)
+			break
+		}
+	}
+	acked := make([]*entry, 0, len(batch.entries))
+	for _, e := range batch.entries {
+		// Entries spilled on Close are acknowledged when written instead.
+		if e.state == stateInFlight {
+			e.state = stateDone
+			acked = append(acked, e)
+		}
+	}
+	q.inMemory -= len(acked)
+	q.progressLocked()
+	q.mu.Unlock()
+
+	q.observer.RemoveEvents(len(batch.entries), 0)
+	completeEntries(acked)
+}
+
+// progressLocked records that the output acknowledged a batch. Must be
+// called with q.mu held.
+func (q *spillQueue) progressLocked() {
+	q.lastProgress = time.Now()
+	if q.spilling {
+		q.logger.Info("Output is making progress again, keeping new events in memory")
+		q.spilling = false
+	}
+}
+
+// runMonitor moves the events waiting in memory to disk if the output
+// stops making progress.
+func (q *spillQueue) runMonitor() {
+	ticker := time.NewTicker(q.monitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.closeChan:
+			return
+		case <-ticker.C:
+			q.maybeSpill()
+		}
+	}
+}
+
+func (q *spillQueue) maybeSpill() {
+	q.mu.Lock()
+	if q.closing || len(q.ready) == 0 || time.Since(q.lastProgress) < q.settings.SpillTimeout {
+		q.mu.Unlock()
+		return
+	}
+	entries := q.ready
+	q.ready = nil
+	for _, e := range entries {
+		e.state = stateSpilled
+	}
+	q.inMemory -= len(entries)
+	q.spilling = true
+	q.mu.Unlock()
+
+	q.logger.Warnf("Output made no progress for %v, moving %d events to disk",
+		q.settings.SpillTimeout, len(entries))
+	q.writeToDisk(entries, true)
+}
+
+// runDiskReader reads batches from the disk queue for Get.
+func (q *spillQueue) runDiskReader() {
+	for {
+		batch, err := q.disk.Get(int(q.getSize.Load()))
+		if err != nil {
+			return
+		}
+		select {
+		case q.diskBatches <- batch:
+		case <-q.closeChan:
+			// The batch is never acknowledged, so its events stay on disk.
+			return
+		}
+	}
+}
+
+func (q *spillQueue) removeProducer(p *producer) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.producers, p)
+}
@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package spillqueue implements a hybrid queue that keeps events in memory
+// and only writes them to a disk queue when it has to:
+//
+//   - when the in-memory budget (Settings.Events) is exhausted, new events
+//     are written to disk instead of blocking the producer,
+//   - when the output makes no progress for Settings.SpillTimeout, the
+//     events waiting in memory are moved to disk, and new events go to disk
+//     until the output acknowledges a batch again,
+//   - on a graceful Close, every event still in memory (including the ones
+//     handed to the output but not acknowledged yet) is written to disk, so
+//     it is delivered by the next run instead of being lost.
+//
+// With a healthy output, events never touch the disk. Events that were
+// spilled are read back from the disk queue and interleaved with the
+// in-memory ones, so there is no strict ordering between the two.
+//
+// Producer ACKs still fire in publish order: an event kept in memory is
+// acknowledged when its batch is Done, an event spilled to disk when it has
+// been written, and completions are re-sequenced per producer.
+package spillqueue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	c "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// QueueType is the user-facing queue type selector (queue.spill).
+const QueueType = "spill"
+
+// Settings configures a spill queue.
+type Settings struct {
+	// Events is the maximum number of events kept in memory, including
+	// the ones handed to the output and not acknowledged yet.
+	Events int
+
+	// SpillTimeout is how long the output may go without acknowledging a
+	// batch while events are waiting in memory before they are moved to
+	// disk.
+	SpillTimeout time.Duration
+
+	// Disk configures the disk queue that events are spilled to.
+	Disk diskqueue.Settings
+}
+
+// userConfig is the YAML-facing shape of the spill queue settings.
+type userConfig struct {
+	Events       int           `config:"events" validate:"min=32"`
+	SpillTimeout time.Duration `config:"spill_timeout" validate:"positive"`
+	Disk         *c.C          `config:"disk" validate:"required"`
+}
+
+var defaultUserConfig = userConfig{
+	Events:       3200, // matches memqueue's DefaultEvents
+	SpillTimeout: 30 * time.Second,
+}
+
+// SettingsForUserConfig unpacks a ucfg config from a Beats queue
+// configuration and returns the equivalent spillqueue.Settings.
+func SettingsForUserConfig(cfg *c.C) (Settings, error) {
+	parsed := defaultUserConfig
+	if cfg != nil {
+		if err := cfg.Unpack(&parsed); err != nil {
+			return Settings{}, fmt.Errorf("couldn't unpack spill queue config: %w", err)
+		}
+	}
+	if parsed.Disk == nil {
+		return Settings{}, fmt.Errorf("spill queue requires a disk section")
+	}
+	disk, err := diskqueue.SettingsForUserConfig(parsed.Disk)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid spill queue disk settings: %w", err)
+	}
+	return Settings{
+		Events:       parsed.Events,
+		SpillTimeout: parsed.SpillTimeout,
+		Disk:         disk,
+	}, nil
+}
+
+// FactoryForSettings returns a QueueFactory that creates spill queues
+// with the given settings, storing spilled events under the given paths.
+func FactoryForSettings(settings Settings, paths *paths.Path) queue.QueueFactory[publisher.Event] {
+	return func(
+		logger *logp.Logger,
+		observer queue.Observer,
+		_ int,
+		encoderFactory queue.EncoderFactory[publisher.Event],
+	) (queue.Queue[publisher.Event], error) {
+		return NewQueue(logger, observer, settings, encoderFactory, paths)
+	}
+}
@@ -0,0 +1,266 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/queuetest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func testSettings(t *testing.T, dir string, events int) Settings {
+	disk := diskqueue.DefaultSettings()
+	disk.Path = dir
+	return Settings{
+		Events:       events,
+		SpillTimeout: time.Minute,
+		Disk:         disk,
+	}
+}
+
+func newTestQueue(t *testing.T, settings Settings) *spillQueue {
+	q, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, settings, nil, &paths.Path{})
+	require.NoError(t, err)
+	return q
+}
+
+func TestProduceConsumer(t *testing.T) {
+	events := 1024
+	batchSize := 50
+
+	// A small memory budget so most events go through the disk queue.
+	factory := func(t *testing.T) queue.Queue[publisher.Event] {
+		return newTestQueue(t, testSettings(t, t.TempDir(), 64))
+	}
+	t.Run("single", func(t *testing.T) {
+		t.Parallel()
+		queuetest.TestSingleProducerConsumer(t, events, batchSize, factory)
+	})
+	t.Run("multi", func(t *testing.T) {
+		t.Parallel()
+		queuetest.TestMultiProducerConsumer(t, events, batchSize, factory)
+	})
+}
+
+// ackCounter is a producer ACK callback recording the acknowledged total.
+type ackCounter struct {
+	mu    sync.Mutex
+	total int
+}
+
+func (c *ackCounter) ack(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += n
+}
+
+func (c *ackCounter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+func publishEvents(t *testing.T, p queue.Producer[publisher.Event], first, count int) {
+	for i := first; i < first+count; i++ {
+		_, ok := p.Publish(queuetest.MakeEvent(mapstr.M{"count": i}))
+		require.True(t, ok, "publish of event %d should succeed", i)
+	}
+}
+
+// getEvents reads batches until count events are received, acknowledging
+// each batch, and returns the received counts.
+func getEvents(t *testing.T, q queue.Queue[publisher.Event], count int) []int {
+	var got []int
+	for len(got) < count {
+		batch, err := q.Get(count - len(got))
+		require.NoError(t, err)
+		for i := range batch.Count() {
+			// Events read back from disk decode numbers as other types.
+			n, err := batch.Entry(i).Content.Fields.GetValue("count")
+			require.NoError(t, err)
+			got = append(got, int(reflect.ValueOf(n).Convert(reflect.TypeOf(0)).Int()))
+		}
+		batch.Done()
+	}
+	return got
+}
+
+func closeAndWait(t *testing.T, q queue.Queue[publisher.Event]) {
+	require.NoError(t, q.Close(false))
+	select {
+	case <-q.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("queue didn't shut down")
+	}
+}
+
+func TestSpillWhenMemoryIsFull(t *testing.T) {
+	q := newTestQueue(t, testSettings(t, t.TempDir(), 4))
+	defer closeAndWait(t, q)
+
+	acks := &ackCounter{}
+	p := q.Producer(queue.ProducerConfig{ACK: acks.ack})
+	publishEvents(t, p, 0, 10)
+
+	// The spilled events are written, but can't be acknowledged before the
+	// older events still in memory.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, acks.get())
+
+	batch, err := q.Get(10)
+	require.NoError(t, err)
+	require.Equal(t, 4, batch.Count(), "the first batch should hold the in-memory events")
+	batch.Done()
+	assert.Eventually(t, func() bool { return acks.get() == 10 }, time.Second, 10*time.Millisecond)
+
+	assert.ElementsMatch(t, []int{4, 5, 6, 7, 8, 9}, getEvents(t, q, 6))
+}
+
+func TestTryPublishWaitsForConcurrentDiskWrites(t *testing.T) {
+	q := newTestQueue(t, testSettings(t, t.TempDir(), 1))
+	defer closeAndWait(t, q)
+
+	p := q.Producer(queue.ProducerConfig{})
+	_, ok := p.TryPublish(queuetest.MakeEvent(mapstr.M{"count": 0}))
+	require.True(t, ok)
+
+	// Memory is full, so the next event goes to disk while another write
+	// is being handed over. It must not be dropped for that.
+	q.diskMu.Lock()
+	result := make(chan bool)
+	go func() {
+		_, ok := p.TryPublish(queuetest.MakeEvent(mapstr.M{"count": 1}))
+		result <- ok
+	}()
+	time.Sleep(20 * time.Millisecond)
+	q.diskMu.Unlock()
+	assert.True(t, <-result, "TryPublish should only fail when the disk queue is full")
+
+	assert.ElementsMatch(t, []int{0, 1}, getEvents(t, q, 2))
+}
+
+func TestSpillWhenOutputStalls(t *testing.T) {
+	settings := testSettings(t, t.TempDir(), 100)
+	settings.SpillTimeout = 50 * time.Millisecond
+	q := newTestQueue(t, settings)
+	defer closeAndWait(t, q)
+
+	acks := &ackCounter{}
+	p := q.Producer(queue.ProducerConfig{ACK: acks.ack})
+	publishEvents(t, p, 0, 5)
+
+	// Nothing consumes the events, so they are moved to disk, which
+	// acknowledges them.
+	require.Eventually(t, func() bool { return acks.get() == 5 }, 5*time.Second, 10*time.Millisecond)
+
+	// While stalled, new events go straight to disk.
+	publishEvents(t, p, 5, 3)
+	require.Eventually(t, func() bool { return acks.get() == 8 }, 5*time.Second, 10*time.Millisecond)
+	q.mu.Lock()
+	assert.True(t, q.spilling)
+	assert.Zero(t, q.inMemory)
+	q.mu.Unlock()
+
+	assert.ElementsMatch(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, getEvents(t, q, 8))
+
+	// Acknowledged batches end the stall.
+	q.mu.Lock()
+	assert.False(t, q.spilling)
+	q.mu.Unlock()
+}
+
+func TestFlushToDiskOnClose(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, testSettings(t, dir, 100))
+
+	acks := &ackCounter{}
+	p := q.Producer(queue.ProducerConfig{ACK: acks.ack})
+	publishEvents(t, p, 0, 6)
+
+	// Two events are handed to the output but never acknowledged.
+	batch, err := q.Get(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.Count())
+
+	closeAndWait(t, q)
+	assert.Equal(t, 6, acks.get(), "events written on shutdown should be acknowledged")
+	select {
+	case <-p.ACKWaitChan():
+	default:
+		t.Error("ACKWaitChan should be closed after shutdown")
+	}
+
+	q = newTestQueue(t, testSettings(t, dir, 100))
+	defer closeAndWait(t, q)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, getEvents(t, q, 6))
+}
+
+func TestForceCloseDropsMemory(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, testSettings(t, dir, 100))
+
+	p := q.Producer(queue.ProducerConfig{ACK: func(int) {}})
+	publishEvents(t, p, 0, 3)
+
+	require.NoError(t, q.Close(true))
+	select {
+	case <-q.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("queue didn't shut down")
+	}
+	select {
+	case <-p.ACKWaitChan():
+	default:
+		t.Error("ACKWaitChan should be closed after a forced shutdown")
+	}
+	_, ok := p.Publish(queuetest.MakeEvent(mapstr.M{"count": 3}))
+	assert.False(t, ok, "publish after Close should fail")
+}
+
+func TestSettingsForUserConfig(t *testing.T) {
+	cfg := config.MustNewConfigFrom(mapstr.M{
+		"events":        512,
+		"spill_timeout": "5s",
+		"disk": mapstr.M{
+			"path":     "/tmp/spill",
+			"max_size": "1GB",
+		},
+	})
+	settings, err := SettingsForUserConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 512, settings.Events)
+	assert.Equal(t, 5*time.Second, settings.SpillTimeout)
+	assert.Equal(t, "/tmp/spill", settings.Disk.Path)
+	assert.Equal(t, uint64(1e9), settings.Disk.MaxBufferSize)
+
+	_, err = SettingsForUserConfig(config.MustNewConfigFrom(mapstr.M{"events": 512}))
+	assert.Error(t, err, "the disk section is required")
+}
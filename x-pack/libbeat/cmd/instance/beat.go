@@ -282,6 +282,8 @@ func NewBeatForReceiver(settings instance.Settings, receiverConfig map[string]an
 		InputQueueSize: b.InputQueueSize,
 		WaitCloseMode:  pipeline.WaitOnPipelineCloseThenForce,
 		WaitClose:      receiverPublisherCloseTimeout,
+		MaxEventAge:    b.Config.Pipeline.MaxEventAge,
+		Routes:         b.Config.Pipeline.Outputs,
 	}
 	publisher, err := pipeline.NewForReceiver(b.Info, monitors, b.Config.Pipeline.Queue, pipelineSettings)
 	if err != nil {